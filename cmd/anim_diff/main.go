@@ -0,0 +1,250 @@
+// Command anim_diff checks whether two animated WebP or GIF files are
+// equivalent, like libwebp's anim_diff: same canvas, loop count and timing,
+// and frames within the given pixel tolerances.
+//
+// Usage:
+//
+//	anim_diff <image1> <image2> [options]
+//
+// Options:
+//
+//	-dump_frames <folder>  dump decoded frames in PNG format
+//	-min_psnr <float>      minimum per-frame PSNR, replacing the -max_diff check
+//	-raw_comparison        compare RGB values without premultiplying them
+//	-max_diff <int>        maximum allowed difference per channel between
+//	                       corresponding pixels in subsequent frames
+//
+// The exit status is 1 when the animations differ.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/daanv2/go-webp/cmd/internal/animutil"
+)
+
+// PSNR reported for identical frames.
+const kMaxPSNR = 99.
+
+type options struct {
+	files       [2]string
+	dump_folder string
+	min_psnr    float64
+	max_diff    int
+	premultiply bool
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error! %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func printHelp() {
+	fmt.Printf("Usage: anim_diff <image1> <image2> [options]\n")
+	fmt.Printf("\nOptions:\n")
+	fmt.Printf("  -dump_frames <folder> dump decoded frames in PNG format\n")
+	fmt.Printf("  -min_psnr <float> ... minimum per-frame PSNR\n")
+	fmt.Printf("  -raw_comparison ..... if this flag is not used, RGB is\n")
+	fmt.Printf("                        premultiplied before comparison\n")
+	fmt.Printf("  -max_diff <int> ..... maximum allowed difference per channel\n")
+	fmt.Printf("                        between corresponding pixels in subsequent\n")
+	fmt.Printf("                        frames\n")
+	fmt.Printf("  -h .................. this help\n")
+}
+
+// Premultiplied value of 'v' by 'alpha', or 'v' itself for raw comparisons.
+func channel(v, alpha uint8, premultiply bool) int {
+	if !premultiply {
+		return int(v)
+	}
+	return (int(v)*int(alpha) + 127) / 255
+}
+
+// Returns the maximum difference per channel and the PSNR between 'a' and
+// 'b', which must have the same bounds.
+func compareFrames(a, b *image.NRGBA, premultiply bool) (max_diff int, psnr float64) {
+	var sse float64
+	width, height := a.Rect.Dx(), a.Rect.Dy()
+	for y := 0; y < height; y++ {
+		row_a := a.Pix[y*a.Stride : y*a.Stride+4*width]
+		row_b := b.Pix[y*b.Stride : y*b.Stride+4*width]
+		for x := 0; x < 4*width; x += 4 {
+			alpha_a, alpha_b := row_a[x+3], row_b[x+3]
+			for c := 0; c < 4; c++ {
+				var d int
+				if c == 3 {
+					d = int(alpha_a) - int(alpha_b)
+				} else {
+					d = channel(row_a[x+c], alpha_a, premultiply) - channel(row_b[x+c], alpha_b, premultiply)
+				}
+				if d < 0 {
+					d = -d
+				}
+				max_diff = max(max_diff, d)
+				sse += float64(d * d)
+			}
+		}
+	}
+	if sse == 0 {
+		return max_diff, kMaxPSNR
+	}
+	mse := sse / float64(4*width*height)
+	return max_diff, min(10*math.Log10(255*255/mse), kMaxPSNR)
+}
+
+func dumpFrames(img *animutil.AnimatedImage, file, folder string) error {
+	base := filepath.Base(file)
+	for i, frame := range img.Frames {
+		out_file := filepath.Join(folder, fmt.Sprintf("dump_%s_frame_%d.png", base, i))
+		f, err := os.Create(out_file)
+		if err != nil {
+			return err
+		}
+		err = png.Encode(f, frame.RGBA)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return fmt.Errorf("error writing %s: %w", out_file, err)
+		}
+	}
+	return nil
+}
+
+// Prints every difference between the two animations and reports whether
+// they are equivalent.
+func compareAnimatedImages(img1, img2 *animutil.AnimatedImage, opts *options) bool {
+	ok := true
+	if img1.CanvasWidth != img2.CanvasWidth || img1.CanvasHeight != img2.CanvasHeight {
+		fmt.Printf("Canvas size mismatch: %d x %d vs %d x %d\n",
+			img1.CanvasWidth, img1.CanvasHeight, img2.CanvasWidth, img2.CanvasHeight)
+		return false
+	}
+	if img1.LoopCount != img2.LoopCount {
+		fmt.Printf("Loop count mismatch: %d vs %d\n", img1.LoopCount, img2.LoopCount)
+		ok = false
+	}
+	// GIF background colors are not reliable, browsers ignoring them.
+	if img1.IsWebP && img2.IsWebP && img1.BgColor != img2.BgColor {
+		fmt.Printf("Background color mismatch: 0x%.8X vs 0x%.8X\n", img1.BgColor, img2.BgColor)
+		ok = false
+	}
+	if len(img1.Frames) != len(img2.Frames) {
+		fmt.Printf("Frame count mismatch: %d vs %d\n", len(img1.Frames), len(img2.Frames))
+		ok = false
+	}
+
+	num_frames := min(len(img1.Frames), len(img2.Frames))
+	for i := 0; i < num_frames; i++ {
+		f1, f2 := &img1.Frames[i], &img2.Frames[i]
+		max_diff, psnr := compareFrames(f1.RGBA, f2.RGBA, opts.premultiply)
+		// As in libwebp's anim_diff, -min_psnr replaces the -max_diff check.
+		var frame_ok bool
+		if opts.min_psnr > 0 {
+			frame_ok = psnr >= opts.min_psnr
+		} else {
+			frame_ok = max_diff <= opts.max_diff
+		}
+		fmt.Printf("Frame #%d: max diff %3d, PSNR %5.2f dB", i, max_diff, psnr)
+		if f1.TimestampMs != f2.TimestampMs || f1.DurationMs != f2.DurationMs {
+			fmt.Printf(", timing mismatch: %d ms (+%d) vs %d ms (+%d)",
+				f1.TimestampMs, f1.DurationMs, f2.TimestampMs, f2.DurationMs)
+			frame_ok = false
+		}
+		if !frame_ok {
+			fmt.Printf("  <- FAIL")
+			ok = false
+		}
+		fmt.Printf("\n")
+	}
+	return ok
+}
+
+func parseArgs(args []string) (*options, error) {
+	opts := &options{premultiply: true}
+	num_files := 0
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		value := func() (string, error) {
+			if i+1 >= len(args) {
+				return "", fmt.Errorf("missing value for %s", arg)
+			}
+			i++
+			return args[i], nil
+		}
+		var v string
+		var err error
+		switch arg {
+		case "-dump_frames":
+			opts.dump_folder, err = value()
+		case "-min_psnr":
+			if v, err = value(); err == nil {
+				if opts.min_psnr, err = strconv.ParseFloat(v, 64); err != nil {
+					err = fmt.Errorf("invalid value %q for -min_psnr", v)
+				}
+			}
+		case "-max_diff":
+			if v, err = value(); err == nil {
+				if opts.max_diff, err = strconv.Atoi(v); err != nil || opts.max_diff < 0 || opts.max_diff > 255 {
+					err = fmt.Errorf("invalid value %q for -max_diff", v)
+				}
+			}
+		case "-raw_comparison":
+			opts.premultiply = false
+		case "-h", "-help":
+			printHelp()
+			return nil, nil
+		default:
+			if len(arg) > 1 && arg[0] == '-' {
+				return nil, fmt.Errorf("unknown option '%s'", arg)
+			}
+			if num_files == 2 {
+				return nil, errors.New("only two input files expected")
+			}
+			opts.files[num_files] = arg
+			num_files++
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if num_files != 2 {
+		printHelp()
+		return nil, errors.New("two input files expected")
+	}
+	return opts, nil
+}
+
+func run(args []string) error {
+	opts, err := parseArgs(args)
+	if err != nil || opts == nil {
+		return err
+	}
+
+	var images [2]*animutil.AnimatedImage
+	for i, file := range opts.files {
+		if images[i], err = animutil.ReadAnimatedImage(file); err != nil {
+			return fmt.Errorf("error decoding file %s: %w", file, err)
+		}
+		if opts.dump_folder != "" {
+			if err := dumpFrames(images[i], file, opts.dump_folder); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !compareAnimatedImages(images[0], images[1], opts) {
+		return fmt.Errorf("animations %s and %s differ", opts.files[0], opts.files[1])
+	}
+	fmt.Printf("%s and %s are equivalent.\n", opts.files[0], opts.files[1])
+	return nil
+}
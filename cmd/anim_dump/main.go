@@ -0,0 +1,111 @@
+// Command anim_dump decodes animated WebP or GIF files and dumps every fully
+// reconstructed frame to PNG, like libwebp's anim_dump.
+//
+// Usage:
+//
+//	anim_dump [options] files...
+//
+// Options:
+//
+//	-folder <string>  output directory (default: current directory)
+//	-prefix <string>  prefix of the dumped frames (default: dump_)
+//	-quiet            don't list the frames
+//
+// Frames are written as <prefix><NNNN>.png, along with their timestamps.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image/png"
+	"os"
+	"path/filepath"
+
+	"github.com/daanv2/go-webp/cmd/internal/animutil"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error! %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func printHelp() {
+	fmt.Printf("Usage: anim_dump [options] files...\n")
+	fmt.Printf("\noptions:\n")
+	fmt.Printf("  -folder <string> .... dump folder (default: '.')\n")
+	fmt.Printf("  -prefix <string> .... prefix for dumped frames (default: 'dump_')\n")
+	fmt.Printf("  -quiet .............. don't list the dumped frames\n")
+	fmt.Printf("  -h .................. this help\n")
+}
+
+func dumpFrames(file, folder, prefix string, quiet bool) error {
+	img, err := animutil.ReadAnimatedImage(file)
+	if err != nil {
+		return fmt.Errorf("error decoding file %s: %w", file, err)
+	}
+	if !quiet {
+		fmt.Printf("%s: %d x %d canvas, %d frame(s), loop count %d\n",
+			file, img.CanvasWidth, img.CanvasHeight, len(img.Frames), img.LoopCount)
+	}
+	for i, frame := range img.Frames {
+		out_file := filepath.Join(folder, fmt.Sprintf("%s%.4d.png", prefix, i))
+		f, err := os.Create(out_file)
+		if err != nil {
+			return err
+		}
+		err = png.Encode(f, frame.RGBA)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return fmt.Errorf("error writing %s: %w", out_file, err)
+		}
+		if !quiet {
+			fmt.Printf("  frame #%.4d: %6d ms -> %6d ms (duration %4d ms): %s\n", i,
+				frame.TimestampMs-frame.DurationMs, frame.TimestampMs, frame.DurationMs, out_file)
+		}
+	}
+	return nil
+}
+
+func run(args []string) error {
+	folder, prefix := ".", "dump_"
+	quiet := false
+	var files []string
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case "-folder", "-prefix":
+			if i+1 >= len(args) {
+				return fmt.Errorf("missing value for %s", arg)
+			}
+			i++
+			if arg == "-folder" {
+				folder = args[i]
+			} else {
+				prefix = args[i]
+			}
+		case "-quiet":
+			quiet = true
+		case "-h", "-help":
+			printHelp()
+			return nil
+		default:
+			if len(arg) > 1 && arg[0] == '-' {
+				return fmt.Errorf("unknown option '%s'", arg)
+			}
+			files = append(files, arg)
+		}
+	}
+	if len(files) == 0 {
+		printHelp()
+		return errors.New("no input file specified")
+	}
+	for _, file := range files {
+		if err := dumpFrames(file, folder, prefix, quiet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,370 @@
+// Command cwebp compresses an image file to a WebP file, like libwebp's cwebp.
+//
+// Usage:
+//
+//	cwebp [options] input_file -o output_file.webp
+//
+// PNG, JPEG and GIF inputs are supported.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+	"time"
+
+	"github.com/daanv2/go-webp"
+	"github.com/daanv2/go-webp/cmd/internal/cliutil"
+	"github.com/daanv2/go-webp/pkg/config"
+	"github.com/daanv2/go-webp/pkg/libwebp/enc"
+	libwebp "github.com/daanv2/go-webp/pkg/libwebp/webp"
+	"github.com/daanv2/go-webp/pkg/picture"
+)
+
+var presets = map[string]config.Preset{
+	"default": config.WEBP_PRESET_DEFAULT,
+	"photo":   config.WEBP_PRESET_PHOTO,
+	"picture": config.WEBP_PRESET_PICTURE,
+	"drawing": config.WEBP_PRESET_DRAWING,
+	"icon":    config.WEBP_PRESET_ICON,
+	"text":    config.WEBP_PRESET_TEXT,
+}
+
+var hints = map[string]config.ImageHint{
+	"photo":   config.WEBP_HINT_PHOTO,
+	"picture": config.WEBP_HINT_PICTURE,
+	"graph":   config.WEBP_HINT_GRAPH,
+}
+
+var alphaFilters = map[string]config.AlphaFilter{
+	"none": config.ALPHA_FILTER_NONE,
+	"fast": config.ALPHA_FILTER_FAST,
+	"best": config.ALPHA_FILTER_BEST,
+	"auto": config.ALPHA_FILTER_AUTO,
+}
+
+var wideDithers = map[string]picture.WideDither{
+	"none":      picture.WIDE_DITHER_NONE,
+	"ordered":   picture.WIDE_DITHER_ORDERED,
+	"diffusion": picture.WIDE_DITHER_DIFFUSION,
+}
+
+// Flags taking several space-separated values, as in libwebp's cwebp.
+var multiValueFlags = map[string]int{
+	"crop":   4,
+	"resize": 2,
+}
+
+type options struct {
+	output      string
+	quality     float64
+	method      int
+	lossless    bool
+	nearLoss    int
+	preset      string
+	hint        string
+	size        int
+	psnr        float64
+	pass        int
+	sns         int
+	filter      int
+	sharpness   int
+	strong      bool
+	autoFilter  bool
+	segments    int
+	partLimit   int
+	sharpYUV    bool
+	wideDither  string
+	alphaQ      int
+	alphaMethod int
+	alphaFilter string
+	exact       bool
+	mt          int
+	lowMemory   bool
+	metadata    string
+	crop        string
+	resize      string
+	printPSNR   bool
+	short       bool
+	quiet       bool
+	verbose     bool
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error! %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func newFlagSet(o *options) *flag.FlagSet {
+	fs := flag.NewFlagSet("cwebp", flag.ContinueOnError)
+	fs.StringVar(&o.output, "o", "", "output file name")
+	fs.Float64Var(&o.quality, "q", 75, "quality factor (0:small..100:big)")
+	fs.IntVar(&o.method, "m", 4, "compression method (0=fast, 6=slowest)")
+	fs.BoolVar(&o.lossless, "lossless", false, "encode image losslessly")
+	fs.IntVar(&o.nearLoss, "near_lossless", 100, "use near-lossless image preprocessing (0..100=off)")
+	fs.StringVar(&o.preset, "preset", "", "preset setting, one of: default, photo, picture, drawing, icon, text, or auto to pick it from the content of the input")
+	fs.StringVar(&o.hint, "hint", "", "specify image characteristics hint, one of: photo, picture or graph")
+	fs.IntVar(&o.size, "size", 0, "target size (in bytes)")
+	fs.Float64Var(&o.psnr, "psnr", 0, "target PSNR (in dB. typically: 42)")
+	fs.IntVar(&o.pass, "pass", 1, "analysis pass number (1..10)")
+	fs.IntVar(&o.sns, "sns", 50, "spatial noise shaping (0:off, 100:max)")
+	fs.IntVar(&o.filter, "f", 60, "filter strength (0=off..100)")
+	fs.IntVar(&o.sharpness, "sharpness", 0, "filter sharpness (0:most .. 7:least sharp)")
+	fs.BoolVar(&o.strong, "strong", true, "use strong filter instead of simple")
+	fs.BoolVar(&o.autoFilter, "af", false, "auto-adjust filter strength")
+	fs.IntVar(&o.segments, "segments", 4, "number of segments to use (1..4)")
+	fs.IntVar(&o.partLimit, "partition_limit", 0, "limit quality to fit the 512k limit on the first partition (0=no degradation ... 100=full)")
+	fs.BoolVar(&o.sharpYUV, "sharp_yuv", false, "use sharper (and slower) RGB->YUV conversion")
+	fs.StringVar(&o.wideDither, "wide_dither", "none", "reduction of 16-bit input to 8 bits, one of: none (rounding), ordered or diffusion")
+	fs.IntVar(&o.alphaQ, "alpha_q", 100, "transparency-compression quality (0..100)")
+	fs.IntVar(&o.alphaMethod, "alpha_method", 1, "transparency-compression method (0..1)")
+	fs.StringVar(&o.alphaFilter, "alpha_filter", "fast", "predictive filtering for alpha plane, one of: none, fast (default), best or auto")
+	fs.BoolVar(&o.exact, "exact", false, "preserve RGB values in transparent area")
+	fs.IntVar(&o.mt, "mt", 0, "number of extra worker goroutines (0 = single-threaded)")
+	fs.BoolVar(&o.lowMemory, "low_memory", false, "reduce memory usage (slower encoding)")
+	fs.StringVar(&o.metadata, "metadata", "none", "comma separated list of metadata to copy from the input to the output if present: all, none, exif, icc, xmp")
+	fs.StringVar(&o.crop, "crop", "", "crop picture with the given rectangle: -crop <x> <y> <w> <h>")
+	fs.StringVar(&o.resize, "resize", "", "resize picture (*after* any cropping): -resize <w> <h>")
+	fs.BoolVar(&o.printPSNR, "print_psnr", false, "prints averaged PSNR distortion")
+	fs.BoolVar(&o.short, "short", false, "condense printed message")
+	fs.BoolVar(&o.quiet, "quiet", false, "don't print anything")
+	fs.BoolVar(&o.verbose, "v", false, "verbose, e.g. print encoding/decoding times")
+	return fs
+}
+
+// Fills 'cfg' from the options, the preset being applied first. The auto
+// preset analyses 'img'.
+func setupConfig(o *options, set map[string]bool, img image.Image, cfg *config.Config) error {
+	if o.preset == "auto" {
+		if _, err := webp.InitAutoPreset(cfg, img, o.quality); err != nil {
+			return fmt.Errorf("cannot analyse input: %w", err)
+		}
+	} else {
+		preset := config.WEBP_PRESET_DEFAULT
+		if o.preset != "" {
+			p, ok := presets[o.preset]
+			if !ok {
+				return fmt.Errorf("invalid preset %q", o.preset)
+			}
+			preset = p
+		}
+		if err := cfg.InitPreset(preset, o.quality); err != nil {
+			return err
+		}
+	}
+	if set["m"] {
+		cfg.Method = o.method
+	}
+	if o.lossless {
+		cfg.Lossless = 1
+	}
+	if set["near_lossless"] {
+		cfg.NearLossless = o.nearLoss
+		cfg.Lossless = 1 // use near-lossless only with lossless
+	}
+	if o.hint != "" {
+		h, ok := hints[o.hint]
+		if !ok {
+			return fmt.Errorf("invalid image hint %q", o.hint)
+		}
+		cfg.ImageHint = h
+	}
+	cfg.TargetSize = o.size
+	cfg.TargetPSNR = o.psnr
+	if set["pass"] {
+		cfg.Pass = o.pass
+	} else if o.size > 0 || o.psnr > 0 {
+		cfg.Pass = 6 // as cwebp does for targeted encoding
+	}
+	if set["sns"] {
+		cfg.SnsStrength = o.sns
+	}
+	if set["f"] {
+		cfg.FilterStrength = o.filter
+	}
+	if set["sharpness"] {
+		cfg.FilterSharpness = o.sharpness
+	}
+	if set["strong"] {
+		cfg.FilterType = 0
+		if o.strong {
+			cfg.FilterType = 1
+		}
+	}
+	if o.autoFilter {
+		cfg.Autofilter = 1
+	}
+	if set["segments"] {
+		cfg.Segments = o.segments
+	}
+	cfg.PartitionLimit = o.partLimit
+	if o.sharpYUV {
+		cfg.UseSharpYUV = 1
+	}
+	d, ok := wideDithers[o.wideDither]
+	if !ok {
+		return fmt.Errorf("invalid wide dither %q", o.wideDither)
+	}
+	cfg.WideDithering = int(d)
+	cfg.AlphaQuality = o.alphaQ
+	cfg.AlphaCompression = config.AlphaCompression(o.alphaMethod)
+	f, ok := alphaFilters[o.alphaFilter]
+	if !ok {
+		return fmt.Errorf("invalid alpha filter %q", o.alphaFilter)
+	}
+	cfg.AlphaFiltering = f
+	if o.exact {
+		cfg.Exact = 1
+	}
+	cfg.ThreadLevel = o.mt
+	if o.lowMemory {
+		cfg.LowMemory = 1
+	}
+	return cfg.Validate()
+}
+
+func run(args []string) error {
+	var o options
+	fs := newFlagSet(&o)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage:\n\n   cwebp [options] input_file -o output_file.webp\n\n")
+		fs.PrintDefaults()
+	}
+	flags, positional, err := cliutil.SplitArgs(fs, args, multiValueFlags)
+	if err != nil {
+		return err
+	}
+	if err := fs.Parse(flags); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return errors.New("exactly one input file must be given")
+	}
+	in := positional[0]
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	log := io.Writer(os.Stderr)
+	if o.quiet {
+		log = io.Discard
+	}
+
+	keep, err := parseMetadataFlag(o.metadata)
+	if err != nil {
+		return err
+	}
+
+	// Read and decode the input.
+	start := time.Now()
+	data, err := os.ReadFile(in)
+	if err != nil {
+		return fmt.Errorf("cannot read input file %s: %w", in, err)
+	}
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("could not process file %s: %w", in, err)
+	}
+	var cfg config.Config
+	if err := setupConfig(&o, set, img, &cfg); err != nil {
+		return err
+	}
+	var meta metadata
+	if keep != 0 {
+		meta = extractMetadata(format, data)
+	}
+	if o.verbose {
+		fmt.Fprintf(log, "File:      %s\n", in)
+		fmt.Fprintf(log, "Time to read input: %.3fs\n", time.Since(start).Seconds())
+	}
+
+	var pic picture.Picture
+	picture.WebPPictureInit(&pic)
+	// Lossy pictures are imported as YUV, so that 16-bit input reaches the
+	// sharp conversion at full precision. Cropping and resizing work on ARGB,
+	// as in libwebp's cwebp, where the crop offsets are not snapped to even
+	// values.
+	pic.UseARGB = cfg.Lossless != 0 || o.crop != "" || o.resize != ""
+	if err := picture.WebPPictureImportImage(&pic, img, picture.WideDither(cfg.WideDithering), cfg.UseSharpYUV); err != nil {
+		return fmt.Errorf("cannot import picture: %w", err)
+	}
+	defer picture.WebPPictureFree(&pic)
+
+	if o.crop != "" {
+		r, err := cliutil.ParseInts(o.crop, 4)
+		if err != nil {
+			return fmt.Errorf("invalid -crop: %w", err)
+		}
+		if enc.WebPPictureCrop(&pic, r[0], r[1], r[2], r[3]) == 0 {
+			return fmt.Errorf("cannot crop picture to %dx%d+%d+%d", r[2], r[3], r[0], r[1])
+		}
+	}
+	if o.resize != "" {
+		r, err := cliutil.ParseInts(o.resize, 2)
+		if err != nil {
+			return fmt.Errorf("invalid -resize: %w", err)
+		}
+		if enc.WebPPictureRescale(&pic, r[0], r[1]) == 0 {
+			return fmt.Errorf("cannot resize picture to %dx%d", r[0], r[1])
+		}
+	}
+
+	var aux libwebp.WebPAuxStats
+	if !o.quiet || o.printPSNR {
+		pic.SetStats(&aux)
+	}
+
+	start = time.Now()
+	var out bytes.Buffer
+	if err := webp.EncodePicture(&out, &pic, &cfg); err != nil {
+		return fmt.Errorf("cannot encode picture as WebP: %w", err)
+	}
+	if o.verbose {
+		fmt.Fprintf(log, "Time to encode picture: %.3fs\n", time.Since(start).Seconds())
+	}
+	encoded := out.Bytes()
+	if meta.copy(keep) {
+		if encoded, err = writeWithMetadata(encoded, &meta, keep); err != nil {
+			return err
+		}
+	}
+
+	if o.output != "" {
+		if err := os.WriteFile(o.output, encoded, 0o644); err != nil {
+			return fmt.Errorf("cannot write output file %s: %w", o.output, err)
+		}
+	} else if !o.quiet {
+		fmt.Fprintf(log, "No output file specified (no -o flag). Encoding will\nbe performed, but its results discarded.\n\n")
+	}
+
+	stats := aux.Export()
+	stats.CodedSize = len(encoded)
+	switch {
+	case o.short && !o.quiet:
+		fmt.Fprintf(log, "%7d %2.2f\n", stats.CodedSize, stats.PSNR[3])
+	case !o.quiet:
+		hasAlpha := picture.WebPPictureHasTransparency(&pic) != 0
+		if cfg.Lossless != 0 {
+			printExtraInfoLossless(log, in, &pic, hasAlpha, &stats)
+		} else {
+			printExtraInfoLossy(log, in, &pic, hasAlpha, &stats, o.verbose)
+		}
+		meta.print(log, keep)
+	}
+	if o.printPSNR && !o.short {
+		fmt.Fprintf(os.Stdout, "PSNR: %.2f\n", stats.PSNR[3])
+	}
+	return nil
+}
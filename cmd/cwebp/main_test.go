@@ -0,0 +1,115 @@
+package main
+
+import (
+	"flag"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/daanv2/go-webp/cmd/internal/cliutil"
+	"github.com/daanv2/go-webp/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+// setup parses the command line 'args' and runs setupConfig on the result,
+// for a small flat input.
+func setup(t *testing.T, args ...string) (config.Config, error) {
+	return setupImage(t, image.NewNRGBA(image.Rect(0, 0, 16, 16)), args...)
+}
+
+// setupImage is setup for the input 'img'.
+func setupImage(t *testing.T, img image.Image, args ...string) (config.Config, error) {
+	t.Helper()
+
+	var o options
+	fs := newFlagSet(&o)
+	flags, _, err := cliutil.SplitArgs(fs, append(args, "in.png"), multiValueFlags)
+	require.NoError(t, err)
+	require.NoError(t, fs.Parse(flags))
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	var cfg config.Config
+	return cfg, setupConfig(&o, set, img, &cfg)
+}
+
+func TestSetupConfig(t *testing.T) {
+	cfg, err := setup(t)
+	require.NoError(t, err)
+	var want config.Config
+	require.NoError(t, want.InitPreset(config.WEBP_PRESET_DEFAULT, 75))
+	require.Equal(t, want, cfg)
+
+	cfg, err = setup(t, "-preset", "photo", "-q", "80")
+	require.NoError(t, err)
+	require.NoError(t, want.InitPreset(config.WEBP_PRESET_PHOTO, 80))
+	require.Equal(t, want, cfg)
+
+	for _, tc := range []struct {
+		args  []string
+		check func(cfg *config.Config) bool
+	}{
+		{[]string{"-m", "6"}, func(cfg *config.Config) bool { return cfg.Method == 6 }},
+		{[]string{"-lossless"}, func(cfg *config.Config) bool { return cfg.Lossless == 1 }},
+		{[]string{"-near_lossless", "60"}, func(cfg *config.Config) bool { return cfg.Lossless == 1 && cfg.NearLossless == 60 }},
+		{[]string{"-hint", "graph"}, func(cfg *config.Config) bool { return cfg.ImageHint == config.WEBP_HINT_GRAPH }},
+		{[]string{"-size", "5000"}, func(cfg *config.Config) bool { return cfg.TargetSize == 5000 && cfg.Pass == 6 }},
+		{[]string{"-size", "5000", "-pass", "2"}, func(cfg *config.Config) bool { return cfg.Pass == 2 }},
+		{[]string{"-strong=false"}, func(cfg *config.Config) bool { return cfg.FilterType == 0 }},
+		{[]string{"-segments", "2"}, func(cfg *config.Config) bool { return cfg.Segments == 2 }},
+		{[]string{"-sharp_yuv"}, func(cfg *config.Config) bool { return cfg.UseSharpYUV == 1 }},
+		{[]string{"-wide_dither", "ordered"}, func(cfg *config.Config) bool { return cfg.WideDithering == 1 }},
+		{[]string{"-wide_dither", "diffusion"}, func(cfg *config.Config) bool { return cfg.WideDithering == 2 }},
+		{[]string{"-alpha_q", "50", "-alpha_filter", "auto"}, func(cfg *config.Config) bool {
+			return cfg.AlphaQuality == 50 && cfg.AlphaFiltering == config.ALPHA_FILTER_AUTO
+		}},
+		{[]string{"-exact", "-mt", "3", "-low_memory"}, func(cfg *config.Config) bool {
+			return cfg.Exact == 1 && cfg.ThreadLevel == 3 && cfg.LowMemory == 1
+		}},
+	} {
+		cfg, err := setup(t, tc.args...)
+		require.NoError(t, err, "%q", tc.args)
+		require.True(t, tc.check(&cfg), "%q: %+v", tc.args, cfg)
+	}
+}
+
+// The auto preset classifies the input; the other flags still apply on top.
+func TestSetupConfigAuto(t *testing.T) {
+	icon := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+	photo := image.NewNRGBA(image.Rect(0, 0, 256, 256))
+	for y := 0; y < 256; y++ {
+		for x := 0; x < 256; x++ {
+			icon.SetNRGBA(x%32, y%32, color.NRGBA{R: 200, A: 255})
+			photo.SetNRGBA(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: uint8((x + y) / 2), A: 255})
+		}
+	}
+
+	cfg, err := setupImage(t, icon, "-preset", "auto")
+	require.NoError(t, err)
+	require.Equal(t, 1, cfg.Lossless)
+	require.Equal(t, config.WEBP_HINT_GRAPH, cfg.ImageHint)
+
+	cfg, err = setupImage(t, photo, "-preset", "auto", "-q", "60", "-m", "2")
+	require.NoError(t, err)
+	require.Zero(t, cfg.Lossless)
+	require.Equal(t, config.WEBP_HINT_PHOTO, cfg.ImageHint)
+	require.Equal(t, 60.0, cfg.Quality)
+	require.Equal(t, 2, cfg.Method)
+
+	cfg, err = setupImage(t, photo, "-preset", "auto", "-hint", "picture")
+	require.NoError(t, err)
+	require.Equal(t, config.WEBP_HINT_PICTURE, cfg.ImageHint)
+}
+
+func TestSetupConfigErrors(t *testing.T) {
+	for _, args := range [][]string{
+		{"-preset", "cartoon"},
+		{"-hint", "sketch"},
+		{"-wide_dither", "random"},
+		{"-alpha_filter", "slow"},
+		{"-m", "7"},
+		{"-mt", "-1"},
+	} {
+		_, err := setup(t, args...)
+		require.Error(t, err, "%q", args)
+	}
+}
@@ -0,0 +1,243 @@
+// Metadata (EXIF, ICC profile, XMP) extraction from the inputs and emission
+// in the extended (VP8X) WebP container.
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/daanv2/go-webp/pkg/libwebp/mux"
+	libwebp "github.com/daanv2/go-webp/pkg/libwebp/webp"
+)
+
+const (
+	METADATA_EXIF = 1 << iota
+	METADATA_ICC
+	METADATA_XMP
+	METADATA_ALL = METADATA_EXIF | METADATA_ICC | METADATA_XMP
+)
+
+type metadata struct {
+	exif, iccp, xmp []byte
+}
+
+func parseMetadataFlag(s string) (int, error) {
+	keep := 0
+	for _, name := range strings.Split(s, ",") {
+		switch strings.TrimSpace(name) {
+		case "all":
+			keep = METADATA_ALL
+		case "none":
+			keep = 0
+		case "exif":
+			keep |= METADATA_EXIF
+		case "icc":
+			keep |= METADATA_ICC
+		case "xmp":
+			keep |= METADATA_XMP
+		default:
+			return 0, fmt.Errorf("unknown metadata type %q", name)
+		}
+	}
+	return keep, nil
+}
+
+// Reports whether some of the metadata in 'keep' is present.
+func (m *metadata) copy(keep int) bool {
+	return (keep&METADATA_EXIF != 0 && len(m.exif) > 0) ||
+		(keep&METADATA_ICC != 0 && len(m.iccp) > 0) ||
+		(keep&METADATA_XMP != 0 && len(m.xmp) > 0)
+}
+
+func (m *metadata) print(w io.Writer, keep int) {
+	if !m.copy(keep) {
+		return
+	}
+	fmt.Fprintf(w, "Metadata:\n")
+	if keep&METADATA_ICC != 0 && len(m.iccp) > 0 {
+		fmt.Fprintf(w, "  * ICC profile:  %6d bytes\n", len(m.iccp))
+	}
+	if keep&METADATA_EXIF != 0 && len(m.exif) > 0 {
+		fmt.Fprintf(w, "  * EXIF data:    %6d bytes\n", len(m.exif))
+	}
+	if keep&METADATA_XMP != 0 && len(m.xmp) > 0 {
+		fmt.Fprintf(w, "  * XMP data:     %6d bytes\n", len(m.xmp))
+	}
+}
+
+// Returns the metadata found in the input 'data' of the given format.
+// Malformed metadata is ignored.
+func extractMetadata(format string, data []byte) metadata {
+	switch format {
+	case "jpeg":
+		return extractJPEGMetadata(data)
+	case "png":
+		return extractPNGMetadata(data)
+	}
+	return metadata{}
+}
+
+//------------------------------------------------------------------------------
+// JPEG
+
+var (
+	kJPEGExifSignature = []byte("Exif\x00\x00")
+	kJPEGXMPSignature  = []byte("http://ns.adobe.com/xap/1.0/\x00")
+	kJPEGICCSignature  = []byte("ICC_PROFILE\x00")
+)
+
+func extractJPEGMetadata(data []byte) metadata {
+	var m metadata
+	type iccSegment struct {
+		seq  int
+		data []byte
+	}
+	var icc []iccSegment
+	if len(data) < 2 || data[0] != 0xff || data[1] != 0xd8 {
+		return m
+	}
+	for pos := 2; pos+4 <= len(data); {
+		if data[pos] != 0xff {
+			break
+		}
+		marker := data[pos+1]
+		if marker == 0xd9 || marker == 0xda { // EOI, SOS: no more metadata
+			break
+		}
+		size := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if size < 2 || pos+2+size > len(data) {
+			break
+		}
+		payload := data[pos+4 : pos+2+size]
+		switch {
+		case marker == 0xe1 && bytes.HasPrefix(payload, kJPEGExifSignature) && m.exif == nil:
+			m.exif = bytes.Clone(payload[len(kJPEGExifSignature):])
+		case marker == 0xe1 && bytes.HasPrefix(payload, kJPEGXMPSignature) && m.xmp == nil:
+			m.xmp = bytes.Clone(payload[len(kJPEGXMPSignature):])
+		case marker == 0xe2 && bytes.HasPrefix(payload, kJPEGICCSignature) && len(payload) > len(kJPEGICCSignature)+2:
+			// sequence number and segment count, then the profile chunk
+			seq := int(payload[len(kJPEGICCSignature)])
+			icc = append(icc, iccSegment{seq, payload[len(kJPEGICCSignature)+2:]})
+		}
+		pos += 2 + size
+	}
+	sort.SliceStable(icc, func(i, j int) bool { return icc[i].seq < icc[j].seq })
+	for _, s := range icc {
+		m.iccp = append(m.iccp, s.data...)
+	}
+	return m
+}
+
+//------------------------------------------------------------------------------
+// PNG
+
+func extractPNGMetadata(data []byte) metadata {
+	var m metadata
+	const kSignatureSize = 8
+	for pos := kSignatureSize; pos+12 <= len(data); {
+		size := int(binary.BigEndian.Uint32(data[pos:]))
+		tag := string(data[pos+4 : pos+8])
+		if size < 0 || pos+12+size > len(data) {
+			break
+		}
+		payload := data[pos+8 : pos+8+size]
+		switch tag {
+		case "iCCP":
+			// name, 0, compression method, zlib stream
+			if i := bytes.IndexByte(payload, 0); i >= 0 && i+2 <= len(payload) && m.iccp == nil {
+				m.iccp, _ = inflate(payload[i+2:])
+			}
+		case "eXIf":
+			if m.exif == nil {
+				m.exif = bytes.Clone(payload)
+			}
+		case "iTXt":
+			if m.xmp == nil {
+				m.xmp = pngXMP(payload)
+			}
+		case "IEND":
+			return m
+		}
+		pos += 12 + size
+	}
+	return m
+}
+
+// Returns the XMP packet of an iTXt chunk, nil if it holds something else.
+func pngXMP(payload []byte) []byte {
+	const kXMPKeyword = "XML:com.adobe.xmp"
+	fields := bytes.SplitN(payload, []byte{0}, 2)
+	if len(fields) != 2 || string(fields[0]) != kXMPKeyword || len(fields[1]) < 2 {
+		return nil
+	}
+	compressed := fields[1][0] != 0
+	rest := fields[1][2:]
+	// language tag and translated keyword
+	for i := 0; i < 2; i++ {
+		j := bytes.IndexByte(rest, 0)
+		if j < 0 {
+			return nil
+		}
+		rest = rest[j+1:]
+	}
+	if compressed {
+		text, err := inflate(rest)
+		if err != nil {
+			return nil
+		}
+		return text
+	}
+	return bytes.Clone(rest)
+}
+
+func inflate(data []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+//------------------------------------------------------------------------------
+// WebP container
+
+// Rewrites the encoded file 'webp' with the metadata of 'meta' selected by
+// 'keep', like WriteWebPWithMetadata() in libwebp's cwebp. The mux builds the
+// VP8X chunk and orders the chunks.
+func writeWithMetadata(webp []byte, meta *metadata, keep int) ([]byte, error) {
+	m, merr := mux.MuxCreate(webp)
+	if merr != libwebp.WEBP_MUX_OK {
+		return nil, muxError(merr, "invalid encoded WebP file")
+	}
+	for _, c := range []struct {
+		flag   int
+		fourcc string
+		data   []byte
+	}{
+		{METADATA_ICC, "ICCP", meta.iccp},
+		{METADATA_EXIF, "EXIF", meta.exif},
+		{METADATA_XMP, "XMP ", meta.xmp},
+	} {
+		if keep&c.flag == 0 || len(c.data) == 0 {
+			continue
+		}
+		if merr := m.SetChunk(c.fourcc, c.data); merr != libwebp.WEBP_MUX_OK {
+			return nil, muxError(merr, "cannot add "+c.fourcc+" chunk")
+		}
+	}
+	out, merr := m.Assemble()
+	if merr != libwebp.WEBP_MUX_OK {
+		return nil, muxError(merr, "cannot assemble WebP file")
+	}
+	return out, nil
+}
+
+func muxError(err libwebp.WebPMuxError, what string) error {
+	return fmt.Errorf("%s (%s)", what, mux.MuxErrorString(err))
+}
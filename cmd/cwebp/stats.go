@@ -0,0 +1,137 @@
+// Encoding statistics reports, as printed by libwebp's cwebp.
+package main
+
+import (
+	"fmt"
+	"io"
+
+	libwebp "github.com/daanv2/go-webp/pkg/libwebp/webp"
+	"github.com/daanv2/go-webp/pkg/picture"
+)
+
+func printByteCount(w io.Writer, counts [4]int, total int) {
+	s := 0
+	for _, c := range counts {
+		s += c
+		fmt.Fprintf(w, "| %7d ", c)
+	}
+	fmt.Fprintf(w, "| %7d  (%.1f%%)\n", s, 100.*float64(s)/float64(total))
+}
+
+func printPercents(w io.Writer, counts [4]int) {
+	total := 0
+	for _, c := range counts {
+		total += c
+	}
+	for _, c := range counts {
+		fmt.Fprintf(w, "|      %3d%%", int(100*float64(c)/float64(max(total, 1))))
+	}
+	fmt.Fprintf(w, "| %7d\n", total)
+}
+
+func printValues(w io.Writer, values [4]int) {
+	for _, v := range values {
+		fmt.Fprintf(w, "| %7d ", v)
+	}
+	fmt.Fprintf(w, "|\n")
+}
+
+func printFullLosslessInfo(w io.Writer, stats *libwebp.AuxStats, description string) {
+	fmt.Fprintf(w, "Lossless-%s compressed size: %d bytes\n", description, stats.LosslessSize)
+	fmt.Fprintf(w, "  * Header size: %d bytes, image data size: %d\n",
+		stats.LosslessHdrSize, stats.LosslessDataSize)
+	if stats.LosslessFeatures != 0 {
+		fmt.Fprintf(w, "  * Lossless features used:")
+		if stats.LosslessFeatures&1 != 0 {
+			fmt.Fprintf(w, " PREDICTION")
+		}
+		if stats.LosslessFeatures&2 != 0 {
+			fmt.Fprintf(w, " CROSS-COLOR-TRANSFORM")
+		}
+		if stats.LosslessFeatures&4 != 0 {
+			fmt.Fprintf(w, " SUBTRACT-GREEN")
+		}
+		if stats.LosslessFeatures&8 != 0 {
+			fmt.Fprintf(w, " PALETTE")
+		}
+		fmt.Fprintf(w, "\n")
+	}
+	fmt.Fprintf(w, "  * Precision Bits: histogram=%d", stats.HistogramBits)
+	if stats.LosslessFeatures&1 != 0 {
+		fmt.Fprintf(w, " prediction=%d", stats.TransformBits)
+	}
+	if stats.LosslessFeatures&2 != 0 {
+		fmt.Fprintf(w, " cross-color=%d", stats.CrossColorTransformBits)
+	}
+	fmt.Fprintf(w, " cache=%d\n", stats.CacheBits)
+	if stats.PaletteSize > 0 {
+		fmt.Fprintf(w, "  * Palette size:   %d\n", stats.PaletteSize)
+	}
+}
+
+func printExtraInfoLossless(w io.Writer, in string, pic *picture.Picture, hasAlpha bool, stats *libwebp.AuxStats) {
+	fmt.Fprintf(w, "File:      %s\n", in)
+	fmt.Fprintf(w, "Dimension: %d x %d", pic.Width, pic.Height)
+	if hasAlpha {
+		fmt.Fprintf(w, " (with alpha)")
+	}
+	fmt.Fprintf(w, "\n")
+	fmt.Fprintf(w, "Output:    %d bytes (%.2f bpp)\n", stats.CodedSize,
+		8.*float64(stats.CodedSize)/float64(pic.Width*pic.Height))
+	printFullLosslessInfo(w, stats, "ARGB")
+}
+
+func printExtraInfoLossy(w io.Writer, in string, pic *picture.Picture, hasAlpha bool, stats *libwebp.AuxStats, verbose bool) {
+	totalSize := stats.CodedSize
+	num_i4 := stats.BlockCount[0]
+	num_i16 := stats.BlockCount[1]
+	num_skip := stats.BlockCount[2]
+	total := max(num_i4+num_i16, 1)
+
+	fmt.Fprintf(w, "File:      %s\n", in)
+	fmt.Fprintf(w, "Dimension: %d x %d", pic.Width, pic.Height)
+	if hasAlpha {
+		fmt.Fprintf(w, " (with alpha)")
+	}
+	fmt.Fprintf(w, "\n")
+	fmt.Fprintf(w, "Output:    %d bytes Y-U-V-All-PSNR %2.2f %2.2f %2.2f   %2.2f dB\n",
+		totalSize, stats.PSNR[0], stats.PSNR[1], stats.PSNR[2], stats.PSNR[3])
+	fmt.Fprintf(w, "           (%.2f bpp)\n", 8.*float64(totalSize)/float64(pic.Width*pic.Height))
+	fmt.Fprintf(w, "block count:  intra4:     %6d  (%.2f%%)\n", num_i4, 100.*float64(num_i4)/float64(total))
+	fmt.Fprintf(w, "              intra16:    %6d  (%.2f%%)\n", num_i16, 100.*float64(num_i16)/float64(total))
+	fmt.Fprintf(w, "              skipped:    %6d  (%.2f%%)\n", num_skip, 100.*float64(num_skip)/float64(total))
+	fmt.Fprintf(w, "bytes used:  header:         %6d  (%.1f%%)\n", stats.HeaderBytes[0],
+		100.*float64(stats.HeaderBytes[0])/float64(totalSize))
+	fmt.Fprintf(w, "             mode-partition: %6d  (%.1f%%)\n", stats.HeaderBytes[1],
+		100.*float64(stats.HeaderBytes[1])/float64(totalSize))
+	if stats.AlphaDataSize > 0 {
+		fmt.Fprintf(w, "             transparency:   %6d (%.1f dB)\n", stats.AlphaDataSize, stats.PSNR[4])
+	}
+	fmt.Fprintf(w, " Residuals bytes  |segment 1|segment 2|segment 3|segment 4|  total\n")
+	if verbose {
+		fmt.Fprintf(w, "  intra4-coeffs:  ")
+		printByteCount(w, stats.ResidualBytes[0], totalSize)
+		fmt.Fprintf(w, " intra16-coeffs:  ")
+		printByteCount(w, stats.ResidualBytes[1], totalSize)
+		fmt.Fprintf(w, "  chroma coeffs:  ")
+		printByteCount(w, stats.ResidualBytes[2], totalSize)
+	}
+	fmt.Fprintf(w, "    macroblocks:  ")
+	printPercents(w, stats.SegmentSize)
+	fmt.Fprintf(w, "      quantizer:  ")
+	printValues(w, stats.SegmentQuant)
+	fmt.Fprintf(w, "   filter level:  ")
+	printValues(w, stats.SegmentLevel)
+	if verbose {
+		fmt.Fprintf(w, "------------------+---------+---------+---------+---------+-----------------\n")
+		var totals [4]int
+		for s := 0; s < 4; s++ {
+			totals[s] = stats.ResidualBytes[0][s] + stats.ResidualBytes[1][s] + stats.ResidualBytes[2][s]
+		}
+		fmt.Fprintf(w, " segments total:  ")
+		printByteCount(w, totals, totalSize)
+	}
+	if stats.LosslessSize > 0 {
+		printFullLosslessInfo(w, stats, "alpha")
+	}
+}
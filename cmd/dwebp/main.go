@@ -0,0 +1,279 @@
+// Command dwebp decodes a WebP file to PNG, PAM, PPM, TIFF, PGM, raw YUV or
+// raw samples, like libwebp's dwebp.
+//
+// Usage:
+//
+//	dwebp in_file [options] [-o out_file]
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/daanv2/go-webp/cmd/internal/cliutil"
+	"github.com/daanv2/go-webp/pkg/libwebp/decoder"
+	libwebp "github.com/daanv2/go-webp/pkg/libwebp/webp"
+	"github.com/daanv2/go-webp/pkg/vp8"
+)
+
+// Output file formats.
+type outputFormat int
+
+const (
+	PNG outputFormat = iota
+	PAM
+	PPM
+	TIFF
+	PGM // YUV planes, as a gray image
+	RAW_YUV
+	RAW_RGBA // raw samples of one of the RGB modes
+)
+
+var kStatusMessages = map[vp8.VP8StatusCode]string{
+	vp8.VP8_STATUS_OUT_OF_MEMORY:       "OUT_OF_MEMORY",
+	vp8.VP8_STATUS_INVALID_PARAM:       "INVALID_PARAM",
+	vp8.VP8_STATUS_BITSTREAM_ERROR:     "BITSTREAM_ERROR",
+	vp8.VP8_STATUS_UNSUPPORTED_FEATURE: "UNSUPPORTED_FEATURE",
+	vp8.VP8_STATUS_SUSPENDED:           "SUSPENDED",
+	vp8.VP8_STATUS_USER_ABORT:          "USER_ABORT",
+	vp8.VP8_STATUS_NOT_ENOUGH_DATA:     "NOT_ENOUGH_DATA",
+	vp8.VP8_STATUS_LIMIT_EXCEEDED:      "LIMIT_EXCEEDED",
+}
+
+// Flags taking several space-separated values, as in libwebp's dwebp.
+var multiValueFlags = map[string]int{
+	"crop":   4,
+	"resize": 2,
+	"scale":  2,
+}
+
+// Size of the pieces fed to the incremental decoder.
+const kIncrementalChunkSize = 4096
+
+// A boolean flag selecting the output format; the last one given wins.
+type formatFlag struct {
+	dst    *outputFormat
+	mode   *libwebp.WEBP_CSP_MODE
+	format outputFormat
+	csp    libwebp.WEBP_CSP_MODE
+}
+
+func (f *formatFlag) IsBoolFlag() bool { return true }
+func (f *formatFlag) String() string   { return "false" }
+func (f *formatFlag) Set(s string) error {
+	if v, err := strconv.ParseBool(s); err != nil || !v {
+		return err
+	}
+	*f.dst = f.format
+	*f.mode = f.csp
+	return nil
+}
+
+type options struct {
+	output      string
+	format      outputFormat
+	rawMode     libwebp.WEBP_CSP_MODE
+	crop        string
+	resize      string
+	flip        bool
+	noFancy     bool
+	noFilter    bool
+	dither      int
+	alphaDither bool
+	mt          bool
+	incremental bool
+	quiet       bool
+	verbose     bool
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error! %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func newFlagSet(o *options) *flag.FlagSet {
+	fs := flag.NewFlagSet("dwebp", flag.ContinueOnError)
+	fs.StringVar(&o.output, "o", "", "output file name")
+	formats := []struct {
+		name   string
+		format outputFormat
+		mode   libwebp.WEBP_CSP_MODE
+		usage  string
+	}{
+		{"png", PNG, libwebp.MODE_RGBA, "output PNG format (default)"},
+		{"pam", PAM, libwebp.MODE_RGBA, "save the raw RGBA samples as a color PAM"},
+		{"ppm", PPM, libwebp.MODE_RGB, "save the raw RGB samples as a color PPM"},
+		{"tiff", TIFF, libwebp.MODE_RGBA, "save as uncompressed TIFF"},
+		{"pgm", PGM, libwebp.MODE_YUV, "save the raw YUV samples as a grayscale PGM file with IMC4 layout"},
+		{"yuv", RAW_YUV, libwebp.MODE_YUVA, "save the raw YUV samples in flat layout"},
+		{"rgba", RAW_RGBA, libwebp.MODE_RGBA, "save raw RGBA samples"},
+		{"bgra", RAW_RGBA, libwebp.MODE_BGRA, "save raw BGRA samples"},
+		{"argb", RAW_RGBA, libwebp.MODE_ARGB, "save raw ARGB samples"},
+		{"rgb", RAW_RGBA, libwebp.MODE_RGB, "save raw RGB samples"},
+		{"bgr", RAW_RGBA, libwebp.MODE_BGR, "save raw BGR samples"},
+		{"rgba4444", RAW_RGBA, libwebp.MODE_RGBA_4444, "save raw RGBA4444 samples"},
+		{"rgb565", RAW_RGBA, libwebp.MODE_RGB_565, "save raw RGB565 samples"},
+		{"rgbA", RAW_RGBA, libwebp.MODE_rgbA, "save raw premultiplied RGBA samples"},
+		{"bgrA", RAW_RGBA, libwebp.MODE_bgrA, "save raw premultiplied BGRA samples"},
+		{"Argb", RAW_RGBA, libwebp.MODE_Argb, "save raw premultiplied ARGB samples"},
+		{"rgbA_4444", RAW_RGBA, libwebp.MODE_rgbA_4444, "save raw premultiplied RGBA4444 samples"},
+	}
+	o.format, o.rawMode = PNG, libwebp.MODE_RGBA
+	for _, f := range formats {
+		fs.Var(&formatFlag{&o.format, &o.rawMode, f.format, f.mode}, f.name, f.usage)
+	}
+	fs.StringVar(&o.crop, "crop", "", "crop output with the given rectangle: -crop <x> <y> <w> <h>")
+	fs.StringVar(&o.resize, "resize", "", "resize output (*after* any cropping): -resize <w> <h>")
+	fs.StringVar(&o.resize, "scale", "", "alias for -resize")
+	fs.BoolVar(&o.flip, "flip", false, "flip the output vertically")
+	fs.BoolVar(&o.noFancy, "nofancy", false, "don't use the fancy YUV420 upscaler")
+	fs.BoolVar(&o.noFilter, "nofilter", false, "disable in-loop filtering")
+	fs.IntVar(&o.dither, "dither", 0, "dithering strength (in 0..100)")
+	fs.BoolVar(&o.alphaDither, "alpha_dither", false, "use alpha-plane dithering if needed")
+	fs.BoolVar(&o.mt, "mt", false, "use multi-threading")
+	fs.BoolVar(&o.incremental, "incremental", false, "use incremental decoding (useful for tests)")
+	fs.BoolVar(&o.quiet, "quiet", false, "quiet mode, don't print anything")
+	fs.BoolVar(&o.verbose, "v", false, "verbose (e.g. print encoding/decoding times)")
+	return fs
+}
+
+// Maps the options onto the decoder options.
+func setupOptions(o *options, opts *decoder.DecodeOptions) error {
+	opts.BypassFiltering = o.noFilter
+	opts.NoFancyUpsampling = o.noFancy
+	opts.UseThreads = o.mt
+	opts.UseWavefront = o.mt // used for bitstreams with several partitions
+	opts.Flip = o.flip
+	opts.DitheringStrength = o.dither
+	if o.alphaDither {
+		opts.AlphaDitheringStrength = 100
+	}
+	if o.crop != "" {
+		r, err := cliutil.ParseInts(o.crop, 4)
+		if err != nil {
+			return fmt.Errorf("invalid -crop: %w", err)
+		}
+		opts.UseCropping = true
+		opts.CropLeft, opts.CropTop, opts.CropWidth, opts.CropHeight = r[0], r[1], r[2], r[3]
+	}
+	if o.resize != "" {
+		r, err := cliutil.ParseInts(o.resize, 2)
+		if err != nil {
+			return fmt.Errorf("invalid -resize: %w", err)
+		}
+		opts.UseScaling = true
+		opts.ScaledWidth, opts.ScaledHeight = r[0], r[1]
+	}
+	return nil
+}
+
+func run(args []string) error {
+	var o options
+	fs := newFlagSet(&o)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: dwebp in_file [options] [-o out_file]\n\n")
+		fs.PrintDefaults()
+	}
+	flags, positional, err := cliutil.SplitArgs(fs, args, multiValueFlags)
+	if err != nil {
+		return err
+	}
+	if err := fs.Parse(flags); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return errors.New("exactly one input file must be given")
+	}
+	in := positional[0]
+
+	log := io.Writer(os.Stderr)
+	if o.quiet {
+		log = io.Discard
+	}
+
+	var opts decoder.DecodeOptions
+	if err := setupOptions(&o, &opts); err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(in)
+	if err != nil {
+		return fmt.Errorf("cannot read input file %s: %w", in, err)
+	}
+	info, status := decoder.GetImageInfo(data)
+	if status != vp8.VP8_STATUS_OK {
+		return fmt.Errorf("decoding of %s failed.\nStatus: %d(%s)", in, status, kStatusMessages[status])
+	}
+	if info.HasAnimation {
+		return fmt.Errorf("decoding of an animated WebP file is not supported")
+	}
+
+	mode := o.rawMode
+	if o.format == PNG && !info.HasAlpha {
+		mode = libwebp.MODE_RGB
+	}
+	if o.format == RAW_YUV && !info.HasAlpha {
+		mode = libwebp.MODE_YUV
+	}
+
+	start := time.Now()
+	var img *decoder.DecodedImage
+	if o.incremental {
+		img, status = decoder.WebPIDecodeAdvanced(data, mode, &opts, kIncrementalChunkSize)
+	} else {
+		img, status = decoder.WebPDecodeAdvanced(data, mode, &opts)
+	}
+	if status != vp8.VP8_STATUS_OK {
+		return fmt.Errorf("decoding of %s failed.\nStatus: %d(%s)", in, status, kStatusMessages[status])
+	}
+	if o.verbose {
+		fmt.Fprintf(log, "Time to decode picture: %.3fs\n", time.Since(start).Seconds())
+	}
+
+	if !o.quiet {
+		alpha := ""
+		if info.HasAlpha {
+			alpha = " (with alpha)"
+		}
+		kind := []string{"undefined", "lossy", "lossless"}[info.Format]
+		fmt.Fprintf(log, "Decoded %s. Dimensions: %d x %d%s. Format: %s. Now saving...\n",
+			in, img.Width, img.Height, alpha, kind)
+	}
+	if o.output == "" {
+		if !o.quiet {
+			fmt.Fprintf(log, "Nothing written; use -o flag to save the result as e.g. PNG.\n")
+		}
+		return nil
+	}
+
+	start = time.Now()
+	f, err := os.Create(o.output)
+	if err != nil {
+		return fmt.Errorf("cannot open output file %s: %w", o.output, err)
+	}
+	if err := writeImage(f, img, o.format); err != nil {
+		f.Close()
+		return fmt.Errorf("cannot write output file %s: %w", o.output, err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if !o.quiet {
+		fmt.Fprintf(log, "Saved file %s\n", o.output)
+	}
+	if o.verbose {
+		fmt.Fprintf(log, "Time to write output: %.3fs\n", time.Since(start).Seconds())
+	}
+	return nil
+}
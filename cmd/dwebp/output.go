@@ -0,0 +1,209 @@
+// Image writers for the decoded samples (PNG, PAM, PPM, TIFF, PGM, raw).
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+
+	"github.com/daanv2/go-webp/pkg/libwebp/decoder"
+	libwebp "github.com/daanv2/go-webp/pkg/libwebp/webp"
+)
+
+// Bytes per pixel of the RGB modes.
+func bytesPerPixel(mode libwebp.WEBP_CSP_MODE) int {
+	switch mode {
+	case libwebp.MODE_RGB, libwebp.MODE_BGR:
+		return 3
+	case libwebp.MODE_RGBA_4444, libwebp.MODE_rgbA_4444, libwebp.MODE_RGB_565:
+		return 2
+	default:
+		return 4
+	}
+}
+
+func writeImage(w io.Writer, img *decoder.DecodedImage, format outputFormat) error {
+	bw := bufio.NewWriter(w)
+	var err error
+	switch format {
+	case PNG:
+		err = writePNG(bw, img)
+	case PAM:
+		err = writePPMorPAM(bw, img, true)
+	case PPM:
+		err = writePPMorPAM(bw, img, false)
+	case TIFF:
+		err = writeTIFF(bw, img)
+	case PGM:
+		err = writePGM(bw, img)
+	case RAW_YUV:
+		err = writeYUV(bw, img)
+	default:
+		err = writeRaw(bw, img)
+	}
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// Writes the rows of the RGB buffer without their padding.
+func writeRows(w io.Writer, img *decoder.DecodedImage) error {
+	row_size := img.Width * bytesPerPixel(img.Mode)
+	for y := 0; y < img.Height; y++ {
+		if _, err := w.Write(img.RGBA[y*img.Stride : y*img.Stride+row_size]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Writes 'height' rows of 'width' samples of a plane.
+func writePlane(w io.Writer, plane []uint8, stride, width, height int) error {
+	for y := 0; y < height; y++ {
+		if _, err := w.Write(plane[y*stride : y*stride+width]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeRaw(w io.Writer, img *decoder.DecodedImage) error {
+	return writeRows(w, img)
+}
+
+// Expects MODE_RGB or MODE_RGBA samples.
+func writePNG(w io.Writer, img *decoder.DecodedImage) error {
+	nrgba := image.NewNRGBA(image.Rect(0, 0, img.Width, img.Height))
+	bpp := bytesPerPixel(img.Mode)
+	for y := 0; y < img.Height; y++ {
+		src := img.RGBA[y*img.Stride:]
+		dst := nrgba.Pix[y*nrgba.Stride:]
+		for x := 0; x < img.Width; x++ {
+			copy(dst[4*x:4*x+3], src[bpp*x:bpp*x+3])
+			dst[4*x+3] = 0xff
+			if bpp == 4 {
+				dst[4*x+3] = src[bpp*x+3]
+			}
+		}
+	}
+	return png.Encode(w, nrgba)
+}
+
+// Expects MODE_RGBA samples for PAM, MODE_RGB for PPM.
+func writePPMorPAM(w io.Writer, img *decoder.DecodedImage, alpha bool) error {
+	var err error
+	if alpha {
+		_, err = fmt.Fprintf(w, "P7\nWIDTH %d\nHEIGHT %d\nDEPTH 4\nMAXVAL 255\nTUPLTYPE RGB_ALPHA\nENDHDR\n",
+			img.Width, img.Height)
+	} else {
+		_, err = fmt.Fprintf(w, "P6\n%d %d\n255\n", img.Width, img.Height)
+	}
+	if err != nil {
+		return err
+	}
+	return writeRows(w, img)
+}
+
+// Writes an uncompressed, little-endian TIFF of the MODE_RGBA samples, alpha
+// being unassociated.
+func writeTIFF(w io.Writer, img *decoder.DecodedImage) error {
+	const (
+		kNumEntries = 11
+		kIFDOffset  = 8
+		// header, entry count, entries, next IFD offset
+		kBitsOffset = kIFDOffset + 2 + kNumEntries*12 + 4
+		kDataOffset = kBitsOffset + 4*2
+	)
+	const (
+		SHORT = 3
+		LONG  = 4
+	)
+	width, height := img.Width, img.Height
+	data_size := uint32(4 * width * height)
+
+	buf := make([]byte, 0, kDataOffset)
+	buf = append(buf, 'I', 'I', 42, 0)
+	buf = binary.LittleEndian.AppendUint32(buf, kIFDOffset)
+	buf = binary.LittleEndian.AppendUint16(buf, kNumEntries)
+	entry := func(tag, typ uint16, count, value uint32) {
+		buf = binary.LittleEndian.AppendUint16(buf, tag)
+		buf = binary.LittleEndian.AppendUint16(buf, typ)
+		buf = binary.LittleEndian.AppendUint32(buf, count)
+		if typ == SHORT && count == 1 {
+			buf = binary.LittleEndian.AppendUint16(buf, uint16(value))
+			buf = binary.LittleEndian.AppendUint16(buf, 0)
+		} else {
+			buf = binary.LittleEndian.AppendUint32(buf, value)
+		}
+	}
+	// Entries must be sorted by tag.
+	entry(256, LONG, 1, uint32(width))  // ImageWidth
+	entry(257, LONG, 1, uint32(height)) // ImageLength
+	entry(258, SHORT, 4, kBitsOffset)   // BitsPerSample
+	entry(259, SHORT, 1, 1)             // Compression: none
+	entry(262, SHORT, 1, 2)             // PhotometricInterpretation: RGB
+	entry(273, LONG, 1, kDataOffset)    // StripOffsets
+	entry(277, SHORT, 1, 4)             // SamplesPerPixel
+	entry(278, LONG, 1, uint32(height)) // RowsPerStrip
+	entry(279, LONG, 1, data_size)      // StripByteCounts
+	entry(284, SHORT, 1, 1)             // PlanarConfiguration: contiguous
+	entry(338, SHORT, 1, 2)             // ExtraSamples: unassociated alpha
+	// no next IFD, then the BitsPerSample values
+	buf = binary.LittleEndian.AppendUint32(buf, 0)
+	for i := 0; i < 4; i++ {
+		buf = binary.LittleEndian.AppendUint16(buf, 8)
+	}
+	if _, err := w.Write(buf); err != nil {
+		return err
+	}
+	return writeRows(w, img)
+}
+
+// Writes the MODE_YUV planes as a gray image: Y on top, U and V side by side
+// below it.
+func writePGM(w io.Writer, img *decoder.DecodedImage) error {
+	width, height := img.Width, img.Height
+	uv_width, uv_height := (width+1)/2, (height+1)/2
+	out_stride := max(width, 2*uv_width)
+	if _, err := fmt.Fprintf(w, "P5\n%d %d\n255\n", out_stride, height+uv_height); err != nil {
+		return err
+	}
+	row := make([]uint8, out_stride)
+	for y := 0; y < height; y++ {
+		copy(row, img.Y[y*img.YStride:y*img.YStride+width])
+		if _, err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	for y := 0; y < uv_height; y++ {
+		copy(row, img.U[y*img.UStride:y*img.UStride+uv_width])
+		copy(row[uv_width:], img.V[y*img.VStride:y*img.VStride+uv_width])
+		if _, err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Writes the Y, U, V and, if present, A planes one after the other.
+func writeYUV(w io.Writer, img *decoder.DecodedImage) error {
+	width, height := img.Width, img.Height
+	uv_width, uv_height := (width+1)/2, (height+1)/2
+	if err := writePlane(w, img.Y, img.YStride, width, height); err != nil {
+		return err
+	}
+	if err := writePlane(w, img.U, img.UStride, uv_width, uv_height); err != nil {
+		return err
+	}
+	if err := writePlane(w, img.V, img.VStride, uv_width, uv_height); err != nil {
+		return err
+	}
+	if img.A != nil {
+		return writePlane(w, img.A, img.AStride, width, height)
+	}
+	return nil
+}
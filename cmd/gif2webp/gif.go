@@ -0,0 +1,113 @@
+// Reading of the ICC and XMP application extensions, which image/gif does
+// not report.
+package main
+
+import "errors"
+
+// XMP padding data is 0x01, 0xff, 0xfe ... 0x01, 0x00.
+const kXMPPaddingSize = 257
+
+var errTruncated = errors.New("truncated GIF data")
+
+// Metadata found in the application extensions of a GIF file.
+type gifMetadata struct {
+	iccp []uint8
+	xmp  []uint8
+}
+
+// Returns the offset past the data sub-blocks starting at 'pos', calling
+// 'sub_block' with each one, length byte included.
+func skipSubBlocks(data []uint8, pos int, sub_block func(block []uint8)) (int, error) {
+	for {
+		if pos >= len(data) {
+			return 0, errTruncated
+		}
+		size := int(data[pos])
+		if size == 0 {
+			return pos + 1, nil
+		}
+		if pos+1+size > len(data) {
+			return 0, errTruncated
+		}
+		if sub_block != nil {
+			sub_block(data[pos : pos+1+size])
+		}
+		pos += 1 + size
+	}
+}
+
+// Walks the blocks of the GIF file 'data' looking for the ICCRGBG1012 and
+// XMP DataXMP application extensions.
+func readGIFMetadata(data []uint8) (gifMetadata, error) {
+	var meta gifMetadata
+	const kHeaderSize = 6 + 7 // signature, logical screen descriptor
+	if len(data) < kHeaderSize {
+		return meta, errTruncated
+	}
+	pos := kHeaderSize
+	if flags := data[10]; flags&0x80 != 0 {
+		pos += 3 << ((flags & 7) + 1) // global color table
+	}
+	for pos < len(data) {
+		var err error
+		switch data[pos] {
+		case 0x3b: // trailer
+			return meta, nil
+		case 0x2c: // image descriptor
+			if pos+10 > len(data) {
+				return meta, errTruncated
+			}
+			flags := data[pos+9]
+			pos += 10
+			if flags&0x80 != 0 {
+				pos += 3 << ((flags & 7) + 1) // local color table
+			}
+			pos++ // LZW minimum code size
+			pos, err = skipSubBlocks(data, pos, nil)
+		case 0x21: // extension
+			if pos+2 >= len(data) {
+				return meta, errTruncated
+			}
+			label := data[pos+1]
+			pos += 2
+			if label != 0xff || int(data[pos]) != 11 || pos+12 > len(data) {
+				pos, err = skipSubBlocks(data, pos, nil)
+				break
+			}
+			var dst *[]uint8
+			is_xmp := false
+			switch string(data[pos+1 : pos+12]) {
+			case "ICCRGBG1012":
+				dst = &meta.iccp
+			case "XMP DataXMP":
+				dst, is_xmp = &meta.xmp, true
+			}
+			pos += 12
+			var payload []uint8
+			pos, err = skipSubBlocks(data, pos, func(block []uint8) {
+				if is_xmp {
+					// The length bytes are part of the XMP packet.
+					payload = append(payload, block...)
+				} else {
+					payload = append(payload, block[1:]...)
+				}
+			})
+			if dst != nil && len(*dst) == 0 {
+				if is_xmp {
+					if len(payload) <= kXMPPaddingSize {
+						payload = nil
+					} else {
+						payload = payload[:len(payload)-kXMPPaddingSize]
+					}
+				}
+				*dst = payload
+			}
+		default:
+			return meta, errors.New("unknown GIF block")
+		}
+		if err != nil {
+			return meta, err
+		}
+	}
+	return meta, errTruncated
+}
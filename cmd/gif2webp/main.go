@@ -0,0 +1,340 @@
+// Command gif2webp converts an animated GIF to an animated WebP file, like
+// libwebp's gif2webp.
+//
+// Usage:
+//
+//	gif2webp [options] gif_file -o webp_file
+//
+// Options:
+//
+//	-lossy               encode image using lossy compression
+//	-lossless            encode image losslessly (default)
+//	-mixed               for each frame in the image, pick lossy or lossless
+//	-q <float>           quality factor (0:small..100:big)
+//	-m <int>             compression method (0=fast, 6=slowest)
+//	-min_size            minimize output size (default:off)
+//	-kmin <int>          min distance between key frames
+//	-kmax <int>          max distance between key frames
+//	-metadata <string>   comma separated list of metadata to copy from the
+//	                     input to the output if present: all, none, icc, xmp
+//	                     (default: xmp)
+//	-loop <int>          loop count of the WebP animation (0 = infinite),
+//	                     in place of the one of the GIF
+//	-loop_compatibility  use compatibility mode for Chrome version prior to
+//	                     M62 (inclusive)
+//	-mt                  use multi-threading if available
+//	-v                   verbose
+//	-quiet               don't print anything
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image/gif"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/daanv2/go-webp/cmd/internal/animutil"
+	"github.com/daanv2/go-webp/pkg/config"
+	"github.com/daanv2/go-webp/pkg/libwebp/mux"
+	"github.com/daanv2/go-webp/pkg/picture"
+)
+
+const (
+	METADATA_ICC = 1 << iota
+	METADATA_XMP
+	METADATA_ALL = METADATA_ICC | METADATA_XMP
+)
+
+type options struct {
+	input, output      string
+	lossless           bool
+	mixed              bool
+	quality            float64
+	method             int
+	minimize_size      bool
+	kmin, kmax         int
+	keep_metadata      int
+	loop_count         int // -1: from the GIF
+	loop_compatibility bool
+	use_threads        bool
+	verbose, quiet     bool
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error! %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func printHelp() {
+	fmt.Printf("Usage:\n")
+	fmt.Printf(" gif2webp [options] gif_file -o webp_file\n")
+	fmt.Printf("Options:\n")
+	fmt.Printf("  -h / -help ............. this help\n")
+	fmt.Printf("  -lossy ................. encode image using lossy compression\n")
+	fmt.Printf("  -lossless .............. encode image losslessly (default)\n")
+	fmt.Printf("  -mixed ................. for each frame in the image, pick lossy\n")
+	fmt.Printf("                           or lossless compression heuristically\n")
+	fmt.Printf("  -q <float> ............. quality factor (0:small..100:big)\n")
+	fmt.Printf("  -m <int> ............... compression method (0=fast, 6=slowest)\n")
+	fmt.Printf("  -min_size .............. minimize output size (default:off)\n")
+	fmt.Printf("                           lossless compression by default; can be\n")
+	fmt.Printf("                           combined with -q, -m, -lossy or -mixed\n")
+	fmt.Printf("                           options\n")
+	fmt.Printf("  -kmin <int> ............ min distance between key frames\n")
+	fmt.Printf("  -kmax <int> ............ max distance between key frames\n")
+	fmt.Printf("  -metadata <string> ..... comma separated list of metadata to\n")
+	fmt.Printf("                           copy from the input to the output if present\n")
+	fmt.Printf("                           Valid values: all, none, icc, xmp (default)\n")
+	fmt.Printf("  -loop <int> ............ loop count of the WebP animation\n")
+	fmt.Printf("                           (0 = infinite), overriding the GIF one\n")
+	fmt.Printf("  -loop_compatibility .... use compatibility mode for Chrome\n")
+	fmt.Printf("                           version prior to M62 (inclusive)\n")
+	fmt.Printf("  -mt .................... use multi-threading if available\n")
+	fmt.Printf("\n")
+	fmt.Printf("  -v ..................... verbose\n")
+	fmt.Printf("  -quiet ................. don't print anything\n")
+}
+
+func parseMetadataFlag(s string) (int, error) {
+	keep := 0
+	for _, name := range strings.Split(s, ",") {
+		switch strings.TrimSpace(name) {
+		case "all":
+			keep = METADATA_ALL
+		case "none":
+			keep = 0
+		case "icc":
+			keep |= METADATA_ICC
+		case "xmp":
+			keep |= METADATA_XMP
+		case "exif":
+			fmt.Fprintf(os.Stderr, "Warning: EXIF metadata is not supported in GIF\n")
+		default:
+			return 0, fmt.Errorf("unknown metadata type %q", name)
+		}
+	}
+	return keep, nil
+}
+
+func parseArgs(args []string) (*options, error) {
+	opts := &options{lossless: true, quality: 75, method: 4, kmin: -1, kmax: -1, keep_metadata: METADATA_XMP, loop_count: -1}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		value := func() (string, error) {
+			if i+1 >= len(args) {
+				return "", fmt.Errorf("missing value for %s", arg)
+			}
+			i++
+			return args[i], nil
+		}
+		intValue := func() (int, error) {
+			v, err := value()
+			if err != nil {
+				return 0, err
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q for %s", v, arg)
+			}
+			return n, nil
+		}
+		var err error
+		switch arg {
+		case "-h", "-help":
+			printHelp()
+			return nil, nil
+		case "-o":
+			opts.output, err = value()
+		case "-lossy":
+			opts.lossless = false
+		case "-lossless":
+			opts.lossless, opts.mixed = true, false
+		case "-mixed":
+			opts.mixed, opts.lossless = true, false
+		case "-q":
+			var v string
+			if v, err = value(); err == nil {
+				if opts.quality, err = strconv.ParseFloat(v, 64); err != nil {
+					err = fmt.Errorf("invalid value %q for -q", v)
+				}
+			}
+		case "-m":
+			opts.method, err = intValue()
+		case "-min_size":
+			opts.minimize_size = true
+		case "-kmin":
+			opts.kmin, err = intValue()
+		case "-kmax":
+			opts.kmax, err = intValue()
+		case "-metadata":
+			var v string
+			if v, err = value(); err == nil {
+				opts.keep_metadata, err = parseMetadataFlag(v)
+			}
+		case "-loop":
+			if opts.loop_count, err = intValue(); err == nil && (opts.loop_count < 0 || opts.loop_count > 65535) {
+				err = fmt.Errorf("invalid loop count %d, expected 0..65535", opts.loop_count)
+			}
+		case "-loop_compatibility":
+			opts.loop_compatibility = true
+		case "-mt":
+			opts.use_threads = true
+		case "-v":
+			opts.verbose = true
+		case "-quiet":
+			opts.quiet = true
+		default:
+			if len(arg) > 1 && arg[0] == '-' {
+				return nil, fmt.Errorf("unknown option '%s'", arg)
+			}
+			opts.input = arg
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	// Key-frame defaults depend on the compression mode.
+	if opts.kmin < 0 && opts.kmax < 0 {
+		if opts.lossless {
+			opts.kmin, opts.kmax = 9, 17
+		} else {
+			opts.kmin, opts.kmax = 3, 5
+		}
+	} else if opts.kmin < 0 {
+		opts.kmin = opts.kmax / 2
+	} else if opts.kmax < 0 {
+		opts.kmax = 2 * opts.kmin
+	}
+	return opts, nil
+}
+
+func run(args []string) error {
+	if len(args) == 0 {
+		printHelp()
+		return errors.New("no input given")
+	}
+	opts, err := parseArgs(args)
+	if err != nil || opts == nil {
+		return err
+	}
+	if opts.input == "" {
+		return errors.New("no input file specified")
+	}
+
+	data, err := os.ReadFile(opts.input)
+	if err != nil {
+		return fmt.Errorf("cannot read input file %s: %w", opts.input, err)
+	}
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("cannot decode GIF %s: %w", opts.input, err)
+	}
+	var meta gifMetadata
+	if opts.keep_metadata != 0 {
+		if meta, err = readGIFMetadata(data); err != nil && !opts.quiet {
+			fmt.Fprintf(os.Stderr, "Warning: cannot read the metadata: %v\n", err)
+		}
+	}
+
+	width, height := animutil.GIFCanvasSize(g)
+	anim_config := mux.DefaultAnimEncoderOptions()
+	anim_config.AnimParams = mux.MuxAnimParams{
+		BgColor:   animutil.GIFBackgroundColor(g),
+		LoopCount: opts.loop_count,
+	}
+	if opts.loop_count < 0 {
+		anim_config.AnimParams.LoopCount = animutil.GIFLoopCount(g.LoopCount, opts.loop_compatibility)
+	}
+	anim_config.MinimizeSize = opts.minimize_size
+	anim_config.Kmin, anim_config.Kmax = opts.kmin, opts.kmax
+	anim_config.AllowMixed = opts.mixed
+	anim_config.Verbose = opts.verbose
+	enc := mux.NewAnimEncoder(width, height, &anim_config)
+	if enc == nil {
+		return errors.New("could not create WebPAnimEncoder object")
+	}
+	defer enc.Delete()
+
+	var cfg config.Config
+	if err := cfg.Init(); err != nil {
+		return err
+	}
+	if opts.lossless {
+		cfg.Lossless = 1
+	}
+	cfg.Quality = opts.quality
+	cfg.Method = opts.method
+	if opts.use_threads {
+		cfg.ThreadLevel = 1
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	comp := animutil.NewCompositor(width, height)
+	timestamp_ms := 0
+	for i, frame := range g.Image {
+		var disposal byte
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		canvas := comp.Draw(frame, disposal)
+
+		var pic picture.Picture
+		picture.WebPPictureInit(&pic)
+		// The animation encoder works on ARGB canvases, and would convert
+		// YUV frames back.
+		pic.UseARGB = true
+		if err := picture.WebPPictureImportImage(&pic, canvas, picture.WIDE_DITHER_NONE, 0); err != nil {
+			return fmt.Errorf("cannot import frame #%d: %w", i, err)
+		}
+		err := enc.Add(&pic, timestamp_ms, &cfg)
+		picture.WebPPictureFree(&pic)
+		if err != nil {
+			return fmt.Errorf("cannot encode frame #%d: %w", i, err)
+		}
+
+		delay := animutil.GIFFrameDuration(g, i)
+		if opts.verbose {
+			fmt.Fprintf(os.Stderr, "Added frame #%3d at time %4d (duration %d ms, dispose %d)\n",
+				i, timestamp_ms, delay, disposal)
+		}
+		timestamp_ms += delay
+	}
+	// Add a last fake frame to signal the last duration.
+	if err := enc.Add(nil, timestamp_ms, nil); err != nil {
+		return err
+	}
+
+	if opts.keep_metadata&METADATA_ICC != 0 && len(meta.iccp) > 0 {
+		if err := enc.SetChunk("ICCP", meta.iccp); err != mux.WEBP_MUX_OK {
+			return fmt.Errorf("could not set ICC profile: %s", mux.MuxErrorString(err))
+		}
+	}
+	if opts.keep_metadata&METADATA_XMP != 0 && len(meta.xmp) > 0 {
+		if err := enc.SetChunk("XMP ", meta.xmp); err != mux.WEBP_MUX_OK {
+			return fmt.Errorf("could not set XMP metadata: %s", mux.MuxErrorString(err))
+		}
+	}
+
+	webp_data, err := enc.Assemble()
+	if err != nil {
+		return fmt.Errorf("cannot assemble the animation: %w", err)
+	}
+	if opts.output != "" {
+		if err := os.WriteFile(opts.output, webp_data, 0o644); err != nil {
+			return fmt.Errorf("cannot write output file %s: %w", opts.output, err)
+		}
+		if !opts.quiet {
+			fmt.Fprintf(os.Stderr, "Saved output file (%d bytes): %s\n", len(webp_data), opts.output)
+		}
+	} else if !opts.quiet {
+		fmt.Fprintf(os.Stderr, "Nothing written; use -o flag to save the result (%d bytes).\n", len(webp_data))
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseArgs(t *testing.T) {
+	opts, err := parseArgs([]string{"in.gif"})
+	require.NoError(t, err)
+	require.Equal(t, "in.gif", opts.input)
+	require.True(t, opts.lossless)
+	require.Equal(t, -1, opts.loop_count)
+
+	opts, err = parseArgs([]string{"-mixed", "-lossless", "-loop", "3", "in.gif"})
+	require.NoError(t, err)
+	require.True(t, opts.lossless)
+	require.False(t, opts.mixed)
+	require.Equal(t, 3, opts.loop_count)
+	require.Equal(t, 9, opts.kmin) // lossless key-frame defaults
+
+	opts, err = parseArgs([]string{"-lossy", "-loop", "0", "in.gif"})
+	require.NoError(t, err)
+	require.False(t, opts.lossless)
+	require.Zero(t, opts.loop_count)
+
+	for _, args := range [][]string{
+		{"-loop"},
+		{"-loop", "x"},
+		{"-loop", "-1"},
+		{"-loop", "65536"},
+	} {
+		_, err := parseArgs(append(args, "in.gif"))
+		require.Error(t, err, "%q", args)
+	}
+}
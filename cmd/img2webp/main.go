@@ -0,0 +1,277 @@
+// Command img2webp generates an animated WebP file from a sequence of still
+// images, like libwebp's img2webp.
+//
+// Usage:
+//
+//	img2webp [file_options] [[frame_options] frame_file]... [-o webp_file]
+//
+// File-level options (apply to the whole animation):
+//
+//	-min_size            minimize size
+//	-kmax <int>          maximum number of frames between key-frames
+//	-kmin <int>          minimum number of frames between key-frames
+//	-mixed               use mixed lossy/lossless automatic mode
+//	-near_lossless <int> use near-lossless image preprocessing (0..100=off)
+//	-sharp_yuv           use sharper (and slower) RGB->YUV conversion
+//	-loop <int>          loop count (default: 0, = infinite loop)
+//	-v                   verbose mode
+//
+// Per-frame options (apply to the following frames until changed):
+//
+//	-d <int>             frame duration in ms (default: 100)
+//	-lossless            use lossless mode (default)
+//	-lossy               use lossy mode
+//	-q <float>           quality
+//	-m <int>             method to use
+//	-exact, -noexact     preserve or alter RGB values in transparent area
+//
+// PNG, JPEG and GIF frames are supported; all must have the same dimensions.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"strconv"
+
+	"github.com/daanv2/go-webp/pkg/config"
+	"github.com/daanv2/go-webp/pkg/libwebp/mux"
+	"github.com/daanv2/go-webp/pkg/picture"
+)
+
+// Frame options, persistent from one frame to the next.
+type frameOptions struct {
+	duration int
+	lossless bool
+	quality  float64
+	method   int
+	exact    bool
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error! %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func printHelp() {
+	fmt.Printf("Usage:\n\n")
+	fmt.Printf("  img2webp [file_options] [[frame_options] frame_file]... [-o webp_file]\n\n")
+	fmt.Printf("File-level options (only used at the start of compression):\n")
+	fmt.Printf(" -min_size ............ minimize size\n")
+	fmt.Printf(" -kmax <int> .......... maximum number of frame between key-frames\n")
+	fmt.Printf(" -kmin <int> .......... minimum number of frame between key-frames\n")
+	fmt.Printf(" -mixed ............... use mixed lossy/lossless automatic mode\n")
+	fmt.Printf(" -near_lossless <int> . use near-lossless image preprocessing\n")
+	fmt.Printf("                        (0..100=off), default=100\n")
+	fmt.Printf(" -sharp_yuv ........... use sharper (and slower) RGB->YUV conversion\n")
+	fmt.Printf(" -loop <int> .......... loop count (default: 0, = infinite loop)\n")
+	fmt.Printf(" -v ................... verbose mode\n")
+	fmt.Printf(" -h ................... this help\n\n")
+	fmt.Printf("Per-frame options (only used for subsequent images input):\n")
+	fmt.Printf(" -d <int> ............. frame duration in ms (default: 100)\n")
+	fmt.Printf(" -lossless  ........... use lossless mode (default)\n")
+	fmt.Printf(" -lossy ............... use lossy mode\n")
+	fmt.Printf(" -q <float> ........... quality\n")
+	fmt.Printf(" -m <int> ............. method to use\n")
+	fmt.Printf(" -exact, -noexact ..... preserve or alter RGB values in transparent area\n")
+	fmt.Printf("                        (default: -noexact, may cause artifacts\n")
+	fmt.Printf("                         with lossy animations)\n\n")
+	fmt.Printf("example: img2webp -loop 2 in0.png -lossy in1.jpg\n")
+	fmt.Printf("                  -d 80 in2.png -o out.webp\n")
+}
+
+func readPicture(file string, pic *picture.Picture) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return err
+	}
+	picture.WebPPictureInit(pic)
+	// The animation encoder works on ARGB canvases, and would convert YUV
+	// frames back.
+	pic.UseARGB = true
+	return picture.WebPPictureImportImage(pic, img, picture.WIDE_DITHER_NONE, 0)
+}
+
+func run(args []string) error {
+	if len(args) == 0 {
+		printHelp()
+		return errors.New("no input given")
+	}
+
+	anim_config := mux.DefaultAnimEncoderOptions()
+	var output string
+	verbose := false
+	near_lossless := -1
+	sharp_yuv := false
+
+	// File-level options come first.
+	i := 0
+	intArg := func(name string) (int, error) {
+		if i+1 >= len(args) {
+			return 0, fmt.Errorf("missing value for %s", name)
+		}
+		i++
+		v, err := strconv.Atoi(args[i])
+		if err != nil {
+			return 0, fmt.Errorf("invalid value %q for %s", args[i], name)
+		}
+		return v, nil
+	}
+	var err error
+	frame := frameOptions{duration: 100, lossless: true, quality: 75, method: 4}
+	for ; i < len(args); i++ {
+		switch args[i] {
+		case "-min_size":
+			anim_config.MinimizeSize = true
+		case "-kmax":
+			anim_config.Kmax, err = intArg("-kmax")
+		case "-kmin":
+			anim_config.Kmin, err = intArg("-kmin")
+		case "-mixed":
+			anim_config.AllowMixed = true
+			frame.lossless = false
+		case "-near_lossless":
+			near_lossless, err = intArg("-near_lossless")
+		case "-sharp_yuv":
+			sharp_yuv = true
+		case "-loop":
+			anim_config.AnimParams.LoopCount, err = intArg("-loop")
+		case "-v":
+			verbose = true
+			anim_config.Verbose = true
+		case "-h", "-help":
+			printHelp()
+			return nil
+		default:
+			goto Frames
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+Frames:
+	var enc *mux.WebPAnimEncoder
+	var width, height int
+	timestamp_ms := 0
+	pic_num := 0
+	for ; i < len(args); i++ {
+		switch args[i] {
+		case "-o":
+			if i+1 >= len(args) {
+				return errors.New("missing output file after -o")
+			}
+			i++
+			output = args[i]
+		case "-d":
+			frame.duration, err = intArg("-d")
+			if err == nil && frame.duration <= 0 {
+				err = errors.New("invalid negative or zero duration")
+			}
+		case "-lossless":
+			frame.lossless = true
+		case "-lossy":
+			frame.lossless = false
+		case "-q":
+			if i+1 >= len(args) {
+				return errors.New("missing value for -q")
+			}
+			i++
+			frame.quality, err = strconv.ParseFloat(args[i], 64)
+		case "-m":
+			frame.method, err = intArg("-m")
+		case "-exact":
+			frame.exact = true
+		case "-noexact":
+			frame.exact = false
+		default:
+			if len(args[i]) > 1 && args[i][0] == '-' {
+				return fmt.Errorf("unknown or misplaced option %s", args[i])
+			}
+			file := args[i]
+			var pic picture.Picture
+			if err := readPicture(file, &pic); err != nil {
+				return fmt.Errorf("cannot read image %s: %w", file, err)
+			}
+			if enc == nil {
+				width, height = pic.Width, pic.Height
+				if enc = mux.NewAnimEncoder(width, height, &anim_config); enc == nil {
+					return errors.New("could not create WebPAnimEncoder object")
+				}
+				defer enc.Delete()
+			} else if pic.Width != width || pic.Height != height {
+				return fmt.Errorf("frame #%d dimension mismatched! Got %d x %d. Was expecting %d x %d",
+					pic_num, pic.Width, pic.Height, width, height)
+			}
+
+			var cfg config.Config
+			if err := cfg.Init(); err != nil {
+				return err
+			}
+			cfg.Lossless = 0
+			if frame.lossless {
+				cfg.Lossless = 1
+			}
+			cfg.Quality = frame.quality
+			cfg.Method = frame.method
+			if frame.exact {
+				cfg.Exact = 1
+			}
+			if near_lossless >= 0 {
+				cfg.NearLossless = near_lossless
+			}
+			if sharp_yuv {
+				cfg.UseSharpYUV = 1
+			}
+			if err := cfg.Validate(); err != nil {
+				return fmt.Errorf("invalid configuration for frame #%d: %w", pic_num, err)
+			}
+
+			err := enc.Add(&pic, timestamp_ms, &cfg)
+			picture.WebPPictureFree(&pic)
+			if err != nil {
+				return fmt.Errorf("cannot encode frame #%d: %w", pic_num, err)
+			}
+			if verbose {
+				fmt.Fprintf(os.Stderr, "Added frame #%3d at time %4d (file: %s)\n", pic_num, timestamp_ms, file)
+			}
+			timestamp_ms += frame.duration
+			pic_num++
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if enc == nil {
+		return errors.New("no input frames")
+	}
+
+	// Add a last fake frame to signal the last duration.
+	if err := enc.Add(nil, timestamp_ms, nil); err != nil {
+		return err
+	}
+	webp_data, err := enc.Assemble()
+	if err != nil {
+		return fmt.Errorf("cannot assemble the animation: %w", err)
+	}
+	if output != "" {
+		if err := os.WriteFile(output, webp_data, 0o644); err != nil {
+			return fmt.Errorf("cannot write output file %s: %w", output, err)
+		}
+	}
+	if verbose || output == "" {
+		fmt.Fprintf(os.Stderr, "[%s: %d frames, %d bytes].\n", output, pic_num, len(webp_data))
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+// Utilities for animated images, like libwebp's examples/anim_util: the
+// fully reconstructed frames of an animated WebP or GIF file.
+package animutil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"os"
+
+	"github.com/daanv2/go-webp/pkg/libwebp/demux"
+	libwebp "github.com/daanv2/go-webp/pkg/libwebp/webp"
+)
+
+// A fully reconstructed frame of the canvas.
+type DecodedFrame struct {
+	RGBA        *image.NRGBA
+	TimestampMs int // end of the frame, from the start of the animation
+	DurationMs  int
+}
+
+type AnimatedImage struct {
+	CanvasWidth, CanvasHeight int
+	LoopCount                 int    // WebP semantics: 0 = infinite
+	BgColor                   uint32 // alpha, red, green, blue from the most significant byte
+	Frames                    []DecodedFrame
+	IsWebP                    bool
+}
+
+// Reports whether 'data' starts with a RIFF/WEBP header.
+func IsWebP( /* const */ data []uint8) bool {
+	return len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WEBP"
+}
+
+// Reports whether 'data' starts with a GIF signature.
+func IsGIF( /* const */ data []uint8) bool {
+	return len(data) >= 6 && (string(data[0:6]) == "GIF87a" || string(data[0:6]) == "GIF89a")
+}
+
+// Reads and decodes all the frames of the WebP or GIF file 'file'.
+func ReadAnimatedImage(file string) (*AnimatedImage, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case IsWebP(data):
+		return ReadAnimatedWebP(data)
+	case IsGIF(data):
+		return ReadAnimatedGIF(data)
+	}
+	return nil, fmt.Errorf("unknown file type for %s", file)
+}
+
+// Decodes the frames of a WebP file, animated or not, through the
+// WebPAnimDecoder.
+func ReadAnimatedWebP( /* const */ data []uint8) (*AnimatedImage, error) {
+	dec, err := demux.NewAnimDecoder(data, &demux.AnimDecoderOptions{ColorMode: libwebp.MODE_RGBA})
+	if err != nil {
+		return nil, err
+	}
+	defer dec.Delete()
+
+	info := dec.Info()
+	img := &AnimatedImage{
+		CanvasWidth:  info.CanvasWidth,
+		CanvasHeight: info.CanvasHeight,
+		LoopCount:    info.LoopCount,
+		BgColor:      info.BgColor,
+		Frames:       make([]DecodedFrame, 0, info.FrameCount),
+		IsWebP:       true,
+	}
+	prev_timestamp := 0
+	for dec.HasMoreFrames() {
+		canvas, timestamp, err := dec.Next()
+		if err != nil {
+			return nil, fmt.Errorf("frame #%d: %w", len(img.Frames), err)
+		}
+		rgba := image.NewNRGBA(image.Rect(0, 0, img.CanvasWidth, img.CanvasHeight))
+		copy(rgba.Pix, canvas)
+		img.Frames = append(img.Frames, DecodedFrame{
+			RGBA:        rgba,
+			TimestampMs: timestamp,
+			DurationMs:  timestamp - prev_timestamp,
+		})
+		prev_timestamp = timestamp
+	}
+	return img, nil
+}
+
+// Decodes and composites the frames of a GIF file.
+func ReadAnimatedGIF( /* const */ data []uint8) (*AnimatedImage, error) {
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if len(g.Image) == 0 {
+		return nil, errors.New("no frame in GIF")
+	}
+	width, height := GIFCanvasSize(g)
+	img := &AnimatedImage{
+		CanvasWidth:  width,
+		CanvasHeight: height,
+		LoopCount:    GIFLoopCount(g.LoopCount, false),
+		BgColor:      GIFBackgroundColor(g),
+		Frames:       make([]DecodedFrame, 0, len(g.Image)),
+	}
+	comp := NewCompositor(width, height)
+	timestamp := 0
+	for i, frame := range g.Image {
+		var disposal byte
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		canvas := comp.Draw(frame, disposal)
+		rgba := image.NewNRGBA(canvas.Rect)
+		copy(rgba.Pix, canvas.Pix)
+		duration := GIFFrameDuration(g, i)
+		timestamp += duration
+		img.Frames = append(img.Frames, DecodedFrame{RGBA: rgba, TimestampMs: timestamp, DurationMs: duration})
+	}
+	return img, nil
+}
@@ -0,0 +1,97 @@
+// GIF frame reconstruction, shared by gif2webp, anim_dump and anim_diff.
+package animutil
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+)
+
+// Background color of a GIF animation, as a WebP ANIM chunk would hold it:
+// the global color map entry given by the background index, or transparent
+// white when it is missing or is the transparent color.
+func GIFBackgroundColor(g *gif.GIF) uint32 {
+	palette, ok := g.Config.ColorModel.(color.Palette)
+	if !ok || int(g.BackgroundIndex) >= len(palette) {
+		return 0x00ffffff
+	}
+	c := color.NRGBAModel.Convert(palette[g.BackgroundIndex]).(color.NRGBA)
+	if c.A == 0 {
+		return 0x00ffffff
+	}
+	return 0xff<<24 | uint32(c.R)<<16 | uint32(c.G)<<8 | uint32(c.B)
+}
+
+// Maps the GIF loop count onto the WebP one: GIF repeats the animation
+// LoopCount more times, -1 meaning no NETSCAPE2.0 extension. In
+// compatibility mode the count is kept as is, like Chrome up to M62 read it.
+func GIFLoopCount(gif_loop_count int, loop_compatibility bool) int {
+	switch {
+	case loop_compatibility:
+		return max(gif_loop_count, 0)
+	case gif_loop_count < 0:
+		return 1 // play once
+	case gif_loop_count == 0:
+		return 0 // infinite
+	default:
+		return min(gif_loop_count+1, 65535)
+	}
+}
+
+// Duration in milliseconds of the frame 'i'. GIF delays are in 1/100 s; very
+// small ones are bumped to 100 ms, as browsers do.
+func GIFFrameDuration(g *gif.GIF, i int) int {
+	delay := 0
+	if i < len(g.Delay) {
+		delay = g.Delay[i] * 10
+	}
+	if delay <= 10 {
+		delay = 100
+	}
+	return delay
+}
+
+// Reconstructs the full canvas of each frame, applying the disposal method
+// of the previous one.
+type Compositor struct {
+	canvas       *image.NRGBA
+	previous     *image.NRGBA // canvas before the frame, for DisposalPrevious
+	dispose      byte
+	dispose_rect image.Rectangle
+}
+
+func NewCompositor(width, height int) *Compositor {
+	return &Compositor{canvas: image.NewNRGBA(image.Rect(0, 0, width, height))}
+}
+
+// Draws 'frame' and returns the resulting canvas, valid until the next call.
+func (c *Compositor) Draw(frame *image.Paletted, disposal byte) *image.NRGBA {
+	switch c.dispose {
+	case gif.DisposalBackground:
+		draw.Draw(c.canvas, c.dispose_rect, image.Transparent, image.Point{}, draw.Src)
+	case gif.DisposalPrevious:
+		if c.previous != nil {
+			copy(c.canvas.Pix, c.previous.Pix)
+		}
+	}
+	if disposal == gif.DisposalPrevious {
+		if c.previous == nil {
+			c.previous = image.NewNRGBA(c.canvas.Rect)
+		}
+		copy(c.previous.Pix, c.canvas.Pix)
+	}
+	draw.Draw(c.canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+	c.dispose, c.dispose_rect = disposal, frame.Bounds().Intersect(c.canvas.Rect)
+	return c.canvas
+}
+
+// Canvas size of a GIF, falling back on the first frame bounds when the
+// logical screen is empty.
+func GIFCanvasSize(g *gif.GIF) (width, height int) {
+	width, height = g.Config.Width, g.Config.Height
+	if (width == 0 || height == 0) && len(g.Image) > 0 {
+		width, height = g.Image[0].Bounds().Max.X, g.Image[0].Bounds().Max.Y
+	}
+	return width, height
+}
@@ -0,0 +1,70 @@
+// Command-line parsing shared by the tools, which take their arguments in
+// the order libwebp's examples do: flags and files mixed, and some flags
+// followed by several space-separated values.
+package cliutil
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Splits 'args' into flags and positional arguments, which may appear
+// anywhere. 'multiValue' maps the names of the flags taking several values
+// to their count; the values are joined by commas, for ParseInts.
+func SplitArgs(fs *flag.FlagSet, args []string, multiValue map[string]int) (flags, positional []string, err error) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			positional = append(positional, args[i+1:]...)
+			break
+		}
+		if len(arg) < 2 || arg[0] != '-' {
+			positional = append(positional, arg)
+			continue
+		}
+		name := strings.TrimLeft(arg, "-")
+		if strings.Contains(name, "=") {
+			flags = append(flags, arg)
+			continue
+		}
+		if n, ok := multiValue[name]; ok {
+			if i+n >= len(args) {
+				return nil, nil, fmt.Errorf("-%s expects %d values", name, n)
+			}
+			flags = append(flags, arg, strings.Join(args[i+1:i+1+n], ","))
+			i += n
+			continue
+		}
+		flags = append(flags, arg)
+		f := fs.Lookup(name)
+		if f == nil {
+			continue // reported by Parse
+		}
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			continue
+		}
+		if i+1 < len(args) {
+			i++
+			flags = append(flags, args[i])
+		}
+	}
+	return flags, positional, nil
+}
+
+// Parses the 'n' comma-separated integers of 's'.
+func ParseInts(s string, n int) ([]int, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != n {
+		return nil, fmt.Errorf("expected %d values, got %q", n, s)
+	}
+	v := make([]int, n)
+	for i, p := range parts {
+		var err error
+		if v[i], err = strconv.Atoi(strings.TrimSpace(p)); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
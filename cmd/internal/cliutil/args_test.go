@@ -0,0 +1,35 @@
+package cliutil
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitArgs(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Bool("v", false, "")
+	fs.Int("q", 0, "")
+	fs.String("crop", "", "")
+	multiValue := map[string]int{"crop": 4}
+
+	flags, positional, err := SplitArgs(fs, []string{"in.png", "-v", "-q", "80", "-crop", "1", "2", "3", "4", "-o=out.webp", "-unknown", "--", "-x"}, multiValue)
+	require.NoError(t, err)
+	require.Equal(t, []string{"-v", "-q", "80", "-crop", "1,2,3,4", "-o=out.webp", "-unknown"}, flags)
+	require.Equal(t, []string{"in.png", "-x"}, positional)
+
+	_, _, err = SplitArgs(fs, []string{"-crop", "1", "2"}, multiValue)
+	require.Error(t, err)
+}
+
+func TestParseInts(t *testing.T) {
+	v, err := ParseInts("1, 2,-3", 3)
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 2, -3}, v)
+
+	for _, s := range []string{"1,2", "1,2,3,4", "1,x,3"} {
+		_, err := ParseInts(s, 3)
+		require.Error(t, err, s)
+	}
+}
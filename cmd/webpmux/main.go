@@ -0,0 +1,658 @@
+// Command webpmux manipulates the chunks and frames of WebP files, like
+// libwebp's webpmux.
+//
+// Usage:
+//
+//	webpmux -get GET_OPTIONS INPUT -o OUTPUT
+//	webpmux -set SET_OPTIONS INPUT -o OUTPUT
+//	webpmux -duration DURATION_OPTIONS [-duration ...] INPUT -o OUTPUT
+//	webpmux -strip STRIP_OPTIONS INPUT -o OUTPUT
+//	webpmux -frame FRAME_OPTIONS [-frame ...] [-loop LOOP_COUNT]
+//	        [-bgcolor BACKGROUND_COLOR] -o OUTPUT
+//	webpmux -info INPUT
+//
+// GET_OPTIONS:
+//
+//	icc, exif, xmp    get the ICC profile, EXIF or XMP metadata
+//	frame n           get the nth frame, as a still WebP file
+//
+// SET_OPTIONS:
+//
+//	loop COUNT        set the loop count of an animation
+//	bgcolor A,R,G,B   set the background color of an animation
+//	icc FILE          set the ICC profile
+//	exif FILE         set the EXIF metadata
+//	xmp FILE          set the XMP metadata
+//
+// STRIP_OPTIONS: icc, exif, xmp.
+//
+// DURATION_OPTIONS: 'duration' for all frames, 'duration,frame' for one,
+// 'duration,start,end' for a range of frames (1-based, inclusive).
+//
+// FRAME_OPTIONS: 'file +d[+x+y[+m[b]]]' where d is the duration in
+// milliseconds, x and y the offsets, m the dispose method (0: none,
+// 1: background) and b the blend method ('+b': blend, '-b': do not blend).
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/daanv2/go-webp/pkg/libwebp/decoder"
+	"github.com/daanv2/go-webp/pkg/libwebp/mux"
+	libwebp "github.com/daanv2/go-webp/pkg/libwebp/webp"
+)
+
+type action int
+
+const (
+	NIL_ACTION action = iota
+	ACTION_GET
+	ACTION_SET
+	ACTION_STRIP
+	ACTION_INFO
+	ACTION_FRAMES
+	ACTION_DURATION
+)
+
+// Fourcc of the metadata chunks, by option name.
+var kMetadataChunks = map[string]string{
+	"icc":  "ICCP",
+	"exif": "EXIF",
+	"xmp":  "XMP ",
+}
+
+// +d[+x+y[+m[b]]]
+var kFrameOptions = regexp.MustCompile(`^\+(\d+)(?:\+(\d+)\+(\d+)(?:\+([01])([+-]b)?)?)?$`)
+
+type frameArg struct {
+	file  string
+	frame mux.MuxFrame
+}
+
+type durationArg struct {
+	duration, start, end int // end = 0: up to the last frame
+}
+
+type config struct {
+	action    action
+	input     string
+	output    string
+	feature   string // icc, exif, xmp, frame, loop or bgcolor
+	arg       string // frame number, loop count, color or file
+	strip     []string
+	frames    []frameArg
+	durations []durationArg
+	loop      int
+	bgcolor   uint32
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error! %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func printHelp(w io.Writer) {
+	fmt.Fprintf(w, `Usage: webpmux -get GET_OPTIONS INPUT -o OUTPUT
+       webpmux -set SET_OPTIONS INPUT -o OUTPUT
+       webpmux -duration DURATION_OPTIONS [-duration ...] INPUT -o OUTPUT
+       webpmux -strip STRIP_OPTIONS INPUT -o OUTPUT
+       webpmux -frame FRAME_OPTIONS [-frame ...] [-loop LOOP_COUNT]
+               [-bgcolor BACKGROUND_COLOR] -o OUTPUT
+       webpmux -info INPUT
+       webpmux [-h|-help]
+
+GET_OPTIONS:
+ Extract relevant data:
+   icc       get ICC profile
+   exif      get EXIF metadata
+   xmp       get XMP metadata
+   frame n   get nth frame
+
+SET_OPTIONS:
+ Set color profile/metadata/parameters:
+   loop LOOP_COUNT            set the loop count
+   bgcolor BACKGROUND_COLOR   set the animation background color
+   icc  file.icc              set ICC profile
+   exif file.exif             set EXIF metadata
+   xmp  file.xmp              set XMP metadata
+
+STRIP_OPTIONS:
+ Strip color profile/metadata:
+   icc       strip ICC profile
+   exif      strip EXIF metadata
+   xmp       strip XMP metadata
+
+DURATION_OPTIONS:
+ Set duration of selected frames:
+   duration            set duration for all frames
+   duration,frame      set duration of a particular frame
+   duration,start,end  set duration of frames in the
+                        interval [start,end])
+
+FRAME_OPTIONS(i):
+ Create animation:
+   file_i +di[+xi+yi[+mi[bi]]]
+   where:    'file_i' is the i'th animation frame (WebP format),
+             'di' is the pause duration before next frame,
+             'xi','yi' specify the image offset for this frame,
+             'mi' is the dispose method for this frame (0 or 1),
+             'bi' is the blending method for this frame (+b or -b)
+
+LOOP_COUNT:
+ Number of times to repeat the animation.
+ Valid range is 0 to 65535 [Default: 0 (infinite)].
+
+BACKGROUND_COLOR:
+ Background color of the canvas.
+  A,R,G,B
+  where:    'A', 'R', 'G' and 'B' are integers in the range 0 to 255 specifying
+            the Alpha, Red, Green and Blue component values respectively
+            [Default: 255,255,255,255]
+`)
+}
+
+func muxError(err libwebp.WebPMuxError, what string) error {
+	return fmt.Errorf("%s (%s)", what, mux.MuxErrorString(err))
+}
+
+// Parses "A,R,G,B" into the ANIM chunk layout.
+func parseBgcolor(s string) (uint32, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return 0, fmt.Errorf("invalid background color %q", s)
+	}
+	var c [4]uint32
+	for i, p := range parts {
+		v, err := strconv.ParseUint(strings.TrimSpace(p), 10, 8)
+		if err != nil {
+			return 0, fmt.Errorf("invalid background color %q", s)
+		}
+		c[i] = uint32(v)
+	}
+	a, r, g, b := c[0], c[1], c[2], c[3]
+	return a<<24 | r<<16 | g<<8 | b, nil
+}
+
+func parseLoop(s string) (int, error) {
+	loop, err := strconv.Atoi(s)
+	if err != nil || loop < 0 || loop > 65535 {
+		return 0, fmt.Errorf("invalid loop count %q", s)
+	}
+	return loop, nil
+}
+
+func parseFrameOptions(s string) (mux.MuxFrame, error) {
+	m := kFrameOptions.FindStringSubmatch(s)
+	if m == nil {
+		return mux.MuxFrame{}, fmt.Errorf("invalid frame options %q", s)
+	}
+	frame := mux.MuxFrame{Dispose: libwebp.WEBP_MUX_DISPOSE_NONE, Blend: libwebp.WEBP_MUX_BLEND}
+	frame.Duration, _ = strconv.Atoi(m[1])
+	if m[2] != "" {
+		frame.XOffset, _ = strconv.Atoi(m[2])
+		frame.YOffset, _ = strconv.Atoi(m[3])
+	}
+	if m[4] == "1" {
+		frame.Dispose = libwebp.WEBP_MUX_DISPOSE_BACKGROUND
+	}
+	if m[5] == "-b" {
+		frame.Blend = libwebp.WEBP_MUX_NO_BLEND
+	}
+	return frame, nil
+}
+
+func parseDuration(s string) (durationArg, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) > 3 {
+		return durationArg{}, fmt.Errorf("invalid duration options %q", s)
+	}
+	v := make([]int, len(parts))
+	for i, p := range parts {
+		var err error
+		if v[i], err = strconv.Atoi(strings.TrimSpace(p)); err != nil || v[i] < 0 {
+			return durationArg{}, fmt.Errorf("invalid duration options %q", s)
+		}
+	}
+	d := durationArg{duration: v[0], start: 1}
+	switch len(v) {
+	case 2:
+		d.start, d.end = v[1], v[1]
+	case 3:
+		d.start, d.end = v[1], v[2]
+	}
+	if d.start < 1 || (d.end != 0 && d.end < d.start) {
+		return durationArg{}, fmt.Errorf("invalid frame range in %q", s)
+	}
+	return d, nil
+}
+
+func setAction(cfg *config, a action) error {
+	if cfg.action != NIL_ACTION && cfg.action != a {
+		return errors.New("multiple actions specified")
+	}
+	cfg.action = a
+	return nil
+}
+
+func parseCommandLine(args []string, cfg *config) error {
+	next := func(i *int, what string) (string, error) {
+		if *i+1 >= len(args) {
+			return "", fmt.Errorf("missing %s", what)
+		}
+		*i++
+		return args[*i], nil
+	}
+	for i := 0; i < len(args); i++ {
+		var err error
+		var v string
+		switch args[i] {
+		case "-h", "-help", "--help":
+			printHelp(os.Stdout)
+			os.Exit(0)
+		case "-o":
+			cfg.output, err = next(&i, "output file")
+		case "-info":
+			err = setAction(cfg, ACTION_INFO)
+		case "-get":
+			if err = setAction(cfg, ACTION_GET); err != nil {
+				break
+			}
+			if cfg.feature, err = next(&i, "GET_OPTIONS"); err != nil {
+				break
+			}
+			if cfg.feature == "frame" {
+				cfg.arg, err = next(&i, "frame number")
+			} else if _, ok := kMetadataChunks[cfg.feature]; !ok {
+				err = fmt.Errorf("invalid GET_OPTIONS %q", cfg.feature)
+			}
+		case "-set":
+			if err = setAction(cfg, ACTION_SET); err != nil {
+				break
+			}
+			if cfg.feature, err = next(&i, "SET_OPTIONS"); err != nil {
+				break
+			}
+			_, isMetadata := kMetadataChunks[cfg.feature]
+			if !isMetadata && cfg.feature != "loop" && cfg.feature != "bgcolor" {
+				err = fmt.Errorf("invalid SET_OPTIONS %q", cfg.feature)
+				break
+			}
+			cfg.arg, err = next(&i, "value for -set "+cfg.feature)
+		case "-strip":
+			if err = setAction(cfg, ACTION_STRIP); err != nil {
+				break
+			}
+			if v, err = next(&i, "STRIP_OPTIONS"); err != nil {
+				break
+			}
+			if _, ok := kMetadataChunks[v]; !ok {
+				err = fmt.Errorf("invalid STRIP_OPTIONS %q", v)
+				break
+			}
+			cfg.strip = append(cfg.strip, v)
+		case "-duration":
+			if err = setAction(cfg, ACTION_DURATION); err != nil {
+				break
+			}
+			if v, err = next(&i, "DURATION_OPTIONS"); err != nil {
+				break
+			}
+			var d durationArg
+			if d, err = parseDuration(v); err == nil {
+				cfg.durations = append(cfg.durations, d)
+			}
+		case "-frame":
+			if err = setAction(cfg, ACTION_FRAMES); err != nil {
+				break
+			}
+			var f frameArg
+			if f.file, err = next(&i, "frame file"); err != nil {
+				break
+			}
+			if v, err = next(&i, "FRAME_OPTIONS"); err != nil {
+				break
+			}
+			if f.frame, err = parseFrameOptions(v); err == nil {
+				cfg.frames = append(cfg.frames, f)
+			}
+		case "-loop":
+			if v, err = next(&i, "LOOP_COUNT"); err == nil {
+				cfg.loop, err = parseLoop(v)
+			}
+		case "-bgcolor":
+			if v, err = next(&i, "BACKGROUND_COLOR"); err == nil {
+				cfg.bgcolor, err = parseBgcolor(v)
+			}
+		default:
+			if strings.HasPrefix(args[i], "-") {
+				err = fmt.Errorf("unknown option %s", args[i])
+			} else if cfg.input != "" {
+				err = fmt.Errorf("multiple input files specified (%s, %s)", cfg.input, args[i])
+			} else {
+				cfg.input = args[i]
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return validateConfig(cfg)
+}
+
+func validateConfig(cfg *config) error {
+	switch cfg.action {
+	case NIL_ACTION:
+		return errors.New("no action specified")
+	case ACTION_FRAMES:
+		if cfg.input != "" {
+			return errors.New("-frame does not take an input file")
+		}
+	default:
+		if cfg.input == "" {
+			return errors.New("no input file specified")
+		}
+	}
+	if cfg.action != ACTION_INFO && cfg.output == "" {
+		return errors.New("no output file specified (use -o)")
+	}
+	return nil
+}
+
+func run(args []string) error {
+	if len(args) == 0 {
+		printHelp(os.Stderr)
+		return errors.New("no arguments given")
+	}
+	cfg := config{bgcolor: 0xffffffff}
+	if err := parseCommandLine(args, &cfg); err != nil {
+		return err
+	}
+	if cfg.action == ACTION_FRAMES {
+		return assembleFrames(&cfg)
+	}
+
+	data, err := os.ReadFile(cfg.input)
+	if err != nil {
+		return fmt.Errorf("cannot read input file %s: %w", cfg.input, err)
+	}
+	m, merr := mux.MuxCreate(data)
+	if merr != libwebp.WEBP_MUX_OK {
+		return muxError(merr, "could not create mux object from "+cfg.input)
+	}
+
+	switch cfg.action {
+	case ACTION_INFO:
+		return displayInfo(os.Stdout, m)
+	case ACTION_GET:
+		return getData(&cfg, m)
+	case ACTION_SET:
+		if err := setData(&cfg, m); err != nil {
+			return err
+		}
+	case ACTION_STRIP:
+		for _, s := range cfg.strip {
+			if merr := m.DeleteChunk(kMetadataChunks[s]); merr != libwebp.WEBP_MUX_OK && merr != libwebp.WEBP_MUX_NOT_FOUND {
+				return muxError(merr, "could not strip "+s)
+			}
+		}
+	case ACTION_DURATION:
+		if err := setDurations(&cfg, m); err != nil {
+			return err
+		}
+	}
+	return writeMux(m, cfg.output)
+}
+
+func writeMux(m *mux.WebPMux, file string) error {
+	out, merr := m.Assemble()
+	if merr != libwebp.WEBP_MUX_OK {
+		return muxError(merr, "could not assemble WebP file")
+	}
+	if err := os.WriteFile(file, out, 0o644); err != nil {
+		return fmt.Errorf("cannot write output file %s: %w", file, err)
+	}
+	fmt.Fprintf(os.Stderr, "Saved file %s (%d bytes)\n", file, len(out))
+	return nil
+}
+
+func getData(cfg *config, m *mux.WebPMux) error {
+	if cfg.feature != "frame" {
+		data, merr := m.GetChunk(kMetadataChunks[cfg.feature])
+		if merr != libwebp.WEBP_MUX_OK {
+			return muxError(merr, "could not get "+cfg.feature)
+		}
+		if err := os.WriteFile(cfg.output, data, 0o644); err != nil {
+			return fmt.Errorf("cannot write output file %s: %w", cfg.output, err)
+		}
+		fmt.Fprintf(os.Stderr, "Saved file %s (%d bytes)\n", cfg.output, len(data))
+		return nil
+	}
+	nth, err := strconv.Atoi(cfg.arg)
+	if err != nil || nth < 0 {
+		return fmt.Errorf("invalid frame number %q", cfg.arg)
+	}
+	frame, merr := m.GetFrame(nth)
+	if merr != libwebp.WEBP_MUX_OK {
+		return muxError(merr, "could not get frame "+cfg.arg)
+	}
+	single, merr := mux.MuxCreate(nil)
+	if merr != libwebp.WEBP_MUX_OK {
+		return muxError(merr, "could not create mux object")
+	}
+	if merr := single.SetImage(frame.Bitstream); merr != libwebp.WEBP_MUX_OK {
+		return muxError(merr, "could not set image")
+	}
+	return writeMux(single, cfg.output)
+}
+
+func setData(cfg *config, m *mux.WebPMux) error {
+	switch cfg.feature {
+	case "loop", "bgcolor":
+		params, merr := m.GetAnimationParams()
+		if merr != libwebp.WEBP_MUX_OK {
+			return muxError(merr, "the input is not an animation")
+		}
+		var err error
+		if cfg.feature == "loop" {
+			params.LoopCount, err = parseLoop(cfg.arg)
+		} else {
+			params.BgColor, err = parseBgcolor(cfg.arg)
+		}
+		if err != nil {
+			return err
+		}
+		if merr := m.SetAnimationParams(params); merr != libwebp.WEBP_MUX_OK {
+			return muxError(merr, "could not set animation parameters")
+		}
+	default:
+		data, err := os.ReadFile(cfg.arg)
+		if err != nil {
+			return fmt.Errorf("cannot read %s: %w", cfg.arg, err)
+		}
+		if merr := m.SetChunk(kMetadataChunks[cfg.feature], data); merr != libwebp.WEBP_MUX_OK {
+			return muxError(merr, "could not set "+cfg.feature)
+		}
+	}
+	return nil
+}
+
+// Rebuilds the frame list with the new durations; later -duration options
+// override earlier ones.
+func setDurations(cfg *config, m *mux.WebPMux) error {
+	num_frames, merr := m.NumFrames()
+	if merr != libwebp.WEBP_MUX_OK {
+		return muxError(merr, "could not count frames")
+	}
+	if _, merr := m.GetAnimationParams(); merr != libwebp.WEBP_MUX_OK {
+		return muxError(merr, "the input is not an animation")
+	}
+	frames := make([]mux.MuxFrame, num_frames)
+	for i := range frames {
+		if frames[i], merr = m.GetFrame(i + 1); merr != libwebp.WEBP_MUX_OK {
+			return muxError(merr, fmt.Sprintf("could not get frame %d", i+1))
+		}
+	}
+	for _, d := range cfg.durations {
+		end := d.end
+		if end == 0 || end > num_frames {
+			end = num_frames
+		}
+		for n := d.start; n <= end; n++ {
+			frames[n-1].Duration = d.duration
+		}
+	}
+	for i := 0; i < num_frames; i++ {
+		if merr := m.DeleteFrame(1); merr != libwebp.WEBP_MUX_OK {
+			return muxError(merr, "could not delete frame")
+		}
+	}
+	for i := range frames {
+		if merr := m.PushFrame(&frames[i]); merr != libwebp.WEBP_MUX_OK {
+			return muxError(merr, fmt.Sprintf("could not add frame %d", i+1))
+		}
+	}
+	return nil
+}
+
+func assembleFrames(cfg *config) error {
+	m, merr := mux.MuxCreate(nil)
+	if merr != libwebp.WEBP_MUX_OK {
+		return muxError(merr, "could not create mux object")
+	}
+	for i := range cfg.frames {
+		f := &cfg.frames[i]
+		data, err := os.ReadFile(f.file)
+		if err != nil {
+			return fmt.Errorf("cannot read frame file %s: %w", f.file, err)
+		}
+		f.frame.Bitstream = data
+		if merr := m.PushFrame(&f.frame); merr != libwebp.WEBP_MUX_OK {
+			return muxError(merr, "could not add frame from "+f.file)
+		}
+	}
+	params := mux.MuxAnimParams{BgColor: cfg.bgcolor, LoopCount: cfg.loop}
+	if merr := m.SetAnimationParams(params); merr != libwebp.WEBP_MUX_OK {
+		return muxError(merr, "could not set animation parameters")
+	}
+	return writeMux(m, cfg.output)
+}
+
+func displayInfo(w io.Writer, m *mux.WebPMux) error {
+	width, height, merr := m.GetCanvasSize()
+	if merr != libwebp.WEBP_MUX_OK {
+		return muxError(merr, "could not get canvas size")
+	}
+	flags, merr := m.GetFeatures()
+	if merr != libwebp.WEBP_MUX_OK {
+		return muxError(merr, "could not get features")
+	}
+	fmt.Fprintf(w, "Canvas size: %d x %d\n", width, height)
+
+	if flags&uint32(libwebp.ALL_VALID_FLAGS) == 0 {
+		fmt.Fprintf(w, "No features present.\n")
+	} else {
+		fmt.Fprintf(w, "Features present:")
+		if flags&uint32(libwebp.ANIMATION_FLAG) != 0 {
+			fmt.Fprintf(w, " animation")
+		}
+		if flags&uint32(libwebp.ICCP_FLAG) != 0 {
+			fmt.Fprintf(w, " ICC profile")
+		}
+		if flags&uint32(libwebp.EXIF_FLAG) != 0 {
+			fmt.Fprintf(w, " EXIF metadata")
+		}
+		if flags&uint32(libwebp.XMP_FLAG) != 0 {
+			fmt.Fprintf(w, " XMP metadata")
+		}
+		if flags&uint32(libwebp.ALPHA_FLAG) != 0 {
+			fmt.Fprintf(w, " transparency")
+		}
+		fmt.Fprintf(w, "\n")
+	}
+
+	if flags&uint32(libwebp.ANIMATION_FLAG) != 0 {
+		params, merr := m.GetAnimationParams()
+		if merr != libwebp.WEBP_MUX_OK {
+			return muxError(merr, "could not get animation parameters")
+		}
+		fmt.Fprintf(w, "Background color : 0x%.8X  Loop Count : %d\n", params.BgColor, params.LoopCount)
+
+		num_frames, merr := m.NumFrames()
+		if merr != libwebp.WEBP_MUX_OK {
+			return muxError(merr, "could not count frames")
+		}
+		fmt.Fprintf(w, "Number of frames: %d\n", num_frames)
+		fmt.Fprintf(w, "No.: width height alpha x_offset y_offset duration   dispose blend image_size  compression\n")
+		for i := 1; i <= num_frames; i++ {
+			frame, merr := m.GetFrame(i)
+			if merr != libwebp.WEBP_MUX_OK {
+				return muxError(merr, fmt.Sprintf("could not get frame %d", i))
+			}
+			info, _ := decoder.GetImageInfo(frame.Bitstream)
+			alpha, dispose, blend := "no", "none", "yes"
+			if info.HasAlpha {
+				alpha = "yes"
+			}
+			if frame.Dispose == libwebp.WEBP_MUX_DISPOSE_BACKGROUND {
+				dispose = "background"
+			}
+			if frame.Blend == libwebp.WEBP_MUX_NO_BLEND {
+				blend = "no"
+			}
+			fmt.Fprintf(w, "%3d: %5d %5d %5s %8d %8d %8d %10s %5s %10d %11s\n",
+				i, info.Width, info.Height, alpha, frame.XOffset, frame.YOffset,
+				frame.Duration, dispose, blend, len(frame.Bitstream), compression(info.Format))
+		}
+	}
+
+	for _, c := range []struct {
+		flag         libwebp.WebPFeatureFlags
+		fourcc, name string
+	}{
+		{libwebp.ICCP_FLAG, "ICCP", "ICC profile"},
+		{libwebp.EXIF_FLAG, "EXIF", "EXIF metadata"},
+		{libwebp.XMP_FLAG, "XMP ", "XMP metadata"},
+	} {
+		if flags&uint32(c.flag) == 0 {
+			continue
+		}
+		data, merr := m.GetChunk(c.fourcc)
+		if merr != libwebp.WEBP_MUX_OK {
+			return muxError(merr, "could not get the "+c.name)
+		}
+		fmt.Fprintf(w, "Size of the %s data: %d\n", c.name, len(data))
+	}
+
+	if flags&uint32(libwebp.ANIMATION_FLAG) == 0 {
+		frame, merr := m.GetFrame(1)
+		if merr != libwebp.WEBP_MUX_OK {
+			return muxError(merr, "could not get the image")
+		}
+		info, _ := decoder.GetImageInfo(frame.Bitstream)
+		if info.HasAlpha {
+			fmt.Fprintf(w, "Size of the image (with alpha): %d\n", len(frame.Bitstream))
+		} else {
+			fmt.Fprintf(w, "Size of the image: %d\n", len(frame.Bitstream))
+		}
+		fmt.Fprintf(w, "Compression: %s\n", compression(info.Format))
+	}
+	return nil
+}
+
+func compression(format int) string {
+	switch format {
+	case 1:
+		return "lossy"
+	case 2:
+		return "lossless"
+	}
+	return "mixed"
+}
@@ -1,14 +1,135 @@
 package webp
 
 import (
+	"encoding/binary"
+	"errors"
 	"image"
+	"image/color"
 	"io"
+
+	"github.com/daanv2/go-webp/pkg/constants"
+	"github.com/daanv2/go-webp/pkg/libwebp/decoder"
+	libwebp "github.com/daanv2/go-webp/pkg/libwebp/webp"
 )
 
+// Decode decodes the still WebP image read from 'r'. Lossy images without
+// alpha are returned as an *image.YCbCr, the others as an *image.NRGBA.
 func Decode(r io.Reader) (image.Image, error) {
-	panic("unimplemented")
+	return (*DecoderOptions)(nil).Decode(r)
+}
+
+// Decode is like the package-level Decode, using the options in 'o'.
+func (o *DecoderOptions) Decode(r io.Reader) (image.Image, error) {
+	if r == nil {
+		return nil, ErrInvalidParam
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	info, status := decoder.GetImageInfo(data)
+	if err := statusError(status); err != nil {
+		return nil, err
+	}
+	if info.Format == 1 && !info.HasAlpha {
+		return o.DecodeYCbCr(data)
+	}
+	opts := o.decodeOptions()
+	if err := checkLimits(info, libwebp.MODE_RGBA, opts); err != nil {
+		return nil, err
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, info.Width, info.Height))
+	if err := decodeInto(data, img, opts); err != nil {
+		return nil, err
+	}
+	return img, nil
 }
 
+// DecodeConfig returns the dimensions of the WebP image read from 'r' and the
+// color model Decode would return it in, without decoding its pixels.
 func DecodeConfig(r io.Reader) (image.Config, error) {
-	panic("unimplemented")
+	return (*DecoderOptions)(nil).DecodeConfig(r)
+}
+
+// DecodeConfig is like the package-level DecodeConfig, using the options in
+// 'o'. It returns ErrLimitExceeded if decoding the image with Decode would
+// exceed the limits of 'o'.
+func (o *DecoderOptions) DecodeConfig(r io.Reader) (image.Config, error) {
+	if r == nil {
+		return image.Config{}, ErrInvalidParam
+	}
+	data, err := readHeaders(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	info, status := decoder.GetImageInfo(data)
+	if err := statusError(status); err != nil {
+		return image.Config{}, err
+	}
+	model, mode := color.Model(color.NRGBAModel), libwebp.MODE_RGBA
+	if info.Format == 1 && !info.HasAlpha {
+		model, mode = color.YCbCrModel, libwebp.MODE_YUV
+	}
+	if err := checkLimits(info, mode, o.decodeOptions()); err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{ColorModel: model, Width: info.Width, Height: info.Height}, nil
+}
+
+// readHeaders reads from 'r' the headers GetImageInfo needs: the RIFF header,
+// the VP8X chunk if any, and the frame header starting the VP8 or VP8L chunk.
+// The chunks in between, such as ICCP or ALPH, are skipped without being
+// buffered; an ALPH chunk sets the alpha flag of the VP8X copy, as it makes
+// the decoder output alpha. Truncated headers are returned as read, for
+// GetImageInfo to report.
+func readHeaders(r io.Reader) ([]byte, error) {
+	data, err := appendRead(r, nil, constants.RIFF_HEADER_SIZE+constants.CHUNK_HEADER_SIZE)
+	if err != nil || len(data) < cap(data) || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return data, err
+	}
+	if string(data[constants.RIFF_HEADER_SIZE:][:4]) == "VP8X" {
+		const flagsOffset = constants.RIFF_HEADER_SIZE + constants.CHUNK_HEADER_SIZE
+		if data, err = appendRead(r, data, constants.VP8X_CHUNK_SIZE); err != nil || len(data) < cap(data) {
+			return data, err
+		}
+		if libwebp.WebPFeatureFlags(data[flagsOffset])&libwebp.ANIMATION_FLAG != 0 {
+			return data, nil // the frames are not looked at
+		}
+		for {
+			var chunk []byte
+			if chunk, err = appendRead(r, nil, constants.CHUNK_HEADER_SIZE); err != nil || len(chunk) < cap(chunk) {
+				return data, err
+			}
+			tag := string(chunk[:4])
+			if tag == "VP8 " || tag == "VP8L" {
+				data = append(data, chunk...)
+				break
+			}
+			if tag == "ALPH" {
+				data[flagsOffset] |= byte(libwebp.ALPHA_FLAG)
+			}
+			size := int64(binary.LittleEndian.Uint32(chunk[4:]))
+			size += size & 1 // padding
+			if _, err := io.CopyN(io.Discard, r, size); err != nil {
+				if errors.Is(err, io.EOF) {
+					return data, nil // truncated
+				}
+				return nil, err
+			}
+		}
+	}
+	return appendRead(r, data, constants.VP8_FRAME_HEADER_SIZE)
+}
+
+// appendRead appends up to 'n' bytes read from 'r' to 'data', with a capacity
+// of exactly len(data) + n so that a short read shows as len < cap. Running
+// out of data is not an error.
+func appendRead(r io.Reader, data []byte, n int) ([]byte, error) {
+	out := make([]byte, len(data)+n)
+	copy(out, data)
+	m, err := io.ReadFull(r, out[len(data):])
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		err = nil
+	}
+	return out[:len(data)+m], err
 }
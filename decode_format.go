@@ -0,0 +1,142 @@
+package webp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/daanv2/go-webp/pkg/libwebp/decoder"
+	libwebp "github.com/daanv2/go-webp/pkg/libwebp/webp"
+)
+
+// PixelFormat is the sample layout produced by DecodeToFormat.
+type PixelFormat int
+
+const (
+	PixelFormatRGB            PixelFormat = iota // R, G, B
+	PixelFormatRGBA                              // R, G, B, A
+	PixelFormatBGR                               // B, G, R
+	PixelFormatBGRA                              // B, G, R, A
+	PixelFormatARGB                              // A, R, G, B
+	PixelFormatRGBA4444                          // [r4 g4], [b4 a4]
+	PixelFormatRGB565                            // [r5 g3], [g3 b5]
+	PixelFormatRGBAPremul                        // R, G, B, A with premultiplied R, G, B
+	PixelFormatBGRAPremul                        // B, G, R, A with premultiplied B, G, R
+	PixelFormatARGBPremul                        // A, R, G, B with premultiplied R, G, B
+	PixelFormatRGBA4444Premul                    // [r4 g4], [b4 a4] with premultiplied r, g, b
+)
+
+var pixelFormatModes = [...]libwebp.WEBP_CSP_MODE{
+	PixelFormatRGB:            libwebp.MODE_RGB,
+	PixelFormatRGBA:           libwebp.MODE_RGBA,
+	PixelFormatBGR:            libwebp.MODE_BGR,
+	PixelFormatBGRA:           libwebp.MODE_BGRA,
+	PixelFormatARGB:           libwebp.MODE_ARGB,
+	PixelFormatRGBA4444:       libwebp.MODE_RGBA_4444,
+	PixelFormatRGB565:         libwebp.MODE_RGB_565,
+	PixelFormatRGBAPremul:     libwebp.MODE_rgbA,
+	PixelFormatBGRAPremul:     libwebp.MODE_bgrA,
+	PixelFormatARGBPremul:     libwebp.MODE_Argb,
+	PixelFormatRGBA4444Premul: libwebp.MODE_rgbA_4444,
+}
+
+var pixelFormatNames = [...]string{
+	"RGB", "RGBA", "BGR", "BGRA", "ARGB", "RGBA4444", "RGB565",
+	"RGBAPremul", "BGRAPremul", "ARGBPremul", "RGBA4444Premul",
+}
+
+func (f PixelFormat) valid() bool {
+	return f >= 0 && int(f) < len(pixelFormatModes)
+}
+
+func (f PixelFormat) String() string {
+	if !f.valid() {
+		return fmt.Sprintf("PixelFormat(%d)", int(f))
+	}
+	return pixelFormatNames[f]
+}
+
+// BytesPerPixel returns the size of one pixel, 0 for an unknown format.
+func (f PixelFormat) BytesPerPixel() int {
+	switch {
+	case !f.valid():
+		return 0
+	case f == PixelFormatRGB || f == PixelFormatBGR:
+		return 3
+	case f == PixelFormatRGBA4444 || f == PixelFormatRGB565 || f == PixelFormatRGBA4444Premul:
+		return 2
+	default:
+		return 4
+	}
+}
+
+// Premultiplied reports whether the colour samples are premultiplied by alpha.
+func (f PixelFormat) Premultiplied() bool {
+	return f.valid() && libwebp.WebPIsPremultipliedMode(pixelFormatModes[f])
+}
+
+// PixelBuffer holds an image decoded by DecodeToFormat. Row y starts at
+// Pix[y*Stride].
+type PixelBuffer struct {
+	Pix           []byte
+	Stride        int
+	Width, Height int
+	Format        PixelFormat
+}
+
+// DecodeToFormat decodes the image read from 'r' to a tightly packed buffer
+// of the given format. Images without alpha get an opaque alpha channel in
+// the formats that have one. 16-bit formats are stored with the most
+// significant byte first.
+func DecodeToFormat(r io.Reader, format PixelFormat) (*PixelBuffer, error) {
+	return (*DecoderOptions)(nil).DecodeToFormat(r, format)
+}
+
+// DecodeToFormat is like the package-level DecodeToFormat, using the options
+// in 'o'.
+func (o *DecoderOptions) DecodeToFormat(r io.Reader, format PixelFormat) (*PixelBuffer, error) {
+	if r == nil || !format.valid() {
+		return nil, ErrInvalidParam
+	}
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(r); err != nil {
+		return nil, err
+	}
+	data := buf.Bytes()
+	info, status := decoder.GetImageInfo(data)
+	if err := statusError(status); err != nil {
+		return nil, err
+	}
+	if err := checkLimits(info, pixelFormatModes[format], o.decodeOptions()); err != nil {
+		return nil, err
+	}
+	stride := info.Width * format.BytesPerPixel()
+	out := &PixelBuffer{
+		Pix:    make([]byte, stride*info.Height),
+		Stride: stride,
+		Width:  info.Width,
+		Height: info.Height,
+		Format: format,
+	}
+	if err := o.DecodeFormatInto(data, format, out.Pix, out.Stride); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DecodeFormatInto decodes 'data' into 'dst' with the given format, rows being
+// 'stride' bytes apart. 'dst' must hold at least
+// (height-1)*stride + width*format.BytesPerPixel() bytes. Nothing is allocated
+// for the output.
+func DecodeFormatInto(data []byte, format PixelFormat, dst []byte, stride int) error {
+	return (*DecoderOptions)(nil).DecodeFormatInto(data, format, dst, stride)
+}
+
+// DecodeFormatInto is like the package-level DecodeFormatInto, using the
+// options in 'o'.
+func (o *DecoderOptions) DecodeFormatInto(data []byte, format PixelFormat, dst []byte, stride int) error {
+	if !format.valid() {
+		return ErrInvalidParam
+	}
+	return statusError(decoder.WebPDecodeIntoRGBABufferOptions(pixelFormatModes[format], data, dst, stride, o.decodeOptions()))
+}
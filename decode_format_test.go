@@ -0,0 +1,113 @@
+package webp_test
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	"github.com/daanv2/go-webp"
+	"github.com/stretchr/testify/require"
+)
+
+// premultiply scales the 'bits'-bit sample 'v' by the 'bits'-bit alpha 'a'.
+func premultiply(v, a uint8, bits uint) int {
+	top := 1<<bits - 1
+	return (int(v)*int(a) + top/2) / top
+}
+
+// requirePixel checks the 'format' pixel 'got' against the non-premultiplied
+// RGBA pixel 'rgba'. Premultiplied samples may be off by one.
+func requirePixel(t *testing.T, format webp.PixelFormat, rgba, got []byte, msg string) {
+	t.Helper()
+
+	r, g, b, a := rgba[0], rgba[1], rgba[2], rgba[3]
+	near := func(want int, got uint8, name string) {
+		require.InDelta(t, want, int(got), 1, "%s: %s of %v in %v", msg, name, got, format)
+	}
+	switch format {
+	case webp.PixelFormatRGB:
+		require.Equal(t, []byte{r, g, b}, got, msg)
+	case webp.PixelFormatRGBA:
+		require.Equal(t, []byte{r, g, b, a}, got, msg)
+	case webp.PixelFormatBGR:
+		require.Equal(t, []byte{b, g, r}, got, msg)
+	case webp.PixelFormatBGRA:
+		require.Equal(t, []byte{b, g, r, a}, got, msg)
+	case webp.PixelFormatARGB:
+		require.Equal(t, []byte{a, r, g, b}, got, msg)
+	case webp.PixelFormatRGBA4444:
+		require.Equal(t, []byte{r&0xf0 | g>>4, b&0xf0 | a>>4}, got, msg)
+	case webp.PixelFormatRGB565:
+		require.Equal(t, []byte{r&0xf8 | g>>5, g<<3&0xe0 | b>>3}, got, msg)
+	case webp.PixelFormatRGBAPremul:
+		near(premultiply(r, a, 8), got[0], "R")
+		near(premultiply(g, a, 8), got[1], "G")
+		near(premultiply(b, a, 8), got[2], "B")
+		require.Equal(t, a, got[3], msg)
+	case webp.PixelFormatBGRAPremul:
+		near(premultiply(b, a, 8), got[0], "B")
+		near(premultiply(g, a, 8), got[1], "G")
+		near(premultiply(r, a, 8), got[2], "R")
+		require.Equal(t, a, got[3], msg)
+	case webp.PixelFormatARGBPremul:
+		require.Equal(t, a, got[0], msg)
+		near(premultiply(r, a, 8), got[1], "R")
+		near(premultiply(g, a, 8), got[2], "G")
+		near(premultiply(b, a, 8), got[3], "B")
+	case webp.PixelFormatRGBA4444Premul:
+		a4 := a >> 4
+		near(premultiply(r>>4, a4, 4), got[0]>>4, "R")
+		near(premultiply(g>>4, a4, 4), got[0]&0x0f, "G")
+		near(premultiply(b>>4, a4, 4), got[1]>>4, "B")
+		require.Equal(t, a4, got[1]&0x0f, msg)
+	default:
+		t.Fatalf("untested format %v", format)
+	}
+}
+
+// Every format holds the samples of the RGBA decode, in its own layout and
+// precision, for lossy images with and without alpha and lossless images.
+func TestDecodeToFormat(t *testing.T) {
+	const width, height = 40, 24
+	for name, data := range map[string][]byte{
+		"lossy":       encode(t, gradient(width, height), nil),
+		"lossy alpha": encode(t, translucent(width, height), nil),
+		"lossless":    encode(t, translucent(width, height), lossless),
+	} {
+		ref, err := webp.DecodeToFormat(bytes.NewReader(data), webp.PixelFormatRGBA)
+		require.NoError(t, err, name)
+		require.Equal(t, width, ref.Width, name)
+		require.Equal(t, height, ref.Height, name)
+
+		for format := webp.PixelFormatRGB; format <= webp.PixelFormatRGBA4444Premul; format++ {
+			bpp := format.BytesPerPixel()
+			out, err := webp.DecodeToFormat(bytes.NewReader(data), format)
+			require.NoError(t, err, "%s %v", name, format)
+			require.Equal(t, width*bpp, out.Stride, "%s %v", name, format)
+
+			// Same pixels with padded rows.
+			stride := width*bpp + 7
+			into := make([]byte, (height-1)*stride+width*bpp)
+			require.NoError(t, webp.DecodeFormatInto(data, format, into, stride), "%s %v", name, format)
+
+			for y := 0; y < height; y++ {
+				for x := 0; x < width; x++ {
+					rgba := ref.Pix[y*ref.Stride+4*x:][:4]
+					msg := name + " pixel " + image.Pt(x, y).String()
+					requirePixel(t, format, rgba, out.Pix[y*out.Stride+bpp*x:][:bpp], msg)
+					requirePixel(t, format, rgba, into[y*stride+bpp*x:][:bpp], msg+" (DecodeFormatInto)")
+				}
+			}
+		}
+	}
+}
+
+func TestDecodeToFormatInvalid(t *testing.T) {
+	data := encode(t, gradient(8, 8), nil)
+	for _, format := range []webp.PixelFormat{-1, webp.PixelFormatRGBA4444Premul + 1} {
+		_, err := webp.DecodeToFormat(bytes.NewReader(data), format)
+		require.ErrorIs(t, err, webp.ErrInvalidParam)
+		require.ErrorIs(t, webp.DecodeFormatInto(data, format, make([]byte, 4*8*8), 4*8), webp.ErrInvalidParam)
+	}
+	require.Zero(t, webp.PixelFormat(-1).BytesPerPixel())
+}
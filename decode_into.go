@@ -0,0 +1,164 @@
+package webp
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"sync"
+
+	"github.com/daanv2/go-webp/pkg/libwebp/decoder"
+	libwebp "github.com/daanv2/go-webp/pkg/libwebp/webp"
+)
+
+// Scratch memory shared by the Into functions, so that steady-state decoding
+// does not allocate.
+var (
+	readBufferPool = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+	pixelPool      = sync.Pool{New: func() any { return new([]byte) }}
+)
+
+// DecodeRGBAInto decodes 'data' as non-premultiplied R, G, B, A samples into
+// 'dst', whose rows are 'stride' bytes apart. 'dst' must hold at least
+// MIN_BUFFER_SIZE(4 * width, height, stride) bytes. Nothing is allocated for
+// the output.
+func DecodeRGBAInto(data []byte, dst []byte, stride int) error {
+	return (*DecoderOptions)(nil).DecodeRGBAInto(data, dst, stride)
+}
+
+// DecodeRGBAInto is like the package-level DecodeRGBAInto, using the options
+// in 'o'.
+func (o *DecoderOptions) DecodeRGBAInto(data []byte, dst []byte, stride int) error {
+	return statusError(decoder.WebPDecodeIntoRGBABufferOptions(libwebp.MODE_RGBA, data, dst, stride, o.decodeOptions()))
+}
+
+// DecodeBGRAInto is like DecodeRGBAInto, with B, G, R, A ordered samples.
+func DecodeBGRAInto(data []byte, dst []byte, stride int) error {
+	return (*DecoderOptions)(nil).DecodeBGRAInto(data, dst, stride)
+}
+
+// DecodeBGRAInto is like the package-level DecodeBGRAInto, using the options
+// in 'o'.
+func (o *DecoderOptions) DecodeBGRAInto(data []byte, dst []byte, stride int) error {
+	return statusError(decoder.WebPDecodeIntoRGBABufferOptions(libwebp.MODE_BGRA, data, dst, stride, o.decodeOptions()))
+}
+
+// DecodeARGBInto is like DecodeRGBAInto, with A, R, G, B ordered samples.
+func DecodeARGBInto(data []byte, dst []byte, stride int) error {
+	return (*DecoderOptions)(nil).DecodeARGBInto(data, dst, stride)
+}
+
+// DecodeARGBInto is like the package-level DecodeARGBInto, using the options
+// in 'o'.
+func (o *DecoderOptions) DecodeARGBInto(data []byte, dst []byte, stride int) error {
+	return statusError(decoder.WebPDecodeIntoRGBABufferOptions(libwebp.MODE_ARGB, data, dst, stride, o.decodeOptions()))
+}
+
+// DecodeInto decodes the image read from 'r' into 'dst', at dst.Bounds().Min.
+// The bounds of 'dst' must be at least as large as the image.
+//
+// *image.RGBA, *image.NRGBA, *image.YCbCr (4:2:0) and *image.NYCbCrA (4:2:0)
+// destinations are decoded into directly, without allocating once the pools
+// are warmed up. Other images must implement draw.Image and go through a
+// pooled scratch buffer and draw.Draw.
+func DecodeInto(r io.Reader, dst image.Image) error {
+	return (*DecoderOptions)(nil).DecodeInto(r, dst)
+}
+
+// DecodeInto is like the package-level DecodeInto, using the options in 'o'.
+func (o *DecoderOptions) DecodeInto(r io.Reader, dst image.Image) error {
+	if r == nil || dst == nil {
+		return ErrInvalidParam
+	}
+	buf := readBufferPool.Get().(*bytes.Buffer)
+	defer readBufferPool.Put(buf)
+	buf.Reset()
+	if _, err := buf.ReadFrom(r); err != nil {
+		return err
+	}
+	return decodeInto(buf.Bytes(), dst, o.decodeOptions())
+}
+
+// DecodeRGBA decodes the image read from 'r' to an *image.RGBA. The colour
+// samples are multiplied by alpha row by row while they are emitted, so the
+// result is ready for draw.Draw without any conversion pass.
+func DecodeRGBA(r io.Reader) (*image.RGBA, error) {
+	return (*DecoderOptions)(nil).DecodeRGBA(r)
+}
+
+// DecodeRGBA is like the package-level DecodeRGBA, using the options in 'o'.
+func (o *DecoderOptions) DecodeRGBA(r io.Reader) (*image.RGBA, error) {
+	if r == nil {
+		return nil, ErrInvalidParam
+	}
+	buf := readBufferPool.Get().(*bytes.Buffer)
+	defer readBufferPool.Put(buf)
+	buf.Reset()
+	if _, err := buf.ReadFrom(r); err != nil {
+		return nil, err
+	}
+	data := buf.Bytes()
+	info, status := decoder.GetImageInfo(data)
+	if err := statusError(status); err != nil {
+		return nil, err
+	}
+	opts := o.decodeOptions()
+	if err := checkLimits(info, libwebp.MODE_rgbA, opts); err != nil {
+		return nil, err
+	}
+	img := image.NewRGBA(image.Rect(0, 0, info.Width, info.Height))
+	if err := statusError(decoder.WebPDecodeIntoRGBABufferOptions(libwebp.MODE_rgbA, data, img.Pix, img.Stride, opts)); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+func decodeInto(data []byte, dst image.Image, opts *decoder.DecodeOptions) error {
+	switch img := dst.(type) {
+	case *image.YCbCr:
+		return decodeYCbCrInto(data, img, nil, 0, opts)
+	case *image.NYCbCrA:
+		return decodeNYCbCrAInto(data, img, opts)
+	}
+
+	info, status := decoder.GetImageInfo(data)
+	if err := statusError(status); err != nil {
+		return err
+	}
+	bounds := dst.Bounds()
+	if bounds.Dx() < info.Width || bounds.Dy() < info.Height {
+		return fmt.Errorf("webp: destination %v too small for %dx%d image", bounds, info.Width, info.Height)
+	}
+	origin := bounds.Min
+
+	switch img := dst.(type) {
+	case *image.RGBA: // premultiplied
+		pix := img.Pix[img.PixOffset(origin.X, origin.Y):]
+		return statusError(decoder.WebPDecodeIntoRGBABufferOptions(libwebp.MODE_rgbA, data, pix, img.Stride, opts))
+	case *image.NRGBA:
+		pix := img.Pix[img.PixOffset(origin.X, origin.Y):]
+		return statusError(decoder.WebPDecodeIntoRGBABufferOptions(libwebp.MODE_RGBA, data, pix, img.Stride, opts))
+	}
+
+	// Generic destination: decode to a pooled NRGBA first.
+	drawable, ok := dst.(draw.Image)
+	if !ok {
+		return fmt.Errorf("webp: cannot decode into %T", dst)
+	}
+	if err := checkLimits(info, libwebp.MODE_RGBA, opts); err != nil {
+		return err
+	}
+	scratch := pixelPool.Get().(*[]byte)
+	defer pixelPool.Put(scratch)
+	size := 4 * info.Width * info.Height
+	if cap(*scratch) < size {
+		*scratch = make([]byte, size)
+	}
+	src := &image.NRGBA{Pix: (*scratch)[:size], Stride: 4 * info.Width, Rect: image.Rect(0, 0, info.Width, info.Height)}
+	if err := statusError(decoder.WebPDecodeIntoRGBABufferOptions(libwebp.MODE_RGBA, data, src.Pix, src.Stride, opts)); err != nil {
+		return err
+	}
+	draw.Draw(drawable, image.Rectangle{Min: origin, Max: origin.Add(src.Rect.Max)}, src, image.Point{}, draw.Src)
+	return nil
+}
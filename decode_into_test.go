@@ -0,0 +1,87 @@
+package webp_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/daanv2/go-webp"
+	"github.com/daanv2/go-webp/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeIntoUnsupported(t *testing.T) {
+	data := encode(t, gradient(16, 16), nil)
+	dst := image.NewUniform(color.White) // not a draw.Image
+	require.Error(t, webp.DecodeInto(bytes.NewReader(data), dst))
+}
+
+// Once the pools are warmed up, decoding into the images supported directly
+// allocates nothing.
+func TestDecodeIntoAllocs(t *testing.T) {
+	const width, height = 64, 48
+	for _, configure := range []func(*config.Config){nil, lossless} {
+		data := encode(t, gradient(width, height), configure)
+		r := bytes.NewReader(data)
+
+		for name, dst := range map[string]image.Image{
+			"NRGBA":   image.NewNRGBA(image.Rect(0, 0, width, height)),
+			"RGBA":    image.NewRGBA(image.Rect(0, 0, width, height)),
+			"YCbCr":   image.NewYCbCr(image.Rect(0, 0, width, height), image.YCbCrSubsampleRatio420),
+			"NYCbCrA": image.NewNYCbCrA(image.Rect(0, 0, width, height), image.YCbCrSubsampleRatio420),
+		} {
+			decode := func() {
+				r.Reset(data)
+				if err := webp.DecodeInto(r, dst); err != nil {
+					t.Fatal(err)
+				}
+			}
+			decode()
+			require.Zero(t, testing.AllocsPerRun(20, decode), "%s, lossless %v", name, configure != nil)
+		}
+	}
+}
+
+func TestDecodeRGBAIntoAllocs(t *testing.T) {
+	const width, height = 64, 48
+	data := encode(t, gradient(width, height), nil)
+	pix := make([]byte, 4*width*height)
+
+	for name, decode := range map[string]func([]byte, []byte, int) error{
+		"DecodeRGBAInto": webp.DecodeRGBAInto,
+		"DecodeBGRAInto": webp.DecodeBGRAInto,
+		"DecodeARGBInto": webp.DecodeARGBInto,
+	} {
+		require.NoError(t, decode(data, pix, 4*width), name)
+		allocs := testing.AllocsPerRun(20, func() {
+			if err := decode(data, pix, 4*width); err != nil {
+				t.Fatal(err)
+			}
+		})
+		require.Zero(t, allocs, name)
+	}
+}
+
+// DecodeRGBA premultiplies the samples: its output is the NRGBA decode
+// drawn into an *image.RGBA, within the rounding of the premultiplication.
+func TestDecodeRGBAPremultiplied(t *testing.T) {
+	const width, height = 40, 24
+	for _, configure := range []func(*config.Config){nil, lossless} {
+		data := encode(t, translucent(width, height), configure)
+		nrgba := image.NewNRGBA(image.Rect(0, 0, width, height))
+		require.NoError(t, webp.DecodeInto(bytes.NewReader(data), nrgba))
+		want := image.NewRGBA(nrgba.Rect)
+		draw.Draw(want, want.Rect, nrgba, image.Point{}, draw.Src)
+
+		got, err := webp.DecodeRGBA(bytes.NewReader(data))
+		require.NoError(t, err)
+		require.Equal(t, want.Rect, got.Rect)
+		for i := range want.Pix {
+			require.InDelta(t, want.Pix[i], got.Pix[i], 1, "sample %d, lossless %v", i, configure != nil)
+		}
+		// The left column is transparent, so black once premultiplied.
+		require.Equal(t, color.RGBA{}, got.RGBAAt(0, height-1))
+	}
+}
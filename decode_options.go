@@ -0,0 +1,88 @@
+package webp
+
+import (
+	"errors"
+
+	"github.com/daanv2/go-webp/pkg/color/yuv"
+	"github.com/daanv2/go-webp/pkg/libwebp/decoder"
+	"github.com/daanv2/go-webp/pkg/libwebp/demux"
+	libwebp "github.com/daanv2/go-webp/pkg/libwebp/webp"
+)
+
+// DecoderOptions tune the decoding functions offered as its methods. A nil or
+// zero DecoderOptions decodes exactly like the package-level functions.
+type DecoderOptions struct {
+	// DitheringStrength dithers the RGB output of lossy images to hide
+	// banding, in [0, 100]. 0 disables it.
+	DitheringStrength int
+
+	// AlphaDitheringStrength smooths alpha planes whose levels were quantized
+	// by the encoder (AlphaQuality < 100), in [0, 100]. Soft gradients such
+	// as shadows otherwise show visible steps. 0 disables it; images with
+	// lossless alpha are not affected.
+	AlphaDitheringStrength int
+
+	// YUVMatrix is the Y'CbCr matrix lossy images are converted to RGB with.
+	// It must match the one they were encoded with, see
+	// picture.Picture.YUVMatrix.
+	// nil means yuv.BT601, the encoder's default.
+	YUVMatrix *yuv.Matrix
+
+	// MaxPixels, MaxMemoryBytes and MaxFrames protect against decompression
+	// bombs when decoding untrusted data: images larger than MaxPixels
+	// (width x height), needing more than MaxMemoryBytes for their pixels and
+	// the decoder state, or animations of more than MaxFrames frames are
+	// rejected with ErrLimitExceeded before any pixel memory is allocated.
+	// For animations, MaxPixels and MaxMemoryBytes bound the canvas. 0
+	// disables a limit.
+	MaxPixels      int
+	MaxMemoryBytes int64
+	MaxFrames      int
+}
+
+// decodeOptions returns the decoder view of 'o', nil for the defaults.
+func (o *DecoderOptions) decodeOptions() *decoder.DecodeOptions {
+	if o == nil {
+		return nil
+	}
+	return &decoder.DecodeOptions{
+		DitheringStrength:      o.DitheringStrength,
+		AlphaDitheringStrength: o.AlphaDitheringStrength,
+		YUVMatrix:              o.YUVMatrix,
+		MaxPixels:              o.MaxPixels,
+		MaxMemoryBytes:         o.MaxMemoryBytes,
+		MaxFrames:              o.MaxFrames,
+	}
+}
+
+// checkLimits returns ErrLimitExceeded if decoding the image described by
+// 'info' to 'mode', output buffer included, exceeds the limits of 'opts'.
+// It is called before allocating that buffer.
+func checkLimits(info decoder.ImageInfo, mode libwebp.WEBP_CSP_MODE, opts *decoder.DecodeOptions) error {
+	return statusError(decoder.CheckImageLimits(info, mode, opts))
+}
+
+// NewAnimDecoder creates a decoder of the animated WebP 'data', which must
+// stay unchanged while it is used, producing non-premultiplied RGBA
+// canvases.
+func NewAnimDecoder(data []byte) (*demux.WebPAnimDecoder, error) {
+	return (*DecoderOptions)(nil).NewAnimDecoder(data)
+}
+
+// NewAnimDecoder is like the package-level NewAnimDecoder, limited by the
+// MaxPixels, MaxMemoryBytes and MaxFrames of 'o'. The other options do not
+// apply to animations.
+func (o *DecoderOptions) NewAnimDecoder(data []byte) (*demux.WebPAnimDecoder, error) {
+	opts := &demux.AnimDecoderOptions{ColorMode: libwebp.MODE_RGBA}
+	if o != nil {
+		opts.MaxPixels, opts.MaxMemoryBytes, opts.MaxFrames = o.MaxPixels, o.MaxMemoryBytes, o.MaxFrames
+	}
+	dec, err := demux.NewAnimDecoder(data, opts)
+	switch {
+	case errors.Is(err, demux.ErrAnimDecoderLimit):
+		return nil, ErrLimitExceeded
+	case err != nil:
+		return nil, err
+	}
+	return dec, nil
+}
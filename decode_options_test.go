@@ -0,0 +1,276 @@
+package webp_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/daanv2/go-webp"
+	"github.com/daanv2/go-webp/pkg/color/yuv"
+	"github.com/daanv2/go-webp/pkg/config"
+	"github.com/daanv2/go-webp/pkg/libwebp/demux"
+	"github.com/daanv2/go-webp/pkg/libwebp/mux"
+	libwebp "github.com/daanv2/go-webp/pkg/libwebp/webp"
+	"github.com/daanv2/go-webp/pkg/picture"
+	"github.com/stretchr/testify/require"
+)
+
+func encodeShadow(t *testing.T, alphaQuality int) (*image.NRGBA, []byte) {
+	t.Helper()
+
+	src := shadow(96)
+	return src, encode(t, src, func(conf *config.Config) { conf.AlphaQuality = alphaQuality })
+}
+
+func decodeAlpha(t *testing.T, data []byte, opts *webp.DecoderOptions) []uint8 {
+	t.Helper()
+
+	out, err := opts.DecodeToFormat(bytes.NewReader(data), webp.PixelFormatRGBA)
+	require.NoError(t, err)
+	alpha := make([]uint8, 0, out.Width*out.Height)
+	for y := 0; y < out.Height; y++ {
+		row := out.Pix[y*out.Stride:]
+		for x := 0; x < out.Width; x++ {
+			alpha = append(alpha, row[4*x+3])
+		}
+	}
+	return alpha
+}
+
+func countLevels(alpha []uint8) int {
+	var seen [256]bool
+	n := 0
+	for _, a := range alpha {
+		if !seen[a] {
+			seen[a] = true
+			n++
+		}
+	}
+	return n
+}
+
+func alphaError(src *image.NRGBA, alpha []uint8) int {
+	sum := 0
+	for i, a := range alpha {
+		d := int(src.Pix[4*i+3]) - int(a)
+		if d < 0 {
+			d = -d
+		}
+		sum += d
+	}
+	return sum
+}
+
+func TestAlphaDithering(t *testing.T) {
+	for _, quality := range []int{10, 30, 60} {
+		src, data := encodeShadow(t, quality)
+
+		plain := decodeAlpha(t, data, nil)
+		smooth := decodeAlpha(t, data, &webp.DecoderOptions{AlphaDitheringStrength: 100})
+		require.Len(t, smooth, len(plain))
+
+		// Smoothing restores intermediate levels and brings the gradient
+		// closer to the source.
+		require.Greater(t, countLevels(smooth), countLevels(plain), "quality %d", quality)
+		require.LessOrEqual(t, alphaError(src, smooth), alphaError(src, plain), "quality %d", quality)
+	}
+}
+
+func TestAlphaDitheringLosslessAlpha(t *testing.T) {
+	_, data := encodeShadow(t, 100)
+
+	plain := decodeAlpha(t, data, nil)
+	smooth := decodeAlpha(t, data, &webp.DecoderOptions{AlphaDitheringStrength: 100})
+	require.Equal(t, plain, smooth)
+}
+
+func TestAlphaDitheringZeroIsDefault(t *testing.T) {
+	_, data := encodeShadow(t, 30)
+
+	require.Equal(t, decodeAlpha(t, data, nil), decodeAlpha(t, data, &webp.DecoderOptions{}))
+}
+
+// Lossy images encoded with a matrix decode to their colors with that matrix
+// only.
+func TestDecoderYUVMatrix(t *testing.T) {
+	src := color.NRGBA{R: 200, G: 40, B: 60, A: 255}
+	img := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+	draw.Draw(img, img.Bounds(), image.NewUniform(src), image.Point{}, draw.Src)
+
+	var pic picture.Picture
+	picture.WebPPictureInit(&pic)
+	defer picture.WebPPictureFree(&pic)
+	pic.UseARGB = true
+	pic.YUVMatrix = yuv.BT709
+	require.NoError(t, picture.WebPPictureImportImage(&pic, img, picture.WIDE_DITHER_NONE, 0))
+	var conf config.Config
+	require.NoError(t, conf.Init())
+	conf.Quality = 100
+	var buf bytes.Buffer
+	require.NoError(t, webp.EncodePicture(&buf, &pic, &conf))
+
+	center := func(opts *webp.DecoderOptions) color.NRGBA {
+		out, err := opts.DecodeToFormat(bytes.NewReader(buf.Bytes()), webp.PixelFormatRGBA)
+		require.NoError(t, err)
+		p := out.Pix[16*out.Stride+4*16:]
+		return color.NRGBA{R: p[0], G: p[1], B: p[2], A: p[3]}
+	}
+	distance := func(c color.NRGBA) int {
+		d := 0
+		for _, v := range []int{int(c.R) - int(src.R), int(c.G) - int(src.G), int(c.B) - int(src.B)} {
+			d = max(d, v, -v)
+		}
+		return d
+	}
+	require.LessOrEqual(t, distance(center(&webp.DecoderOptions{YUVMatrix: yuv.BT709})), 3)
+	require.Greater(t, distance(center(nil)), 3)
+}
+
+func TestDecoderOptionsRange(t *testing.T) {
+	_, data := encodeShadow(t, 30)
+
+	for _, opts := range []*webp.DecoderOptions{
+		{AlphaDitheringStrength: -1},
+		{AlphaDitheringStrength: 101},
+		{DitheringStrength: 101},
+	} {
+		_, err := opts.DecodeToFormat(bytes.NewReader(data), webp.PixelFormatRGBA)
+		require.ErrorIs(t, err, webp.ErrInvalidParam)
+	}
+}
+
+func TestDecoderLimits(t *testing.T) {
+	_, data := encodeShadow(t, 30)
+	const size = 96
+
+	decoders := map[string]func(o *webp.DecoderOptions) error{
+		"Decode": func(o *webp.DecoderOptions) error {
+			_, err := o.Decode(bytes.NewReader(data))
+			return err
+		},
+		"DecodeConfig": func(o *webp.DecoderOptions) error {
+			_, err := o.DecodeConfig(bytes.NewReader(data))
+			return err
+		},
+		"DecodeToFormat": func(o *webp.DecoderOptions) error {
+			_, err := o.DecodeToFormat(bytes.NewReader(data), webp.PixelFormatRGBA)
+			return err
+		},
+		"DecodeRGBA": func(o *webp.DecoderOptions) error {
+			_, err := o.DecodeRGBA(bytes.NewReader(data))
+			return err
+		},
+		"DecodeInto": func(o *webp.DecoderOptions) error {
+			return o.DecodeInto(bytes.NewReader(data), image.NewNRGBA(image.Rect(0, 0, size, size)))
+		},
+		"DecodeYCbCr": func(o *webp.DecoderOptions) error {
+			_, err := o.DecodeYCbCr(data)
+			return err
+		},
+		"DecodeRGBAInto": func(o *webp.DecoderOptions) error {
+			return o.DecodeRGBAInto(data, make([]byte, 4*size*size), 4*size)
+		},
+		"DecodeBGRAInto": func(o *webp.DecoderOptions) error {
+			return o.DecodeBGRAInto(data, make([]byte, 4*size*size), 4*size)
+		},
+		"DecodeARGBInto": func(o *webp.DecoderOptions) error {
+			return o.DecodeARGBInto(data, make([]byte, 4*size*size), 4*size)
+		},
+		"DecodeYCbCrInto": func(o *webp.DecoderOptions) error {
+			return o.DecodeYCbCrInto(data, image.NewYCbCr(image.Rect(0, 0, size, size), image.YCbCrSubsampleRatio420))
+		},
+		"DecodeNYCbCrAInto": func(o *webp.DecoderOptions) error {
+			return o.DecodeNYCbCrAInto(data, image.NewNYCbCrA(image.Rect(0, 0, size, size), image.YCbCrSubsampleRatio420))
+		},
+	}
+	for name, decode := range decoders {
+		require.NoError(t, decode(&webp.DecoderOptions{MaxPixels: size * size, MaxMemoryBytes: 1 << 20}), name)
+		require.ErrorIs(t, decode(&webp.DecoderOptions{MaxPixels: size*size - 1}), webp.ErrLimitExceeded, name)
+		require.ErrorIs(t, decode(&webp.DecoderOptions{MaxMemoryBytes: size * size}), webp.ErrLimitExceeded, name)
+		require.ErrorIs(t, decode(&webp.DecoderOptions{MaxPixels: -1}), webp.ErrInvalidParam, name)
+	}
+}
+
+// encodeAnimation returns a lossless animation of 'numFrames' frames of
+// 'size' x 'size' pixels.
+func encodeAnimation(t *testing.T, size, numFrames int) []byte {
+	t.Helper()
+
+	enc := mux.NewAnimEncoder(size, size, nil)
+	require.NotNil(t, enc)
+	defer enc.Delete()
+	var conf config.Config
+	require.NoError(t, conf.Init())
+	conf.Lossless = 1
+	for i := 0; i < numFrames; i++ {
+		img := image.NewNRGBA(image.Rect(0, 0, size, size))
+		draw.Draw(img, image.Rect(i, i, size/2+i, size/2+i), image.NewUniform(color.NRGBA{R: 255, A: 255}), image.Point{}, draw.Src)
+		var pic picture.Picture
+		picture.WebPPictureInit(&pic)
+		pic.UseARGB = true
+		require.NoError(t, picture.WebPPictureImportImage(&pic, img, picture.WIDE_DITHER_NONE, 0))
+		err := enc.Add(&pic, 100*i, &conf)
+		picture.WebPPictureFree(&pic)
+		require.NoError(t, err)
+	}
+	require.NoError(t, enc.Add(nil, 100*numFrames, nil))
+	data, err := enc.Assemble()
+	require.NoError(t, err)
+	return data
+}
+
+// The animation decoder checks the canvas, the frame count and the memory
+// of the canvases (CheckAnimDecoderLimits()) before allocating them.
+func TestAnimDecoderLimits(t *testing.T) {
+	const size, numFrames = 32, 3
+	data := encodeAnimation(t, size, numFrames)
+
+	for _, tc := range []struct {
+		opts demux.AnimDecoderOptions
+		err  error
+	}{
+		{demux.AnimDecoderOptions{MaxFrames: numFrames, MaxPixels: size * size, MaxMemoryBytes: 1 << 20}, nil},
+		{demux.AnimDecoderOptions{MaxFrames: numFrames - 1}, demux.ErrAnimDecoderLimit},
+		{demux.AnimDecoderOptions{MaxPixels: size*size - 1}, demux.ErrAnimDecoderLimit},
+		{demux.AnimDecoderOptions{MaxMemoryBytes: 2 * 4 * size * size}, demux.ErrAnimDecoderLimit},
+		{demux.AnimDecoderOptions{MaxFrames: -1}, demux.ErrAnimDecoderCreate},
+	} {
+		tc.opts.ColorMode = libwebp.MODE_RGBA
+		dec, err := demux.NewAnimDecoder(data, &tc.opts)
+		if tc.err != nil {
+			require.ErrorIs(t, err, tc.err, "%+v", tc.opts)
+			continue
+		}
+		require.NoError(t, err, "%+v", tc.opts)
+		require.Equal(t, numFrames, dec.Info().FrameCount)
+		for dec.HasMoreFrames() {
+			_, _, err := dec.Next()
+			require.NoError(t, err)
+		}
+		dec.Delete()
+	}
+}
+
+// DecoderOptions forward their limits to the animation decoder.
+func TestDecoderOptionsAnimLimits(t *testing.T) {
+	const size, numFrames = 32, 3
+	data := encodeAnimation(t, size, numFrames)
+
+	dec, err := (&webp.DecoderOptions{MaxFrames: numFrames, MaxPixels: size * size}).NewAnimDecoder(data)
+	require.NoError(t, err)
+	require.Equal(t, numFrames, dec.Info().FrameCount)
+	dec.Delete()
+
+	for _, opts := range []*webp.DecoderOptions{
+		{MaxFrames: numFrames - 1},
+		{MaxPixels: size*size - 1},
+		{MaxMemoryBytes: 2 * 4 * size * size},
+	} {
+		_, err := opts.NewAnimDecoder(data)
+		require.ErrorIs(t, err, webp.ErrLimitExceeded, "%+v", opts)
+	}
+	_, err = (&webp.DecoderOptions{MaxFrames: -1}).NewAnimDecoder(data)
+	require.Error(t, err)
+}
@@ -0,0 +1,53 @@
+package webp_test
+
+import (
+	"bytes"
+	"image/color"
+	"io"
+	"testing"
+
+	"github.com/daanv2/go-webp"
+	"github.com/stretchr/testify/require"
+)
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+// DecodeConfig reads the headers only, and skips the chunks before the
+// image data without buffering them.
+func TestDecodeConfig(t *testing.T) {
+	const width, height = 40, 24
+	for _, tc := range []struct {
+		name  string
+		data  []byte
+		model color.Model
+	}{
+		{"lossy", encode(t, gradient(width, height), nil), color.YCbCrModel},
+		{"lossy alpha", encode(t, translucent(width, height), nil), color.NRGBAModel},
+		{"lossless", encode(t, translucent(width, height), lossless), color.NRGBAModel},
+	} {
+		r := &countingReader{r: bytes.NewReader(tc.data)}
+		cfg, err := webp.DecodeConfig(r)
+		require.NoError(t, err, tc.name)
+		require.Equal(t, width, cfg.Width, tc.name)
+		require.Equal(t, height, cfg.Height, tc.name)
+		require.Equal(t, tc.model, cfg.ColorModel, tc.name)
+		require.Less(t, r.n, len(tc.data), tc.name)
+
+		img, err := webp.Decode(bytes.NewReader(tc.data))
+		require.NoError(t, err, tc.name)
+		require.Equal(t, tc.model, img.ColorModel(), tc.name)
+
+		_, err = webp.DecodeConfig(bytes.NewReader(tc.data[:20]))
+		require.ErrorIs(t, err, webp.ErrNotEnoughData, tc.name)
+	}
+}
@@ -0,0 +1,112 @@
+package webp
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/daanv2/go-webp/pkg/libwebp/decoder"
+	libwebp "github.com/daanv2/go-webp/pkg/libwebp/webp"
+)
+
+// DecodeYCbCr decodes 'data' to its native 4:2:0 Y'CbCr planes, without any
+// RGB conversion. The result is an *image.NYCbCrA if the image has alpha,
+// an *image.YCbCr otherwise.
+func DecodeYCbCr(data []byte) (image.Image, error) {
+	return (*DecoderOptions)(nil).DecodeYCbCr(data)
+}
+
+// DecodeYCbCr is like the package-level DecodeYCbCr, applying 'o'.
+func (o *DecoderOptions) DecodeYCbCr(data []byte) (image.Image, error) {
+	info, status := decoder.GetImageInfo(data)
+	if err := statusError(status); err != nil {
+		return nil, err
+	}
+	opts := o.decodeOptions()
+	mode := libwebp.MODE_YUV
+	if info.HasAlpha {
+		mode = libwebp.MODE_YUVA
+	}
+	if err := checkLimits(info, mode, opts); err != nil {
+		return nil, err
+	}
+	rect := image.Rect(0, 0, info.Width, info.Height)
+	if info.HasAlpha {
+		img := image.NewNYCbCrA(rect, image.YCbCrSubsampleRatio420)
+		if err := decodeNYCbCrAInto(data, img, opts); err != nil {
+			return nil, err
+		}
+		return img, nil
+	}
+	img := image.NewYCbCr(rect, image.YCbCrSubsampleRatio420)
+	if err := decodeYCbCrInto(data, img, nil, 0, opts); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// DecodeYCbCrInto decodes 'data' into the planes of 'dst', starting at
+// dst.Rect.Min. 'dst' must use 4:2:0 subsampling and be at least as large as
+// the image. The alpha channel, if any, is dropped.
+func DecodeYCbCrInto(data []byte, dst *image.YCbCr) error {
+	return (*DecoderOptions)(nil).DecodeYCbCrInto(data, dst)
+}
+
+// DecodeYCbCrInto is like the package-level DecodeYCbCrInto, applying 'o'.
+func (o *DecoderOptions) DecodeYCbCrInto(data []byte, dst *image.YCbCr) error {
+	return decodeYCbCrInto(data, dst, nil, 0, o.decodeOptions())
+}
+
+// DecodeNYCbCrAInto is like DecodeYCbCrInto, but also fills the alpha plane
+// of 'dst' (with 0xff if the image is opaque).
+func DecodeNYCbCrAInto(data []byte, dst *image.NYCbCrA) error {
+	return (*DecoderOptions)(nil).DecodeNYCbCrAInto(data, dst)
+}
+
+// DecodeNYCbCrAInto is like the package-level DecodeNYCbCrAInto, applying
+// 'o'.
+func (o *DecoderOptions) DecodeNYCbCrAInto(data []byte, dst *image.NYCbCrA) error {
+	return decodeNYCbCrAInto(data, dst, o.decodeOptions())
+}
+
+func decodeNYCbCrAInto(data []byte, dst *image.NYCbCrA, opts *decoder.DecodeOptions) error {
+	if dst == nil {
+		return ErrInvalidParam
+	}
+	a := dst.A[dst.AOffset(dst.Rect.Min.X, dst.Rect.Min.Y):]
+	return decodeYCbCrInto(data, &dst.YCbCr, a, dst.AStride, opts)
+}
+
+// decodeYCbCrInto decodes into the planes of 'dst' and, if not nil, into the
+// alpha plane 'a'.
+func decodeYCbCrInto(data []byte, dst *image.YCbCr, a []uint8, aStride int, opts *decoder.DecodeOptions) error {
+	y, u, v, err := yuvPlanes(data, dst)
+	if err != nil {
+		return err
+	}
+	return statusError(decoder.WebPDecodeYUVAIntoOptions(data, y, dst.YStride, u, dst.CStride, v, dst.CStride, a, aStride, opts))
+}
+
+// yuvPlanes checks 'dst' against the image in 'data' and returns its planes,
+// starting at dst.Rect.Min.
+func yuvPlanes(data []byte, dst *image.YCbCr) (y, u, v []uint8, err error) {
+	if dst == nil {
+		return nil, nil, nil, ErrInvalidParam
+	}
+	if dst.SubsampleRatio != image.YCbCrSubsampleRatio420 {
+		return nil, nil, nil, fmt.Errorf("webp: unsupported subsample ratio %v, want 4:2:0", dst.SubsampleRatio)
+	}
+	info, status := decoder.GetImageInfo(data)
+	if err := statusError(status); err != nil {
+		return nil, nil, nil, err
+	}
+	// Chroma offsets are only exact for even origins.
+	origin := dst.Rect.Min
+	if origin.X&1 != 0 || origin.Y&1 != 0 {
+		return nil, nil, nil, fmt.Errorf("webp: destination origin %v must be even", origin)
+	}
+	if dst.Rect.Dx() < info.Width || dst.Rect.Dy() < info.Height {
+		return nil, nil, nil, fmt.Errorf("webp: destination %v too small for %dx%d image", dst.Rect, info.Width, info.Height)
+	}
+	c := dst.COffset(origin.X, origin.Y)
+	return dst.Y[dst.YOffset(origin.X, origin.Y):], dst.Cb[c:], dst.Cr[c:], nil
+}
@@ -0,0 +1,56 @@
+package webp_test
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	"github.com/daanv2/go-webp"
+	"github.com/stretchr/testify/require"
+)
+
+func requireYCbCr420(t *testing.T, img *image.YCbCr, width, height int) {
+	t.Helper()
+
+	cw, ch := (width+1)/2, (height+1)/2
+	require.Equal(t, image.Rect(0, 0, width, height), img.Rect)
+	require.Equal(t, image.YCbCrSubsampleRatio420, img.SubsampleRatio)
+	require.GreaterOrEqual(t, img.YStride, width)
+	require.GreaterOrEqual(t, img.CStride, cw)
+	require.GreaterOrEqual(t, len(img.Y), (height-1)*img.YStride+width)
+	require.GreaterOrEqual(t, len(img.Cb), (ch-1)*img.CStride+cw)
+	require.GreaterOrEqual(t, len(img.Cr), (ch-1)*img.CStride+cw)
+}
+
+func TestDecodeYCbCr(t *testing.T) {
+	const width, height = 37, 21 // odd, so the chroma planes round up
+	img, err := webp.DecodeYCbCr(encode(t, gradient(width, height), nil))
+	require.NoError(t, err)
+	ycbcr, ok := img.(*image.YCbCr)
+	require.True(t, ok, "%T", img)
+	requireYCbCr420(t, ycbcr, width, height)
+	// The gradient goes from black to bright colors along both axes.
+	require.Less(t, ycbcr.Y[0], ycbcr.Y[ycbcr.YOffset(width-1, height-1)])
+}
+
+func TestDecodeYCbCrAlpha(t *testing.T) {
+	const size = 33
+	src := shadow(size)
+	data := encode(t, src, nil)
+	img, err := webp.DecodeYCbCr(data)
+	require.NoError(t, err)
+	nycbcra, ok := img.(*image.NYCbCrA)
+	require.True(t, ok, "%T", img)
+	requireYCbCr420(t, &nycbcra.YCbCr, size, size)
+	require.GreaterOrEqual(t, nycbcra.AStride, size)
+	require.GreaterOrEqual(t, len(nycbcra.A), (size-1)*nycbcra.AStride+size)
+
+	// The alpha plane is the one of the RGBA decode.
+	rgba, err := webp.DecodeToFormat(bytes.NewReader(data), webp.PixelFormatRGBA)
+	require.NoError(t, err)
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			require.Equal(t, rgba.Pix[y*rgba.Stride+4*x+3], nycbcra.A[nycbcra.AOffset(x, y)], "pixel %d,%d", x, y)
+		}
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"errors"
 	"image"
 	"io"
+	"unsafe"
 
 	"github.com/daanv2/go-webp/pkg/config"
 	"github.com/daanv2/go-webp/pkg/libwebp/enc"
+	"github.com/daanv2/go-webp/pkg/picture"
 )
 
 func Encode(w io.Writer, img image.Image, conf *config.Config) error {
@@ -20,5 +22,73 @@ func Encode(w io.Writer, img image.Image, conf *config.Config) error {
 		return errors.New("writer is nil")
 	}
 
-	return enc.WebPEncode(conf, img)
+	var pic picture.Picture
+	picture.WebPPictureInit(&pic)
+	if err := importImage(&pic, img, conf); err != nil {
+		return err
+	}
+	defer picture.WebPPictureFree(&pic)
+	return EncodePicture(w, &pic, conf)
+}
+
+// importImage imports 'img' into 'pic' as 'conf' encodes it: as ARGB for
+// lossless, as YUV otherwise, so that samples wider than 8 bits reach the
+// sharp conversion at full precision. conf.WideDithering selects how they are
+// reduced to 8 bits.
+func importImage(pic *picture.Picture, img image.Image, conf *config.Config) error {
+	pic.UseARGB = conf.Lossless != 0
+	return picture.WebPPictureImportImage(pic, img, picture.WideDither(conf.WideDithering), conf.UseSharpYUV)
+}
+
+// InitAutoPreset initializes 'conf' for 'img', an image of unknown type:
+// enc.WebPAnalyzeContent picks the preset, applied at 'quality', the image
+// hint and whether to encode losslessly. The analysis is returned too.
+func InitAutoPreset(conf *config.Config, img image.Image, quality float64) (*enc.ContentAnalysis, error) {
+	if conf == nil || img == nil {
+		return nil, ErrInvalidParam
+	}
+	var pic picture.Picture
+	picture.WebPPictureInit(&pic)
+	pic.UseARGB = true
+	if err := picture.WebPPictureImportImage(&pic, img, picture.WIDE_DITHER_NONE, 0); err != nil {
+		return nil, err
+	}
+	defer picture.WebPPictureFree(&pic)
+	res, err := enc.WebPAnalyzeContent(&pic)
+	if err != nil {
+		return nil, err
+	}
+	if err := res.Apply(conf, quality); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// EncodePicture encodes 'pic' with 'conf' and writes the bitstream to 'w'.
+// It replaces pic.Writer. Statistics are collected if enabled with
+// pic.SetStats.
+func EncodePicture(w io.Writer, pic *picture.Picture, conf *config.Config) error {
+	if w == nil || pic == nil || conf == nil {
+		return ErrInvalidParam
+	}
+	var werr error
+	pic.Writer = func(data *uint8, data_size uint64, _ *picture.Picture) int {
+		if data_size == 0 {
+			return 1
+		}
+		if _, werr = w.Write(unsafe.Slice(data, data_size)); werr != nil {
+			return 0
+		}
+		return 1
+	}
+	if enc.WebPEncode(conf, pic) == 0 {
+		if werr != nil {
+			return werr
+		}
+		if pic.ErrorCode != nil {
+			return pic.ErrorCode
+		}
+		return picture.ENC_ERROR_BAD_WRITE
+	}
+	return nil
 }
@@ -0,0 +1,155 @@
+package webp_test
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	"github.com/daanv2/go-webp"
+	"github.com/daanv2/go-webp/pkg/config"
+	"github.com/daanv2/go-webp/pkg/picture"
+	"github.com/daanv2/go-webp/pkg/webptest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeInvalidParam(t *testing.T) {
+	var conf config.Config
+	require.NoError(t, conf.Init())
+	img := gradient(8, 8)
+	var buf bytes.Buffer
+
+	require.Error(t, webp.Encode(nil, img, &conf))
+	require.Error(t, webp.Encode(&buf, nil, &conf))
+	require.Error(t, webp.Encode(&buf, img, nil))
+	require.ErrorIs(t, webp.EncodePicture(&buf, nil, &conf), webp.ErrInvalidParam)
+	require.Zero(t, buf.Len())
+}
+
+func TestEncodeLossless(t *testing.T) {
+	src := gradient(40, 24)
+	data := encode(t, src, lossless)
+
+	out, err := webp.DecodeToFormat(bytes.NewReader(data), webp.PixelFormatRGBA)
+	require.NoError(t, err)
+	require.Equal(t, 40, out.Width)
+	require.Equal(t, 24, out.Height)
+	for y := 0; y < out.Height; y++ {
+		require.Equal(t, src.Pix[y*src.Stride:][:4*40], out.Pix[y*out.Stride:][:4*40], "row %d", y)
+	}
+}
+
+func TestEncodePictureWriter(t *testing.T) {
+	var pic picture.Picture
+	picture.WebPPictureInit(&pic)
+	defer picture.WebPPictureFree(&pic)
+	pic.UseARGB = true
+	require.NoError(t, picture.WebPPictureImportImage(&pic, gradient(16, 16), picture.WIDE_DITHER_NONE, 0))
+
+	var conf config.Config
+	require.NoError(t, conf.Init())
+	var buf bytes.Buffer
+	require.NoError(t, webp.EncodePicture(&buf, &pic, &conf))
+	require.Equal(t, "RIFF", string(buf.Bytes()[:4]))
+	require.Equal(t, "WEBP", string(buf.Bytes()[8:12]))
+}
+
+func TestEncodeExtraInfo(t *testing.T) {
+	for kind := picture.EXTRA_INFO_INTRA_TYPE; kind < picture.EXTRA_INFO_LAST; kind++ {
+		var pic picture.Picture
+		picture.WebPPictureInit(&pic)
+		pic.UseARGB = true
+		require.NoError(t, picture.WebPPictureImportImage(&pic, gradient(72, 40), picture.WIDE_DITHER_NONE, 0))
+		pic.EnableExtraInfo(kind)
+
+		var conf config.Config
+		require.NoError(t, conf.Init())
+		var buf bytes.Buffer
+		require.NoError(t, webp.EncodePicture(&buf, &pic, &conf))
+
+		mb_w, mb_h := pic.MacroblockDimensions()
+		require.Equal(t, 5, mb_w)
+		require.Equal(t, 3, mb_h)
+		require.Len(t, pic.MacroblockInfo, mb_w*mb_h)
+		for i, info := range pic.MacroblockInfo {
+			require.LessOrEqual(t, info.IntraType, uint8(1), "macroblock %d", i)
+			require.Less(t, info.Segment, uint8(4), "macroblock %d", i)
+			require.NotZero(t, info.Quant, "macroblock %d", i)
+			require.Equal(t, info.IntraType == 0, info.Intra16Mode == 0xff, "macroblock %d", i)
+		}
+		require.Equal(t, pic.ExtraInfoMap(kind), pic.ExtraInfo, "kind %d", kind)
+		picture.WebPPictureFree(&pic)
+	}
+}
+
+// encodeAlpha encodes 'img' lossy, its alpha with quality 50, and returns the
+// ALPH chunk and the alpha filter reported in the picture.
+func encodeAlpha(t *testing.T, img image.Image, configure func(conf *config.Config)) (alph []byte, filter int) {
+	t.Helper()
+
+	var pic picture.Picture
+	picture.WebPPictureInit(&pic)
+	defer picture.WebPPictureFree(&pic)
+	require.NoError(t, picture.WebPPictureImportImage(&pic, img, picture.WIDE_DITHER_NONE, 0))
+	var conf config.Config
+	require.NoError(t, conf.Init())
+	conf.AlphaQuality = 50
+	configure(&conf)
+	var buf bytes.Buffer
+	require.NoError(t, webp.EncodePicture(&buf, &pic, &conf))
+
+	chunks := webptest.Chunks(buf.Bytes()[12:], "ALPH")
+	require.Len(t, chunks, 1)
+	// The filtering method is in bits 2-3 of the ALPH header.
+	require.Equal(t, int(chunks[0][0]>>2&3), pic.AlphaFilter, "filter in the bitstream")
+	return chunks[0], pic.AlphaFilter
+}
+
+func TestEncodeAlphaFilter(t *testing.T) {
+	img := shadow(64)
+	alphaFiltering := func(filtering config.AlphaFilter, alphaMethod int) func(conf *config.Config) {
+		return func(conf *config.Config) {
+			conf.AlphaFiltering = filtering
+			conf.AlphaMethod = alphaMethod
+		}
+	}
+
+	_, filter := encodeAlpha(t, img, alphaFiltering(config.ALPHA_FILTER_NONE, -1))
+	require.Zero(t, filter) // WEBP_FILTER_NONE
+
+	// ALPHA_FILTER_BEST keeps the smallest of all the filters.
+	best, _ := encodeAlpha(t, img, alphaFiltering(config.ALPHA_FILTER_BEST, -1))
+	for _, filtering := range []config.AlphaFilter{config.ALPHA_FILTER_FAST, config.ALPHA_FILTER_AUTO} {
+		alph, _ := encodeAlpha(t, img, alphaFiltering(filtering, -1))
+		require.GreaterOrEqual(t, len(alph), len(best), "filtering %d", filtering)
+	}
+
+	// From effort 5, ALPHA_FILTER_AUTO tries every filter like
+	// ALPHA_FILTER_BEST, and keeps the same one.
+	for _, alphaMethod := range []int{5, 6} {
+		want, wantFilter := encodeAlpha(t, img, alphaFiltering(config.ALPHA_FILTER_BEST, alphaMethod))
+		got, gotFilter := encodeAlpha(t, img, alphaFiltering(config.ALPHA_FILTER_AUTO, alphaMethod))
+		require.Equal(t, wantFilter, gotFilter, "alpha method %d", alphaMethod)
+		require.Equal(t, want, got, "alpha method %d", alphaMethod)
+	}
+}
+
+// The alpha effort is AlphaMethod whatever Method, and Method if AlphaMethod
+// is -1.
+func TestEncodeAlphaMethod(t *testing.T) {
+	img := shadow(64)
+	methods := func(method, alphaMethod int) func(conf *config.Config) {
+		return func(conf *config.Config) {
+			conf.Method = method
+			conf.AlphaMethod = alphaMethod
+		}
+	}
+	for alphaMethod := 0; alphaMethod <= 6; alphaMethod++ {
+		want, _ := encodeAlpha(t, img, methods(0, alphaMethod))
+		for _, method := range []int{3, 6} {
+			got, _ := encodeAlpha(t, img, methods(method, alphaMethod))
+			require.Equal(t, want, got, "method %d, alpha method %d", method, alphaMethod)
+		}
+		got, _ := encodeAlpha(t, img, methods(alphaMethod, -1))
+		require.Equal(t, want, got, "method %d, alpha method -1", alphaMethod)
+	}
+}
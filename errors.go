@@ -0,0 +1,47 @@
+package webp
+
+import (
+	"errors"
+
+	"github.com/daanv2/go-webp/pkg/vp8"
+)
+
+// Errors returned by the decoding functions, one per VP8StatusCode.
+var (
+	ErrOutOfMemory        = errors.New("webp: out of memory")
+	ErrInvalidParam       = errors.New("webp: invalid parameter")
+	ErrBitstream          = errors.New("webp: bitstream error")
+	ErrUnsupportedFeature = errors.New("webp: unsupported feature")
+	ErrSuspended          = errors.New("webp: decoding suspended")
+	ErrUserAbort          = errors.New("webp: aborted by user")
+	ErrNotEnoughData      = errors.New("webp: not enough data")
+
+	// ErrLimitExceeded is returned, before any pixel memory is allocated,
+	// for images exceeding the MaxPixels, MaxMemoryBytes or MaxFrames of the
+	// DecoderOptions or the limits of the ThumbnailOptions.
+	ErrLimitExceeded = errors.New("webp: image exceeds the decoding limits")
+)
+
+// statusError converts a decoder status to an error, nil for VP8_STATUS_OK.
+func statusError(status vp8.VP8StatusCode) error {
+	switch status {
+	case vp8.VP8_STATUS_OK:
+		return nil
+	case vp8.VP8_STATUS_OUT_OF_MEMORY:
+		return ErrOutOfMemory
+	case vp8.VP8_STATUS_INVALID_PARAM:
+		return ErrInvalidParam
+	case vp8.VP8_STATUS_UNSUPPORTED_FEATURE:
+		return ErrUnsupportedFeature
+	case vp8.VP8_STATUS_SUSPENDED:
+		return ErrSuspended
+	case vp8.VP8_STATUS_USER_ABORT:
+		return ErrUserAbort
+	case vp8.VP8_STATUS_NOT_ENOUGH_DATA:
+		return ErrNotEnoughData
+	case vp8.VP8_STATUS_LIMIT_EXCEEDED:
+		return ErrLimitExceeded
+	default:
+		return ErrBitstream
+	}
+}
@@ -0,0 +1,77 @@
+package webp_test
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	"github.com/daanv2/go-webp"
+	"github.com/daanv2/go-webp/pkg/config"
+	"github.com/daanv2/go-webp/pkg/webptest"
+	"github.com/stretchr/testify/require"
+)
+
+func FuzzDecode(f *testing.F) {
+	webptest.AddCorpus(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		cfg, err := webp.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		opts := &webp.DecoderOptions{MaxPixels: 1 << 20}
+
+		if img, err := opts.DecodeRGBA(bytes.NewReader(data)); err == nil {
+			require.Equal(t, image.Rect(0, 0, cfg.Width, cfg.Height), img.Bounds())
+		}
+		format := webp.PixelFormat(len(data) % int(webp.PixelFormatRGBA4444Premul+1))
+		if out, err := opts.DecodeToFormat(bytes.NewReader(data), format); err == nil {
+			require.Equal(t, cfg.Width, out.Width)
+			require.Equal(t, cfg.Height, out.Height)
+			require.GreaterOrEqual(t, len(out.Pix), out.Stride*(out.Height-1)+format.BytesPerPixel()*out.Width)
+		}
+		if img, err := opts.DecodeYCbCr(data); err == nil {
+			require.Equal(t, image.Rect(0, 0, cfg.Width, cfg.Height), img.Bounds())
+		}
+		if img, err := opts.Decode(bytes.NewReader(data)); err == nil {
+			require.Equal(t, image.Rect(0, 0, cfg.Width, cfg.Height), img.Bounds())
+			require.Equal(t, cfg.ColorModel, img.ColorModel())
+		}
+	})
+}
+
+// Encodes the image made of 'pix' repeated, then decodes it: lossless
+// encoding must give the pixels back.
+func FuzzEncodeRoundTrip(f *testing.F) {
+	f.Add(uint8(0), uint8(0), true, uint8(75), []byte{0x80, 0x40, 0x20, 0xff})
+	f.Add(uint8(15), uint8(7), false, uint8(75), []byte{0x80, 0x40, 0x20, 0xff})
+	f.Add(uint8(63), uint8(33), true, uint8(0), []byte{0, 0, 0, 0, 0xff, 0xff, 0xff, 0x7f, 1, 2, 3, 4})
+	f.Add(uint8(40), uint8(40), false, uint8(100), []byte{0x10, 0xc0, 0x30, 0x00, 0xf0, 0x20, 0x90, 0xff})
+	f.Fuzz(func(t *testing.T, width, height uint8, lossless bool, quality uint8, pix []byte) {
+		src := image.NewNRGBA(image.Rect(0, 0, 1+int(width)%64, 1+int(height)%64))
+		if len(pix) > 0 {
+			for i := range src.Pix {
+				src.Pix[i] = pix[i%len(pix)]
+			}
+		}
+
+		data := encode(t, src, func(conf *config.Config) {
+			conf.Quality = float64(quality % 101)
+			conf.Method = int(quality) % 7
+			if lossless {
+				conf.Lossless = 1
+				conf.Exact = 1 // keep the RGB of transparent pixels
+			}
+		})
+
+		out, err := webp.DecodeToFormat(bytes.NewReader(data), webp.PixelFormatRGBA)
+		require.NoError(t, err)
+		require.Equal(t, src.Rect.Dx(), out.Width)
+		require.Equal(t, src.Rect.Dy(), out.Height)
+		if !lossless {
+			return
+		}
+		for y := 0; y < out.Height; y++ {
+			require.Equal(t, src.Pix[y*src.Stride:][:4*out.Width], out.Pix[y*out.Stride:][:4*out.Width], "row %d", y)
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package webp_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/daanv2/go-webp"
+	"github.com/daanv2/go-webp/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+// Test images and the encoding of the fixtures shared by the tests.
+
+// gradient returns an opaque image whose colors vary along both axes.
+func gradient(width, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(4 * x), G: uint8(4 * y), B: uint8(2 * (x + y)), A: 255})
+		}
+	}
+	return img
+}
+
+// shadow returns a grey image whose alpha is a soft radial gradient, the kind
+// of content that bands once its alpha levels are quantized.
+func shadow(size int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	c := float64(size-1) / 2
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			dx, dy := float64(x)-c, float64(y)-c
+			d := (dx*dx + dy*dy) / (c * c)
+			a := 0.0
+			if d < 1 {
+				a = 255 * (1 - d)
+			}
+			img.SetNRGBA(x, y, color.NRGBA{R: 32, G: 32, B: 32, A: uint8(a)})
+		}
+	}
+	return img
+}
+
+// translucent returns a gradient whose alpha goes from transparent on the
+// left to opaque on the right.
+func translucent(width, height int) *image.NRGBA {
+	img := gradient(width, height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := img.NRGBAAt(x, y)
+			c.A = uint8(255 * x / (width - 1))
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// textured returns an opaque image with smooth and noisy areas, so that the
+// analysis finds several segments.
+func textured(width, height int) *image.NRGBA {
+	img := gradient(width, height)
+	seed := uint32(1)
+	for y := 0; y < height; y++ {
+		for x := width / 2; x < width; x++ {
+			seed = seed*1664525 + 1013904223
+			n := uint8(seed >> 24)
+			img.SetNRGBA(x, y, color.NRGBA{R: n, G: n / 2, B: 255 - n, A: 255})
+		}
+	}
+	return img
+}
+
+// encode encodes 'img' with the default configuration, adjusted by
+// 'configure' if not nil.
+func encode(t testing.TB, img image.Image, configure func(conf *config.Config)) []byte {
+	t.Helper()
+
+	var conf config.Config
+	require.NoError(t, conf.Init())
+	if configure != nil {
+		configure(&conf)
+	}
+	var buf bytes.Buffer
+	require.NoError(t, webp.Encode(&buf, img, &conf))
+	return buf.Bytes()
+}
+
+// lossless is a configure function for encode.
+func lossless(conf *config.Config) {
+	conf.Lossless = 1
+}
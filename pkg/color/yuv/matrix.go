@@ -0,0 +1,218 @@
+package yuv
+
+import "math"
+
+// Range of the Y'CbCr samples.
+type Range int
+
+const (
+	RANGE_LIMITED Range = iota // Y in [16..235], U/V in [16..240] ("TV" / "studio" range)
+	RANGE_FULL                 // Y/U/V in [0..255] ("PC" / "JPEG" range)
+)
+
+// Matrix describes a Y'CbCr colour space: the luma coefficients of the
+// standard and the range of the samples, along with the fixed-point
+// coefficients used for the conversions in both directions.
+//
+// RGB.YUV uses YUV_FIX fixed-point precision, like RGBToY/U/V.
+// YUV.RGB uses 14-bit coefficients for MultHi(), like YUVToR/G/B; the
+// offsets include the rounding of the final YUV_FIX2 descaling.
+type Matrix struct {
+	Kr, Kb float64 // luma coefficients of red and blue
+	Range  Range
+
+	// RGB.YUV coefficients, the last entry is the offset.
+	RGBToYCoeffs [4]int
+	RGBToUCoeffs [4]int
+	RGBToVCoeffs [4]int
+
+	// YUV.RGB coefficients
+	YScale                    int // luma scale, shared by R/G/B
+	VToR, UToG, VToG, UToB    int
+	ROffset, GOffset, BOffset int
+}
+
+// Luma coefficients of the supported standards.
+const (
+	KR_BT601  = 0.299
+	KB_BT601  = 0.114
+	KR_BT709  = 0.2126
+	KB_BT709  = 0.0722
+	KR_BT2020 = 0.2627
+	KB_BT2020 = 0.0593
+)
+
+var (
+	// BT.601 limited range, with the coefficients historically used by WebP.
+	// This is the default matrix and matches RGBToY/U/V and YUVToR/G/B.
+	BT601 = &Matrix{
+		Kr: KR_BT601, Kb: KB_BT601, Range: RANGE_LIMITED,
+		RGBToYCoeffs: [4]int{16839, 33059, 6420, 16 << YUV_FIX},
+		RGBToUCoeffs: [4]int{-9719, -19081, 28800, 128 << YUV_FIX},
+		RGBToVCoeffs: [4]int{28800, -24116, -4684, 128 << YUV_FIX},
+		YScale:       19077,
+		VToR:         26149, UToG: 6419, VToG: 13320, UToB: 33050,
+		ROffset: -14234, GOffset: 8708, BOffset: -17685,
+	}
+	BT601Full  = NewMatrix(KR_BT601, KB_BT601, RANGE_FULL)
+	BT709      = NewMatrix(KR_BT709, KB_BT709, RANGE_LIMITED)
+	BT709Full  = NewMatrix(KR_BT709, KB_BT709, RANGE_FULL)
+	BT2020     = NewMatrix(KR_BT2020, KB_BT2020, RANGE_LIMITED)
+	BT2020Full = NewMatrix(KR_BT2020, KB_BT2020, RANGE_FULL)
+)
+
+// MatrixOrDefault returns 'm', or BT601 if 'm' is nil.
+func MatrixOrDefault(m *Matrix) *Matrix {
+	if m == nil {
+		return BT601
+	}
+	return m
+}
+
+// NewMatrix computes the conversion coefficients for the given luma
+// coefficients and range.
+func NewMatrix(kr, kb float64, r Range) *Matrix {
+	kg := 1. - kr - kb
+	scale_y, scale_uv, add_y := 1., 1., 0.
+	if r == RANGE_LIMITED {
+		scale_y = 219. / 255.
+		scale_uv = 224. / 255.
+		add_y = 16.
+	}
+	// Half of the chroma excursion, per unit of (B-Y) and (R-Y)
+	cb := 0.5 / (1. - kb) * scale_uv
+	cr := 0.5 / (1. - kr) * scale_uv
+
+	fix := func(v float64) int { return int(math.Floor(v*(1<<YUV_FIX) + 0.5)) }
+	fix14 := func(v float64) int { return int(math.Floor(v*(1<<14) + 0.5)) }
+
+	m := &Matrix{Kr: kr, Kb: kb, Range: r}
+	m.RGBToYCoeffs = [4]int{fix(kr * scale_y), fix(kg * scale_y), fix(kb * scale_y), fix(add_y)}
+	m.RGBToUCoeffs = [4]int{fix(-kr * cb), fix(-kg * cb), fix((1 - kb) * cb), 128 << YUV_FIX}
+	m.RGBToVCoeffs = [4]int{fix((1 - kr) * cr), fix(-kg * cr), fix(-kb * cr), 128 << YUV_FIX}
+
+	// Inverse: R = Y' + 2(1-kr).Cr, B = Y' + 2(1-kb).Cb, G = (Y' - kr.R - kb.B) / kg
+	inv_y := 1. / scale_y
+	inv_uv := 1. / scale_uv
+	m.YScale = fix14(inv_y)
+	m.VToR = fix14(2 * (1 - kr) * inv_uv)
+	m.UToB = fix14(2 * (1 - kb) * inv_uv)
+	m.UToG = fix14(2 * (1 - kb) * kb / kg * inv_uv)
+	m.VToG = fix14(2 * (1 - kr) * kr / kg * inv_uv)
+
+	// MultHi(v, c) = v * c >> 8, so offsets are in YUV_FIX2 precision.
+	const rounder = 1 << (YUV_FIX2 - 1)
+	y_off := add_y * float64(m.YScale) / 256.
+	m.ROffset = int(math.Floor(-y_off-128.*float64(m.VToR)/256.)) + rounder
+	m.GOffset = int(math.Floor(-y_off+128.*float64(m.UToG+m.VToG)/256.)) + rounder
+	m.BOffset = int(math.Floor(-y_off-128.*float64(m.UToB)/256.)) + rounder
+	return m
+}
+
+//------------------------------------------------------------------------------
+// RGB.YUV
+
+// RGBToY converts a single pixel to luma.
+func (m *Matrix) RGBToY(r int, g int, b int, rounding int) int {
+	c := &m.RGBToYCoeffs
+	luma := c[0]*r + c[1]*g + c[2]*b
+	y := (luma + rounding + c[3]) >> YUV_FIX
+	if y&^0xff != 0 {
+		if y < 0 {
+			return 0
+		}
+		return 255
+	}
+	return y
+}
+
+// RGBToU expects the r/g/b values summed over four pixels.
+func (m *Matrix) RGBToU(r int, g int, b int, rounding int) int {
+	c := &m.RGBToUCoeffs
+	return m.clipUV(c[0]*r+c[1]*g+c[2]*b, rounding)
+}
+
+// RGBToV expects the r/g/b values summed over four pixels.
+func (m *Matrix) RGBToV(r int, g int, b int, rounding int) int {
+	c := &m.RGBToVCoeffs
+	return m.clipUV(c[0]*r+c[1]*g+c[2]*b, rounding)
+}
+
+func (m *Matrix) clipUV(uv int, rounding int) int {
+	uv = (uv + rounding + (m.RGBToUCoeffs[3] << 2)) >> (YUV_FIX + 2)
+	if uv&^0xff != 0 {
+		if uv < 0 {
+			return 0
+		}
+		return 255
+	}
+	return uv
+}
+
+//------------------------------------------------------------------------------
+// YUV.RGB
+
+func (m *Matrix) YUVToR(y int, v int) int {
+	return Clip8(MultHi(y, m.YScale) + MultHi(v, m.VToR) + m.ROffset)
+}
+
+func (m *Matrix) YUVToG(y, u, v int) int {
+	return Clip8(MultHi(y, m.YScale) - MultHi(u, m.UToG) - MultHi(v, m.VToG) + m.GOffset)
+}
+
+func (m *Matrix) YUVToB(y, u int) int {
+	return Clip8(MultHi(y, m.YScale) + MultHi(u, m.UToB) + m.BOffset)
+}
+
+func (m *Matrix) YuvToRgb(y, u, v uint8, rgb []uint8) {
+	rgb[0] = uint8(m.YUVToR(int(y), int(v)))
+	rgb[1] = uint8(m.YUVToG(int(y), int(u), int(v)))
+	rgb[2] = uint8(m.YUVToB(int(y), int(u)))
+}
+
+func (m *Matrix) YuvToBgr(y, u, v uint8, bgr []uint8) {
+	bgr[0] = uint8(m.YUVToB(int(y), int(u)))
+	bgr[1] = uint8(m.YUVToG(int(y), int(u), int(v)))
+	bgr[2] = uint8(m.YUVToR(int(y), int(v)))
+}
+
+func (m *Matrix) YuvToRgba(y, u, v uint8, rgba []uint8) {
+	m.YuvToRgb(y, u, v, rgba)
+	rgba[3] = 0xff
+}
+
+func (m *Matrix) YuvToBgra(y, u, v uint8, bgra []uint8) {
+	m.YuvToBgr(y, u, v, bgra)
+	bgra[3] = 0xff
+}
+
+func (m *Matrix) YuvToArgb(y, u, v uint8, argb []uint8) {
+	argb[0] = 0xff
+	m.YuvToRgb(y, u, v, argb[1:])
+}
+
+func (m *Matrix) YuvToRgba4444(y, u, v uint8, argb []uint8) {
+	r := m.YUVToR(int(y), int(v))         // 4 usable bits
+	g := m.YUVToG(int(y), int(u), int(v)) // 4 usable bits
+	b := m.YUVToB(int(y), int(u))         // 4 usable bits
+	argb[0] = uint8((r & 0xf0) | (g >> 4))
+	argb[1] = uint8((b & 0xf0) | 0x0f) // overwrite the lower 4 bits
+}
+
+func (m *Matrix) YuvToRgb565(y, u, v uint8, rgb []uint8) {
+	r := m.YUVToR(int(y), int(v))         // 5 usable bits
+	g := m.YUVToG(int(y), int(u), int(v)) // 6 usable bits
+	b := m.YUVToB(int(y), int(u))         // 5 usable bits
+	rgb[0] = uint8((r & 0xf8) | (g >> 5))
+	rgb[1] = uint8(((g << 3) & 0xe0) | (b >> 3))
+}
+
+// Pixel emitters using the default (BT601) matrix.
+
+func YuvToRgb(y, u, v uint8, rgb []uint8)       { BT601.YuvToRgb(y, u, v, rgb) }
+func YuvToBgr(y, u, v uint8, bgr []uint8)       { BT601.YuvToBgr(y, u, v, bgr) }
+func YuvToRgba(y, u, v uint8, rgba []uint8)     { BT601.YuvToRgba(y, u, v, rgba) }
+func YuvToBgra(y, u, v uint8, bgra []uint8)     { BT601.YuvToBgra(y, u, v, bgra) }
+func YuvToArgb(y, u, v uint8, argb []uint8)     { BT601.YuvToArgb(y, u, v, argb) }
+func YuvToRgba4444(y, u, v uint8, argb []uint8) { BT601.YuvToRgba4444(y, u, v, argb) }
+func YuvToRgb565(y, u, v uint8, rgb []uint8)    { BT601.YuvToRgb565(y, u, v, rgb) }
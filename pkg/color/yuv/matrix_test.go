@@ -0,0 +1,129 @@
+package yuv
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var matrices = map[string]*Matrix{
+	"BT601":      BT601,
+	"BT601Full":  BT601Full,
+	"BT709":      BT709,
+	"BT709Full":  BT709Full,
+	"BT2020":     BT2020,
+	"BT2020Full": BT2020Full,
+}
+
+// toYUV converts a flat 2x2 block of (r, g, b), as the importers do.
+func toYUV(m *Matrix, r, g, b int) (y, u, v int) {
+	return m.RGBToY(r, g, b, YUV_HALF),
+		m.RGBToU(4*r, 4*g, 4*b, YUV_HALF<<2),
+		m.RGBToV(4*r, 4*g, 4*b, YUV_HALF<<2)
+}
+
+// The default matrix is the historical fixed-point conversion.
+func TestBT601MatchesDefaultConversion(t *testing.T) {
+	for r := 0; r < 256; r += 5 {
+		for g := 0; g < 256; g += 5 {
+			for b := 0; b < 256; b += 5 {
+				y, u, v := toYUV(BT601, r, g, b)
+				require.Equal(t, RGBToY(r, g, b, YUV_HALF), y)
+				require.Equal(t, RGBToU(4*r, 4*g, 4*b, YUV_HALF<<2), u)
+				require.Equal(t, RGBToV(4*r, 4*g, 4*b, YUV_HALF<<2), v)
+
+				require.Equal(t, YUVToR(y, v), BT601.YUVToR(y, v))
+				require.Equal(t, YUVToG(y, u, v), BT601.YUVToG(y, u, v))
+				require.Equal(t, YUVToB(y, u), BT601.YUVToB(y, u))
+			}
+		}
+	}
+}
+
+func TestMatrixKnownValues(t *testing.T) {
+	for _, tc := range []struct {
+		matrix  string
+		r, g, b int
+		y, u, v int
+	}{
+		{"BT601", 0, 0, 0, 16, 128, 128},
+		{"BT601", 255, 255, 255, 235, 128, 128},
+		{"BT601", 255, 0, 0, 82, 90, 240},
+		{"BT601Full", 0, 0, 0, 0, 128, 128},
+		{"BT601Full", 255, 255, 255, 255, 128, 128},
+		{"BT601Full", 255, 0, 0, 76, 85, 255},
+		{"BT601Full", 0, 0, 255, 29, 255, 107},
+		{"BT709", 0, 0, 0, 16, 128, 128},
+		{"BT709", 255, 255, 255, 235, 128, 128},
+		{"BT709", 255, 0, 0, 63, 102, 240},
+		{"BT709", 0, 255, 0, 173, 42, 26},
+		{"BT709", 0, 0, 255, 32, 240, 118},
+		{"BT709Full", 255, 0, 0, 54, 99, 255},
+		{"BT2020", 255, 0, 0, 74, 97, 240},
+		{"BT2020Full", 0, 255, 0, 173, 36, 11},
+	} {
+		y, u, v := toYUV(matrices[tc.matrix], tc.r, tc.g, tc.b)
+		require.Equal(t, [3]int{tc.y, tc.u, tc.v}, [3]int{y, u, v},
+			"%s (%d, %d, %d)", tc.matrix, tc.r, tc.g, tc.b)
+	}
+}
+
+// Converting to YUV and back gives the RGB values, up to the precision of
+// the samples: limited ranges lose a bit more than full ones.
+func TestMatrixRoundTrip(t *testing.T) {
+	for name, m := range matrices {
+		max_diff := 2
+		if m.Range == RANGE_LIMITED {
+			max_diff = 3
+		}
+		for r := 0; r < 256; r += 17 {
+			for g := 0; g < 256; g += 17 {
+				for b := 0; b < 256; b += 17 {
+					y, u, v := toYUV(m, r, g, b)
+					if u == 0 || u == 255 || v == 0 || v == 255 {
+						continue // clipped chroma, saturated full-range colors
+					}
+					var rgb [3]uint8
+					m.YuvToRgb(uint8(y), uint8(u), uint8(v), rgb[:])
+					for c, want := range []int{r, g, b} {
+						require.InDelta(t, want, int(rgb[c]), float64(max_diff),
+							"%s (%d, %d, %d) channel %d", name, r, g, b, c)
+					}
+				}
+			}
+		}
+	}
+}
+
+// Greys have neutral chroma and map back to themselves.
+func TestMatrixGreys(t *testing.T) {
+	for name, m := range matrices {
+		for l := 0; l < 256; l++ {
+			y, u, v := toYUV(m, l, l, l)
+			require.Equal(t, 128, u, "%s grey %d", name, l)
+			require.Equal(t, 128, v, "%s grey %d", name, l)
+			var rgb [3]uint8
+			m.YuvToRgb(uint8(y), uint8(u), uint8(v), rgb[:])
+			for c := range rgb {
+				require.InDelta(t, l, int(rgb[c]), 1, "%s grey %d", name, l)
+			}
+		}
+	}
+}
+
+// The RGB.YUV coefficients are those of libwebp's sharpyuv matrices.
+func TestNewMatrixCoefficients(t *testing.T) {
+	for _, tc := range []struct {
+		matrix  *Matrix
+		y, u, v [4]int
+	}{
+		{BT601Full, [4]int{19595, 38470, 7471, 0}, [4]int{-11058, -21710, 32768, 128 << 16}, [4]int{32768, -27439, -5329, 128 << 16}},
+		{BT709Full, [4]int{13933, 46871, 4732, 0}, [4]int{-7509, -25259, 32768, 128 << 16}, [4]int{32768, -29763, -3005, 128 << 16}},
+		{NewMatrix(KR_BT601, KB_BT601, RANGE_LIMITED), [4]int{16829, 33039, 6416, 16 << 16}, [4]int{-9714, -19071, 28784, 128 << 16}, [4]int{28784, -24103, -4681, 128 << 16}},
+		{BT709, [4]int{11966, 40254, 4064, 16 << 16}, [4]int{-6596, -22189, 28784, 128 << 16}, [4]int{28784, -26145, -2639, 128 << 16}},
+	} {
+		require.Equal(t, tc.y, tc.matrix.RGBToYCoeffs, "Kr %v Kb %v range %d", tc.matrix.Kr, tc.matrix.Kb, tc.matrix.Range)
+		require.Equal(t, tc.u, tc.matrix.RGBToUCoeffs, "Kr %v Kb %v range %d", tc.matrix.Kr, tc.matrix.Kb, tc.matrix.Range)
+		require.Equal(t, tc.v, tc.matrix.RGBToVCoeffs, "Kr %v Kb %v range %d", tc.matrix.Kr, tc.matrix.Kb, tc.matrix.Range)
+	}
+}
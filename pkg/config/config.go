@@ -2,8 +2,12 @@ package config
 
 import (
 	"errors"
+	"fmt"
 )
 
+// Maximum value of Config.ThreadLevel.
+const MAX_THREAD_LEVEL = 64
+
 // Compression parameters.
 type Config struct {
 	Lossless int // Lossless encoding (0=lossy(default), 1=lossless).
@@ -29,16 +33,24 @@ type Config struct {
 	FilterSharpness int // range: [0 = off .. 7 = least sharp]
 	// filtering type: 0 = simple, 1 = strong (only used
 	// if filter_strength > 0 or autofilter > 0)
-	FilterType       int
-	Autofilter       int // Auto adjust filter's strength [0 = off, 1 = on]
-	AlphaCompression int // Algorithm for encoding the alpha plane (0 = none, // 1 = compressed with WebP lossless). Default is 1.
-	// Predictive filtering method for alpha plane.
-	//  0: none, 1: fast, 2: best. Default if 1.
-	AlphaFiltering int
-	// Between 0 (smallest size) and 100 (lossless).
+	FilterType int
+	Autofilter int // Auto adjust filter's strength [0 = off, 1 = on]
+	// Algorithm for encoding the alpha plane. Default is
+	// ALPHA_COMPRESSION_LOSSLESS.
+	AlphaCompression AlphaCompression
+	// Predictive filtering method for alpha plane. Default is
+	// ALPHA_FILTER_FAST.
+	AlphaFiltering AlphaFilter
+	// Between 0 (smallest size) and 100 (lossless). Below 100, the number
+	// of alpha levels is reduced before compression.
 	// Default is 100.
 	AlphaQuality int
-	Pass         int // number of entropy-analysis passes (in [1..10]).
+	// Effort of the lossless alpha compression, in [0..6], independently
+	// of Method. -1 uses Method. -1 is the default set by Init() and
+	// InitPreset(): a Config that skipped them, as the zero value does,
+	// gets effort 0, the fastest.
+	AlphaMethod int
+	Pass        int // number of entropy-analysis passes (in [1..10]).
 
 	// if true, export the compressed picture back.
 	// In-loop filtering is not applied.
@@ -57,8 +69,11 @@ type Config struct {
 	// JPEG compression. Generally, the output size will
 	// be similar but the degradation will be lower.
 	EmulateJpegSize int
-	ThreadLevel     int // If non-zero, try and use multi-threaded encoding.
-	LowMemory       int // If set, reduce memory usage (but increase CPU use).
+	// Number of worker goroutines used for lossy encoding, in addition to the
+	// calling one (0 = single-threaded). The output only depends on whether
+	// it is zero or not.
+	ThreadLevel int
+	LowMemory   int // If set, reduce memory usage (but increase CPU use).
 
 	// Near lossless encoding [0 = max loss .. 100 = off
 	// (default)].
@@ -72,6 +87,10 @@ type Config struct {
 
 	UseDeltaPalette int // reserved
 	UseSharpYUV     int // if needed, use sharp (and slow) RGB.YUV conversion
+	// Reduction of samples wider than 8 bits (16-bit and float images) when
+	// they are imported: 0=round to nearest, 1=ordered (Bayer) dithering,
+	// 2=error diffusion. See picture.WideDither.
+	WideDithering int
 
 	Qmin int // minimum permissible quality factor
 	Qmax int // maximum permissible quality factor
@@ -132,9 +151,10 @@ func (config *Config) init(preset Preset, quality float64) error {
 	config.Preprocessing = 0
 	config.Autofilter = 0
 	config.PartitionLimit = 0
-	config.AlphaCompression = 1
-	config.AlphaFiltering = 1
+	config.AlphaCompression = ALPHA_COMPRESSION_LOSSLESS
+	config.AlphaFiltering = ALPHA_FILTER_FAST
 	config.AlphaQuality = 100
+	config.AlphaMethod = -1
 	config.Lossless = 0
 	config.Exact = 0
 	config.ImageHint = WEBP_HINT_DEFAULT
@@ -143,6 +163,7 @@ func (config *Config) init(preset Preset, quality float64) error {
 	config.LowMemory = 0
 	config.NearLossless = 100
 	config.UseSharpYUV = 0
+	config.WideDithering = 0
 
 	switch preset {
 	case WEBP_PRESET_DEFAULT:
@@ -230,15 +251,18 @@ func (config *Config) Validate() error {
 	if config.PartitionLimit < 0 || config.PartitionLimit > 100 {
 		return errors.New("partition_limit must be between 0 and 100")
 	}
-	if config.AlphaCompression < 0 {
-		return errors.New("alpha_compression must be non-negative")
+	if config.AlphaCompression < 0 || config.AlphaCompression >= ALPHA_COMPRESSION_LAST {
+		return errors.New("alpha_compression must be less than ALPHA_COMPRESSION_LAST")
 	}
-	if config.AlphaFiltering < 0 {
-		return errors.New("alpha_filtering must be non-negative")
+	if config.AlphaFiltering < 0 || config.AlphaFiltering >= ALPHA_FILTER_LAST {
+		return errors.New("alpha_filtering must be less than ALPHA_FILTER_LAST")
 	}
 	if config.AlphaQuality < 0 || config.AlphaQuality > 100 {
 		return errors.New("alpha_quality must be between 0 and 100")
 	}
+	if config.AlphaMethod < -1 || config.AlphaMethod > 6 {
+		return errors.New("alpha_method must be between -1 and 6")
+	}
 	if config.Lossless < 0 || config.Lossless > 1 {
 		return errors.New("lossless must be 0 or 1")
 	}
@@ -251,8 +275,8 @@ func (config *Config) Validate() error {
 	if config.EmulateJpegSize < 0 || config.EmulateJpegSize > 1 {
 		return errors.New("emulate_jpeg_size must be 0 or 1")
 	}
-	if config.ThreadLevel < 0 || config.ThreadLevel > 1 {
-		return errors.New("thread_level must be 0 or 1")
+	if config.ThreadLevel < 0 || config.ThreadLevel > MAX_THREAD_LEVEL {
+		return fmt.Errorf("thread_level must be between 0 and %d", MAX_THREAD_LEVEL)
 	}
 	if config.LowMemory < 0 || config.LowMemory > 1 {
 		return errors.New("low_memory must be 0 or 1")
@@ -263,6 +287,9 @@ func (config *Config) Validate() error {
 	if config.UseSharpYUV < 0 || config.UseSharpYUV > 1 {
 		return errors.New("use_sharp_yuv must be 0 or 1")
 	}
+	if config.WideDithering < 0 || config.WideDithering > 2 {
+		return errors.New("wide_dithering must be between 0 and 2")
+	}
 
 	return nil
 }
@@ -23,3 +23,26 @@ const (
 	WEBP_PRESET_ICON                  // small-sized colorful images
 	WEBP_PRESET_TEXT                  // text-like
 )
+
+// Algorithm used to compress the alpha plane of lossy images.
+type AlphaCompression int
+
+const (
+	ALPHA_COMPRESSION_NONE     AlphaCompression = iota // stored as is
+	ALPHA_COMPRESSION_LOSSLESS                         // WebP lossless (default)
+	ALPHA_COMPRESSION_LAST
+)
+
+// Predictive filtering of the alpha plane, applied before compression.
+type AlphaFilter int
+
+const (
+	ALPHA_FILTER_NONE AlphaFilter = iota // no filtering
+	ALPHA_FILTER_FAST                    // estimate the best filter (default)
+	ALPHA_FILTER_BEST                    // try all filters, keep the smallest
+	// Try the estimated filter and no filtering, or all filters when the
+	// alpha effort is 5 or more. The filter kept is reported in
+	// Picture.AlphaFilter.
+	ALPHA_FILTER_AUTO
+	ALPHA_FILTER_LAST
+)
@@ -157,7 +157,11 @@ func AllocateAlphaPlane(/* const */ dec *vp8.VP8Decoder, /* const */ io *VP8Io)
 //------------------------------------------------------------------------------
 // Main entry point.
 
-func uint88DecompressAlphaRows(/* const */ dec *VP8Decoder, /* const */ io *VP8Io, row int, num_rows int ) *VP {
+// Decodes 'num_rows' rows of alpha starting at 'row' and returns a pointer to
+// the first one. If the alpha plane was quantized and dec.alpha_dithering is
+// set, the whole plane is decoded at once and smoothed with
+// WebPDequantizeLevels() before being returned.
+func VP8DecompressAlphaRows(/* const */ dec *VP8Decoder, /* const */ io *VP8Io, row int, num_rows int ) *uint8 {
   width := io.width
   height := io.crop_bottom
 
@@ -176,7 +180,7 @@ func uint88DecompressAlphaRows(/* const */ dec *VP8Decoder, /* const */ io *VP8I
       }
       if !AllocateAlphaPlane(dec, io) { goto Error }
       if (!ALPHInit(dec.alph_dec, dec.alpha_data, dec.alpha_data_size, io, dec.alpha_plane)) {
-        var vp *VP8LDecoder8l_dec = dec.alph_dec.vp8l_dec
+        var vp8l_dec *VP8LDecoder = dec.alph_dec.vp8l_dec
         VP8SetError(
             dec, tenary.If(vp8l_dec == nil, VP8_STATUS_OUT_OF_MEMORY, vp8l_dec.status), "Alpha decoder initialization failed.")
         goto Error
@@ -196,9 +200,8 @@ func uint88DecompressAlphaRows(/* const */ dec *VP8Decoder, /* const */ io *VP8I
     if (dec.is_alpha_decoded) {  // finished?
       dec.alph_dec = nil
       if (dec.alpha_dithering > 0) {
-        var alpha *uint8 = dec.alpha_plane + io.crop_top * width + io.crop_left
-        var bounded_alpha *uint8 = alpha; // bidi index -> (uint64)*width(io.crop_bottom - io.crop_top)
-        if (!WebPDequantizeLevels(bounded_alpha, io.crop_right - io.crop_left, io.crop_bottom - io.crop_top, width, dec.alpha_dithering)) {
+        alpha := dec.alpha_plane[io.crop_top * width + io.crop_left:]
+        if (!WebPDequantizeLevels(alpha, io.crop_right - io.crop_left, io.crop_bottom - io.crop_top, width, dec.alpha_dithering)) {
           goto Error
         }
       }
@@ -206,7 +209,7 @@ func uint88DecompressAlphaRows(/* const */ dec *VP8Decoder, /* const */ io *VP8I
   }
 
   // Return a pointer to the current decoded row.
-  return dec.alpha_plane + row * width
+  return &dec.alpha_plane[row * width]
 
 Error:
   return nil
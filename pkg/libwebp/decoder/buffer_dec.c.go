@@ -183,21 +183,10 @@ func WebPFlipBuffer( /* const */ buffer *WebPDecBuffer) vp8.VP8StatusCode {
 	return vp8.VP8_STATUS_OK
 }
 
-// Prepare 'buffer' with the requested initial dimensions width/height.
-// If no external storage is supplied, initializes buffer by allocating output
-// memory and setting up the stride information. Validate the parameters. Return
-// an error code in case of problem (no memory, or invalid stride / size /
-// dimension / etc.). If is not nil *options, also verify that the options'
-// parameters are valid and apply them to the width/height dimensions of the
-// output buffer. This takes cropping / scaling / rotation into account.
-// Also incorporates the options.flip flag to flip the buffer parameters if
-// needed.
-func WebPAllocateDecBuffer(width int, height int /*const*/, options *WebPDecoderOptions /*const*/, buffer *WebPDecBuffer) vp8.VP8StatusCode {
-	var status vp8.VP8StatusCode
-	if buffer == nil || width <= 0 || height <= 0 {
-		return vp8.VP8_STATUS_INVALID_PARAM
-	}
-	if options != nil { // First, apply options if there is any.
+// Applies the cropping and scaling of 'options' (if not nil) to the
+// 'width' x 'height' bitstream dimensions, giving the output ones.
+func WebPGetOutputDimensions(width int, height int /*const*/, options *WebPDecoderOptions, out_width *int, out_height *int) vp8.VP8StatusCode {
+	if options != nil {
 		if options.use_cropping {
 			cw := options.crop_width
 			ch := options.crop_height
@@ -220,8 +209,30 @@ func WebPAllocateDecBuffer(width int, height int /*const*/, options *WebPDecoder
 			height = scaled_height
 		}
 	}
-	buffer.width = width
-	buffer.height = height
+	*out_width = width
+	*out_height = height
+	return vp8.VP8_STATUS_OK
+}
+
+// Prepare 'buffer' with the requested initial dimensions width/height.
+// If no external storage is supplied, initializes buffer by allocating output
+// memory and setting up the stride information. Validate the parameters. Return
+// an error code in case of problem (no memory, or invalid stride / size /
+// dimension / etc.). If is not nil *options, also verify that the options'
+// parameters are valid and apply them to the width/height dimensions of the
+// output buffer. This takes cropping / scaling / rotation into account.
+// Also incorporates the options.flip flag to flip the buffer parameters if
+// needed.
+func WebPAllocateDecBuffer(width int, height int /*const*/, options *WebPDecoderOptions /*const*/, buffer *WebPDecBuffer) vp8.VP8StatusCode {
+	var status vp8.VP8StatusCode
+	if buffer == nil || width <= 0 || height <= 0 {
+		return vp8.VP8_STATUS_INVALID_PARAM
+	}
+	// First, apply options if there is any.
+	status = WebPGetOutputDimensions(width, height, options, &buffer.width, &buffer.height)
+	if status != vp8.VP8_STATUS_OK {
+		return status
+	}
 
 	// Then, allocate buffer for real.
 	status = AllocateBuffer(buffer)
@@ -66,14 +66,13 @@ func DoUVTransform(bits uint32, /*const*/ src *int16, /*const*/ dst []uint8) {
   }
 }
 
-func ReconstructRow(/* const */ dec *VP8Decoder, /*const*/ ctx *VP8ThreadContext) {
+// Initialize the left (and top-left) samples of 'yuv_b' at the start of row
+// 'mb_y'.
+func InitLeftSamples(/* const */ yuv_b *uint8, mb_y int) {
   var j int
-  var mb_x int
-  mb_y := ctx.mb_y
-  cache_id := ctx.id
-  var y_dst []uint8 = dec.yuv_b + Y_OFF
-  var u_dst []uint8 = dec.yuv_b + U_OFF
-  var v_dst []uint8 = dec.yuv_b + V_OFF
+  var y_dst []uint8 = yuv_b + Y_OFF
+  var u_dst []uint8 = yuv_b + U_OFF
+  var v_dst []uint8 = yuv_b + V_OFF
 
   // Initialize left-most block.
   for j = 0; j < 16; j++ {
@@ -96,88 +95,119 @@ func ReconstructRow(/* const */ dec *VP8Decoder, /*const*/ ctx *VP8ThreadContext
     stdlib.Memset(u_dst -constants.BPS - 1, 127, 8 + 1)
     stdlib.Memset(v_dst -constants.BPS - 1, 127, 8 + 1)
   }
+}
 
-  // Reconstruct one row.
-  for mb_x = 0; mb_x < dec.mb_w; mb_x++ {
-    var block *VP8MBData = ctx.mb_data + mb_x
-
-    // Rotate in the left samples from previously decoded block. We move four
-    // pixels at a time for alignment reason, and because of in-loop filter.
-    if (mb_x > 0) {
-      for j = -1; j < 16; j++ {
-        Copy32b(&y_dst[j *constants.BPS - 4], &y_dst[j *constants.BPS + 12])
-      }
-      for j = -1; j < 8; j++ {
-        Copy32b(&u_dst[j *constants.BPS - 4], &u_dst[j *constants.BPS + 4])
-        Copy32b(&v_dst[j *constants.BPS - 4], &v_dst[j *constants.BPS + 4])
-      }
+// Reconstruct macroblock (mb_x, mb_y) into 'yuv_b', using the top samples in
+// dec.yuv_t (which are updated for the next row). Macroblocks of a row must be
+// reconstructed in order, after InitLeftSamples().
+func ReconstructMB(/* const */ dec *VP8Decoder, /*const*/ block *VP8MBData, /*const*/ yuv_b *uint8, mb_x int, mb_y int) {
+  var j int
+  var y_dst []uint8 = yuv_b + Y_OFF
+  var u_dst []uint8 = yuv_b + U_OFF
+  var v_dst []uint8 = yuv_b + V_OFF
+
+  // Rotate in the left samples from previously decoded block. We move four
+  // pixels at a time for alignment reason, and because of in-loop filter.
+  if (mb_x > 0) {
+    for j = -1; j < 16; j++ {
+      Copy32b(&y_dst[j *constants.BPS - 4], &y_dst[j *constants.BPS + 12])
     }
-    {
-      // bring top samples into the cache
-      var top_yuv *VP8TopSamples = dec.yuv_t + mb_x
-      var coeffs *int16 = block.coeffs
-      bits := block.non_zero_y
-      var n int
+    for j = -1; j < 8; j++ {
+      Copy32b(&u_dst[j *constants.BPS - 4], &u_dst[j *constants.BPS + 4])
+      Copy32b(&v_dst[j *constants.BPS - 4], &v_dst[j *constants.BPS + 4])
+    }
+  }
+  {
+    // bring top samples into the cache
+    var top_yuv *VP8TopSamples = dec.yuv_t + mb_x
+    var coeffs *int16 = block.coeffs
+    bits := block.non_zero_y
+    var n int
 
-      if (mb_y > 0) {
-        stdlib.MemCpy(y_dst -constants.BPS, top_yuv[0].y, 16)
-        stdlib.MemCpy(u_dst -constants.BPS, top_yuv[0].u, 8)
-        stdlib.MemCpy(v_dst -constants.BPS, top_yuv[0].v, 8)
-      }
+    if (mb_y > 0) {
+      stdlib.MemCpy(y_dst -constants.BPS, top_yuv[0].y, 16)
+      stdlib.MemCpy(u_dst -constants.BPS, top_yuv[0].u, 8)
+      stdlib.MemCpy(v_dst -constants.BPS, top_yuv[0].v, 8)
+    }
 
-      // predict and add residuals
-      if (block.is_i4x4) {  // 4x4
-        var top_right *uint32 = (*uint32)(y_dst -constants.BPS + 16)
+    // predict and add residuals
+    if (block.is_i4x4) {  // 4x4
+      var top_right *uint32 = (*uint32)(y_dst -constants.BPS + 16)
 
-        if (mb_y > 0) {
-          if (mb_x >= dec.mb_w - 1) {  // on rightmost border
-            stdlib.Memset(top_right, top_yuv[0].y[15], sizeof(*top_right))
-          } else {
-            stdlib.MemCpy(top_right, top_yuv[1].y, sizeof(*top_right))
-          }
+      if (mb_y > 0) {
+        if (mb_x >= dec.mb_w - 1) {  // on rightmost border
+          stdlib.Memset(top_right, top_yuv[0].y[15], sizeof(*top_right))
+        } else {
+          stdlib.MemCpy(top_right, top_yuv[1].y, sizeof(*top_right))
         }
-        // replicate the top-right pixels below
-        top_right[BPS] = top_right[0]
-		top_right[2 *constants.BPS] = top_right[0]
-		top_right[3 *constants.BPS] = top_right[0]
-
-        // predict and add residuals for all 4x4 blocks in turn.
+      }
+      // replicate the top-right pixels below
+      top_right[BPS] = top_right[0]
+      top_right[2 *constants.BPS] = top_right[0]
+      top_right[3 *constants.BPS] = top_right[0]
+
+      // predict and add residuals for all 4x4 blocks in turn.
+      for n = 0; n < 16;  {
+        var dst []uint8 = y_dst + kScan[n]
+        VP8PredLuma4[block.imodes[n]](dst)
+        DoTransform(bits, coeffs + n * 16, dst)
+        n++
+        bits <<= 2
+      }
+    } else {  // 16x16
+      pred_func := CheckMode(mb_x, mb_y, block.imodes[0])
+      VP8PredLuma16[pred_func](y_dst)
+      if (bits != 0) {
         for n = 0; n < 16;  {
-          var dst []uint8 = y_dst + kScan[n]
-          VP8PredLuma4[block.imodes[n]](dst)
-          DoTransform(bits, coeffs + n * 16, dst)
-		  n++
-		  bits <<= 2
+          DoTransform(bits, coeffs + n * 16, y_dst + kScan[n])
+          n++
+          bits <<= 2
         }
-      } else {  // 16x16
-        pred_func := CheckMode(mb_x, mb_y, block.imodes[0])
-        VP8PredLuma16[pred_func](y_dst)
-        if (bits != 0) {
-          for n = 0; n < 16;  {
-            DoTransform(bits, coeffs + n * 16, y_dst + kScan[n])
-
-			n++
-			 bits <<= 2
-          }
-        }
-      }
-      {
-        // Chroma
-        bits_uv := block.non_zero_uv
-        pred_func := CheckMode(mb_x, mb_y, block.uvmode)
-        VP8PredChroma8[pred_func](u_dst)
-        VP8PredChroma8[pred_func](v_dst)
-        DoUVTransform(bits_uv >> 0, coeffs + 16 * 16, u_dst)
-        DoUVTransform(bits_uv >> 8, coeffs + 20 * 16, v_dst)
       }
+    }
+    {
+      // Chroma
+      bits_uv := block.non_zero_uv
+      pred_func := CheckMode(mb_x, mb_y, block.uvmode)
+      VP8PredChroma8[pred_func](u_dst)
+      VP8PredChroma8[pred_func](v_dst)
+      DoUVTransform(bits_uv >> 0, coeffs + 16 * 16, u_dst)
+      DoUVTransform(bits_uv >> 8, coeffs + 20 * 16, v_dst)
+    }
 
-      // stash away top samples for next block
-      if (mb_y < dec.mb_h - 1) {
-        stdlib.MemCpy(top_yuv[0].y, y_dst + 15 *constants.BPS, 16)
-        stdlib.MemCpy(top_yuv[0].u, u_dst + 7 *constants.BPS, 8)
-        stdlib.MemCpy(top_yuv[0].v, v_dst + 7 *constants.BPS, 8)
-      }
+    // stash away top samples for next block
+    if (mb_y < dec.mb_h - 1) {
+      stdlib.MemCpy(top_yuv[0].y, y_dst + 15 *constants.BPS, 16)
+      stdlib.MemCpy(top_yuv[0].u, u_dst + 7 *constants.BPS, 8)
+      stdlib.MemCpy(top_yuv[0].v, v_dst + 7 *constants.BPS, 8)
     }
+  }
+}
+
+// Copy the macroblock reconstructed in 'yuv_b' to 'y_out', 'u_out' and 'v_out'.
+func TransferMB(/* const */ yuv_b *uint8, y_out *uint8, u_out *uint8, v_out *uint8, y_stride int, uv_stride int) {
+  var j int
+  for j = 0; j < 16; j++ {
+    stdlib.MemCpy(y_out + j * y_stride, yuv_b + Y_OFF + j *constants.BPS, 16)
+  }
+  for j = 0; j < 8; j++ {
+    stdlib.MemCpy(u_out + j * uv_stride, yuv_b + U_OFF + j *constants.BPS, 8)
+    stdlib.MemCpy(v_out + j * uv_stride, yuv_b + V_OFF + j *constants.BPS, 8)
+  }
+}
+
+func ReconstructRow(/* const */ dec *VP8Decoder, /*const*/ ctx *VP8ThreadContext) {
+  var mb_x int
+  mb_y := ctx.mb_y
+  cache_id := ctx.id
+
+  InitLeftSamples(dec.yuv_b, mb_y)
+
+  // Reconstruct one row.
+  for mb_x = 0; mb_x < dec.mb_w; mb_x++ {
+    var block *VP8MBData = ctx.mb_data + mb_x
+    ReconstructMB(dec, block, dec.yuv_b, mb_x, mb_y)
+
     // Transfer reconstructed samples from yuv_b cache to final destination.
     {
       y_offset := cache_id * 16 * dec.cache_y_stride
@@ -185,13 +215,7 @@ func ReconstructRow(/* const */ dec *VP8Decoder, /*const*/ ctx *VP8ThreadContext
       var y_out *uint8 = dec.cache_y + mb_x * 16 + y_offset
       var u_out *uint8 = dec.cache_u + mb_x * 8 + uv_offset
       var v_out *uint8 = dec.cache_v + mb_x * 8 + uv_offset
-      for j = 0; j < 16; j++ {
-        stdlib.MemCpy(y_out + j * dec.cache_y_stride, y_dst + j *constants.BPS, 16)
-      }
-      for j = 0; j < 8; j++ {
-        stdlib.MemCpy(u_out + j * dec.cache_uv_stride, u_dst + j *constants.BPS, 8)
-        stdlib.MemCpy(v_out + j * dec.cache_uv_stride, v_dst + j *constants.BPS, 8)
-      }
+      TransferMB(dec.yuv_b, y_out, u_out, v_out, dec.cache_y_stride, dec.cache_uv_stride)
     }
   }
 }
@@ -205,8 +229,16 @@ func DoFilter(/* const */ dec *VP8Decoder, mb_x int, mb_y int) {
   var ctx *VP8ThreadContext = &dec.thread_ctx
   cache_id := ctx.id
   y_bps := dec.cache_y_stride
+  uv_bps := dec.cache_uv_stride
   var f_info *VP8FInfo = ctx.f_info + mb_x
   var y_dst []uint8 = dec.cache_y + cache_id * 16 * y_bps + mb_x * 16
+  var u_dst []uint8 = dec.cache_u + cache_id * 8 * uv_bps + mb_x * 8
+  var v_dst []uint8 = dec.cache_v + cache_id * 8 * uv_bps + mb_x * 8
+  FilterMB(dec, f_info, mb_x, mb_y, y_dst, u_dst, v_dst, y_bps, uv_bps)
+}
+
+// Apply the in-loop filter to the macroblock at 'y_dst', 'u_dst' and 'v_dst'.
+func FilterMB(/* const */ dec *VP8Decoder, /*const*/ f_info *VP8FInfo, mb_x int, mb_y int, y_dst []uint8, u_dst []uint8, v_dst []uint8, y_bps int, uv_bps int) {
   ilevel := f_info.f_ilevel
   limit := f_info.f_limit
   if (limit == 0) {
@@ -227,9 +259,6 @@ func DoFilter(/* const */ dec *VP8Decoder, mb_x int, mb_y int) {
       VP8SimpleVFilter16i(y_dst, y_bps, limit)
     }
   } else {  // complex
-    uv_bps := dec.cache_uv_stride
-    var u_dst []uint8 = dec.cache_u + cache_id * 8 * uv_bps + mb_x * 8
-    var v_dst []uint8 = dec.cache_v + cache_id * 8 * uv_bps + mb_x * 8
     hev_thresh := f_info.hev_thresh
     if (mb_x > 0) {
       VP8HFilter16(y_dst, y_bps, limit + 4, ilevel, hev_thresh)
@@ -395,9 +424,9 @@ func MACROBLOCK_VPOS(mb_y uint64) uint64 {
 }
 
 // Finalize and transmit a complete row. Return false in case of user-abort.
-func FinishRow(arg *vp8.VP8Decoder, arg *vp8.VP8Io) int {
-  var dec *vp8.VP8Decoder = arg1
-  var io *vp8.VP8Io = arg2
+func FinishRow(arg1, arg2 any) int {
+  var dec *vp8.VP8Decoder = arg1.(*vp8.VP8Decoder)
+  var io *vp8.VP8Io = arg2.(*vp8.VP8Io)
   ok := 1
   var ctx *VP8ThreadContext = &dec.thread_ctx
   cache_id := ctx.id
@@ -413,7 +442,7 @@ func FinishRow(arg *vp8.VP8Decoder, arg *vp8.VP8Io) int {
   is_first_row := (mb_y == 0)
   is_last_row := (mb_y >= dec.br_mb_y - 1)
 
-  if (dec.mt_method == 2) {
+  if (dec.mt_method == vp8.MT_PIPELINE) {
     ReconstructRow(dec, ctx)
   }
 
@@ -496,7 +525,7 @@ func VP8ProcessRow(/* const */ dec *vp8.VP8Decoder, /*const*/ io *vp8.VP8Io) int
   filter_row := (dec.filter_type > 0) &&
                          (dec.mb_y >= dec.tl_mb_y) &&
                          (dec.mb_y <= dec.br_mb_y)
-  if (dec.mt_method == 0) {
+  if (dec.mt_method == vp8.MT_NONE) {
     // ctx.id and ctx.f_info are already set
     ctx.mb_y = dec.mb_y
     ctx.filter_row = filter_row
@@ -512,7 +541,7 @@ func VP8ProcessRow(/* const */ dec *vp8.VP8Decoder, /*const*/ io *vp8.VP8Io) int
       ctx.id = dec.cache_id
       ctx.mb_y = dec.mb_y
       ctx.filter_row = filter_row
-      if (dec.mt_method == 2) {  // swap macroblock data
+      if (dec.mt_method == vp8.MT_PIPELINE) {  // swap macroblock data
         var tmp *VP8MBData = ctx.mb_data
         ctx.mb_data = dec.mb_data
         dec.mb_data = tmp
@@ -641,7 +670,7 @@ func InitThreadContext(/* const */ dec *vp8.VP8Decoder) int  {
       return VP8SetError(dec, VP8_STATUS_OUT_OF_MEMORY, "thread initialization failed.")
     }
     worker.data1 = dec
-    worker.data2 = (*void)&dec.thread_ctx.io
+    worker.data2 = &dec.thread_ctx.io
     worker.hook = FinishRow
     dec.num_caches = tenary.If(dec.filter_type > 0, MT_CACHE_LINES, MT_CACHE_LINES - 1)
   } else {
@@ -652,6 +681,9 @@ func InitThreadContext(/* const */ dec *vp8.VP8Decoder) int  {
 
 // Return the multi-threading method to use (0=off), depending
 // on options and bitstream size. Only for lossy decoding.
+// 1 = [parse+recon][filter], 2 = [parse][recon+filter],
+// MT_WAVEFRONT = one goroutine per group of partitions, see frame_wpp_dec.go.
+// The incremental decoder ('headers' == nil) never uses MT_WAVEFRONT.
 func VP8GetThreadMethod(/* const */ options *WebPDecoderOptions, /*const*/ headers *WebPHeaderStructure, width int, height int) int {
   if (options == nil || options.use_threads == 0) {
     return 0
@@ -660,10 +692,12 @@ func VP8GetThreadMethod(/* const */ options *WebPDecoderOptions, /*const*/ heade
   _ = width
   _ = height
   assert.Assert(headers == nil || !headers.is_lossless)
-#if defined(WEBP_USE_THREAD)
-  if width >= MIN_WIDTH_FOR_THREADS { return 2; }
-#endif
-  return 0
+  // Jobs run on the shared goroutine pool, see WebPSetMaxWorkers().
+  if width >= vp8.MIN_WIDTH_FOR_THREADS {
+    if options.use_wavefront != 0 && headers != nil { return vp8.MT_WAVEFRONT }
+    return vp8.MT_PIPELINE
+  }
+  return vp8.MT_NONE
 }
 
 #undef MT_CACHE_LINES
@@ -681,7 +715,7 @@ func AllocateMemory(/* const */ dec *VP8Decoder) int {
   mb_info_size := (mb_w + 1) * generics.SizeOf(VP8MB)
   f_info_size := tenary.If(dec.filter_type > 0, mb_w * (tenary.If(dec.mt_method > 0, 2, 1)) * sizeof(VP8FInfo), 0)
   yuv_size := YUV_SIZE * sizeof(*dec.yuv_b)
-  mb_data_size := tenary.If(dec.mt_method == 2, 2, 1) * mb_w * sizeof(*dec.mb_data)
+  mb_data_size := tenary.If(dec.mt_method == vp8.MT_PIPELINE, 2, 1) * mb_w * sizeof(*dec.mb_data)
   cache_height := (16 * num_caches + kFilterExtraRows[dec.filter_type]) * 3 / 2
   cache_size := top_size * cache_height
   // alpha_size is the only one that scales as width x height.
@@ -734,7 +768,7 @@ func AllocateMemory(/* const */ dec *VP8Decoder) int {
 
   dec.mb_data = (*VP8MBData)mem
   dec.thread_ctx.mb_data = (*VP8MBData)mem
-  if (dec.mt_method == 2) {
+  if (dec.mt_method == vp8.MT_PIPELINE) {
     dec.thread_ctx.mb_data += mb_w
   }
   mem += mb_data_size
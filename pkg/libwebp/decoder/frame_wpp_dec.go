@@ -0,0 +1,230 @@
+package decoder
+
+// Wavefront-parallel frame decoding (mt_method == MT_WAVEFRONT).
+//
+// Macroblock rows are assigned to the token partitions round-robin, so rows of
+// different partitions can have their residuals parsed concurrently. Rows are
+// split in 'num_groups' groups (a divisor of the number of partitions), each
+// decoded in order by its own goroutine: all rows of a partition belong to the
+// same group and its bit-reader is only ever used sequentially.
+// Macroblock (x, y) is parsed, reconstructed and filtered once (x + 1, y - 1)
+// is done, i.e. each row lags one macroblock behind the row above:
+//
+//   row 0: [0][1][2][3][4][5]...
+//   row 1:       [0][1][2][3]...
+//   row 2:             [0][1]...
+//
+// Reconstruction only reads the unfiltered samples kept in dec.yuv_t and in
+// the per-goroutine yuv_b, so the whole frame is filtered in place. The
+// output is bit-exact with the sequential decoder.
+
+import (
+	"sync"
+
+	"github.com/daanv2/go-webp/pkg/assert"
+	"github.com/daanv2/go-webp/pkg/libwebp/utils"
+	"github.com/daanv2/go-webp/pkg/vp8"
+)
+
+type wavefrontFrame struct {
+	dec        *vp8.VP8Decoder
+	mb_data    []VP8MBData // intra modes and residuals, for all rows
+	y, u, v    []uint8     // reconstructed frame
+	y_stride   int
+	uv_stride  int
+	num_groups int
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	progress []int // number of macroblocks finished (and filtered) per row
+	eof      bool  // a token partition ended prematurely
+	stop     bool  // decoding must be abandoned
+}
+
+// Wait until 'count' macroblocks of row 'mb_y' are finished. Returns false if
+// decoding was abandoned.
+func (w *wavefrontFrame) wait(mb_y int, count int) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for !w.stop && w.progress[mb_y] < count {
+		w.cond.Wait()
+	}
+	return !w.stop
+}
+
+func (w *wavefrontFrame) done(mb_y int, count int) {
+	w.mu.Lock()
+	w.progress[mb_y] = count
+	w.mu.Unlock()
+	w.cond.Broadcast()
+}
+
+func (w *wavefrontFrame) abort(eof bool) {
+	w.mu.Lock()
+	w.stop = true
+	w.eof = w.eof || eof
+	w.mu.Unlock()
+	w.cond.Broadcast()
+}
+
+// Parse, reconstruct and filter the rows of group 'group'.
+func (w *wavefrontFrame) DecodeRows(group int) {
+	dec := w.dec
+	mb_w := dec.mb_w
+	yuv_b := make([]uint8, YUV_SIZE)
+	for mb_y := group; mb_y < dec.br_mb_y; mb_y += w.num_groups {
+		var token_br *VP8BitReader = &dec.parts[mb_y&dec.num_parts_minus_one]
+		var left VP8MB
+		var finfo VP8FInfo
+		filter_row := (dec.filter_type > 0) &&
+			(mb_y >= dec.tl_mb_y) && (mb_y <= dec.br_mb_y)
+
+		InitLeftSamples(&yuv_b[0], mb_y)
+		for mb_x := 0; mb_x < mb_w; mb_x++ {
+			if mb_y > 0 && !w.wait(mb_y-1, min(mb_x+2, mb_w)) {
+				return
+			}
+			block := &w.mb_data[mb_y*mb_w+mb_x]
+			if DecodeMB(dec, dec.mb_info+mb_x, &left, block, &finfo, token_br) == 0 {
+				w.abort(true)
+				return
+			}
+			ReconstructMB(dec, block, &yuv_b[0], mb_x, mb_y)
+
+			y_offset := mb_y*16*w.y_stride + mb_x*16
+			uv_offset := mb_y*8*w.uv_stride + mb_x*8
+			TransferMB(&yuv_b[0], &w.y[y_offset], &w.u[uv_offset], &w.v[uv_offset], w.y_stride, w.uv_stride)
+			if filter_row && mb_x >= dec.tl_mb_x && mb_x < dec.br_mb_x {
+				FilterMB(dec, &finfo, mb_x, mb_y, w.y[y_offset:], w.u[uv_offset:], w.v[uv_offset:], w.y_stride, w.uv_stride)
+			}
+			w.done(mb_y, mb_x+1)
+		}
+	}
+}
+
+// Emit the rows as they get finished, like FinishRow() does. Returns false in
+// case of error or user-abort.
+func (w *wavefrontFrame) EmitRows(io *vp8.VP8Io) int {
+	dec := w.dec
+	extra_y_rows := kFilterExtraRows[dec.filter_type]
+	for mb_y := 0; mb_y < dec.br_mb_y; mb_y++ {
+		if !w.wait(mb_y, dec.mb_w) {
+			return 0
+		}
+		if io.put == nil {
+			continue
+		}
+		y_start := int(MACROBLOCK_VPOS(uint64(mb_y)))
+		y_end := int(MACROBLOCK_VPOS(uint64(mb_y + 1)))
+		if mb_y > 0 {
+			y_start -= extra_y_rows
+		}
+		if mb_y < dec.br_mb_y-1 {
+			y_end -= extra_y_rows
+		}
+		if y_end > io.crop_bottom {
+			y_end = io.crop_bottom // make sure we don't overflow on last row.
+		}
+		io.a = nil
+		if dec.alpha_data != nil && y_start < y_end {
+			io.a = VP8DecompressAlphaRows(dec, io, y_start, y_end-y_start)
+			if io.a == nil {
+				return VP8SetError(dec, VP8_STATUS_BITSTREAM_ERROR, "Could not decode alpha data.")
+			}
+		}
+		if y_start < io.crop_top {
+			delta_y := io.crop_top - y_start
+			y_start = io.crop_top
+			if io.a != nil {
+				io.a += io.width * delta_y
+			}
+		}
+		if y_start < y_end {
+			assert.Assert(y_start&1 == 0)
+			io.y = &w.y[y_start*w.y_stride+io.crop_left]
+			io.u = &w.u[(y_start>>1)*w.uv_stride+(io.crop_left>>1)]
+			io.v = &w.v[(y_start>>1)*w.uv_stride+(io.crop_left>>1)]
+			if io.a != nil {
+				io.a += io.crop_left
+			}
+			io.mb_y = y_start - io.crop_top
+			io.mb_w = io.crop_right - io.crop_left
+			io.mb_h = y_end - y_start
+			if !io.put(io) {
+				return 0
+			}
+		}
+	}
+	return 1
+}
+
+// Decode the whole frame, with MT_WAVEFRONT. Returns false in case of error.
+func VP8ParseFrameWavefront( /* const */ dec *vp8.VP8Decoder, io *vp8.VP8Io) int {
+	mb_w := dec.mb_w
+	num_parts := dec.num_parts_minus_one + 1
+	w := &wavefrontFrame{
+		dec:       dec,
+		mb_data:   make([]VP8MBData, mb_w*dec.br_mb_y),
+		y_stride:  16 * mb_w,
+		uv_stride: 8 * mb_w,
+		progress:  make([]int, dec.br_mb_y),
+	}
+	w.cond = sync.NewCond(&w.mu)
+	w.y = make([]uint8, w.y_stride*16*dec.br_mb_y)
+	w.u = make([]uint8, w.uv_stride*8*dec.br_mb_y)
+	w.v = make([]uint8, w.uv_stride*8*dec.br_mb_y)
+
+	// The intra modes of all rows come from the first partition.
+	for mb_y := 0; mb_y < dec.br_mb_y; mb_y++ {
+		if VP8ParseIntraModeRow(&dec.br, dec) == 0 {
+			return VP8SetError(dec, VP8_STATUS_NOT_ENOUGH_DATA, "Premature end-of-partition0 encountered.")
+		}
+		for mb_x := 0; mb_x < mb_w; mb_x++ {
+			var block *VP8MBData = dec.mb_data + mb_x
+			w.mb_data[mb_y*mb_w+mb_x] = *block
+		}
+		VP8InitScanline(dec)
+	}
+
+	// Use as many groups as the shared pool allows. The number of partitions
+	// is a power of two, so is the number of groups.
+	slots := utils.WebPAcquireWorkers(num_parts)
+	w.num_groups = 1
+	for w.num_groups*2 <= slots {
+		w.num_groups *= 2
+	}
+	var wg sync.WaitGroup
+	ok := 1
+	if slots == 0 {
+		// No goroutine available: decode everything, then emit.
+		w.DecodeRows(0)
+		ok = w.EmitRows(io)
+	} else {
+		utils.WebPReleaseWorkers(slots - w.num_groups)
+		for group := 0; group < w.num_groups; group++ {
+			wg.Add(1)
+			go func(group int) {
+				defer wg.Done()
+				w.DecodeRows(group)
+			}(group)
+		}
+		ok = w.EmitRows(io)
+		if ok == 0 {
+			w.abort(false)
+		}
+		wg.Wait()
+		utils.WebPReleaseWorkers(w.num_groups)
+	}
+	dec.mb_y = dec.br_mb_y
+
+	if w.eof {
+		return VP8SetError(dec, VP8_STATUS_NOT_ENOUGH_DATA, "Premature end-of-file encountered.")
+	}
+	if ok == 0 {
+		if dec.status != VP8_STATUS_OK {
+			return 0
+		}
+		return VP8SetError(dec, VP8_STATUS_USER_ABORT, "Output aborted.")
+	}
+	return 1
+}
@@ -0,0 +1,121 @@
+package decoder
+
+import (
+	"testing"
+
+	"github.com/daanv2/go-webp/pkg/libwebp/utils"
+	"github.com/daanv2/go-webp/pkg/vp8"
+	"github.com/daanv2/go-webp/pkg/webptest"
+	"github.com/stretchr/testify/require"
+)
+
+// Keeps the fuzzed images small enough not to exhaust the memory.
+const fuzzMaxPixels = 1 << 20
+
+func dataPtr(data []byte) *uint8 {
+	if len(data) == 0 {
+		return nil
+	}
+	return &data[0]
+}
+
+func FuzzParseHeadersInternal(f *testing.F) {
+	webptest.AddCorpus(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var width, height, has_alpha, has_animation, format int
+		status := ParseHeadersInternal(dataPtr(data), uint64(len(data)), &width, &height, &has_alpha, &has_animation, &format, nil)
+		if status != vp8.VP8_STATUS_OK {
+			return
+		}
+		require.True(t, width >= 1 && width <= WEBP_MAX_DIMENSION, "width %d", width)
+		require.True(t, height >= 1 && height <= WEBP_MAX_DIMENSION, "height %d", height)
+		require.Contains(t, []int{0, 1, 2}, format)
+
+		info, status := GetImageInfo(data)
+		require.Equal(t, vp8.VP8_STATUS_OK, status)
+		require.Equal(t, ImageInfo{
+			Width:        width,
+			Height:       height,
+			HasAlpha:     has_alpha != 0,
+			HasAnimation: has_animation != 0,
+			Format:       format,
+		}, info)
+	})
+}
+
+// Decodes 'data' to RGBA and YUV, at full size and cropped and scaled, then
+// incrementally, which must give the same pixels.
+func fuzzDecode(t *testing.T, data []byte) {
+	opts := &DecodeOptions{MaxPixels: fuzzMaxPixels}
+	img, status := WebPDecodeAdvanced(data, MODE_RGBA, opts)
+	if status != vp8.VP8_STATUS_OK {
+		return
+	}
+	require.GreaterOrEqual(t, img.Stride, 4*img.Width)
+	require.GreaterOrEqual(t, len(img.RGBA), img.Stride*(img.Height-1)+4*img.Width)
+
+	incremental, status := WebPIDecodeAdvanced(data, MODE_RGBA, opts, 1+len(data)%7)
+	if status == vp8.VP8_STATUS_OK {
+		require.Equal(t, img, incremental)
+	}
+
+	yuv, status := WebPDecodeAdvanced(data, MODE_YUVA, opts)
+	if status == vp8.VP8_STATUS_OK {
+		require.Equal(t, img.Width, yuv.Width)
+		require.Equal(t, img.Height, yuv.Height)
+		require.GreaterOrEqual(t, len(yuv.Y), yuv.YStride*(yuv.Height-1)+yuv.Width)
+	}
+
+	scaled, status := WebPDecodeAdvanced(data, MODE_RGBA, &DecodeOptions{
+		UseCropping:    true,
+		CropLeft:       img.Width / 4,
+		CropTop:        img.Height / 4,
+		CropWidth:      max(img.Width/2, 1),
+		CropHeight:     max(img.Height/2, 1),
+		UseScaling:     true,
+		ScaledWidth:    max(img.Width/3, 1),
+		ScaledHeight:   max(img.Height/3, 1),
+		ResampleFilter: utils.WebPResampleFilter(len(data) % int(utils.WEBP_FILTER_LAST)),
+		MaxPixels:      fuzzMaxPixels,
+	})
+	if status == vp8.VP8_STATUS_OK {
+		require.Equal(t, max(img.Width/3, 1), scaled.Width)
+		require.Equal(t, max(img.Height/3, 1), scaled.Height)
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	webptest.AddCorpus(f)
+	f.Fuzz(fuzzDecode)
+}
+
+// Raw VP8 bitstreams, without container.
+func FuzzVP8Decode(f *testing.F) {
+	webptest.AddChunks(f, "VP8 ")
+	f.Fuzz(fuzzDecode)
+}
+
+// Raw VP8L bitstreams, without container.
+func FuzzVP8LDecode(f *testing.F) {
+	webptest.AddChunks(f, "VP8L")
+	f.Fuzz(fuzzDecode)
+}
+
+// ALPH chunks, decoded along with the lossy 1x1 image of the seed file they
+// come from.
+func FuzzALPHDecode(f *testing.F) {
+	var vp8x, image []byte
+	for _, data := range webptest.Corpus() {
+		chunks := data[12:]
+		if len(webptest.Chunks(chunks, "ALPH")) == 0 || len(webptest.Chunks(chunks, "ANIM")) > 0 {
+			continue
+		}
+		f.Add(webptest.Chunks(chunks, "ALPH")[0])
+		vp8x = webptest.Chunk("VP8X", webptest.Chunks(chunks, "VP8X")[0])
+		image = webptest.Chunk("VP8 ", webptest.Chunks(chunks, "VP8 ")[0])
+	}
+	require.NotNil(f, image)
+	f.Fuzz(func(t *testing.T, alph []byte) {
+		fuzzDecode(t, webptest.RIFF(vp8x, webptest.Chunk("ALPH", alph), image))
+	})
+}
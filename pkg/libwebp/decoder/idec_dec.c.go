@@ -419,7 +419,11 @@ func DecodePartition0(/* const */ idec *WebPIDecoder) VP8StatusCode {
     return IDecError(idec, status)
   }
 
-  // Allocate/Verify output buffer now
+  // Check the limits, then allocate/verify output buffer now
+  dec.status = WebPCheckDecodeLimits(params.options, io.width, io.height, false, dec.alpha_data != nil, output)
+  if (dec.status != VP8_STATUS_OK) {
+    return IDecError(idec, dec.status)
+  }
   dec.status = WebPAllocateDecBuffer(io.width, io.height, params.options, output)
   if (dec.status != VP8_STATUS_OK) {
     return IDecError(idec, dec.status)
@@ -536,7 +540,11 @@ func DecodeVP8LHeader(/* const */ idec *WebPIDecoder) VP8StatusCode {
     }
     return ErrorStatusLossless(idec, dec.status)
   }
-  // Allocate/verify output buffer now.
+  // Check the limits, then allocate/verify output buffer now.
+  dec.status = WebPCheckDecodeLimits(params.options, io.width, io.height, true, false, output)
+  if (dec.status != VP8_STATUS_OK) {
+    return IDecError(idec, dec.status)
+  }
   dec.status = WebPAllocateDecBuffer(io.width, io.height, params.options, output)
   if (dec.status != VP8_STATUS_OK) {
     return IDecError(idec, dec.status)
@@ -14,6 +14,7 @@ package decoder
 // Author: Skal (pascal.massimino@gmail.com)
 
 import "github.com/daanv2/go-webp/pkg/assert"
+import "github.com/daanv2/go-webp/pkg/color/yuv"
 import "github.com/daanv2/go-webp/pkg/stddef"
 import "github.com/daanv2/go-webp/pkg/stdlib"
 import "github.com/daanv2/go-webp/pkg/string"
@@ -49,12 +50,25 @@ func EmitYUV(/* const */ io *VP8Io, /*const*/ p *WebPDecParams) int {
   return io.mb_h
 }
 
+// Returns the YUV.RGB matrix requested in the options, if any.
+func GetYUVMatrix(/* const */ p *WebPDecParams) *yuv.Matrix {
+  if p.options == nil { return nil  }
+  return p.options.yuv_matrix
+}
+
+// Returns the resampling kernel requested in the options, WEBP_FILTER_BOX by
+// default.
+func GetResampleFilter(/* const */ p *WebPDecParams) WebPResampleFilter {
+  if p.options == nil { return WEBP_FILTER_BOX  }
+  return p.options.resample_filter
+}
+
 // Point-sampling U/V sampler.
 func EmitSampledRGB(/* const */ io *VP8Io, /*const*/ p *WebPDecParams) int {
   var output *WebPDecBuffer = p.output
   var buf *WebPRGBABuffer = &output.u.RGBA
   var dst []uint8 = buf.rgba + (ptrdiff_t)io.mb_y * buf.stride
-  WebPSamplerProcessPlane(io.y, io.y_stride, io.u, io.v, io.uv_stride, dst, buf.stride, io.mb_w, io.mb_h, WebPSamplers[output.colorspace])
+  WebPSamplerProcessPlane(io.y, io.y_stride, io.u, io.v, io.uv_stride, dst, buf.stride, io.mb_w, io.mb_h, WebPGetSampler(output.colorspace, GetYUVMatrix(p)))
   return io.mb_h
 }
 
@@ -66,7 +80,7 @@ func EmitFancyRGB(/* const */ io *VP8Io, /*const*/ p *WebPDecParams) int {
   num_lines_out := io.mb_h;  // a priori guess
   var buf *WebPRGBABuffer = &p.output.u.RGBA
   dst []uint8 = buf.rgba + (ptrdiff_t)io.mb_y * buf.stride
-  WebPUpsampleLinePairFunc upsample = WebPUpsamplers[p.output.colorspace]
+  WebPUpsampleLinePairFunc upsample = WebPGetUpsampler(p.output.colorspace, GetYUVMatrix(p))
   var cur_y *uint8 = io.y
   var cur_u *uint8 = io.u
   var cur_v *uint8 = io.v
@@ -176,8 +190,8 @@ func EmitAlphaRGB(/* const */ io *VP8Io, /*const*/ p *WebPDecParams,  expected_n
   var alpha *uint8 = io.a
   if (alpha != nil) {
     mb_w := io.mb_w
-    WEBP_CSP_MODE := p.output.colorspace
-    alpha_first = (colorspace == MODE_ARGB || colorspace == MODE_Argb)
+    var colorspace WEBP_CSP_MODE = p.output.colorspace
+    alpha_first := (colorspace == MODE_ARGB || colorspace == MODE_Argb)
     var buf *WebPRGBABuffer = &p.output.u.RGBA
     num_rows int 
     start_y := GetAlphaSourceRow(io, &alpha, &num_rows)
@@ -203,8 +217,8 @@ func EmitAlphaRGBA4444(/* const */ io *VP8Io, /*const*/ p *WebPDecParams, expect
     num_rows int 
     start_y := GetAlphaSourceRow(io, &alpha, &num_rows)
     var base_rgba []uint8 = buf.rgba + (ptrdiff_t)start_y * buf.stride
-    alpha_dst []uint8 = base_rgba
-    alpha_mask := float64(0x0)
+    alpha_dst []uint8 = base_rgba[1:] // alpha is the low nibble of the second byte
+    alpha_mask := uint8(0x0f)
     var i, j int
     for j = 0; j < num_rows; j++ {
       for i = 0; i < mb_w; i++ {
@@ -318,15 +332,16 @@ func InitYUVRescaler(/* const */ io *VP8Io, /*const*/ p *WebPDecParams) int {
   p.scaler_v = &scalers[2]
   p.scaler_a = tenary.If(has_alpha, &scalers[3], nil)
 
-  if (!WebPRescalerInit(p.scaler_y, io.mb_w, io.mb_h, buf.y, out_width, out_height, buf.y_stride, 1, work) ||
-      !WebPRescalerInit(p.scaler_u, uv_in_width, uv_in_height, buf.u, uv_out_width, uv_out_height, buf.u_stride, 1, work + work_size) ||
-      !WebPRescalerInit(p.scaler_v, uv_in_width, uv_in_height, buf.v, uv_out_width, uv_out_height, buf.v_stride, 1, work + work_size + uv_work_size)) {
+  filter := GetResampleFilter(p)
+  if (!WebPRescalerInitFilter(p.scaler_y, filter, io.mb_w, io.mb_h, buf.y, out_width, out_height, buf.y_stride, 1, work) ||
+      !WebPRescalerInitFilter(p.scaler_u, filter, uv_in_width, uv_in_height, buf.u, uv_out_width, uv_out_height, buf.u_stride, 1, work + work_size) ||
+      !WebPRescalerInitFilter(p.scaler_v, filter, uv_in_width, uv_in_height, buf.v, uv_out_width, uv_out_height, buf.v_stride, 1, work + work_size + uv_work_size)) {
     return 0
   }
   p.emit = EmitRescaledYUV
 
   if (has_alpha) {
-    if (!WebPRescalerInit(p.scaler_a, io.mb_w, io.mb_h, buf.a, out_width, out_height, buf.a_stride, 1, work + work_size + 2 * uv_work_size)) {
+    if (!WebPRescalerInitFilter(p.scaler_a, filter, io.mb_w, io.mb_h, buf.a, out_width, out_height, buf.a_stride, 1, work + work_size + 2 * uv_work_size)) {
       return 0
     }
     p.emit_alpha = EmitRescaledAlphaYUV
@@ -409,7 +424,7 @@ func ExportAlpha(/* const */ p *WebPDecParams, y_pos int, max_lines_out int) int
 func ExportAlphaRGBA4444(/* const */ p *WebPDecParams, y_pos int, max_lines_out int ) int {
   var buf *WebPRGBABuffer = &p.output.u.RGBA
   var base_rgba []uint8 = buf.rgba + (ptrdiff_t)y_pos * buf.stride
-  var alpha_dst []uint8 = base_rgba
+  var alpha_dst []uint8 = base_rgba[1:] // alpha is the low nibble of the second byte
   num_lines_out := 0
   var colorspace WEBP_CSP_MODE = p.output.colorspace
   width := p.scaler_a.dst_width
@@ -490,16 +505,17 @@ func InitRGBRescaler(/* const */ io *VP8Io, /*const*/ p *WebPDecParams) int {
   p.scaler_v = &scalers[2]
   p.scaler_a = tenary.If(has_alpha, &scalers[3], nil)
 
-  if (!WebPRescalerInit(p.scaler_y, io.mb_w, io.mb_h, tmp + 0 * out_width, out_width, out_height, 0, 1, work + 0 * work_size) ||
-      !WebPRescalerInit(p.scaler_u, uv_in_width, uv_in_height, tmp + 1 * out_width, out_width, out_height, 0, 1, work + 1 * work_size) ||
-      !WebPRescalerInit(p.scaler_v, uv_in_width, uv_in_height, tmp + 2 * out_width, out_width, out_height, 0, 1, work + 2 * work_size)) {
+  filter := GetResampleFilter(p)
+  if (!WebPRescalerInitFilter(p.scaler_y, filter, io.mb_w, io.mb_h, tmp + 0 * out_width, out_width, out_height, 0, 1, work + 0 * work_size) ||
+      !WebPRescalerInitFilter(p.scaler_u, filter, uv_in_width, uv_in_height, tmp + 1 * out_width, out_width, out_height, 0, 1, work + 1 * work_size) ||
+      !WebPRescalerInitFilter(p.scaler_v, filter, uv_in_width, uv_in_height, tmp + 2 * out_width, out_width, out_height, 0, 1, work + 2 * work_size)) {
     return 0
   }
   p.emit = EmitRescaledRGB
   WebPInitYUV444Converters()
 
   if (has_alpha) {
-    if (!WebPRescalerInit(p.scaler_a, io.mb_w, io.mb_h, tmp + 3 * out_width, out_width, out_height, 0, 1, work + 3 * work_size)) {
+    if (!WebPRescalerInitFilter(p.scaler_a, filter, io.mb_w, io.mb_h, tmp + 3 * out_width, out_width, out_height, 0, 1, work + 3 * work_size)) {
       return 0
     }
     p.emit_alpha = EmitRescaledAlphaRGB
@@ -0,0 +1,123 @@
+package decoder
+
+import (
+	"github.com/daanv2/go-webp/pkg/libwebp/utils"
+	"github.com/daanv2/go-webp/pkg/vp8"
+)
+
+// Decoding limits. WEBP_MAX_DIMENSION lets a few hundred bytes describe a
+// 16383 x 16383 image, which decodes to 1 GiB of RGBA: the max_pixels,
+// max_memory_bytes and max_frames options are checked once the headers are
+// parsed, before the output buffer (AllocateBuffer()), the decoder memory
+// (AllocateMemory(), AllocateInternalBuffers32b()) or the animation canvases
+// are allocated.
+
+// Upper bound of the memory AllocateMemory() requests per macroblock
+// column: caches of (16 * MT_CACHE_LINES + 8) rows of 32-byte top samples
+// times 3/2, plus the per-macroblock contexts and the coefficients of two
+// macroblock rows for the multi-threaded decoding.
+const kVP8MemoryPerMBColumn = 32*(16*MT_CACHE_LINES+8)*3/2 + 2*800 + 64
+
+// Memory VP8ParseFrameWavefront() allocates per macroblock: its VP8MBData,
+// and its 16x16 luma and two 8x8 chroma samples in the frame.
+const kWavefrontMemoryPerMB = 800 + 16*16 + 2*8*8
+
+// Returns an upper bound of the memory allocated by the decoder itself,
+// output buffer excluded, to decode a 'width' x 'height' bitstream without
+// wavefront threading nor kernel resampling, which WebPCheckDecodeLimits()
+// adds.
+func WebPDecoderMemorySize(width int, height int, is_lossless bool, has_alpha bool) uint64 {
+	w, h := uint64(width), uint64(height)
+	if is_lossless {
+		// The whole ARGB image, plus the top row and the rows of argb_cache
+		// (see AllocateInternalBuffers32b()).
+		return 4 * (w*h + w + 16*w)
+	}
+	size := (w+15)>>4*kVP8MemoryPerMBColumn + 4096
+	if has_alpha {
+		// The alpha plane, and the lossless image it may be compressed to.
+		size += 2 * w * h
+	}
+	return size
+}
+
+// Returns the memory VP8ParseFrameWavefront() allocates to decode a 'width' x
+// 'height' frame: the data and samples of every macroblock, the progress of
+// the rows and the work buffer of each group of partitions.
+func WebPWavefrontMemorySize(width int, height int) uint64 {
+	mb_w, mb_h := uint64(width+15)>>4, uint64(height+15)>>4
+	return mb_w*mb_h*kWavefrontMemoryPerMB + 8*mb_h + vp8.MAX_NUM_PARTITIONS*vp8.YUV_SIZE
+}
+
+// Returns the size of the output buffer AllocateBuffer() allocates for a
+// 'width' x 'height' image in 'mode'.
+func WebPDecBufferSize(width int, height int, mode WEBP_CSP_MODE) uint64 {
+	w, h := uint64(width), uint64(height)
+	size := w * uint64(kModeBpp[mode]) * h
+	if !WebPIsRGBMode(mode) {
+		size += 2 * ((w + 1) / 2) * ((h + 1) / 2)
+		if mode == MODE_YUVA {
+			size += w * h
+		}
+	}
+	return size
+}
+
+// Checks the 'width' x 'height' bitstream about to be decoded into 'output'
+// against the limits of 'options' (none if nil). The output buffer is
+// accounted for only if the decoder allocates it. Returns
+// VP8_STATUS_LIMIT_EXCEEDED if a limit is exceeded.
+func WebPCheckDecodeLimits( /* const */ options *WebPDecoderOptions, width int, height int, is_lossless bool, has_alpha bool /*const*/, output *WebPDecBuffer) vp8.VP8StatusCode {
+	if options == nil {
+		return vp8.VP8_STATUS_OK
+	}
+	if options.max_pixels > 0 && uint64(width)*uint64(height) > uint64(options.max_pixels) {
+		return vp8.VP8_STATUS_LIMIT_EXCEEDED
+	}
+	if options.max_memory_bytes > 0 {
+		var out_width, out_height int
+		status := WebPGetOutputDimensions(width, height, options, &out_width, &out_height)
+		if status != vp8.VP8_STATUS_OK {
+			return status
+		}
+		memory := WebPDecoderMemorySize(width, height, is_lossless, has_alpha)
+		// See VP8GetThreadMethod().
+		if !is_lossless && options.use_threads != 0 && options.use_wavefront != 0 &&
+			width >= vp8.MIN_WIDTH_FOR_THREADS {
+			memory += WebPWavefrontMemorySize(width, height)
+		}
+		if options.use_scaling != 0 && options.resample_filter != utils.WEBP_FILTER_BOX {
+			// One resampler per plane (Y, U, V, A), none larger than the luma one.
+			in_width, in_height := width, height
+			if options.use_cropping != 0 {
+				in_width, in_height = options.crop_width, options.crop_height
+			}
+			memory += 4 * utils.WebPResamplerMemorySize(options.resample_filter, in_width, in_height, out_width, out_height, 1)
+		}
+		if output != nil && output.is_external_memory <= 0 && output.private_memory == nil &&
+			IsValidColorspace(output.colorspace) {
+			memory += WebPDecBufferSize(out_width, out_height, output.colorspace)
+		}
+		if memory > uint64(options.max_memory_bytes) {
+			return vp8.VP8_STATUS_LIMIT_EXCEEDED
+		}
+	}
+	return vp8.VP8_STATUS_OK
+}
+
+// Go variant of WebPCheckDecodeLimits() for the callers allocating the output
+// themselves: checks that decoding the image described by 'info' to 'mode'
+// with 'opts' (nil for no limits) stays within the limits, a decoder-allocated
+// output buffer included.
+func CheckImageLimits(info ImageInfo, mode WEBP_CSP_MODE /*const*/, opts *DecodeOptions) vp8.VP8StatusCode {
+	var options WebPDecoderOptions
+	var output WebPDecBuffer
+	if opts == nil {
+		return vp8.VP8_STATUS_OK
+	}
+	if !InitDecoderOptions(opts, &options) || mode < MODE_RGB || mode >= MODE_LAST {
+		return vp8.VP8_STATUS_INVALID_PARAM
+	}
+	output.colorspace = mode
+	return WebPCheckDecodeLimits(&options, info.Width, info.Height, info.Format == 2, info.HasAlpha, &output)
+}
@@ -0,0 +1,54 @@
+package decoder
+
+import "sync"
+
+// Decoder objects are recycled across the one-shot DecodeInto() calls, so
+// that their scratch memory (dec.mem for lossy, dec.pixels for lossless) is
+// reused instead of being allocated for every image.
+var (
+	vp8_decoder_pool  sync.Pool
+	vp8l_decoder_pool sync.Pool
+)
+
+// Returns a decoder in the same state as the one from VP8New().
+func AcquireVP8Decoder() *VP8Decoder {
+	if dec, ok := vp8_decoder_pool.Get().(*VP8Decoder); ok {
+		return dec
+	}
+	return VP8New()
+}
+
+// Resets 'dec', keeping its scratch memory, and puts it back in the pool.
+// 'dec' must not be used afterward.
+func ReleaseVP8Decoder(dec *VP8Decoder) {
+	if dec == nil {
+		return
+	}
+	mem, mem_size := dec.mem, dec.mem_size
+	VP8Clear(dec)
+	*dec = VP8Decoder{}
+	SetOk(dec)
+	WebPGetWorkerInterface().Init(&dec.worker)
+	dec.mem, dec.mem_size = mem, mem_size
+	vp8_decoder_pool.Put(dec)
+}
+
+// Returns a decoder in the same state as the one from VP8LNew().
+func AcquireVP8LDecoder() *VP8LDecoder {
+	if dec, ok := vp8l_decoder_pool.Get().(*VP8LDecoder); ok {
+		return dec
+	}
+	return VP8LNew()
+}
+
+// Resets 'dec', keeping its pixel buffer, and puts it back in the pool.
+// 'dec' must not be used afterward.
+func ReleaseVP8LDecoder(dec *VP8LDecoder) {
+	if dec == nil {
+		return
+	}
+	pixels := dec.pixels
+	VP8LClear(dec)
+	*dec = VP8LDecoder{status: VP8_STATUS_OK, state: READ_DIM, spare_pixels: pixels}
+	vp8l_decoder_pool.Put(dec)
+}
@@ -10,7 +10,9 @@ package decoder
 
 import (
 	"github.com/daanv2/go-webp/pkg/assert"
+	"github.com/daanv2/go-webp/pkg/color/yuv"
 	"github.com/daanv2/go-webp/pkg/constants" // ALPHA_FLAG
+	"github.com/daanv2/go-webp/pkg/libwebp/utils"
 	"github.com/daanv2/go-webp/pkg/stdlib"
 	"github.com/daanv2/go-webp/pkg/util/tenary"
 	"github.com/daanv2/go-webp/pkg/vp8"
@@ -502,10 +504,7 @@ func DecodeInto(data *uint8, data_size uint64 /*const*/, params *WebPDecParams)
 	WebPInitCustomIo(params, &io) // Plug the I/O functions.
 
 	if !headers.is_lossless {
-		var dec *VP8Decoder = VP8New()
-		if dec == nil {
-			return vp8.VP8_STATUS_OUT_OF_MEMORY
-		}
+		var dec *VP8Decoder = AcquireVP8Decoder()
 		dec.alpha_data = headers.alpha_data
 		dec.alpha_data_size = headers.alpha_data_size
 
@@ -513,34 +512,42 @@ func DecodeInto(data *uint8, data_size uint64 /*const*/, params *WebPDecParams)
 		if !VP8GetHeaders(dec, &io) {
 			status = dec.status // An error occurred. Grab error status.
 		} else {
-			// Allocate/check output buffers.
-			status = WebPAllocateDecBuffer(io.width, io.height, params.options, params.output)
+			// Check the limits, then allocate/check output buffers.
+			status = WebPCheckDecodeLimits(params.options, io.width, io.height, false, dec.alpha_data != nil, params.output)
+			if status == vp8.VP8_STATUS_OK {
+				status = WebPAllocateDecBuffer(io.width, io.height, params.options, params.output)
+			}
 			if status == vp8.VP8_STATUS_OK { // Decode
 				// This change must be done before calling VP8Decode()
 				dec.mt_method = VP8GetThreadMethod(params.options, &headers, io.width, io.height)
 				VP8InitDithering(params.options, dec)
+				if dec.mt_method == vp8.MT_WAVEFRONT && !vp8.VP8CanDecodeWavefront(dec.num_parts_minus_one, dec.dither) {
+					// Nothing to decode concurrently: pipeline the rows instead.
+					dec.mt_method = vp8.MT_PIPELINE
+				}
 				if !VP8Decode(dec, &io) {
 					status = dec.status
 				}
 			}
 		}
-		VP8Delete(dec)
+		ReleaseVP8Decoder(dec)
 	} else {
-		var dec *VP8LDecoder = VP8LNew()
-		if dec == nil {
-			return vp8.VP8_STATUS_OUT_OF_MEMORY
-		}
+		var dec *VP8LDecoder = AcquireVP8LDecoder()
 		if !VP8LDecodeHeader(dec, &io) {
 			status = dec.status // An error occurred. Grab error status.
 		} else {
-			// Allocate/check output buffers.
-			status = WebPAllocateDecBuffer(io.width, io.height, params.options, params.output)
+			// Check the limits, then allocate/check output buffers.
+			status = WebPCheckDecodeLimits(params.options, io.width, io.height, true, false, params.output)
+			if status == vp8.VP8_STATUS_OK {
+				status = WebPAllocateDecBuffer(io.width, io.height, params.options, params.output)
+			}
 			if status == vp8.VP8_STATUS_OK { // Decode
 				if !VP8LDecodeImage(dec) {
 					status = dec.status
 				}
 			}
 		}
+		ReleaseVP8LDecoder(dec)
 	}
 
 	if status != vp8.VP8_STATUS_OK {
@@ -575,6 +582,44 @@ func DecodeIntoRGBABuffer(colorspace WEBP_CSP_MODE, data *uint8, data_size uint6
 	return rgba
 }
 
+// Go variant of DecodeIntoRGBABuffer(): decodes 'data' into 'rgba' using
+// 'colorspace', the buffer size being len(rgba). The buffer is validated with
+// CheckDecBuffer() before any decoding work is done. No output memory is
+// allocated.
+func WebPDecodeIntoRGBABuffer(colorspace WEBP_CSP_MODE /*const*/, data []uint8, rgba []uint8, stride int) vp8.VP8StatusCode {
+	return WebPDecodeIntoRGBABufferOptions(colorspace, data, rgba, stride, nil)
+}
+
+// Same as WebPDecodeIntoRGBABuffer(), using 'opts' if not nil.
+func WebPDecodeIntoRGBABufferOptions(colorspace WEBP_CSP_MODE /*const*/, data []uint8, rgba []uint8, stride int /*const*/, opts *DecodeOptions) vp8.VP8StatusCode {
+	var params WebPDecParams
+	var buf WebPDecBuffer
+	var options WebPDecoderOptions
+	if len(data) == 0 || rgba == nil || !WebPIsRGBMode(colorspace) || !WebPInitDecBuffer(&buf) {
+		return vp8.VP8_STATUS_INVALID_PARAM
+	}
+	if !WebPGetInfo(data, uint64(len(data)), &buf.width, &buf.height) {
+		return vp8.VP8_STATUS_BITSTREAM_ERROR
+	}
+	WebPResetDecParams(&params)
+	if opts != nil {
+		if !InitDecoderOptions(opts, &options) {
+			return vp8.VP8_STATUS_INVALID_PARAM
+		}
+		params.options = &options
+	}
+	params.output = &buf
+	buf.colorspace = colorspace
+	buf.u.RGBA.rgba = rgba
+	buf.u.RGBA.stride = stride
+	buf.u.RGBA.size = uint64(len(rgba))
+	buf.is_external_memory = 1
+	if status := CheckDecBuffer(&buf); status != vp8.VP8_STATUS_OK {
+		return status
+	}
+	return DecodeInto(data, uint64(len(data)), &params)
+}
+
 // RGB and BGR variants. Here too the transparency information, if present,
 // will be dropped and ignored.
 func WebPDecodeRGBInto( /* const */ data *uint8, data_size uint64, output *uint8, size uint64, stride int) *uint8 {
@@ -637,6 +682,50 @@ func WebPDecodeYUVInto( /* const */ data *uint8, data_size uint64, luma *uint8,
 	return luma
 }
 
+// Go variant of WebPDecodeYUVInto(), also decoding the alpha plane 'a' if it
+// is not nil (it is filled with 0xff if the image has no alpha). The size of
+// each plane is the length of its slice. 'v_stride' may differ from 'u_stride'.
+// No RGB conversion takes place.
+func WebPDecodeYUVAInto( /* const */ data []uint8, luma []uint8, luma_stride int, u []uint8, u_stride int, v []uint8, v_stride int, a []uint8, a_stride int) vp8.VP8StatusCode {
+	return WebPDecodeYUVAIntoOptions(data, luma, luma_stride, u, u_stride, v, v_stride, a, a_stride, nil)
+}
+
+// Same as WebPDecodeYUVAInto(), using 'opts' if not nil.
+func WebPDecodeYUVAIntoOptions( /* const */ data []uint8, luma []uint8, luma_stride int, u []uint8, u_stride int, v []uint8, v_stride int, a []uint8, a_stride int /*const*/, opts *DecodeOptions) vp8.VP8StatusCode {
+	var params WebPDecParams
+	var output WebPDecBuffer
+	var options WebPDecoderOptions
+	if len(data) == 0 || luma == nil || u == nil || v == nil {
+		return vp8.VP8_STATUS_INVALID_PARAM
+	}
+	if !WebPInitDecBuffer(&output) {
+		return vp8.VP8_STATUS_INVALID_PARAM
+	}
+	WebPResetDecParams(&params)
+	if opts != nil {
+		if !InitDecoderOptions(opts, &options) {
+			return vp8.VP8_STATUS_INVALID_PARAM
+		}
+		params.options = &options
+	}
+	params.output = &output
+	output.colorspace = tenary.If(a != nil, MODE_YUVA, MODE_YUV)
+	output.u.YUVA.y = luma
+	output.u.YUVA.y_stride = luma_stride
+	output.u.YUVA.y_size = uint64(len(luma))
+	output.u.YUVA.u = u
+	output.u.YUVA.u_stride = u_stride
+	output.u.YUVA.u_size = uint64(len(u))
+	output.u.YUVA.v = v
+	output.u.YUVA.v_stride = v_stride
+	output.u.YUVA.v_size = uint64(len(v))
+	output.u.YUVA.a = a
+	output.u.YUVA.a_stride = a_stride
+	output.u.YUVA.a_size = uint64(len(a))
+	output.is_external_memory = 1
+	return DecodeInto(data, uint64(len(data)), &params)
+}
+
 //------------------------------------------------------------------------------
 
 func Decode(mode WEBP_CSP_MODE /*const*/, data *uint8, data_size uint64 /*const*/, width *int /*const*/, height *int /*const*/, keep_info *WebPDecBuffer) *uint8 {
@@ -739,6 +828,200 @@ func DefaultFeatures( /* const */ features *WebPBitstreamFeatures) {
 	stdlib.Memset(features, 0, sizeof(*features))
 }
 
+// Go view of WebPBitstreamFeatures.
+type ImageInfo struct {
+	Width, Height int
+	HasAlpha      bool
+	HasAnimation  bool
+	Format        int // 0 = undefined (/mixed), 1 = lossy, 2 = lossless
+}
+
+// Go view of WebPDecoderOptions.
+type DecodeOptions struct {
+	DitheringStrength      int // dithering of lossy RGB output, in [0..100]
+	AlphaDitheringStrength int // smoothing of quantized alpha planes, in [0..100]
+
+	BypassFiltering   bool // skip the in-loop filtering
+	NoFancyUpsampling bool // use the faster pointwise upsampler
+	UseThreads        bool // use multi-threaded decoding
+	Flip              bool // flip the output vertically
+
+	// With UseThreads, decode the macroblock rows of different token
+	// partitions concurrently (wavefront). Lossy bitstreams with a single
+	// partition, and dithered ones, are decoded as with UseThreads alone.
+	UseWavefront bool
+
+	// Y'CbCr matrix of lossy bitstreams, used for the RGB output. nil means
+	// yuv.BT601, which is what the encoder produces by default.
+	YUVMatrix *yuv.Matrix
+
+	// Cropping is applied first, then scaling.
+	UseCropping                              bool
+	CropLeft, CropTop, CropWidth, CropHeight int
+	UseScaling                               bool
+	ScaledWidth, ScaledHeight                int                      // if one is 0, it is guessed from the other
+	ResampleFilter                           utils.WebPResampleFilter // kernel used for scaling
+
+	// Limits protecting against decompression bombs, 0 meaning no limit.
+	// They are checked once the headers are parsed, before the output and
+	// the decoder memory are allocated, and make decoding fail with
+	// VP8_STATUS_LIMIT_EXCEEDED.
+	MaxPixels      int   // width x height of the image
+	MaxMemoryBytes int64 // output buffer plus the decoder's own memory
+	MaxFrames      int   // frames of animations
+}
+
+// Fills 'options' from 'opts'. Returns false if a value is out of range.
+func InitDecoderOptions( /* const */ opts *DecodeOptions, options *WebPDecoderOptions) bool {
+	if opts.DitheringStrength < 0 || opts.DitheringStrength > 100 ||
+		opts.AlphaDitheringStrength < 0 || opts.AlphaDitheringStrength > 100 ||
+		opts.ResampleFilter < utils.WEBP_FILTER_BOX || opts.ResampleFilter >= utils.WEBP_FILTER_LAST ||
+		opts.MaxPixels < 0 || opts.MaxMemoryBytes < 0 || opts.MaxFrames < 0 {
+		return false
+	}
+	stdlib.Memset(options, 0, sizeof(*options))
+	options.dithering_strength = opts.DitheringStrength
+	options.alpha_dithering_strength = opts.AlphaDitheringStrength
+	options.bypass_filtering = tenary.If(opts.BypassFiltering, 1, 0)
+	options.no_fancy_upsampling = tenary.If(opts.NoFancyUpsampling, 1, 0)
+	options.use_threads = tenary.If(opts.UseThreads, 1, 0)
+	options.flip = tenary.If(opts.Flip, 1, 0)
+	options.use_wavefront = tenary.If(opts.UseWavefront, 1, 0)
+	options.yuv_matrix = opts.YUVMatrix
+	options.max_pixels = opts.MaxPixels
+	options.max_memory_bytes = opts.MaxMemoryBytes
+	options.max_frames = opts.MaxFrames
+	if opts.UseCropping {
+		options.use_cropping = 1
+		options.crop_left, options.crop_top = opts.CropLeft, opts.CropTop
+		options.crop_width, options.crop_height = opts.CropWidth, opts.CropHeight
+	}
+	if opts.UseScaling {
+		options.use_scaling = 1
+		options.scaled_width, options.scaled_height = opts.ScaledWidth, opts.ScaledHeight
+		options.resample_filter = opts.ResampleFilter
+	}
+	return true
+}
+
+// Go view of a decoded WebPDecBuffer. For RGB modes, only RGBA and Stride
+// are set; for MODE_YUV and MODE_YUVA, the planes are (A only for MODE_YUVA).
+type DecodedImage struct {
+	Mode          WEBP_CSP_MODE
+	Width, Height int
+
+	RGBA   []uint8
+	Stride int
+
+	Y, U, V, A                         []uint8
+	YStride, UStride, VStride, AStride int
+}
+
+// Copies the samples of 'buf' out of its memory.
+func ExportDecBuffer( /* const */ buf *WebPDecBuffer) *DecodedImage {
+	img := &DecodedImage{Mode: buf.colorspace, Width: buf.width, Height: buf.height}
+	if WebPIsRGBMode(buf.colorspace) {
+		rgba := &buf.u.RGBA
+		img.RGBA = append([]uint8(nil), rgba.rgba[:rgba.size]...)
+		img.Stride = rgba.stride
+		return img
+	}
+	yuva := &buf.u.YUVA
+	img.Y = append([]uint8(nil), yuva.y[:yuva.y_size]...)
+	img.U = append([]uint8(nil), yuva.u[:yuva.u_size]...)
+	img.V = append([]uint8(nil), yuva.v[:yuva.v_size]...)
+	img.YStride, img.UStride, img.VStride = yuva.y_stride, yuva.u_stride, yuva.v_stride
+	if buf.colorspace == MODE_YUVA && yuva.a != nil {
+		img.A = append([]uint8(nil), yuva.a[:yuva.a_size]...)
+		img.AStride = yuva.a_stride
+	}
+	return img
+}
+
+// Prepares 'config' to decode 'data' to 'mode' with 'opts' (nil for the
+// defaults).
+func initAdvancedConfig( /* const */ data []uint8, mode WEBP_CSP_MODE /*const*/, opts *DecodeOptions, config *WebPDecoderConfig) vp8.VP8StatusCode {
+	if len(data) == 0 || mode < MODE_RGB || mode >= MODE_LAST ||
+		!WebPInitDecoderConfig(config) {
+		return vp8.VP8_STATUS_INVALID_PARAM
+	}
+	if opts != nil && !InitDecoderOptions(opts, &config.options) {
+		return vp8.VP8_STATUS_INVALID_PARAM
+	}
+	if status := GetFeatures(data, uint64(len(data)), &config.input); status != vp8.VP8_STATUS_OK {
+		return status
+	}
+	config.output.colorspace = mode
+	if !WebPValidateDecoderConfig(config) {
+		return vp8.VP8_STATUS_INVALID_PARAM
+	}
+	return vp8.VP8_STATUS_OK
+}
+
+// Decodes 'data' to 'mode' with the advanced API (WebPDecode()), applying
+// all of 'opts' (nil for the defaults).
+func WebPDecodeAdvanced( /* const */ data []uint8, mode WEBP_CSP_MODE /*const*/, opts *DecodeOptions) (*DecodedImage, vp8.VP8StatusCode) {
+	var config WebPDecoderConfig
+	if status := initAdvancedConfig(data, mode, opts, &config); status != vp8.VP8_STATUS_OK {
+		return nil, status
+	}
+	defer WebPFreeDecBuffer(&config.output)
+	if status := WebPDecode(data, uint64(len(data)), &config); status != vp8.VP8_STATUS_OK {
+		return nil, status
+	}
+	return ExportDecBuffer(&config.output), vp8.VP8_STATUS_OK
+}
+
+// Same as WebPDecodeAdvanced(), through the incremental decoder: 'data' is
+// appended with WebPIAppend() in pieces of 'chunk_size' bytes (all at once
+// if 'chunk_size' <= 0).
+func WebPIDecodeAdvanced( /* const */ data []uint8, mode WEBP_CSP_MODE /*const*/, opts *DecodeOptions, chunk_size int) (*DecodedImage, vp8.VP8StatusCode) {
+	var config WebPDecoderConfig
+	if status := initAdvancedConfig(data, mode, opts, &config); status != vp8.VP8_STATUS_OK {
+		return nil, status
+	}
+	defer WebPFreeDecBuffer(&config.output)
+	idec := WebPIDecode(nil, 0, &config)
+	if idec == nil {
+		return nil, vp8.VP8_STATUS_OUT_OF_MEMORY
+	}
+	defer WebPIDelete(idec)
+	if chunk_size <= 0 {
+		chunk_size = len(data)
+	}
+	status := vp8.VP8_STATUS_SUSPENDED
+	for pos := 0; pos < len(data) && status == vp8.VP8_STATUS_SUSPENDED; pos += chunk_size {
+		end := min(pos+chunk_size, len(data))
+		status = WebPIAppend(idec, &data[pos], uint64(end-pos))
+	}
+	if status == vp8.VP8_STATUS_SUSPENDED {
+		return nil, vp8.VP8_STATUS_NOT_ENOUGH_DATA // truncated bitstream
+	}
+	if status != vp8.VP8_STATUS_OK {
+		return nil, status
+	}
+	return ExportDecBuffer(&config.output), vp8.VP8_STATUS_OK
+}
+
+// Parses just enough of 'data' to retrieve its ImageInfo.
+func GetImageInfo( /* const */ data []uint8) (ImageInfo, vp8.VP8StatusCode) {
+	var features WebPBitstreamFeatures
+	if len(data) == 0 {
+		return ImageInfo{}, vp8.VP8_STATUS_NOT_ENOUGH_DATA
+	}
+	status := GetFeatures(data, uint64(len(data)), &features)
+	if status != vp8.VP8_STATUS_OK {
+		return ImageInfo{}, status
+	}
+	return ImageInfo{
+		Width:        features.width,
+		Height:       features.height,
+		HasAlpha:     features.has_alpha != 0,
+		HasAnimation: features.has_animation != 0,
+		Format:       features.format,
+	}, vp8.VP8_STATUS_OK
+}
+
 func GetFeatures( /* const */ data *uint8, data_size uint64 /*const*/, features *WebPBitstreamFeatures) vp8.VP8StatusCode {
 	if features == nil || data == nil {
 		return vp8.VP8_STATUS_INVALID_PARAM
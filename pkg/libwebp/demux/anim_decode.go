@@ -13,6 +13,7 @@ import (
 	"github.com/daanv2/go-webp/pkg/constants"
 	"github.com/daanv2/go-webp/pkg/stdlib"
 	"github.com/daanv2/go-webp/pkg/util/tenary"
+	"github.com/daanv2/go-webp/pkg/vp8"
 )
 
 type BlendRowFunc = func( /* const */ *uint32 /* const */, *uint32, int)
@@ -35,6 +36,8 @@ type WebPAnimDecoder struct {
 	// Index of the next frame to be decoded
 	// (starting from 1).
 	next_frame int
+	// Status of the decoding of the last frame.
+	frame_status vp8.VP8StatusCode
 }
 
 func DefaultDecoderOptions( /* const */ dec_options *WebPAnimDecoderOptions) {
@@ -67,23 +70,61 @@ func ApplyDecoderOptions( /* const */ dec_options *WebPAnimDecoderOptions /* con
 	config.output.colorspace = mode
 	config.output.is_external_memory = 1
 	config.options.use_threads = dec_options.use_threads
+	// Every frame is checked as well when decoded.
+	config.options.max_pixels = dec_options.max_pixels
+	config.options.max_memory_bytes = dec_options.max_memory_bytes
+	config.options.max_frames = dec_options.max_frames
 	// Note: config.output.u.RGBA is set at the time of decoding each frame.
 	return true
 }
 
-func WebPAnimDecoder( /* const */ webp_data *WebPData /* const */, dec_options *WebPAnimDecoderOptions, abi_version int) *WebPAnimDecoderNewInternal {
+// Checks the animation described by 'info' against the limits of
+// 'dec_options': the canvas size, the number of frames, and the memory of the
+// two canvases plus the decoding of a canvas-sized frame.
+func CheckAnimDecoderLimits( /* const */ dec_options *WebPAnimDecoderOptions /* const */, info *WebPAnimInfo) vp8.VP8StatusCode {
+	num_pixels := uint64(info.canvas_width) * uint64(info.canvas_height)
+	if dec_options.max_pixels > 0 && num_pixels > uint64(dec_options.max_pixels) {
+		return vp8.VP8_STATUS_LIMIT_EXCEEDED
+	}
+	if dec_options.max_frames > 0 && uint64(info.frame_count) > uint64(dec_options.max_frames) {
+		return vp8.VP8_STATUS_LIMIT_EXCEEDED
+	}
+	if dec_options.max_memory_bytes > 0 {
+		memory := 2*num_pixels*NUM_CHANNELS +
+			WebPDecoderMemorySize(int(info.canvas_width), int(info.canvas_height), true, false)
+		if memory > uint64(dec_options.max_memory_bytes) {
+			return vp8.VP8_STATUS_LIMIT_EXCEEDED
+		}
+	}
+	return vp8.VP8_STATUS_OK
+}
+
+func WebPAnimDecoderNewInternal( /* const */ webp_data *WebPData /* const */, dec_options *WebPAnimDecoderOptions, abi_version int) *WebPAnimDecoder {
+	return WebPAnimDecoderNewStatus(webp_data, dec_options, nil)
+}
+
+// Same as WebPAnimDecoderNewInternal(), also setting '*status' (if not nil)
+// on failure: VP8_STATUS_LIMIT_EXCEEDED if the animation exceeds the limits of
+// 'dec_options', checked before the canvases are allocated.
+func WebPAnimDecoderNewStatus( /* const */ webp_data *WebPData /* const */, dec_options *WebPAnimDecoderOptions, status *vp8.VP8StatusCode) *WebPAnimDecoder {
 	var options WebPAnimDecoderOptions
 	var features WebPBitstreamFeatures
 	var dec *WebPAnimDecoder = nil
+	var dummy vp8.VP8StatusCode
+	if status == nil {
+		status = &dummy
+	}
+	*status = vp8.VP8_STATUS_INVALID_PARAM
 	if webp_data == nil {
 		return nil
 	}
 
 	// Validate the bitstream before doing expensive allocations. The demuxer may
 	// be more tolerant than the decoder.
-	if WebPGetFeatures(webp_data.bytes, webp_data.size, &features) != constants.VP8_STATUS_OK {
+	if *status = WebPGetFeatures(webp_data.bytes, webp_data.size, &features); *status != vp8.VP8_STATUS_OK {
 		return nil
 	}
+	*status = vp8.VP8_STATUS_BITSTREAM_ERROR
 
 	// Note: calloc() so that the pointer members are initialized to nil.
 	dec = &WebPAnimDecoder{}
@@ -110,6 +151,10 @@ func WebPAnimDecoder( /* const */ webp_data *WebPData /* const */, dec_options *
 	dec.info.bgcolor = WebPDemuxGetI(dec.demux, constants.WEBP_FF_BACKGROUND_COLOR)
 	dec.info.frame_count = WebPDemuxGetI(dec.demux, constants.WEBP_FF_FRAME_COUNT)
 
+	if *status = CheckAnimDecoderLimits(&options, &dec.info); *status != vp8.VP8_STATUS_OK {
+		goto Error
+	}
+
 	// Note: calloc() because we fill frame with zeroes as well.
 	dec.curr_frame = make([]uint8, dec.info.canvas_width*dec.info.canvas_height*NUM_CHANNELS)
 	//   dec.curr_frame = (*uint8)WebPSafeCalloc(dec.info.canvas_width * NUM_CHANNELS, dec.info.canvas_height)
@@ -120,6 +165,7 @@ func WebPAnimDecoder( /* const */ webp_data *WebPData /* const */, dec_options *
 	//   if dec.prev_frame_disposed == nil { goto Error }
 
 	WebPAnimDecoderReset(dec)
+	*status = vp8.VP8_STATUS_OK
 	return dec
 
 Error:
@@ -309,7 +355,7 @@ func FindBlendRangeAtRow( /* const */ src *WebPIterator /* const */, dst *WebPIt
 // Returns:
 //   False if any of the arguments are nil, or if there is a parsing or
 //   decoding error, or if there are no more frames. Otherwise, returns true.
-func WebPAnimDecoderGetNext(dec *WebPAnimDecoder, buf_ptr *uint8, timestamp_ptr *int) int {
+func WebPAnimDecoderGetNext(dec *WebPAnimDecoder, buf_ptr *[]uint8, timestamp_ptr *int) int {
 	var iter WebPIterator
 	var width uint32
 	var height uint32
@@ -362,7 +408,8 @@ func WebPAnimDecoderGetNext(dec *WebPAnimDecoder, buf_ptr *uint8, timestamp_ptr
 		buf.size = uint64(size)
 		buf.rgba = dec.curr_frame + out_offset
 
-		if WebPDecode(in, in_size, config) != VP8_STATUS_OK {
+		dec.frame_status = WebPDecode(in, in_size, config)
+		if dec.frame_status != VP8_STATUS_OK {
 			goto Error
 		}
 	}
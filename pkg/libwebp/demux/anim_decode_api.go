@@ -0,0 +1,121 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the COPYING file in the root of the source
+// tree. An additional intellectual property rights grant can be found
+// in the file PATENTS. All contributing project authors may
+// be found in the AUTHORS file in the root of the source tree.
+// -----------------------------------------------------------------------------
+//
+// Go view of the WebPAnimDecoder API.
+package demux
+
+import (
+	"errors"
+
+	"github.com/daanv2/go-webp/pkg/util/tenary"
+	"github.com/daanv2/go-webp/pkg/vp8"
+)
+
+// Go view of WebPAnimDecoderOptions.
+type AnimDecoderOptions struct {
+	// Output colorspace: MODE_RGBA, MODE_BGRA, MODE_rgbA or MODE_bgrA.
+	ColorMode WEBP_CSP_MODE
+	// Use multi-threaded decoding.
+	UseThreads bool
+
+	// Limits protecting against decompression bombs, 0 meaning no limit:
+	// canvas width x height, memory for the canvases and the decoding of
+	// the frames, and number of frames. They are checked before the
+	// canvases are allocated.
+	MaxPixels      int
+	MaxMemoryBytes int64
+	MaxFrames      int
+}
+
+// Go view of WebPAnimInfo.
+type AnimInfo struct {
+	CanvasWidth, CanvasHeight int
+	LoopCount                 int    // 0 = infinite
+	BgColor                   uint32 // alpha, red, green, blue from the most significant byte
+	FrameCount                int
+}
+
+var (
+	ErrAnimDecoderCreate = errors.New("could not create WebPAnimDecoder object")
+	ErrAnimDecoderFrame  = errors.New("error decoding animation frame")
+	ErrAnimDecoderLimit  = errors.New("animation exceeds the decoding limits")
+)
+
+// Creates an animation decoder for the WebP file 'data', which must stay
+// unchanged during the lifetime of the decoder. 'opts' may be nil for
+// MODE_RGBA without threads nor limits. Returns ErrAnimDecoderLimit if the
+// animation exceeds the limits of 'opts'.
+func NewAnimDecoder( /* const */ data []uint8, opts *AnimDecoderOptions) (*WebPAnimDecoder, error) {
+	var options WebPAnimDecoderOptions
+	var status vp8.VP8StatusCode
+	DefaultDecoderOptions(&options)
+	if opts != nil {
+		if opts.MaxPixels < 0 || opts.MaxMemoryBytes < 0 || opts.MaxFrames < 0 {
+			return nil, ErrAnimDecoderCreate
+		}
+		options.color_mode = opts.ColorMode
+		options.use_threads = tenary.If(opts.UseThreads, 1, 0)
+		options.max_pixels = opts.MaxPixels
+		options.max_memory_bytes = opts.MaxMemoryBytes
+		options.max_frames = opts.MaxFrames
+	}
+	webp_data := WebPData{bytes: data, size: uint64(len(data))}
+	dec := WebPAnimDecoderNewStatus(&webp_data, &options, &status)
+	if dec == nil {
+		if status == vp8.VP8_STATUS_LIMIT_EXCEEDED {
+			return nil, ErrAnimDecoderLimit
+		}
+		return nil, ErrAnimDecoderCreate
+	}
+	return dec, nil
+}
+
+// Global information about the animation.
+func (dec *WebPAnimDecoder) Info() AnimInfo {
+	return AnimInfo{
+		CanvasWidth:  int(dec.info.canvas_width),
+		CanvasHeight: int(dec.info.canvas_height),
+		LoopCount:    int(dec.info.loop_count),
+		BgColor:      dec.info.bgcolor,
+		FrameCount:   int(dec.info.frame_count),
+	}
+}
+
+// Reports whether some frames are yet to be decoded.
+func (dec *WebPAnimDecoder) HasMoreFrames() bool {
+	return WebPAnimDecoderHasMoreFrames(dec) != 0
+}
+
+// Go variant of WebPAnimDecoderGetNext(): the fully reconstructed canvas of
+// the next frame, 4 bytes per pixel without padding, and its end timestamp.
+// The canvas belongs to the decoder and is only valid until the next call.
+func (dec *WebPAnimDecoder) Next() (canvas []uint8, timestamp_ms int, err error) {
+	if WebPAnimDecoderGetNext(dec, &canvas, &timestamp_ms) == 0 {
+		if dec.frame_status == vp8.VP8_STATUS_LIMIT_EXCEEDED {
+			return nil, 0, ErrAnimDecoderLimit
+		}
+		return nil, 0, ErrAnimDecoderFrame
+	}
+	return canvas, timestamp_ms, nil
+}
+
+// Restarts decoding from the first frame.
+func (dec *WebPAnimDecoder) Reset() {
+	WebPAnimDecoderReset(dec)
+}
+
+// The demuxer owned by the decoder, to access the metadata chunks.
+func (dec *WebPAnimDecoder) Demuxer() *WebPDemuxer {
+	return WebPAnimDecoderGetDemuxer(dec)
+}
+
+// Releases the memory of the decoder.
+func (dec *WebPAnimDecoder) Delete() {
+	WebPAnimDecoderDelete(dec)
+}
@@ -0,0 +1,119 @@
+package demux
+
+import (
+	"testing"
+
+	"github.com/daanv2/go-webp/pkg/constants"
+	"github.com/daanv2/go-webp/pkg/webptest"
+	"github.com/stretchr/testify/require"
+)
+
+// Keeps the fuzzed canvases small enough not to exhaust the memory.
+const (
+	fuzzMaxPixels = 1 << 20
+	fuzzMaxFrames = 64
+)
+
+func FuzzDemux(f *testing.F) {
+	webptest.AddCorpus(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		webp_data := WebPData{bytes: data, size: uint64(len(data))}
+		dmux := WebPDemux(&webp_data)
+		if dmux == nil {
+			return
+		}
+		defer WebPDemuxDelete(dmux)
+
+		canvas_width := int(WebPDemuxGetI(dmux, constants.WEBP_FF_CANVAS_WIDTH))
+		canvas_height := int(WebPDemuxGetI(dmux, constants.WEBP_FF_CANVAS_HEIGHT))
+		frame_count := int(WebPDemuxGetI(dmux, constants.WEBP_FF_FRAME_COUNT))
+
+		var iter WebPIterator
+		num_frames := 0
+		for ok := WebPDemuxGetFrame(dmux, 1, &iter) != 0; ok; ok = WebPDemuxNextFrame(&iter) != 0 {
+			num_frames++
+			require.Equal(t, num_frames, iter.frame_num)
+			require.Equal(t, frame_count, iter.num_frames)
+			require.True(t, iter.x_offset >= 0 && iter.width > 0 && iter.x_offset+iter.width <= canvas_width,
+				"frame %d: x %d width %d, canvas %d", num_frames, iter.x_offset, iter.width, canvas_width)
+			require.True(t, iter.y_offset >= 0 && iter.height > 0 && iter.y_offset+iter.height <= canvas_height,
+				"frame %d: y %d height %d, canvas %d", num_frames, iter.y_offset, iter.height, canvas_height)
+			require.LessOrEqual(t, iter.fragment.size, uint64(len(data)))
+		}
+		WebPDemuxReleaseIterator(&iter)
+		require.Equal(t, frame_count, num_frames)
+
+		for _, fourcc := range [][4]byte{{'I', 'C', 'C', 'P'}, {'E', 'X', 'I', 'F'}, {'X', 'M', 'P', ' '}} {
+			var chunk_iter WebPChunkIterator
+			for ok := WebPDemuxGetChunk(dmux, fourcc, 1, &chunk_iter) != 0; ok; ok = WebPDemuxNextChunk(&chunk_iter) != 0 {
+				require.LessOrEqual(t, chunk_iter.chunk_num, chunk_iter.num_chunks)
+				require.LessOrEqual(t, chunk_iter.chunk.size, uint64(len(data)))
+			}
+			WebPDemuxReleaseChunkIterator(&chunk_iter)
+		}
+	})
+}
+
+// Truncated files, as parsed while they are downloaded.
+func FuzzDemuxPartial(f *testing.F) {
+	webptest.AddCorpus(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for size := len(data); size > 0; size /= 2 {
+			var state WebPDemuxState
+			webp_data := WebPData{bytes: data[:size], size: uint64(size)}
+			dmux := WebPDemuxPartial(&webp_data, &state)
+			if dmux == nil {
+				require.Contains(t, []WebPDemuxState{WEBP_DEMUX_PARSE_ERROR, WEBP_DEMUX_PARSING_HEADER}, state)
+				continue
+			}
+			require.NotEqual(t, WEBP_DEMUX_PARSE_ERROR, state)
+			var iter WebPIterator
+			for ok := WebPDemuxGetFrame(dmux, 1, &iter) != 0; ok; ok = WebPDemuxNextFrame(&iter) != 0 {
+				require.LessOrEqual(t, iter.fragment.size, uint64(size))
+			}
+			WebPDemuxReleaseIterator(&iter)
+			WebPDemuxDelete(dmux)
+		}
+	})
+}
+
+func FuzzAnimDecoder(f *testing.F) {
+	webptest.AddCorpus(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		dec, err := NewAnimDecoder(data, &AnimDecoderOptions{
+			ColorMode: MODE_RGBA,
+			MaxPixels: fuzzMaxPixels,
+			MaxFrames: fuzzMaxFrames,
+		})
+		if err != nil {
+			return
+		}
+		defer dec.Delete()
+
+		info := dec.Info()
+		require.LessOrEqual(t, info.CanvasWidth*info.CanvasHeight, fuzzMaxPixels)
+		require.LessOrEqual(t, info.FrameCount, fuzzMaxFrames)
+		var first []uint8
+		prev_timestamp := 0
+		for dec.HasMoreFrames() {
+			canvas, timestamp, err := dec.Next()
+			if err != nil {
+				return
+			}
+			require.Len(t, canvas, 4*info.CanvasWidth*info.CanvasHeight)
+			require.GreaterOrEqual(t, timestamp, prev_timestamp)
+			prev_timestamp = timestamp
+			if first == nil {
+				first = append([]uint8{}, canvas...)
+			}
+		}
+
+		// Decoding again from the start gives the same canvas.
+		if first != nil {
+			dec.Reset()
+			canvas, _, err := dec.Next()
+			require.NoError(t, err)
+			require.Equal(t, first, canvas)
+		}
+	})
+}
@@ -159,7 +159,7 @@ func ApplyAlphaMultiply4444_C(rgba []uint84444, w int, h int, stride int, rg_byt
 }
 
 func ApplyAlphaMultiply_16b_C(rgba []uint84444, w int, h int, stride int) {
-  ApplyAlphaMultiply4444_C(rgba4444, w, h, stride, 1)
+  ApplyAlphaMultiply4444_C(rgba4444, w, h, stride, 0) // [r4g4][b4a4]
 }
 
 func DispatchAlpha_C(/* const */ alpha *uint8, alpha_stride int, width, height int, dst []uint8, dst_stride int) int {
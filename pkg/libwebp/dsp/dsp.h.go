@@ -185,11 +185,12 @@ type WebPConvertARGBToY = func(/* const */ argb *uint32, y []uint8, width int)
 type WebPConvertARGBToUV = func(/* const */ argb *uint32, u []uint8, v []uint8, src_width int, do_store int)
 
 // Convert a row of accumulated (four-values) of rgba32 toward U/V
-type WebPConvertRGBA32ToUV = func(/* const */ rgb *uint16, u []uint8, v []uint8, width int)
+type WebPConvertRGBA32ToUV = func(/* const */ rgb *uint16, u []uint8, v []uint8, width int, m *yuv.Matrix)
 
 // Convert RGB or BGR to Y. Step is 3 or 4. If step is 4, data is RGBA or BGRA.
-type WebPConvertRGBToY = func(/* const */ rgb []uint8, y []uint8, width int, step int)
-type WebPConvertBGRToY = func(/* const */ bgr []uint8, y []uint8, width int, step int)
+// A nil matrix 'm' selects the default (BT601, limited range) one.
+type WebPConvertRGBToY = func(/* const */ rgb []uint8, y []uint8, width int, step int, m *yuv.Matrix)
+type WebPConvertBGRToY = func(/* const */ bgr []uint8, y []uint8, width int, step int, m *yuv.Matrix)
 
 // Must be called before using the above.
 func WebPInitConvertARGBToYUV(void)
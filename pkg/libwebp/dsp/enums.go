@@ -17,6 +17,7 @@ const ( // Filter types.
 	WEBP_FILTER_GRADIENT
 	WEBP_FILTER_BEST // meta-types
 	WEBP_FILTER_FAST
+	WEBP_FILTER_AUTO
 )
 
 const (
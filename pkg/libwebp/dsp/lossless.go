@@ -577,10 +577,10 @@ func VP8LConvertBGRAToRGBA4444_C( /* const */ src []uint32, num_pixels int, dst
 	for _, argb := range src[:num_pixels] {
 		rg := ((argb >> 16) & 0xf0) | ((argb >> 12) & 0xf)
 		ba := ((argb >> 0) & 0xf0) | ((argb >> 28) & 0xf)
-		dst[i] = uint8(ba)
-		i++
 		dst[i] = uint8(rg)
 		i++
+		dst[i] = uint8(ba)
+		i++
 	}
 }
 
@@ -589,10 +589,10 @@ func VP8LConvertBGRAToRGB565_C( /* const */ src []uint32, num_pixels int, dst []
 	for _, argb := range src[:num_pixels] {
 		rg := ((argb >> 16) & 0xf8) | ((argb >> 13) & 0x7)
 		gb := ((argb >> 5) & 0xe0) | ((argb >> 3) & 0x1f)
-		dst[i] = uint8(gb)
-		i++
 		dst[i] = uint8(rg)
 		i++
+		dst[i] = uint8(gb)
+		i++
 	}
 }
 
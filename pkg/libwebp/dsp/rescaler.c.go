@@ -175,6 +175,14 @@ func WebPRescalerImportRow(/* const */ wrk *WebPRescaler, /*const*/ src *uint8)
 }
 
 func WebPRescalerExportRow(/* const */ wrk *WebPRescaler) {
+  if (wrk.resampler != nil) {  // kernel filter
+    if (wrk.y_accum <= 0) {
+      assert.Assert(!WebPRescalerOutputDone(wrk))
+      WebPResamplerExportRow(wrk.resampler)
+      WebPRescalerSyncResampler(wrk)
+    }
+    return
+  }
   if (wrk.y_accum <= 0) {
     assert.Assert(!WebPRescalerOutputDone(wrk))
     if (wrk.y_expand) {
@@ -15,6 +15,7 @@ package dsp
 
 import "github.com/daanv2/go-webp/pkg/assert"
 import "github.com/daanv2/go-webp/pkg/stddef"
+import "github.com/daanv2/go-webp/pkg/color/yuv"
 
 import "github.com/daanv2/go-webp/pkg/libwebp/dsp"
 import "github.com/daanv2/go-webp/pkg/libwebp/dsp"
@@ -337,3 +338,67 @@ WEBP_DSP_INIT_FUNC(WebPInitUpsamplers) {
 }
 
 //------------------------------------------------------------------------------
+// Upsamplers for a non-default YUV.RGB conversion matrix.
+
+// Go version of UPSAMPLE_FUNC(), emitting pixels through 'fn'.
+func upsample_func(fn func(y, u, v uint8, rgb []uint8), XSTEP int) WebPUpsampleLinePairFunc {
+  return func(/* const */ top_y []uint8, /*const*/ bottom_y []uint8, /*const*/ top_u []uint8, /*const*/ top_v []uint8, /*const*/ cur_u []uint8, /*const*/ cur_v []uint8, top_dst []uint8, bottom_dst []uint8, len int) {
+    load_uv := func(u, v uint8) uint32 { return uint32(u) | (uint32(v) << 16) }
+    emit := func(y uint8, uv uint32, dst []uint8) { fn(y, uint8(uv&0xff), uint8(uv>>16), dst) }
+    last_pixel_pair := (len - 1) >> 1
+    tl_uv := load_uv(top_u[0], top_v[0]) // top-left sample
+    l_uv := load_uv(cur_u[0], cur_v[0])  // left-sample
+    assert.Assert(top_y != nil)
+    emit(top_y[0], (3*tl_uv+l_uv+0x00020002)>>2, top_dst)
+    if bottom_y != nil {
+      emit(bottom_y[0], (3*l_uv+tl_uv+0x00020002)>>2, bottom_dst)
+    }
+    for x := 1; x <= last_pixel_pair; x++ {
+      t_uv := load_uv(top_u[x], top_v[x]) // top sample
+      uv := load_uv(cur_u[x], cur_v[x])   // sample
+      // precompute invariant values associated with first and second diagonals
+      avg := tl_uv + t_uv + l_uv + uv + 0x00080008
+      diag_12 := (avg + 2*(t_uv+l_uv)) >> 3
+      diag_03 := (avg + 2*(tl_uv+uv)) >> 3
+      emit(top_y[2*x-1], (diag_12+tl_uv)>>1, top_dst[(2*x-1)*XSTEP:])
+      emit(top_y[2*x-0], (diag_03+t_uv)>>1, top_dst[(2*x-0)*XSTEP:])
+      if bottom_y != nil {
+        emit(bottom_y[2*x-1], (diag_03+l_uv)>>1, bottom_dst[(2*x-1)*XSTEP:])
+        emit(bottom_y[2*x+0], (diag_12+uv)>>1, bottom_dst[(2*x+0)*XSTEP:])
+      }
+      tl_uv = t_uv
+      l_uv = uv
+    }
+    if len&1 == 0 {
+      emit(top_y[len-1], (3*tl_uv+l_uv+0x00020002)>>2, top_dst[(len-1)*XSTEP:])
+      if bottom_y != nil {
+        emit(bottom_y[len-1], (3*l_uv+tl_uv+0x00020002)>>2, bottom_dst[(len-1)*XSTEP:])
+      }
+    }
+  }
+}
+
+// Returns the fancy upsampler for 'colorspace' using the conversion matrix
+// 'm'. A nil matrix (or the default one) returns the WebPUpsamplers entry.
+func WebPGetUpsampler(colorspace webp.WEBP_CSP_MODE, m *yuv.Matrix) WebPUpsampleLinePairFunc {
+  if m == nil || m == yuv.BT601 {
+    return WebPUpsamplers[colorspace]
+  }
+  switch colorspace {
+  case webp.MODE_RGB:
+    return upsample_func(m.YuvToRgb, 3)
+  case webp.MODE_RGBA, webp.MODE_rgbA:
+    return upsample_func(m.YuvToRgba, 4)
+  case webp.MODE_BGR:
+    return upsample_func(m.YuvToBgr, 3)
+  case webp.MODE_BGRA, webp.MODE_bgrA:
+    return upsample_func(m.YuvToBgra, 4)
+  case webp.MODE_ARGB, webp.MODE_Argb:
+    return upsample_func(m.YuvToArgb, 4)
+  case webp.MODE_RGBA_4444, webp.MODE_rgbA_4444:
+    return upsample_func(m.YuvToRgba4444, 2)
+  case webp.MODE_RGB_565:
+    return upsample_func(m.YuvToRgb565, 2)
+  }
+  return nil
+}
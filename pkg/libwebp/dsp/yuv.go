@@ -12,24 +12,19 @@ import (
 	"github.com/daanv2/go-webp/pkg/assert"
 	"github.com/daanv2/go-webp/pkg/color/yuv"
 	"github.com/daanv2/go-webp/pkg/constants"
+	"github.com/daanv2/go-webp/pkg/libwebp/webp"
 	"github.com/daanv2/go-webp/pkg/stdlib"
 )
 
-//go:fix inline
-func row_func(callfn func(y, u, v, rgb *uint8), XSTEP int) func(y, u, v, dst []uint8, len int) {
+func row_func(callfn func(y, u, v uint8, rgb []uint8), XSTEP int) func(y, u, v, dst []uint8, len int) {
 	return func(y, u, v, dst []uint8, len int) {
-		var end *uint8 = dst + (len&~1)*(XSTEP)
-
-		for dst != end {
-			callfn(y[0], u[0], v[0], dst)
-			callfn(y[1], u[0], v[0], dst+(XSTEP))
-			y += 2
-			u++
-			v++
-			dst += 2 * (XSTEP)
+		i := 0
+		for ; i < len&^1; i += 2 {
+			callfn(y[i+0], u[i>>1], v[i>>1], dst[(i+0)*XSTEP:])
+			callfn(y[i+1], u[i>>1], v[i>>1], dst[(i+1)*XSTEP:])
 		}
-		if len & 1 {
-			callfn(y[0], u[0], v[0], dst)
+		if len&1 != 0 {
+			callfn(y[i], u[i>>1], v[i>>1], dst[i*XSTEP:])
 		}
 	}
 }
@@ -43,6 +38,31 @@ var YuvToArgbRow = row_func(yuv.YuvToArgb, 4)
 var YuvToRgba4444Row = row_func(yuv.YuvToRgba4444, 2)
 var YuvToRgb565Row = row_func(yuv.YuvToRgb565, 2)
 
+// Returns the sampler for 'colorspace' using the conversion matrix 'm'.
+// A nil matrix (or the default one) returns the regular WebPSamplers entry.
+func WebPGetSampler(colorspace webp.WEBP_CSP_MODE, m *yuv.Matrix) WebPSamplerRowFunc {
+	if m == nil || m == yuv.BT601 {
+		return WebPSamplers[colorspace]
+	}
+	switch colorspace {
+	case webp.MODE_RGB:
+		return row_func(m.YuvToRgb, 3)
+	case webp.MODE_RGBA, webp.MODE_rgbA:
+		return row_func(m.YuvToRgba, 4)
+	case webp.MODE_BGR:
+		return row_func(m.YuvToBgr, 3)
+	case webp.MODE_BGRA, webp.MODE_bgrA:
+		return row_func(m.YuvToBgra, 4)
+	case webp.MODE_ARGB, webp.MODE_Argb:
+		return row_func(m.YuvToArgb, 4)
+	case webp.MODE_RGBA_4444, webp.MODE_rgbA_4444:
+		return row_func(m.YuvToRgba4444, 2)
+	case webp.MODE_RGB_565:
+		return row_func(m.YuvToRgb565, 2)
+	}
+	return nil
+}
+
 // Main call for processing a plane with a WebPSamplerRowFunc function:
 func WebPSamplerProcessPlane( /* const */ y *uint8, y_stride int /*const*/, u *uint8 /*const*/, v *uint8, uv_stride int, dst []uint8, dst_stride int, width, height int, fn WebPSamplerRowFunc) {
 	var j int
@@ -121,32 +141,37 @@ func WebPConvertARGBToUV( /* const */ argb []uint32, u []uint8, v []uint8, src_w
 
 //-----------------------------------------------------------------------------
 
-func WebPConvertRGBToY( /* const */ rgb []uint8, y []uint8, width int, step int) {
+// The conversion functions below use the matrix 'm', or the default (BT601)
+// one if nil.
+func WebPConvertRGBToY( /* const */ rgb []uint8, y []uint8, width int, step int, m *yuv.Matrix) {
+	m = yuv.MatrixOrDefault(m)
 	var i int
 	for i = 0; i < width; {
-		y[i] = uint8(yuv.RGBToY(int(rgb[0]), int(rgb[1]), int(rgb[2]), yuv.YUV_HALF))
+		y[i] = uint8(m.RGBToY(int(rgb[0]), int(rgb[1]), int(rgb[2]), yuv.YUV_HALF))
 		i++
 		rgb = rgb[step:]
 	}
 }
 
-func WebPConvertBGRToY( /* const */ bgr []uint8, y []uint8, width int, step int) {
+func WebPConvertBGRToY( /* const */ bgr []uint8, y []uint8, width int, step int, m *yuv.Matrix) {
+	m = yuv.MatrixOrDefault(m)
 	var i int
 	for i = 0; i < width; {
-		y[i] = uint8(yuv.RGBToY(int(bgr[2]), int(bgr[1]), int(bgr[0]), yuv.YUV_HALF))
+		y[i] = uint8(m.RGBToY(int(bgr[2]), int(bgr[1]), int(bgr[0]), yuv.YUV_HALF))
 		i++
 		bgr = bgr[step:]
 	}
 }
 
-func WebPConvertRGBA32ToUV( /* const */ rgb []uint16, u []uint8, v []uint8, width int) {
+func WebPConvertRGBA32ToUV( /* const */ rgb []uint16, u []uint8, v []uint8, width int, m *yuv.Matrix) {
+	m = yuv.MatrixOrDefault(m)
 	var i int
 	for i = 0; i < width; {
 		r := int(rgb[0])
 		g := int(rgb[1])
 		b := int(rgb[2])
-		u[i] = uint8(yuv.RGBToU(r, g, b, yuv.YUV_HALF<<2))
-		v[i] = uint8(yuv.RGBToV(r, g, b, yuv.YUV_HALF<<2))
+		u[i] = uint8(m.RGBToU(r, g, b, yuv.YUV_HALF<<2))
+		v[i] = uint8(m.RGBToV(r, g, b, yuv.YUV_HALF<<2))
 		i += 1
 		rgb = rgb[4:]
 	}
@@ -303,7 +328,7 @@ func WebPAccumulateRGB( /* const */ r_ptr *uint8 /*const*/, g_ptr *uint8 /*const
 
 func WebPImportYUVAFromRGBA( /* const */ r_ptr []uint8 /*const*/, g_ptr []uint8 /*const*/, b_ptr []uint8 /*const*/, a_ptr []uint8, step int, // bytes per pixel
 	rgb_stride int, // bytes per scanline
-	has_alpha bool, width, height int, tmp_rgb *uint16, y_stride int, uv_stride int, a_stride int, dst_y *uint8, dst_u *uint8, dst_v *uint8, dst_a *uint8) {
+	has_alpha bool, width, height int, tmp_rgb *uint16, y_stride int, uv_stride int, a_stride int, dst_y *uint8, dst_u *uint8, dst_v *uint8, dst_a *uint8, m *yuv.Matrix) {
 	var y int
 	is_rgb := (r_ptr < b_ptr) // otherwise it's bgr
 	uv_width := (width + 1) >> 1
@@ -316,11 +341,11 @@ func WebPImportYUVAFromRGBA( /* const */ r_ptr []uint8 /*const*/, g_ptr []uint8
 	for y = 0; y < (height >> 1); y++ {
 		rows_have_alpha := has_alpha
 		if is_rgb {
-			WebPConvertRGBToY(r_ptr, dst_y, width, step)
-			WebPConvertRGBToY(r_ptr+rgb_stride, dst_y+y_stride, width, step)
+			WebPConvertRGBToY(r_ptr, dst_y, width, step, m)
+			WebPConvertRGBToY(r_ptr+rgb_stride, dst_y+y_stride, width, step, m)
 		} else {
-			WebPConvertBGRToY(b_ptr, dst_y, width, step)
-			WebPConvertBGRToY(b_ptr+rgb_stride, dst_y+y_stride, width, step)
+			WebPConvertBGRToY(b_ptr, dst_y, width, step, m)
+			WebPConvertBGRToY(b_ptr+rgb_stride, dst_y+y_stride, width, step, m)
 		}
 		dst_y += 2 * y_stride
 		if has_alpha {
@@ -342,7 +367,7 @@ func WebPImportYUVAFromRGBA( /* const */ r_ptr []uint8 /*const*/, g_ptr []uint8
 			WebPAccumulateRGBA(r_ptr, g_ptr, b_ptr, a_ptr, rgb_stride, tmp_rgb, width)
 		}
 		// Convert to U/V
-		WebPConvertRGBA32ToUV(tmp_rgb, dst_u, dst_v, uv_width)
+		WebPConvertRGBA32ToUV(tmp_rgb, dst_u, dst_v, uv_width, m)
 		dst_u += uv_stride
 		dst_v += uv_stride
 		r_ptr += 2 * rgb_stride
@@ -355,15 +380,15 @@ func WebPImportYUVAFromRGBA( /* const */ r_ptr []uint8 /*const*/, g_ptr []uint8
 }
 
 func WebPImportYUVAFromRGBALastLine( /* const */ r_ptr *uint8 /*const*/, g_ptr *uint8 /*const*/, b_ptr *uint8 /*const*/, a_ptr *uint8, step int, // bytes per pixel
-	has_alpha bool, width int, tmp_rgb *uint16, dst_y *uint8, dst_u *uint8, dst_v *uint8, dst_a *uint8) {
+	has_alpha bool, width int, tmp_rgb *uint16, dst_y *uint8, dst_u *uint8, dst_v *uint8, dst_a *uint8, m *yuv.Matrix) {
 	is_rgb := (r_ptr < b_ptr) // otherwise it's bgr
 	uv_width := (width + 1) >> 1
 	row_has_alpha := has_alpha && dst_a != nil
 
 	if is_rgb {
-		WebPConvertRGBToY(r_ptr, dst_y, width, step)
+		WebPConvertRGBToY(r_ptr, dst_y, width, step, m)
 	} else {
-		WebPConvertBGRToY(b_ptr, dst_y, width, step)
+		WebPConvertBGRToY(b_ptr, dst_y, width, step, m)
 	}
 	if row_has_alpha {
 		row_has_alpha &= !WebPExtractAlpha(a_ptr, 0, width, 1, dst_a, 0)
@@ -378,7 +403,7 @@ func WebPImportYUVAFromRGBALastLine( /* const */ r_ptr *uint8 /*const*/, g_ptr *
 	} else {
 		WebPAccumulateRGBA(r_ptr, g_ptr, b_ptr, a_ptr /*rgb_stride=*/, 0, tmp_rgb, width)
 	}
-	WebPConvertRGBA32ToUV(tmp_rgb, dst_u, dst_v, uv_width)
+	WebPConvertRGBA32ToUV(tmp_rgb, dst_u, dst_v, uv_width, m)
 }
 
 // Macros to give the offset of each channel in a uint32 containing ARGB.
@@ -208,6 +208,13 @@ func GetFilterMap(/* const */ alpha *uint8, width, height int, filter int, effor
     if (try_filter_none || num_colors > kMaxColorsForFilterNone) {
       bit_map |= FILTER_TRY_NONE
     }
+  } else if (filter == WEBP_FILTER_AUTO) {
+    // Estimated candidate and FILTER_NONE, or everything at high effort.
+    if (effort_level >= 5) {
+      bit_map = FILTER_TRY_ALL
+    } else {
+      bit_map = GetFilterMap(alpha, width, height, WEBP_FILTER_FAST, 6)
+    }
   } else if (filter == WEBP_FILTER_NONE) {
     bit_map = FILTER_TRY_NONE
   } else {  // WEBP_FILTER_BEST . try all
@@ -221,9 +228,11 @@ func InitFilterTrial(/* const */ score *FilterTrial) {
   VP8BitWriterInit(&score.bw, 0)
 }
 
-func ApplyFiltersAndEncode(/* const */ alpha *uint8, width, height int, data_size uint64, method int, filter int, reduce_levels int, effort_level int, *uint8* const output, /*const*/ output_size *uint64, /*const*/ stats *WebPAuxStats) int {
+// The filter of the smallest trial is stored in 'best_filter'.
+func ApplyFiltersAndEncode(/* const */ alpha *uint8, width, height int, data_size uint64, method int, filter int, reduce_levels int, effort_level int, *uint8* const output, /*const*/ output_size *uint64, /*const*/ best_filter *int, /*const*/ stats *WebPAuxStats) int {
   ok := 1
    var best FilterTrial
+  *best_filter = WEBP_FILTER_NONE
   try_map := GetFilterMap(alpha, width, height, filter, effort_level)
   InitFilterTrial(&best)
 
@@ -238,6 +247,7 @@ func ApplyFiltersAndEncode(/* const */ alpha *uint8, width, height int, data_siz
         ok = EncodeAlphaInternal(alpha, width, height, method, filter, reduce_levels, effort_level, filtered_alpha, &trial)
         if (ok && trial.score < best.score) {
           best = trial
+          *best_filter = filter
         }
       }
     }
@@ -283,7 +293,7 @@ func EncodeAlpha(/* const */ enc *vp8.VP8Encoder, quality int, method int, filte
   assert.Assert(output != nil && output_size != nil)
   assert.Assert(width > 0 && height > 0)
   assert.Assert(pic.a_stride >= width)
-  assert.Assert(filter >= WEBP_FILTER_NONE && filter <= WEBP_FILTER_FAST)
+  assert.Assert(filter >= WEBP_FILTER_NONE && filter <= WEBP_FILTER_AUTO)
 
   if (quality < 0 || quality > 100) {
     return pic.SetEncodingError(picture.ENC_ERROR_INVALID_CONFIGURATION)
@@ -317,9 +327,12 @@ func EncodeAlpha(/* const */ enc *vp8.VP8Encoder, quality int, method int, filte
 
   if (ok) {
     VP8FiltersInit()
-    ok = ApplyFiltersAndEncode(quant_alpha, width, height, data_size, method, filter, reduce_levels, effort_level, output, output_size, pic.stats)
+    best_filter := WEBP_FILTER_NONE
+    ok = ApplyFiltersAndEncode(quant_alpha, width, height, data_size, method, filter, reduce_levels, effort_level, output, output_size, &best_filter, pic.stats)
     if (!ok) {
       pic.SetEncodingError(picture.ENC_ERROR_OUT_OF_MEMORY)  // imprecise
+    } else {
+      pic.AlphaFilter = best_filter
     }
   }
 
@@ -329,16 +342,23 @@ func EncodeAlpha(/* const */ enc *vp8.VP8Encoder, quality int, method int, filte
 //------------------------------------------------------------------------------
 // Main calls
 
-func CompressAlphaJob(arg *void1, unused *void) int {
-  var enc *vp8.VP8Encoder = (*vp8.VP8Encoder)arg1
-  var config *config.Config = enc.config
+func CompressAlphaJob(arg1, unused any) int {
+  var enc *vp8.VP8Encoder = arg1.(*vp8.VP8Encoder)
+  var cfg *config.Config = enc.config
   alpha_data *uint8 = nil
   alpha_size := 0
-  effort_level := config.Method;  // maps to [0..6]
-  var WEBP_FILTER_TYPE filter = (config.AlphaFiltering == 0)   ? WEBP_FILTER_NONE
-      : (config.AlphaFiltering == 1) ? WEBP_FILTER_FAST
-                                       : WEBP_FILTER_BEST
-  if (!EncodeAlpha(enc, config.AlphaQuality, config.AlphaCompression, filter, effort_level, &alpha_data, &alpha_size)) {
+  effort_level := cfg.Method;  // maps to [0..6]
+  if (cfg.AlphaMethod >= 0) {
+    effort_level = cfg.AlphaMethod
+  }
+  var filter WEBP_FILTER_TYPE
+  switch (cfg.AlphaFiltering) {
+    case config.ALPHA_FILTER_NONE: filter = WEBP_FILTER_NONE
+    case config.ALPHA_FILTER_FAST: filter = WEBP_FILTER_FAST
+    case config.ALPHA_FILTER_AUTO: filter = WEBP_FILTER_AUTO
+    default: filter = WEBP_FILTER_BEST
+  }
+  if (!EncodeAlpha(enc, cfg.AlphaQuality, int(cfg.AlphaCompression), filter, effort_level, &alpha_data, &alpha_size)) {
     return 0
   }
   if (alpha_size != uint32(alpha_size)) {  // Soundness check.
@@ -382,9 +382,9 @@ type SegmentJob struct {
 } 
 
 // main work call
-func DoSegmentsJob(arg *void1, arg *void2) int {
-  var job *SegmentJob = (*SegmentJob)arg1
-  var it *vp8.VP8EncIterator = (*vp8.VP8EncIterator)arg2
+func DoSegmentsJob(arg1, arg2 any) int {
+  var job *SegmentJob = arg1.(*SegmentJob)
+  var it *vp8.VP8EncIterator = arg2.(*vp8.VP8EncIterator)
   ok := 1
   if (!VP8IteratorIsDone(it)) {
     uint8 tmp[32 + WEBP_ALIGN_CST]
@@ -399,14 +399,12 @@ func DoSegmentsJob(arg *void1, arg *void2) int {
   return ok
 }
 
-#ifdef WEBP_USE_THREAD
 func MergeJobs(/* const */ src *SegmentJob, /*const*/ dst *SegmentJob) {
   var i int
   for (i = 0; i <= MAX_ALPHA; ++i) dst.alphas[i] += src.alphas[i]
   dst.alpha += src.alpha
   dst.uv_alpha += src.uv_alpha
 }
-#endif
 
 // initialize the job struct with some tasks to perform
 func InitSegmentJob(/* const */ enc *vp8.VP8Encoder, /*const*/ job *SegmentJob, start_row int, end_row int) {
@@ -436,43 +434,36 @@ func VP8EncAnalyze(/* const */ enc *vp8.VP8Encoder) int {
   if (do_segments) {
     last_row := enc.mb_h
     total_mb := last_row * enc.mb_w
-#ifdef WEBP_USE_THREAD
-    // We give a little more than a half work to the main thread.
-    split_row := (9 * last_row + 15) >> 4
-    kMinSplitRow := 2;  // minimal rows needed for mt to be worth it
-    do_mt := (enc.thread_level > 0) && (split_row >= kMinSplitRow)
-#else
-    do_mt := 0
-#endif
+    kMinSplitRow := 2;  // minimal rows needed per job for mt to be worth it
+    // The main job runs in this goroutine, the 'thread_level' others in
+    // workers. Each job gets a band of rows.
+    num_jobs := 1 + enc.thread_level
+    if num_jobs > last_row / kMinSplitRow { num_jobs = last_row / kMinSplitRow }
+    if num_jobs < 1 { num_jobs = 1 }
     const worker_interface *WebPWorkerInterface = WebPGetWorkerInterface()
-     var main_job SegmentJob
-    if (do_mt) {
-#ifdef WEBP_USE_THREAD
-       var side_job SegmentJob
-      // Note the use of '&' instead of '&&' because we must call the functions
-      // no matter what.
-      InitSegmentJob(enc, &main_job, 0, split_row)
-      InitSegmentJob(enc, &side_job, split_row, last_row)
-      // we don't need to call Reset() on main_job.worker, since we're calling
-      // WebPWorkerExecute() on it
-      ok &= worker_interface.Reset(&side_job.worker)
-      // launch the two jobs in parallel
-      if (ok) {
-        worker_interface.Launch(&side_job.worker)
-        worker_interface.Execute(&main_job.worker)
-        ok &= worker_interface.Sync(&side_job.worker)
-        ok &= worker_interface.Sync(&main_job.worker)
+    jobs := make([]SegmentJob, num_jobs)
+    var main_job *SegmentJob = &jobs[0]
+    var n int
+    for n = 0; n < num_jobs; n++ {
+      InitSegmentJob(enc, &jobs[n], n * last_row / num_jobs, (n + 1) * last_row / num_jobs)
+    }
+    // we don't need to call Reset() on main_job.worker, since we're calling
+    // WebPWorkerExecute() on it
+    for n = 1; ok && n < num_jobs; n++ {
+      ok &= worker_interface.Reset(&jobs[n].worker)
+    }
+    // launch the jobs in parallel
+    if (ok) {
+      for n = 1; n < num_jobs; n++ {
+        worker_interface.Launch(&jobs[n].worker)
       }
-      worker_interface.End(&side_job.worker)
-      if ok { MergeJobs(&side_job, &main_job) }  // merge results together
-#endif                                          // WEBP_USE_THREAD
-    } else {
-      // Even for single-thread case, we use the generic Worker tools.
-      InitSegmentJob(enc, &main_job, 0, last_row)
       worker_interface.Execute(&main_job.worker)
-      ok &= worker_interface.Sync(&main_job.worker)
     }
-    worker_interface.End(&main_job.worker)
+    for n = num_jobs - 1; n >= 0; n-- {
+      ok &= worker_interface.Sync(&jobs[n].worker)
+      worker_interface.End(&jobs[n].worker)
+      if ok && n > 0 { MergeJobs(&jobs[n], main_job) }  // merge results together
+    }
     if (ok) {
       enc.alpha = main_job.alpha / total_mb
       enc.uv_alpha = main_job.uv_alpha / total_mb
@@ -0,0 +1,156 @@
+// Content analysis, to pick a preset and an image hint for pictures of
+// unknown type.
+
+package enc
+
+import (
+	"github.com/daanv2/go-webp/pkg/config"
+	"github.com/daanv2/go-webp/pkg/constants"
+	"github.com/daanv2/go-webp/pkg/libwebp/dsp"
+	"github.com/daanv2/go-webp/pkg/libwebp/utils"
+	"github.com/daanv2/go-webp/pkg/picture"
+)
+
+const (
+	MAX_ANALYSIS_SAMPLES  = 256 * 256 // pixels looked at, at most
+	ICON_MAX_PIXELS       = 128 * 128 // up to this size, palette images are icons
+	TEXT_MAX_COLORS       = 16        // palette images with few colours and ...
+	TEXT_MIN_EDGE_DENSITY = 0.08      // ... many sharp edges are text
+	EDGE_THRESHOLD        = 48        // channel residual of a sharp edge
+	SMOOTH_THRESHOLD      = 8         // maximal channel residual of a soft gradient
+
+	// Screenshots and UI: mostly flat, with sharp edges.
+	SCREEN_MIN_FLAT_FRACTION = 0.5
+	SCREEN_MIN_EDGE_DENSITY  = TEXT_MIN_EDGE_DENSITY / 2
+	// Photos: little flat area, or mostly soft gradients.
+	PHOTO_MAX_FLAT_FRACTION   = 0.2
+	PHOTO_MIN_SMOOTH_FRACTION = 0.6
+)
+
+// Summary of the content of a picture, and the settings recommended for it.
+type ContentAnalysis struct {
+	NumColors      int     // distinct colours, MAX_PALETTE_SIZE + 1 if more
+	EdgeDensity    float64 // fraction of samples on a sharp edge
+	FlatFraction   float64 // fraction of samples equal to their neighbours
+	SmoothFraction float64 // fraction of samples on a soft, non-zero gradient
+
+	Preset   config.Preset
+	Hint     config.ImageHint
+	Lossless bool
+}
+
+// Largest absolute difference between the channels of two ARGB samples.
+// The spatial residual of the lossless encoder wraps around: a residual 'd'
+// of a channel of 'pix' with value 'v' is a difference of d - 256 when d > v.
+func residualMagnitude(pix, pred uint32) int {
+	diff := dsp.VP8LSubPixels(pix, pred)
+	largest := 0
+	for shift := 0; shift < 32; shift += 8 {
+		d, v := int((diff>>shift)&0xff), int((pix>>shift)&0xff)
+		if d > v {
+			d = 256 - d
+		}
+		largest = max(largest, d)
+	}
+	return largest
+}
+
+// Analyses the samples of 'pic', which must use ARGB, and recommends a
+// preset, an image hint and whether to encode losslessly:
+//   - few colours (a palette fits): lossless, ICON when small, TEXT when
+//     the colours are very few and the edges dense, DRAWING otherwise.
+//   - mostly flat areas with sharp edges (screenshots, UI): lossless TEXT.
+//   - little flat area, or mostly soft gradients: lossy PHOTO.
+//   - otherwise: lossy PICTURE.
+//
+// Samples are classified from their residuals against their left and top
+// neighbours, on a regular grid of at most MAX_ANALYSIS_SAMPLES pixels, so
+// the cost is bounded for large pictures.
+func WebPAnalyzeContent( /* const */ pic *picture.Picture) (*ContentAnalysis, error) {
+	if pic == nil || pic.ARGB == nil {
+		return nil, picture.ENC_ERROR_nil_PARAMETER
+	}
+	width, height := pic.Width, pic.Height
+	if width <= 0 || height <= 0 {
+		return nil, pic.SetEncodingError(picture.ENC_ERROR_BAD_DIMENSION)
+	}
+	argb := pic.ARGB
+	stride := pic.ARGBStride
+	var res ContentAnalysis
+
+	var palette [constants.MAX_PALETTE_SIZE]uint32
+	res.NumColors = utils.GetColorPalette(pic, &palette[0])
+
+	step := 1
+	for ((width+step-1)/step)*((height+step-1)/step) > MAX_ANALYSIS_SAMPLES {
+		step++
+	}
+	pixel := func(x, y int) uint32 {
+		c := argb[y*stride+x]
+		return uint32(c.A)<<24 | uint32(c.R)<<16 | uint32(c.G)<<8 | uint32(c.B)
+	}
+	num_samples, num_edges, num_flat, num_smooth := 0, 0, 0, 0
+	// The grid is staggered, so that edges aligned on it are not missed.
+	for j := 0; 1+j*step+j%step < height; j++ {
+		y := 1 + j*step + j%step
+		for i := 0; 1+i*step+(i+j)%step < width; i++ {
+			x := 1 + i*step + (i+j)%step
+			pix := pixel(x, y)
+			left, top := pixel(x-1, y), pixel(x, y-1)
+			residual := max(residualMagnitude(pix, left), residualMagnitude(pix, top))
+			switch {
+			case residual == 0:
+				num_flat++
+			case residual >= EDGE_THRESHOLD:
+				num_edges++
+			case residual <= SMOOTH_THRESHOLD:
+				num_smooth++
+			}
+			num_samples++
+		}
+	}
+	if num_samples > 0 {
+		res.EdgeDensity = float64(num_edges) / float64(num_samples)
+		res.FlatFraction = float64(num_flat) / float64(num_samples)
+		res.SmoothFraction = float64(num_smooth) / float64(num_samples)
+	}
+
+	switch {
+	case res.NumColors <= constants.MAX_PALETTE_SIZE:
+		res.Lossless = true
+		res.Hint = config.WEBP_HINT_GRAPH
+		if width*height <= ICON_MAX_PIXELS {
+			res.Preset = config.WEBP_PRESET_ICON
+		} else if res.NumColors <= TEXT_MAX_COLORS && res.EdgeDensity >= TEXT_MIN_EDGE_DENSITY {
+			res.Preset = config.WEBP_PRESET_TEXT
+		} else {
+			res.Preset = config.WEBP_PRESET_DRAWING
+		}
+	case res.FlatFraction >= SCREEN_MIN_FLAT_FRACTION && res.EdgeDensity >= SCREEN_MIN_EDGE_DENSITY:
+		res.Lossless = true
+		res.Hint = config.WEBP_HINT_GRAPH
+		res.Preset = config.WEBP_PRESET_TEXT
+	case res.FlatFraction < PHOTO_MAX_FLAT_FRACTION || res.SmoothFraction >= PHOTO_MIN_SMOOTH_FRACTION:
+		res.Hint = config.WEBP_HINT_PHOTO
+		res.Preset = config.WEBP_PRESET_PHOTO
+	default:
+		res.Hint = config.WEBP_HINT_PICTURE
+		res.Preset = config.WEBP_PRESET_PICTURE
+	}
+	return &res, nil
+}
+
+// Initializes 'cfg' with the recommended preset at the given quality, and
+// sets Lossless and ImageHint accordingly. For lossless encoding, 'quality'
+// is the compression effort.
+func (res *ContentAnalysis) Apply(cfg *config.Config, quality float64) error {
+	if err := cfg.InitPreset(res.Preset, quality); err != nil {
+		return err
+	}
+	cfg.Lossless = 0
+	if res.Lossless {
+		cfg.Lossless = 1
+	}
+	cfg.ImageHint = res.Hint
+	return cfg.Validate()
+}
@@ -0,0 +1,114 @@
+package enc
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/daanv2/go-webp/pkg/config"
+	"github.com/daanv2/go-webp/pkg/picture"
+	"github.com/stretchr/testify/require"
+)
+
+func newAnalysisPicture(t *testing.T, width, height int, at func(x, y int) color.RGBA) *picture.Picture {
+	t.Helper()
+
+	pic := &picture.Picture{}
+	picture.WebPPictureInit(pic)
+	pic.UseARGB = true
+	pic.Width, pic.Height = width, height
+	require.NoError(t, picture.WebPPictureAlloc(pic))
+	t.Cleanup(func() { picture.WebPPictureFree(pic) })
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			pic.ARGB[y*pic.ARGBStride+x] = at(x, y)
+		}
+	}
+	return pic
+}
+
+// noise returns reproducible pseudo-random opaque colours.
+func noise() func(x, y int) color.RGBA {
+	seed := uint32(1)
+	return func(x, y int) color.RGBA {
+		seed = seed*1664525 + 1013904223
+		return color.RGBA{R: uint8(seed >> 24), G: uint8(seed >> 16), B: uint8(seed >> 8), A: 255}
+	}
+}
+
+func TestAnalyzeContent(t *testing.T) {
+	flat := func(x, y int) color.RGBA { return color.RGBA{R: 40, G: 120, B: 200, A: 255} }
+	// Smooth gradients with more colours than a palette holds.
+	gradient := func(x, y int) color.RGBA {
+		return color.RGBA{R: uint8(x), G: uint8(y), B: uint8((x + y) / 2), A: 255}
+	}
+	// Flat 16x16 blocks, all of different colours, with sharp edges between
+	// them: a screenshot.
+	blocks := func(x, y int) color.RGBA {
+		bx, by := x/16, y/16
+		return color.RGBA{R: uint8(255 * ((bx + by) & 1)), G: uint8(8 * bx), B: uint8(8 * by), A: 255}
+	}
+	// Flat on the left, noisy on the right.
+	mixed := func() func(x, y int) color.RGBA {
+		n := noise()
+		return func(x, y int) color.RGBA {
+			if x < 100 {
+				return color.RGBA{R: 90, G: 90, B: 90, A: 255}
+			}
+			return n(x, y)
+		}
+	}
+
+	for _, tc := range []struct {
+		name          string
+		width, height int
+		at            func(x, y int) color.RGBA
+		preset        config.Preset
+		hint          config.ImageHint
+		lossless      bool
+	}{
+		{"flat icon", 32, 32, flat, config.WEBP_PRESET_ICON, config.WEBP_HINT_GRAPH, true},
+		{"flat", 512, 512, flat, config.WEBP_PRESET_DRAWING, config.WEBP_HINT_GRAPH, true},
+		{"graphic", 512, 512, blocks, config.WEBP_PRESET_TEXT, config.WEBP_HINT_GRAPH, true},
+		{"photo", 256, 256, gradient, config.WEBP_PRESET_PHOTO, config.WEBP_HINT_PHOTO, false},
+		{"texture", 256, 256, noise(), config.WEBP_PRESET_PHOTO, config.WEBP_HINT_PHOTO, false},
+		{"picture", 250, 200, mixed(), config.WEBP_PRESET_PICTURE, config.WEBP_HINT_PICTURE, false},
+	} {
+		res, err := WebPAnalyzeContent(newAnalysisPicture(t, tc.width, tc.height, tc.at))
+		require.NoError(t, err, tc.name)
+		require.Equal(t, tc.preset, res.Preset, "%s: %+v", tc.name, res)
+		require.Equal(t, tc.hint, res.Hint, "%s: %+v", tc.name, res)
+		require.Equal(t, tc.lossless, res.Lossless, "%s: %+v", tc.name, res)
+
+		var cfg config.Config
+		require.NoError(t, res.Apply(&cfg, 75))
+		require.Equal(t, tc.lossless, cfg.Lossless != 0, tc.name)
+		require.Equal(t, tc.hint, cfg.ImageHint, tc.name)
+	}
+}
+
+func TestAnalyzeContentFractions(t *testing.T) {
+	flat, err := WebPAnalyzeContent(newAnalysisPicture(t, 64, 64, func(x, y int) color.RGBA {
+		return color.RGBA{A: 255}
+	}))
+	require.NoError(t, err)
+	require.Equal(t, 1, flat.NumColors)
+	require.Equal(t, 1.0, flat.FlatFraction)
+	require.Zero(t, flat.EdgeDensity)
+	require.Zero(t, flat.SmoothFraction)
+
+	// Every sample but those on a diagonal line is flat, and the line has
+	// sharp edges.
+	line, err := WebPAnalyzeContent(newAnalysisPicture(t, 64, 64, func(x, y int) color.RGBA {
+		if x == y {
+			return color.RGBA{R: 255, G: 255, B: 255, A: 255}
+		}
+		return color.RGBA{A: 255}
+	}))
+	require.NoError(t, err)
+	require.Equal(t, 2, line.NumColors)
+	require.InDelta(t, 3.0/63, line.EdgeDensity, 0.005) // on the line, right of and below it
+	require.Equal(t, 1.0, line.FlatFraction+line.EdgeDensity)
+
+	_, err = WebPAnalyzeContent(nil)
+	require.Error(t, err)
+}
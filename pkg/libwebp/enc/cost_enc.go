@@ -246,6 +246,18 @@ func VP8RecordStats(bit int, stats *proba_t) int {
 	return bit
 }
 
+// Add the counts of 'src' to '*dst'. Both counts are halved in case of
+// overflow, like VP8RecordStats() does.
+func VP8MergeStats(src proba_t, dst *proba_t) {
+	total := (*dst >> 16) + (src >> 16)
+	bits := (*dst & 0xffff) + (src & 0xffff)
+	for total >= 0xfffe {
+		total = (total + 1) >> 1
+		bits = (bits + 1) >> 1
+	}
+	*dst = (total << 16) | bits
+}
+
 // Cost of coding one event with probability 'proba'.
 func VP8BitCost(bit int, uint8 proba) int {
 	return tenary.If(!(bit != 0), VP8EntropyCost[proba], VP8EntropyCost[255-proba])
@@ -338,22 +338,30 @@ func CodeResiduals(/* const */ bw *VP8BitWriter, /*const*/ it *vp8.VP8EncIterato
   VP8IteratorBytesToNz(it)
 }
 
+// Same as VP8InitResidual(), but records the statistics in the iterator's
+// band, if any.
+func InitRecordResidual(first int, coeff_type int, /*const*/ it *vp8.VP8EncIterator, /*const*/ res *VP8Residual) {
+  VP8InitResidual(first, coeff_type, it.enc, res)
+  if (it.band != nil) {
+    res.stats = it.band.stats[coeff_type]
+  }
+}
+
 // Same as CodeResiduals, but doesn't actually write anything.
 // Instead, it just records the event distribution.
 func RecordResiduals(/* const */ it *vp8.VP8EncIterator, /*const*/ rd *VP8ModeScore) {
   int x, y, ch
    var res VP8Residual
-  var enc *vp8.VP8Encoder = it.enc
 
   VP8IteratorNzToBytes(it)
 
   if (it.mb.type == 1) {  // i16x16
-    VP8InitResidual(0, 1, enc, &res)
+    InitRecordResidual(0, 1, it, &res)
     VP8SetResidualCoeffs(rd.y_dc_levels, &res)
     it.top_nz[8] = it.left_nz[8] = VP8RecordCoeffs(it.top_nz[8] + it.left_nz[8], &res)
-    VP8InitResidual(1, 0, enc, &res)
+    InitRecordResidual(1, 0, it, &res)
   } else {
-    VP8InitResidual(0, 3, enc, &res)
+    InitRecordResidual(0, 3, it, &res)
   }
 
   // luma-AC
@@ -366,7 +374,7 @@ func RecordResiduals(/* const */ it *vp8.VP8EncIterator, /*const*/ rd *VP8ModeSc
   }
 
   // U/V
-  VP8InitResidual(0, 2, enc, &res)
+  InitRecordResidual(0, 2, it, &res)
   for ch = 0; ch <= 2; ch += 2 {
     for y = 0; y < 2; y++ {
       for x = 0; x < 2; x++ {
@@ -461,7 +469,7 @@ func StoreSSE(/* const */ it *vp8.VP8EncIterator) {
   enc.sse_count += 16 * 16
 }
 
-func StoreSideInfo(/* const */ it *vp8.VP8EncIterator) {
+func StoreStats(/* const */ it *vp8.VP8EncIterator) {
   var enc *vp8.VP8Encoder = it.enc
   var mb *VP8MBInfo = it.mb
   var pic *picture.Picture = enc.pic
@@ -472,38 +480,6 @@ func StoreSideInfo(/* const */ it *vp8.VP8EncIterator) {
     enc.block_count[1] += (mb.type == 1)
     enc.block_count[2] += (mb.skip != 0)
   }
-
-  if (pic.ExtraInfo != nil) {
-    var info *uint8 = &pic.ExtraInfo[it.x + it.y * enc.mb_w]
-    switch (pic.ExtraInfoType) {
-      case 1:
-        *info = mb.type
-        break
-      case 2:
-        *info = mb.segment
-        break
-      case 3:
-        *info = enc.dqm[mb.segment].quant
-        break
-      case 4:
-        *info = (mb.type == 1) ? it.preds[0] : 0xff
-        break
-      case 5:
-        *info = mb.uv_mode
-        break
-      case 6: {
-        b := (int)((it.luma_bits + it.uv_bits + 7) >> 3)
-        *info = tenary.If(b > 255, 255, b)
-        break
-      }
-      case 7:
-        *info = mb.alpha
-        break
-      default:
-        *info = 0
-        break
-    }
-  }
 #if SEGMENT_VISU  // visualize segments and prediction modes
   SetBlock(it.yuv_out + Y_OFF_ENC, mb.segment * 64, 16)
   SetBlock(it.yuv_out + U_OFF_ENC, it.preds[0] * 64, 8)
@@ -521,19 +497,54 @@ func ResetSideInfo(/* const */ it *vp8.VP8EncIterator) {
 }
 #else   // TRUE
 func ResetSSE(/* const */ enc *vp8.VP8Encoder) { _ = enc; }
-func StoreSideInfo(/* const */ it *vp8.VP8EncIterator) {
-  var enc *vp8.VP8Encoder = it.enc
-  var pic *picture.Picture = enc.pic
-  if (pic.ExtraInfo != nil) {
-    if (it.x == 0 && it.y == 0) {  // only do it once, at start
-      stdlib.Memset(pic.ExtraInfo, 0, enc.mb_w * enc.mb_h * sizeof(*pic.ExtraInfo))
-    }
-  }
-}
-
+func StoreStats(/* const */ it *vp8.VP8EncIterator) { _ = it; }
 func ResetSideInfo(/* const */ it *vp8.VP8EncIterator) { _ = it; }
 #endif  // FALSE
 
+// Clamps a per-macroblock measure into a map entry.
+func clampInfo(v uint64) uint8 {
+	return uint8(min(v, 255))
+}
+
+// Records the coding decisions of the current macroblock into
+// pic.MacroblockInfo and pic.ExtraInfo (if requested).
+// 'rd' holds the final score of the macroblock.
+func StoreSideInfo(it *vp8.VP8EncIterator, rd *VP8ModeScore) {
+	enc := it.enc
+	mb := it.mb
+	pic := enc.pic
+
+	StoreStats(it)
+
+	if pic.MacroblockInfo == nil && pic.ExtraInfo == nil {
+		return
+	}
+
+	info := picture.MacroblockInfo{
+		IntraType:   uint8(mb.vtype),
+		Segment:     uint8(mb.segment),
+		Quant:       uint8(enc.dqm[mb.segment].quant),
+		Intra16Mode: tenary.If(mb.vtype == 1, it.preds[0], uint8(0xff)),
+		UVMode:      uint8(mb.uv_mode),
+		Skip:        mb.skip != 0,
+		// bits, rounded up to bytes
+		BitCost: clampInfo((it.luma_bits + it.uv_bits + 7) >> 3),
+		// 384 = 16x16 luma + 2x 8x8 chroma samples
+		Distortion: clampInfo(uint64(max(rd.D, 0)) / 384),
+	}
+	if mb.skip != 0 {
+		info.BitCost = 0
+	}
+
+	idx := it.x + it.y*enc.mb_w
+	if idx < len(pic.MacroblockInfo) {
+		pic.MacroblockInfo[idx] = info
+	}
+	if idx < len(pic.ExtraInfo) {
+		pic.ExtraInfo[idx] = info.Value(pic.ExtraInfoType)
+	}
+}
+
 func GetPSNR(uint64 mse, size uint64 ) float64 {
   return (mse > 0 && size > 0) ? 10. * log10(255. * 255. * size / mse) : 99
 }
@@ -554,31 +565,152 @@ func SetLoopParams(/* const */ enc *vp8.VP8Encoder, float64 q) {
   ResetSSE(enc)
 }
 
+// Multi-threaded statistics pass: the picture is split in bands of rows, each
+// scanned by its own iterator (see VP8EncBand) and merged afterward. The split
+// only depends on the picture size, so that the output doesn't depend on the
+// number of threads.
+const MIN_STAT_BAND_ROWS = 16  // minimal rows per band
+const MAX_STAT_BANDS = 16
+
+// struct used to collect the result of a band
+type StatPassJob struct {
+	worker WebPWorker
+	it vp8.VP8EncIterator
+	band vp8.VP8EncBand
+	rd_opt VP8RDLevel
+	size, size_p0 uint64
+	distortion int
+	delta_progress int
+}
+
+func DoStatPassJob(arg1, arg2 any) int {
+  var job *StatPassJob = arg1.(*StatPassJob)
+  var it *vp8.VP8EncIterator = arg2.(*vp8.VP8EncIterator)
+  ok := 1
+  for {
+     var info VP8ModeScore
+    VP8IteratorImport(it, nil)
+    if (VP8Decimate(it, &info, job.rd_opt)) {
+      job.band.nb_skip++
+    }
+    RecordResiduals(it, &info)
+    job.size += info.R + info.H
+    job.size_p0 += info.H
+    job.distortion += info.D
+    if (job.delta_progress && !VP8IteratorProgress(it, job.delta_progress)) {
+      ok = 0
+      break
+    }
+    VP8IteratorSaveBoundary(it)
+    if !VP8IteratorNext(it) { break }
+  }
+  return ok
+}
+
+func GetNumStatBands(/* const */ enc *vp8.VP8Encoder) int {
+  if (enc.thread_level <= 0) {
+    return 1
+  }
+  num_bands := enc.mb_h / MIN_STAT_BAND_ROWS
+  return tenary.If(num_bands > MAX_STAT_BANDS, MAX_STAT_BANDS, num_bands)
+}
+
+// Scan the whole picture as 'num_bands' bands, using up to
+// 1 + enc.thread_level goroutines. Returns false in case of user-abort.
+func StatPassBands(/* const */ enc *vp8.VP8Encoder, VP8RDLevel rd_opt, num_bands int, percent_delta int, size *uint64, size_p0 *uint64, distortion *int) int {
+  const worker_interface *WebPWorkerInterface = WebPGetWorkerInterface()
+  jobs := make([]StatPassJob, num_bands)
+  num_workers := tenary.If(enc.thread_level + 1 < num_bands, enc.thread_level + 1, num_bands)
+  ok := 1
+  var b, t, p int
+
+  for b = 0; b < num_bands; b++ {
+    var job *StatPassJob = &jobs[b]
+    VP8InitBand(enc, &job.band, b * enc.mb_h / num_bands, (b + 1) * enc.mb_h / num_bands)
+    worker_interface.Init(&job.worker)
+    job.worker.data1 = job
+    job.worker.data2 = &job.it
+    job.worker.hook = DoStatPassJob
+    job.rd_opt = rd_opt
+    // only the first band records the progress, since we don't
+    // expect the user's hook to be multi-thread safe
+    job.delta_progress = tenary.If(b == 0, percent_delta, 0)
+  }
+  // Launch the bands in rounds of 'num_workers', the last of each round
+  // running in this goroutine.
+  for b = 0; b < num_bands; b += num_workers {
+    end := tenary.If(b + num_workers < num_bands, b + num_workers, num_bands)
+    for t = b; t < end; t++ {
+      VP8IteratorInitBand(enc, &jobs[t].it, &jobs[t].band)
+    }
+    for t = b; ok && t < end - 1; t++ {
+      ok &= worker_interface.Reset(&jobs[t].worker)
+      worker_interface.Launch(&jobs[t].worker)
+    }
+    if (ok) {
+      worker_interface.Execute(&jobs[end - 1].worker)
+    }
+    for t = b; t < end; t++ {
+      ok &= worker_interface.Sync(&jobs[t].worker)
+      worker_interface.End(&jobs[t].worker)
+    }
+    if !ok { return 0 }
+  }
+
+  // merge results together
+  for b = 0; b < num_bands; b++ {
+    var job *StatPassJob = &jobs[b]
+    for t = 0; t < NUM_TYPES; t++ {
+      for p = 0; p < NUM_BANDS; p++ {
+        var c, n int
+        for c = 0; c < NUM_CTX; c++ {
+          for n = 0; n < NUM_PROBAS; n++ {
+            VP8MergeStats(job.band.stats[t][p][c][n], &enc.proba.stats[t][p][c][n])
+          }
+        }
+      }
+    }
+    enc.proba.nb_skip += job.band.nb_skip
+    VP8StoreBandPreds(enc, &job.band)
+    *size += job.size
+    *size_p0 += job.size_p0
+    *distortion += job.distortion
+  }
+  return ok
+}
+
 func OneStatPass(/* const */ enc *vp8.VP8Encoder, VP8RDLevel rd_opt, nb_mbs int, percent_delta int, /*const*/ s *PassStats) uint64 {
    var it vp8.VP8EncIterator
   size uint64  = 0
   uint64 size_p0 = 0
   distortion := 0
   pixel_count := uint64(nb_mbs)* 384
+  num_bands := GetNumStatBands(enc)
 
-  VP8IteratorInit(enc, &it)
   SetLoopParams(enc, s.q)
-  for {
-     var info VP8ModeScore
-    VP8IteratorImport(&it, nil)
-    if (VP8Decimate(&it, &info, rd_opt)) {
-      // Just record the number of skips and act like skip_proba is not used.
-      ++enc.proba.nb_skip
-    }
-    RecordResiduals(&it, &info)
-    size += info.R + info.H
-    size_p0 += info.H
-    distortion += info.D
-    if (percent_delta && !VP8IteratorProgress(&it, percent_delta)) {
+  if (num_bands > 1 && nb_mbs == enc.mb_w * enc.mb_h) {
+    if (!StatPassBands(enc, rd_opt, num_bands, percent_delta, &size, &size_p0, &distortion)) {
       return 0
     }
-    VP8IteratorSaveBoundary(&it)
-  } while (VP8IteratorNext(&it) && --nb_mbs > 0)
+  } else {
+    VP8IteratorInit(enc, &it)
+    for {
+       var info VP8ModeScore
+      VP8IteratorImport(&it, nil)
+      if (VP8Decimate(&it, &info, rd_opt)) {
+        // Just record the number of skips and act like skip_proba is not used.
+        ++enc.proba.nb_skip
+      }
+      RecordResiduals(&it, &info)
+      size += info.R + info.H
+      size_p0 += info.H
+      distortion += info.D
+      if (percent_delta && !VP8IteratorProgress(&it, percent_delta)) {
+        return 0
+      }
+      VP8IteratorSaveBoundary(&it)
+    } while (VP8IteratorNext(&it) && --nb_mbs > 0)
+  }
 
   size_p0 += enc.segment_hdr.size
   if (s.do_size_search) {
@@ -738,7 +870,7 @@ func VP8EncLoop(/* const */ enc *vp8.VP8Encoder) int {
     } else {  // reset predictors after a skip
       ResetAfterSkip(&it)
     }
-    StoreSideInfo(&it)
+    StoreSideInfo(&it, &info)
     VP8StoreFilterStats(&it)
     VP8IteratorExport(&it)
     ok = VP8IteratorProgress(&it, 20)
@@ -814,7 +946,7 @@ func VP8EncTokenLoop(/* const */ enc *vp8.VP8Encoder) int {
       size_p0 += info.H
       distortion += info.D
       if (is_last_pass) {
-        StoreSideInfo(&it)
+        StoreSideInfo(&it, &info)
         VP8StoreFilterStats(&it)
         VP8IteratorExport(&it)
         ok = VP8IteratorProgress(&it, pass_progress)
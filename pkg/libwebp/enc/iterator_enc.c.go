@@ -27,6 +27,10 @@ func InitLeft(/* const */ it *vp8.VP8EncIterator) {
 
 func InitTop(/* const */ it *vp8.VP8EncIterator) {
   var enc *vp8.VP8Encoder = it.enc
+  if (it.band != nil) {
+    InitBandTop(it)
+    return
+  }
   top_size := enc.mb_w * 16
   stdlib.Memset(enc.y_top, 127, 2 * top_size)
   stdlib.Memset(enc.nz, 0, enc.mb_w * sizeof(*enc.nz))
@@ -35,12 +39,88 @@ func InitTop(/* const */ it *vp8.VP8EncIterator) {
   }
 }
 
+// The top samples of a band are not reconstructed yet when the band starts:
+// use the source samples above it instead, as if the reconstruction was
+// perfect. Contexts start from zero.
+func InitBandTop(/* const */ it *vp8.VP8EncIterator) {
+  var enc *vp8.VP8Encoder = it.enc
+  var band *vp8.VP8EncBand = it.band
+  var pic *picture.Picture = enc.pic
+  y := band.start_row
+  stdlib.Memset(band.nz, 0, len(band.nz))
+  if (band.top_derr != nil) {
+    stdlib.Memset(band.top_derr, 0, len(band.top_derr))
+  }
+  if (y == 0) {
+    stdlib.Memset(band.y_top, 127, len(band.y_top))
+    stdlib.Memset(band.uv_top, 127, len(band.uv_top))
+    return
+  }
+  {
+    uv_width := (pic.width + 1) >> 1
+    var ysrc *uint8 = pic.y + (y * 16 - 1) * pic.y_stride
+    var usrc *uint8 = pic.u + (y * 8 - 1) * pic.uv_stride
+    var vsrc *uint8 = pic.v + (y * 8 - 1) * pic.uv_stride
+    var x int
+    for x = 0; x < enc.mb_w * 16; x++ {
+      band.y_top[x] = ysrc[MinSize(x, pic.width - 1)]
+    }
+    for x = 0; x < enc.mb_w * 8; x++ {
+      band.uv_top[(x >> 3) * 16 + (x & 7) + 0] = usrc[MinSize(x, uv_width - 1)]
+      band.uv_top[(x >> 3) * 16 + (x & 7) + 8] = vsrc[MinSize(x, uv_width - 1)]
+    }
+  }
+}
+
+// Allocate the private context of the band of rows [start_row, end_row), and
+// snapshot its intra modes (and the ones of the row above) from enc.preds.
+// Must be called before any band starts.
+func VP8InitBand(/* const */ enc *vp8.VP8Encoder, /*const*/ band *vp8.VP8EncBand, start_row int, end_row int) {
+  preds_w := enc.preds_w
+  num_rows := 1 + 4 * (end_row - start_row)
+  var y int
+  band.start_row = start_row
+  band.end_row = end_row
+  band.y_top = make([]uint8, enc.mb_w * 16)
+  band.uv_top = make([]uint8, enc.mb_w * 16)
+  band.nz = make([]uint32, enc.mb_w + 1)
+  band.top_derr = nil
+  if (enc.top_derr != nil) {
+    band.top_derr = make([]vp8.DError, enc.mb_w)
+  }
+  band.preds = make([]uint8, num_rows * preds_w)
+  for y = 0; y < num_rows; y++ {
+    stdlib.MemCpy(band.preds[y * preds_w:], enc.preds + (4 * start_row - 1 + y) * preds_w - 1, preds_w)
+  }
+  stdlib.Memset(band.stats, 0, sizeof(band.stats))
+  band.nb_skip = 0
+}
+
+// Copy back the intra modes decided in the band to enc.preds.
+func VP8StoreBandPreds(/* const */ enc *vp8.VP8Encoder, /*const*/ band *vp8.VP8EncBand) {
+  preds_w := enc.preds_w
+  var y int
+  for y = 1; y < 1 + 4 * (band.end_row - band.start_row); y++ {
+    stdlib.MemCpy(enc.preds + (4 * band.start_row - 1 + y) * preds_w, band.preds[y * preds_w + 1:], preds_w - 1)
+  }
+}
+
 // reset iterator position to row 'y'
 func VP8IteratorSetRow(/* const */ it *vp8.VP8EncIterator, y int) {
   var enc *vp8.VP8Encoder = it.enc
   it.x = 0
   it.y = y
   it.bw = &enc.parts[y & (enc.num_parts - 1)]
+  if (it.band != nil) {
+    var band *vp8.VP8EncBand = it.band
+    it.preds = band.preds[(1 + 4 * (y - band.start_row)) * enc.preds_w + 1:]
+    it.nz = &band.nz[1]
+    it.mb = enc.mb_info + y * enc.mb_w
+    it.y_top = &band.y_top[0]
+    it.uv_top = &band.uv_top[0]
+    InitLeft(it)
+    return
+  }
   it.preds = enc.preds + y * 4 * enc.preds_w
   it.nz = enc.nz
   it.mb = enc.mb_info + y * enc.mb_w
@@ -52,8 +132,13 @@ func VP8IteratorSetRow(/* const */ it *vp8.VP8EncIterator, y int) {
 // restart a scan
 func VP8IteratorReset(/* const */ it *vp8.VP8EncIterator) {
   var enc *vp8.VP8Encoder = it.enc
-  VP8IteratorSetRow(it, 0)
-  VP8IteratorSetCountDown(it, enc.mb_w * enc.mb_h);  // default
+  if (it.band != nil) {
+    VP8IteratorSetRow(it, it.band.start_row)
+    VP8IteratorSetCountDown(it, (it.band.end_row - it.band.start_row) * enc.mb_w)
+  } else {
+    VP8IteratorSetRow(it, 0)
+    VP8IteratorSetCountDown(it, enc.mb_w * enc.mb_h);  // default
+  }
   InitTop(it)
   stdlib.Memset(it.bit_count, 0, sizeof(it.bit_count))
   it.do_trellis = 0
@@ -86,6 +171,17 @@ func VP8IteratorInit(/* const */ enc *vp8.VP8Encoder, /*const*/ it *vp8.VP8EncIt
   VP8IteratorReset(it)
 }
 
+// Same as VP8IteratorInit(), for an iterator scanning 'band' only.
+// See VP8InitBand().
+func VP8IteratorInitBand(/* const */ enc *vp8.VP8Encoder, /*const*/ it *vp8.VP8EncIterator, /*const*/ band *vp8.VP8EncBand) {
+  it.band = band
+  VP8IteratorInit(enc, it)
+  it.top_derr = nil
+  if (band.top_derr != nil) {
+    it.top_derr = &band.top_derr[0]
+  }
+}
+
 // Report progression based on macroblock rows. Return 0 for user-abort request.
 func VP8IteratorProgress(/* const */ it *vp8.VP8EncIterator, delta int) int {
   var enc *vp8.VP8Encoder = it.enc
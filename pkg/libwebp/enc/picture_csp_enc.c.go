@@ -19,36 +19,41 @@ const MinDimensionIterativeConversion = 4
 //------------------------------------------------------------------------------
 // Main function
 
-func PreprocessARGB(/* const */ r_ptr *uint8, /*const*/ g_ptr *uint8, /*const*/ b_ptr *uint8, step int, rgb_stride int, /*const*/ picture *picture.Picture) int {
-  ok := SharpYuvConvert(
-      r_ptr, g_ptr, b_ptr, step, rgb_stride, /*rgb_bit_depth=*/8, picture.Y, picture.YStride, picture.U, picture.UVStride, picture.V, picture.UVStride, /*yuv_bit_depth=*/8, picture.Width, picture.Height, SharpYuvGetConversionMatrix(kSharpYuvMatrixWebp))
-  if (!ok) {
-    return picture.SetEncodingError(picture.ENC_ERROR_OUT_OF_MEMORY)
+func PreprocessARGB(/* const */ r_ptr []uint8, /*const*/ g_ptr []uint8, /*const*/ b_ptr []uint8, step int, rgb_stride int, /*const*/ picture *picture.Picture) error {
+  err := sharpyuv.SharpYuvConvert(
+      r_ptr, g_ptr, b_ptr, step, rgb_stride, /*rgb_bit_depth=*/8, picture.Y, picture.YStride, picture.U, picture.UVStride, picture.V, picture.UVStride, /*yuv_bit_depth=*/8, picture.Width, picture.Height, SharpYuvMatrix(picture.YUVMatrix))
+  return picture.SetEncodingError(err)
+}
+
+// Returns the sharpyuv equivalent of the conversion matrix 'm'.
+func SharpYuvMatrix(/* const */ m *yuv.Matrix) *sharpyuv.SharpYuvConversionMatrix {
+  if m == nil || m == yuv.BT601 {
+    return sharpyuv.SharpYuvGetConversionMatrix(sharpyuv.SharpYuvMatrixWebp)
   }
-  return ok
+  return &sharpyuv.SharpYuvConversionMatrix{RGBToY: m.RGBToYCoeffs, RGBToU: m.RGBToUCoeffs, RGBToV: m.RGBToVCoeffs}
 }
 
-func ConvertRowToY(/* const */ r_ptr *uint8, /*const*/ g_ptr *uint8, /*const*/ b_ptr *uint8, step int, /*const*/ dst_y *uint8, width int, /*const*/ rg *VP8Random) {
+func ConvertRowToY(/* const */ r_ptr *uint8, /*const*/ g_ptr *uint8, /*const*/ b_ptr *uint8, step int, /*const*/ dst_y *uint8, width int, /*const*/ rg *VP8Random, /*const*/ m *yuv.Matrix) {
+  m = yuv.MatrixOrDefault(m)
   var i, j int = 0, 0
   for ; i < width; {
-    dst_y[i] = VP8RGBToY(r_ptr[j], g_ptr[j], b_ptr[j], VP8RandomBits(rg, YUV_FIX))
+    dst_y[i] = m.RGBToY(r_ptr[j], g_ptr[j], b_ptr[j], VP8RandomBits(rg, YUV_FIX))
 
 		i += 1
 		j += step
   }
 }
 
-func ConvertRowsToUV(/* const */ rgb *uint16, /*const*/ dst_u *uint8, /*const*/ dst_v *uint8, width int, /*const*/ rg *VP8Random) {
+func ConvertRowsToUV(/* const */ rgb *uint16, /*const*/ dst_u *uint8, /*const*/ dst_v *uint8, width int, /*const*/ rg *VP8Random, /*const*/ m *yuv.Matrix) {
+  m = yuv.MatrixOrDefault(m)
   var i int
   for i = 0; i < width; i += 1, rgb += 4 {
     r := rgb[0], g = rgb[1], b = rgb[2]
-    dst_u[i] = VP8RGBToU(r, g, b, VP8RandomBits(rg, YUV_FIX + 2))
-    dst_v[i] = VP8RGBToV(r, g, b, VP8RandomBits(rg, YUV_FIX + 2))
+    dst_u[i] = m.RGBToU(r, g, b, VP8RandomBits(rg, YUV_FIX + 2))
+    dst_v[i] = m.RGBToV(r, g, b, VP8RandomBits(rg, YUV_FIX + 2))
   }
 }
 
-extern func SharpYuvInit(VP8CPUInfo cpu_info_func)
-
 
 
 //------------------------------------------------------------------------------
@@ -82,7 +87,8 @@ func WebPPictureYUVAToARGB(picture *picture.Picture) int {
     argb_stride := 4 * picture.ARGBStride
     dst []uint8 = (*uint8)picture.ARGB
     *cur_u = picture.U, *cur_v = picture.V, *cur_y := picture.Y
-    WebPUpsampleLinePairFunc upsample = WebPGetLinePairConverter(ALPHA_OFFSET > 0)
+    alpha_mode := tenary.If(ALPHA_OFFSET > 0, MODE_BGRA, MODE_ARGB)
+    WebPUpsampleLinePairFunc upsample = WebPGetUpsampler(alpha_mode, picture.YUVMatrix)
 
     // First row, with replicated top samples.
     upsample(cur_y, nil, cur_u, cur_v, cur_u, cur_v, dst, nil, width)
@@ -122,10 +122,10 @@ func WebPPictureCrop(pic *picture.Picture, left int, top int, width, height int)
 //------------------------------------------------------------------------------
 // Simple picture rescaler
 
-func RescalePlane(/* const */ src *uint8, src_width int , src_height int, src_stride int, dst []uint8, dst_width int, dst_height int, dst_stride int, work *rescaler_t, num_channels int) int {
+func RescalePlane(/* const */ src *uint8, src_width int , src_height int, src_stride int, dst []uint8, dst_width int, dst_height int, dst_stride int, work *rescaler_t, num_channels int, filter WebPResampleFilter) int {
    var rescaler WebPRescaler
   y := 0
-  if (!WebPRescalerInit(&rescaler, src_width, src_height, dst, dst_width, dst_height, dst_stride, num_channels, work)) {
+  if (!WebPRescalerInitFilter(&rescaler, filter, src_width, src_height, dst, dst_width, dst_height, dst_stride, num_channels, work)) {
     return 0
   }
   for y < src_height {
@@ -147,6 +147,10 @@ func AlphaMultiplyY(/* const */ pic *picture.Picture, inverse int) {
 }
 
 func WebPPictureRescale(picture *picture.Picture, width, height int) int {
+  return WebPPictureRescaleFilter(picture, width, height, WEBP_FILTER_BOX)
+}
+
+func WebPPictureRescaleFilter(picture *picture.Picture, width, height int, filter WebPResampleFilter) int {
    var tmp picture.Picture
   int prev_width, prev_height
   rescaler_t* work
@@ -176,7 +180,7 @@ func WebPPictureRescale(picture *picture.Picture, width, height int) int {
     // If present, we need to rescale alpha first (for AlphaMultiplyY).
     if (picture.A != nil) {
       WebPInitAlphaProcessing()
-      if (!RescalePlane(picture.A, prev_width, prev_height, picture.AStride, tmp.a, width, height, tmp.a_stride, work, 1)) {
+      if (!RescalePlane(picture.A, prev_width, prev_height, picture.AStride, tmp.a, width, height, tmp.a_stride, work, 1, filter)) {
         status = ENC_ERROR_BAD_DIMENSION
         goto Cleanup
       }
@@ -185,9 +189,9 @@ func WebPPictureRescale(picture *picture.Picture, width, height int) int {
     // We take transparency into account on the luma plane only. That's not
     // totally exact blending, but still is a good approximation.
     AlphaMultiplyY(picture, 0)
-    if (!RescalePlane(picture.Y, prev_width, prev_height, picture.YStride, tmp.y, width, height, tmp.y_stride, work, 1) ||
-        !RescalePlane(picture.U, HALVE(prev_width), HALVE(prev_height), picture.UVStride, tmp.u, HALVE(width), HALVE(height), tmp.uv_stride, work, 1) ||
-        !RescalePlane(picture.V, HALVE(prev_width), HALVE(prev_height), picture.UVStride, tmp.v, HALVE(width), HALVE(height), tmp.uv_stride, work, 1)) {
+    if (!RescalePlane(picture.Y, prev_width, prev_height, picture.YStride, tmp.y, width, height, tmp.y_stride, work, 1, filter) ||
+        !RescalePlane(picture.U, HALVE(prev_width), HALVE(prev_height), picture.UVStride, tmp.u, HALVE(width), HALVE(height), tmp.uv_stride, work, 1, filter) ||
+        !RescalePlane(picture.V, HALVE(prev_width), HALVE(prev_height), picture.UVStride, tmp.v, HALVE(width), HALVE(height), tmp.uv_stride, work, 1, filter)) {
       status = ENC_ERROR_BAD_DIMENSION
       goto Cleanup
     }
@@ -205,7 +209,7 @@ func WebPPictureRescale(picture *picture.Picture, width, height int) int {
     // the premultiplication afterward (while preserving the alpha channel).
     WebPInitAlphaProcessing()
     AlphaMultiplyARGB(picture, 0)
-    if (!RescalePlane((/* const */ *uint8)picture.ARGB, prev_width, prev_height, picture.ARGBStride * 4, (*uint8)tmp.argb, width, height, tmp.argb_stride * 4, work, 4)) {
+    if (!RescalePlane((/* const */ *uint8)picture.ARGB, prev_width, prev_height, picture.ARGBStride * 4, (*uint8)tmp.argb, width, height, tmp.argb_stride * 4, work, 4, filter)) {
       status = ENC_ERROR_BAD_DIMENSION
       goto Cleanup
     }
@@ -204,8 +204,8 @@ func MarkError2(/* const */ enc *WebPAnimEncoder, /*const*/ str *byte, error_cod
   }
 }
 
-func WebPAnimEncoder(
-    width, height int, /*const*/ enc_options *WebPAnimEncoderOptions, abi_version int) *WebPAnimEncoderNewInternal {
+func WebPAnimEncoderNewInternal(
+    width, height int, /*const*/ enc_options *WebPAnimEncoderOptions, abi_version int) *WebPAnimEncoder {
   var enc *WebPAnimEncoder
 
   if (width <= 0 || height <= 0 ||
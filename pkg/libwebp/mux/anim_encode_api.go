@@ -0,0 +1,113 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the COPYING file in the root of the source
+// tree. An additional intellectual property rights grant can be found
+// in the file PATENTS. All contributing project authors may
+// be found in the AUTHORS file in the root of the source tree.
+// -----------------------------------------------------------------------------
+//
+// Go view of the WebPAnimEncoder API.
+package mux
+
+import (
+	"errors"
+
+	"github.com/daanv2/go-webp/pkg/config"
+	"github.com/daanv2/go-webp/pkg/picture"
+	"github.com/daanv2/go-webp/pkg/util/tenary"
+)
+
+// Go view of WebPAnimEncoderOptions.
+type AnimEncoderOptions struct {
+	AnimParams MuxAnimParams
+
+	// Minimize the output size (slow). Implicitly disables key-frame
+	// insertion.
+	MinimizeSize bool
+
+	// Minimum and maximum distance between consecutive key-frames. If
+	// Kmax <= 0, key-frame insertion is disabled; if Kmax == 1, all frames
+	// are key-frames.
+	Kmin, Kmax int
+
+	// Choose either lossy or lossless compression for each frame.
+	AllowMixed bool
+
+	// Print info and warning messages to stderr.
+	Verbose bool
+}
+
+// Returns the options used when none are given: white background, infinite
+// loop and no key-frame insertion.
+func DefaultAnimEncoderOptions() AnimEncoderOptions {
+	var options WebPAnimEncoderOptions
+	DefaultEncoderOptions(&options)
+	return AnimEncoderOptions{
+		AnimParams: MuxAnimParams{BgColor: options.anim_params.bgcolor, LoopCount: options.anim_params.loop_count},
+		Kmin:       options.kmin,
+		Kmax:       options.kmax,
+	}
+}
+
+// Creates an animation encoder for a 'width' x 'height' canvas. 'opts' may be
+// nil for the defaults. Returns nil if the dimensions are invalid.
+func NewAnimEncoder(width, height int /*const*/, opts *AnimEncoderOptions) *WebPAnimEncoder {
+	if opts == nil {
+		return WebPAnimEncoderNewInternal(width, height, nil, WEBP_MUX_ABI_VERSION)
+	}
+	options := WebPAnimEncoderOptions{
+		anim_params:   WebPMuxAnimParams{bgcolor: opts.AnimParams.BgColor, loop_count: opts.AnimParams.LoopCount},
+		minimize_size: tenary.If(opts.MinimizeSize, 1, 0),
+		kmin:          opts.Kmin,
+		kmax:          opts.Kmax,
+		allow_mixed:   tenary.If(opts.AllowMixed, 1, 0),
+		verbose:       tenary.If(opts.Verbose, 1, 0),
+	}
+	return WebPAnimEncoderNewInternal(width, height, &options, WEBP_MUX_ABI_VERSION)
+}
+
+// Returns the error of the last call as an error, nil if there was none.
+func (enc *WebPAnimEncoder) lastError( /* const */ frame *picture.Picture) error {
+	if enc.error_str != "" {
+		return errors.New(enc.error_str)
+	}
+	if frame != nil && frame.ErrorCode != nil {
+		return frame.ErrorCode
+	}
+	return errors.New("animation encoding failed")
+}
+
+// Go variant of WebPAnimEncoderAdd(). The last call should be made with a nil
+// 'frame', its timestamp giving the duration of the last frame. 'cfg' may be
+// nil for the defaults.
+func (enc *WebPAnimEncoder) Add(frame *picture.Picture, timestamp_ms int /*const*/, cfg *config.Config) error {
+	if WebPAnimEncoderAdd(enc, frame, timestamp_ms, cfg) == 0 {
+		return enc.lastError(frame)
+	}
+	return nil
+}
+
+// Go variant of WebPAnimEncoderAssemble().
+func (enc *WebPAnimEncoder) Assemble() ([]uint8, error) {
+	var webp_data WebPData
+	if WebPAnimEncoderAssemble(enc, &webp_data) == 0 {
+		return nil, enc.lastError(nil)
+	}
+	return webp_data.bytes[:webp_data.size], nil
+}
+
+// Go variant of WebPAnimEncoderSetChunk(), the data being copied.
+func (enc *WebPAnimEncoder) SetChunk(fourcc string /*const*/, data []uint8) WebPMuxError {
+	cc, ok := fourCC(fourcc)
+	if !ok {
+		return WEBP_MUX_INVALID_ARGUMENT
+	}
+	chunk_data := WebPData{bytes: data, size: uint64(len(data))}
+	return WebPAnimEncoderSetChunk(enc, cc, &chunk_data, 1)
+}
+
+// Releases the memory of the encoder.
+func (enc *WebPAnimEncoder) Delete() {
+	WebPAnimEncoderDelete(enc)
+}
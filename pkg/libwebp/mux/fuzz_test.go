@@ -0,0 +1,46 @@
+package mux_test
+
+import (
+	"testing"
+
+	"github.com/daanv2/go-webp/pkg/libwebp/mux"
+	libwebp "github.com/daanv2/go-webp/pkg/libwebp/webp"
+	"github.com/daanv2/go-webp/pkg/webptest"
+	"github.com/stretchr/testify/require"
+)
+
+func FuzzMuxCreate(f *testing.F) {
+	webptest.AddCorpus(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		m, err := mux.MuxCreate(data)
+		if err != libwebp.WEBP_MUX_OK {
+			return
+		}
+		flags, _ := m.GetFeatures()
+		m.GetCanvasSize()
+		if flags&uint32(libwebp.ANIMATION_FLAG) != 0 {
+			m.GetAnimationParams()
+		}
+		num_frames, err := m.NumFrames()
+		require.Equal(t, libwebp.WEBP_MUX_OK, err)
+		for nth := 1; nth <= num_frames; nth++ {
+			if frame, err := m.GetFrame(nth); err == libwebp.WEBP_MUX_OK {
+				require.NotEmpty(t, frame.Bitstream)
+			}
+		}
+		for _, fourcc := range []string{"ICCP", "EXIF", "XMP "} {
+			m.GetChunk(fourcc)
+		}
+
+		// What the mux assembles, it reads back unchanged.
+		assembled, err := m.Assemble()
+		if err != libwebp.WEBP_MUX_OK {
+			return
+		}
+		again, err := mux.MuxCreate(assembled)
+		require.Equal(t, libwebp.WEBP_MUX_OK, err)
+		reassembled, err := again.Assemble()
+		require.Equal(t, libwebp.WEBP_MUX_OK, err)
+		require.Equal(t, assembled, reassembled)
+	})
+}
@@ -0,0 +1,211 @@
+// Copyright 2011 Google Inc. All Rights Reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the COPYING file in the root of the source
+// tree. An additional intellectual property rights grant can be found
+// in the file PATENTS. All contributing project authors may
+// be found in the AUTHORS file in the root of the source tree.
+// -----------------------------------------------------------------------------
+//
+// Go view of the mux API: byte slices instead of WebPData, exported fields
+// instead of the private ones of WebPMuxFrameInfo and WebPMuxAnimParams.
+package mux
+
+// Go view of WebPMuxFrameInfo.
+type MuxFrame struct {
+	// Single-image WebP file, or raw VP8/VP8L bitstream.
+	Bitstream        []uint8
+	XOffset, YOffset int // snapped to even values when pushed
+	Duration         int // in milliseconds
+	Dispose          WebPMuxAnimDispose
+	Blend            WebPMuxAnimBlend
+	IsAnimationFrame bool // false for the image of a still file
+}
+
+// Go view of WebPMuxAnimParams.
+type MuxAnimParams struct {
+	BgColor   uint32 // alpha, red, green, blue from the most significant byte
+	LoopCount int    // 0 = infinite
+}
+
+// Creates a mux object from the WebP file 'data', which is copied. An empty
+// 'data' gives an empty mux object.
+func MuxCreate( /* const */ data []uint8) (*WebPMux, WebPMuxError) {
+	if len(data) == 0 {
+		return WebPNewInternal(WEBP_MUX_ABI_VERSION), WEBP_MUX_OK
+	}
+	bitstream := WebPData{bytes: data, size: uint64(len(data))}
+	mux := WebPMuxCreateInternal(&bitstream, 1, WEBP_MUX_ABI_VERSION)
+	if mux == nil {
+		return nil, WEBP_MUX_BAD_DATA
+	}
+	return mux, WEBP_MUX_OK
+}
+
+func fourCC(fourcc string) (cc [4]byte, ok bool) {
+	if len(fourcc) != 4 {
+		return cc, false
+	}
+	copy(cc[:], fourcc)
+	return cc, true
+}
+
+// Go variant of WebPMuxSetChunk(), the data being copied.
+func (mux *WebPMux) SetChunk(fourcc string /*const*/, data []uint8) WebPMuxError {
+	cc, ok := fourCC(fourcc)
+	if !ok {
+		return WEBP_MUX_INVALID_ARGUMENT
+	}
+	chunk_data := WebPData{bytes: data, size: uint64(len(data))}
+	return WebPMuxSetChunk(mux, cc, &chunk_data, 1)
+}
+
+// Go variant of WebPMuxGetChunk(). The returned slice belongs to the mux
+// object.
+func (mux *WebPMux) GetChunk(fourcc string) ([]uint8, WebPMuxError) {
+	cc, ok := fourCC(fourcc)
+	if !ok {
+		return nil, WEBP_MUX_INVALID_ARGUMENT
+	}
+	var chunk_data WebPData
+	if err := WebPMuxGetChunk(mux, cc, &chunk_data); err != WEBP_MUX_OK {
+		return nil, err
+	}
+	return chunk_data.bytes[:chunk_data.size], WEBP_MUX_OK
+}
+
+// Go variant of WebPMuxDeleteChunk().
+func (mux *WebPMux) DeleteChunk(fourcc string) WebPMuxError {
+	cc, ok := fourCC(fourcc)
+	if !ok {
+		return WEBP_MUX_INVALID_ARGUMENT
+	}
+	return WebPMuxDeleteChunk(mux, cc)
+}
+
+// Go variant of WebPMuxSetImage(), the data being copied.
+func (mux *WebPMux) SetImage( /* const */ bitstream []uint8) WebPMuxError {
+	data := WebPData{bytes: bitstream, size: uint64(len(bitstream))}
+	return WebPMuxSetImage(mux, &data, 1)
+}
+
+// Go variant of WebPMuxPushFrame(), the bitstream being copied.
+func (mux *WebPMux) PushFrame( /* const */ frame *MuxFrame) WebPMuxError {
+	if frame == nil {
+		return WEBP_MUX_INVALID_ARGUMENT
+	}
+	info := WebPMuxFrameInfo{
+		bitstream:      WebPData{bytes: frame.Bitstream, size: uint64(len(frame.Bitstream))},
+		x_offset:       frame.XOffset,
+		y_offset:       frame.YOffset,
+		duration:       frame.Duration,
+		id:             WEBP_CHUNK_ANMF,
+		dispose_method: frame.Dispose,
+		blend_method:   frame.Blend,
+	}
+	return WebPMuxPushFrame(mux, &info, 1)
+}
+
+// Go variant of WebPMuxGetFrame(). 'nth' starts at 1, 0 being the last
+// frame. The returned bitstream is a single-image WebP file owned by the
+// caller.
+func (mux *WebPMux) GetFrame(nth int) (MuxFrame, WebPMuxError) {
+	var info WebPMuxFrameInfo
+	if nth < 0 {
+		return MuxFrame{}, WEBP_MUX_INVALID_ARGUMENT
+	}
+	if err := WebPMuxGetFrame(mux, uint32(nth), &info); err != WEBP_MUX_OK {
+		return MuxFrame{}, err
+	}
+	return MuxFrame{
+		Bitstream:        info.bitstream.bytes[:info.bitstream.size],
+		XOffset:          info.x_offset,
+		YOffset:          info.y_offset,
+		Duration:         info.duration,
+		Dispose:          info.dispose_method,
+		Blend:            info.blend_method,
+		IsAnimationFrame: info.id == WEBP_CHUNK_ANMF,
+	}, WEBP_MUX_OK
+}
+
+// Go variant of WebPMuxDeleteFrame(). 'nth' starts at 1, 0 being the last
+// frame.
+func (mux *WebPMux) DeleteFrame(nth int) WebPMuxError {
+	if nth < 0 {
+		return WEBP_MUX_INVALID_ARGUMENT
+	}
+	return WebPMuxDeleteFrame(mux, uint32(nth))
+}
+
+// Number of frames (or images) in the mux object.
+func (mux *WebPMux) NumFrames() (int, WebPMuxError) {
+	var num_frames, num_images int
+	if err := WebPMuxNumChunks(mux, WEBP_CHUNK_ANMF, &num_frames); err != WEBP_MUX_OK {
+		return 0, err
+	}
+	if err := WebPMuxNumChunks(mux, WEBP_CHUNK_IMAGE, &num_images); err != WEBP_MUX_OK {
+		return 0, err
+	}
+	return max(num_frames, num_images), WEBP_MUX_OK
+}
+
+// Go variant of WebPMuxSetAnimationParams().
+func (mux *WebPMux) SetAnimationParams( /* const */ params MuxAnimParams) WebPMuxError {
+	p := WebPMuxAnimParams{bgcolor: params.BgColor, loop_count: params.LoopCount}
+	return WebPMuxSetAnimationParams(mux, &p)
+}
+
+// Go variant of WebPMuxGetAnimationParams().
+func (mux *WebPMux) GetAnimationParams() (MuxAnimParams, WebPMuxError) {
+	var p WebPMuxAnimParams
+	if err := WebPMuxGetAnimationParams(mux, &p); err != WEBP_MUX_OK {
+		return MuxAnimParams{}, err
+	}
+	return MuxAnimParams{BgColor: p.bgcolor, LoopCount: p.loop_count}, WEBP_MUX_OK
+}
+
+// Go variant of WebPMuxSetCanvasSize().
+func (mux *WebPMux) SetCanvasSize(width, height int) WebPMuxError {
+	return WebPMuxSetCanvasSize(mux, width, height)
+}
+
+// Go variant of WebPMuxGetCanvasSize().
+func (mux *WebPMux) GetCanvasSize() (width, height int, err WebPMuxError) {
+	err = WebPMuxGetCanvasSize(mux, &width, &height)
+	return width, height, err
+}
+
+// Go variant of WebPMuxGetFeatures(): a combination of WebPFeatureFlags.
+func (mux *WebPMux) GetFeatures() (uint32, WebPMuxError) {
+	var flags uint32
+	err := WebPMuxGetFeatures(mux, &flags)
+	return flags, err
+}
+
+// Go variant of WebPMuxAssemble().
+func (mux *WebPMux) Assemble() ([]uint8, WebPMuxError) {
+	var assembled WebPData
+	if err := WebPMuxAssemble(mux, &assembled); err != WEBP_MUX_OK {
+		return nil, err
+	}
+	return assembled.bytes[:assembled.size], WEBP_MUX_OK
+}
+
+// Returns a readable description of 'err', as libwebp's webpmux prints it.
+func MuxErrorString(err WebPMuxError) string {
+	switch err {
+	case WEBP_MUX_OK:
+		return "WEBP_MUX_OK"
+	case WEBP_MUX_NOT_FOUND:
+		return "WEBP_MUX_NOT_FOUND"
+	case WEBP_MUX_INVALID_ARGUMENT:
+		return "WEBP_MUX_INVALID_ARGUMENT"
+	case WEBP_MUX_BAD_DATA:
+		return "WEBP_MUX_BAD_DATA"
+	case WEBP_MUX_MEMORY_ERROR:
+		return "WEBP_MUX_MEMORY_ERROR"
+	case WEBP_MUX_NOT_ENOUGH_DATA:
+		return "WEBP_MUX_NOT_ENOUGH_DATA"
+	}
+	return "unknown error"
+}
@@ -0,0 +1,363 @@
+// Copyright 2022 Google Inc. All Rights Reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the COPYING file in the root of the source
+// tree. An additional intellectual property rights grant can be found
+// in the file PATENTS. All contributing project authors may
+// be found in the AUTHORS file in the root of the source tree.
+
+// Package sharpyuv implements the sharp (iterative) RGB to YUV420 conversion:
+// the chroma is downsampled in linear light, and the luma is refined until the
+// upsampled result matches the source, which avoids colour bleeding around
+// saturated edges.
+package sharpyuv
+
+import "errors"
+
+var (
+	ErrInvalidParameter = errors.New("sharpyuv: invalid parameter")
+	ErrInvalidBitDepth  = errors.New("sharpyuv: unsupported bit depth")
+	ErrBufferTooSmall   = errors.New("sharpyuv: buffer too small")
+)
+
+// Samples accepted for input and output planes: 8 bits per sample, or up
+// to 16 bits (see the bit depth arguments of SharpYuvConvert).
+type Sample interface {
+	~uint8 | ~uint16
+}
+
+// Options for SharpYuvConvertWithOptions.
+type SharpYuvOptions struct {
+	// This matrix cannot be nil and can be initialized by
+	// SharpYuvComputeConversionMatrix.
+	YuvMatrix    *SharpYuvConversionMatrix
+	TransferType SharpYuvTransferFunctionType
+}
+
+// Initializes SharpYuvOptions with the given matrix and the default
+// (sRGB) transfer function.
+func SharpYuvOptionsInit(yuv_matrix *SharpYuvConversionMatrix, options *SharpYuvOptions) error {
+	if yuv_matrix == nil || options == nil {
+		return ErrInvalidParameter
+	}
+	options.YuvMatrix = yuv_matrix
+	options.TransferType = SharpYuvTransferFunctionSrgb
+	return nil
+}
+
+const kNumIterations = 4
+
+const YUV_FIX = 16 // fixed-point precision for RGB.YUV
+const kYuvHalf = 1 << (YUV_FIX - 1)
+
+// Max bit depth so that intermediate calculations fit in 16 bits.
+const kMaxBitDepth = 14
+
+type fixed_t = int16    // signed type with extra precision for UV
+type fixed_y_t = uint16 // unsigned type with extra precision for W
+
+// Returns the precision shift to use based on the input rgb_bit_depth.
+func GetPrecisionShift(rgb_bit_depth int) int {
+	// Try to add 2 bits of precision if it fits in kMaxBitDepth. Otherwise remove
+	// bits if needed.
+	if rgb_bit_depth+2 <= kMaxBitDepth {
+		return 2
+	}
+	return kMaxBitDepth - rgb_bit_depth
+}
+
+func clip_bit_depth(y int, bit_depth int) fixed_y_t {
+	max_y := (1 << bit_depth) - 1
+	return fixed_y_t(clipInt(y, 0, max_y))
+}
+
+func RGBToGray(r, g, b int64) int {
+	luma := 13933*r + 46871*g + 4732*b + kYuvHalf
+	return int(luma >> YUV_FIX)
+}
+
+func ScaleDown(a, b, c, d fixed_y_t, rgb_bit_depth int, transfer_type SharpYuvTransferFunctionType) int {
+	bit_depth := rgb_bit_depth + GetPrecisionShift(rgb_bit_depth)
+	A := SharpYuvGammaToLinear(a, bit_depth, transfer_type)
+	B := SharpYuvGammaToLinear(b, bit_depth, transfer_type)
+	C := SharpYuvGammaToLinear(c, bit_depth, transfer_type)
+	D := SharpYuvGammaToLinear(d, bit_depth, transfer_type)
+	return int(SharpYuvLinearToGamma((A+B+C+D+2)>>2, bit_depth, transfer_type))
+}
+
+func UpdateW(src []fixed_y_t, dst []fixed_y_t, w int, rgb_bit_depth int, transfer_type SharpYuvTransferFunctionType) {
+	bit_depth := rgb_bit_depth + GetPrecisionShift(rgb_bit_depth)
+	for i := 0; i < w; i++ {
+		R := SharpYuvGammaToLinear(src[0*w+i], bit_depth, transfer_type)
+		G := SharpYuvGammaToLinear(src[1*w+i], bit_depth, transfer_type)
+		B := SharpYuvGammaToLinear(src[2*w+i], bit_depth, transfer_type)
+		Y := RGBToGray(int64(R), int64(G), int64(B))
+		dst[i] = fixed_y_t(SharpYuvLinearToGamma(uint32(Y), bit_depth, transfer_type))
+	}
+}
+
+func UpdateChroma(src1 []fixed_y_t, src2 []fixed_y_t, dst []fixed_t, uv_w int, rgb_bit_depth int, transfer_type SharpYuvTransferFunctionType) {
+	// src1/src2 hold 3 planes of w = 2*uv_w samples each.
+	w := 2 * uv_w
+	for i := 0; i < uv_w; i++ {
+		r := ScaleDown(src1[0*w+2*i], src1[0*w+2*i+1], src2[0*w+2*i], src2[0*w+2*i+1], rgb_bit_depth, transfer_type)
+		g := ScaleDown(src1[1*w+2*i], src1[1*w+2*i+1], src2[1*w+2*i], src2[1*w+2*i+1], rgb_bit_depth, transfer_type)
+		b := ScaleDown(src1[2*w+2*i], src1[2*w+2*i+1], src2[2*w+2*i], src2[2*w+2*i+1], rgb_bit_depth, transfer_type)
+		W := RGBToGray(int64(r), int64(g), int64(b))
+		dst[0*uv_w+i] = fixed_t(r - W)
+		dst[1*uv_w+i] = fixed_t(g - W)
+		dst[2*uv_w+i] = fixed_t(b - W)
+	}
+}
+
+func StoreGray(rgb []fixed_y_t, y []fixed_y_t, w int) {
+	for i := 0; i < w; i++ {
+		y[i] = fixed_y_t(RGBToGray(int64(rgb[0*w+i]), int64(rgb[1*w+i]), int64(rgb[2*w+i])))
+	}
+}
+
+func Filter2(A int, B int, W0 fixed_y_t, bit_depth int) fixed_y_t {
+	v0 := (A*3 + B + 2) >> 2
+	return clip_bit_depth(v0+int(W0), bit_depth)
+}
+
+func ImportOneRow[S Sample](r_ptr, g_ptr, b_ptr []S, rgb_step int, rgb_bit_depth int, pic_width int, dst []fixed_y_t) {
+	w := (pic_width + 1) &^ 1
+	shift := GetPrecisionShift(rgb_bit_depth)
+	for i := 0; i < pic_width; i++ {
+		off := i * rgb_step
+		dst[i+0*w] = fixed_y_t(Shift(int(r_ptr[off]), shift))
+		dst[i+1*w] = fixed_y_t(Shift(int(g_ptr[off]), shift))
+		dst[i+2*w] = fixed_y_t(Shift(int(b_ptr[off]), shift))
+	}
+	if pic_width&1 != 0 { // replicate rightmost pixel
+		dst[pic_width+0*w] = dst[pic_width+0*w-1]
+		dst[pic_width+1*w] = dst[pic_width+1*w-1]
+		dst[pic_width+2*w] = dst[pic_width+2*w-1]
+	}
+}
+
+func InterpolateTwoRows(best_y []fixed_y_t, prev_uv, cur_uv, next_uv []fixed_t, w int, out1, out2 []fixed_y_t, rgb_bit_depth int) {
+	uv_w := w >> 1
+	len := (w - 1) >> 1 // length to filter
+	bit_depth := rgb_bit_depth + GetPrecisionShift(rgb_bit_depth)
+	for k := 0; k < 3; k++ { // process each R/G/B segments in turn
+		o1 := out1[k*w:]
+		o2 := out2[k*w:]
+		p := prev_uv[k*uv_w:]
+		c := cur_uv[k*uv_w:]
+		n := next_uv[k*uv_w:]
+
+		// special boundary case for i==0
+		o1[0] = Filter2(int(c[0]), int(p[0]), best_y[0], bit_depth)
+		o2[0] = Filter2(int(c[0]), int(n[0]), best_y[w], bit_depth)
+
+		SharpYuvFilterRow(c, p, len, best_y[1:], o1[1:], bit_depth)
+		SharpYuvFilterRow(c, n, len, best_y[w+1:], o2[1:], bit_depth)
+
+		// special boundary case for i == w - 1 when w is even
+		if w&1 == 0 {
+			o1[w-1] = Filter2(int(c[uv_w-1]), int(p[uv_w-1]), best_y[w-1+0], bit_depth)
+			o2[w-1] = Filter2(int(c[uv_w-1]), int(n[uv_w-1]), best_y[w-1+w], bit_depth)
+		}
+	}
+}
+
+func RGBToYUVComponent(r, g, b int, coeffs *[4]int, sfix int) int {
+	srounder := 1 << (YUV_FIX + sfix - 1)
+	// the offset is expressed for unscaled samples
+	luma := coeffs[0]*r + coeffs[1]*g + coeffs[2]*b + Shift(coeffs[3], sfix) + srounder
+	return luma >> (YUV_FIX + sfix)
+}
+
+func ConvertWRGBToYUV[D Sample](best_y []fixed_y_t, best_uv []fixed_t, y_ptr []D, y_stride int, u_ptr []D, u_stride int, v_ptr []D, v_stride int, rgb_bit_depth int, yuv_bit_depth int, width, height int, yuv_matrix *SharpYuvConversionMatrix) {
+	w := (width + 1) &^ 1
+	h := (height + 1) &^ 1
+	uv_w := w >> 1
+	uv_h := h >> 1
+	sfix := GetPrecisionShift(rgb_bit_depth)
+	yuv_max := (1 << yuv_bit_depth) - 1
+
+	uv := best_uv
+	for j := 0; j < height; j++ {
+		for i := 0; i < width; i++ {
+			off := i >> 1
+			W := int(best_y[j*w+i])
+			r := int(uv[off+0*uv_w]) + W
+			g := int(uv[off+1*uv_w]) + W
+			b := int(uv[off+2*uv_w]) + W
+			y := RGBToYUVComponent(r, g, b, &yuv_matrix.RGBToY, sfix)
+			y_ptr[j*y_stride+i] = D(clipInt(y, 0, yuv_max))
+		}
+		if j&1 != 0 {
+			uv = uv[3*uv_w:]
+		}
+	}
+	uv = best_uv
+	for j := 0; j < uv_h; j++ {
+		for i := 0; i < uv_w; i++ {
+			// Note r, g and b values here are off by W, but a constant offset on all
+			// 3 components doesn't change the value of u and v with a YCbCr matrix.
+			r := int(uv[i+0*uv_w])
+			g := int(uv[i+1*uv_w])
+			b := int(uv[i+2*uv_w])
+			u := RGBToYUVComponent(r, g, b, &yuv_matrix.RGBToU, sfix)
+			v := RGBToYUVComponent(r, g, b, &yuv_matrix.RGBToV, sfix)
+			u_ptr[j*u_stride+i] = D(clipInt(u, 0, yuv_max))
+			v_ptr[j*v_stride+i] = D(clipInt(v, 0, yuv_max))
+		}
+		uv = uv[3*uv_w:]
+	}
+}
+
+// Main function. r/g/b point to the first sample of the respective channel;
+// rgb_step and rgb_stride are expressed in samples (not bytes). U/V planes
+// must be at least ((width + 1) / 2) x ((height + 1) / 2).
+// Input samples are 'rgb_bit_depth' bits (8, 10, 12 or 16), output
+// samples are 'yuv_bit_depth' bits (8, 10 or 12). 8-bit output requires
+// D to be uint8.
+func SharpYuvConvertWithOptions[S Sample, D Sample](r_ptr, g_ptr, b_ptr []S, rgb_step int, rgb_stride int, rgb_bit_depth int, y_ptr []D, y_stride int, u_ptr []D, u_stride int, v_ptr []D, v_stride int, yuv_bit_depth int, width, height int, options *SharpYuvOptions) error {
+	if options == nil || options.YuvMatrix == nil || width <= 0 || height <= 0 ||
+		rgb_step <= 0 || rgb_stride <= 0 {
+		return ErrInvalidParameter
+	}
+	if (rgb_bit_depth != 8 && rgb_bit_depth != 10 && rgb_bit_depth != 12 && rgb_bit_depth != 16) ||
+		(yuv_bit_depth != 8 && yuv_bit_depth != 10 && yuv_bit_depth != 12) {
+		return ErrInvalidBitDepth
+	}
+	if (rgb_bit_depth > 8) != isWide[S]() || (yuv_bit_depth > 8) != isWide[D]() {
+		return ErrInvalidBitDepth
+	}
+	uv_width := (width + 1) >> 1
+	uv_height := (height + 1) >> 1
+	last_rgb := (height-1)*rgb_stride + (width-1)*rgb_step
+	if len(r_ptr) <= last_rgb || len(g_ptr) <= last_rgb || len(b_ptr) <= last_rgb ||
+		len(y_ptr) < (height-1)*y_stride+width ||
+		len(u_ptr) < (uv_height-1)*u_stride+uv_width ||
+		len(v_ptr) < (uv_height-1)*v_stride+uv_width {
+		return ErrBufferTooSmall
+	}
+
+	SharpYuvInitGammaTables()
+	transfer_type := options.TransferType
+
+	// Scale the matrix to account for the difference of bit depth.
+	scaled_matrix := *options.YuvMatrix
+	if rgb_bit_depth != yuv_bit_depth {
+		rgb_max := (1 << rgb_bit_depth) - 1
+		rgb_round := 1 << (rgb_bit_depth - 1)
+		yuv_max := (1 << yuv_bit_depth) - 1
+		for i := 0; i < 3; i++ {
+			scaled_matrix.RGBToY[i] = (options.YuvMatrix.RGBToY[i]*yuv_max + rgb_round) / rgb_max
+			scaled_matrix.RGBToU[i] = (options.YuvMatrix.RGBToU[i]*yuv_max + rgb_round) / rgb_max
+			scaled_matrix.RGBToV[i] = (options.YuvMatrix.RGBToV[i]*yuv_max + rgb_round) / rgb_max
+		}
+	}
+
+	// we expand the right/bottom border if needed
+	w := (width + 1) &^ 1
+	h := (height + 1) &^ 1
+	uv_w := w >> 1
+	uv_h := h >> 1
+	y_bit_depth := rgb_bit_depth + GetPrecisionShift(rgb_bit_depth)
+	prev_diff_y_sum := ^uint64(0)
+
+	tmp_buffer := make([]fixed_y_t, w*3*2) // scratch
+	best_y_base := make([]fixed_y_t, w*h)
+	target_y_base := make([]fixed_y_t, w*h)
+	best_rgb_y := make([]fixed_y_t, w*2)
+	best_uv_base := make([]fixed_t, uv_w*3*uv_h)
+	target_uv_base := make([]fixed_t, uv_w*3*uv_h)
+	best_rgb_uv := make([]fixed_t, uv_w*3)
+	diff_y_threshold := uint64(3.0 * float64(w) * float64(h))
+
+	src1 := tmp_buffer[0*w : 3*w]
+	src2 := tmp_buffer[3*w : 6*w]
+
+	// Import RGB samples to W/RGB representation.
+	for j := 0; j < height; j += 2 {
+		is_last_row := j == height-1
+		best_y := best_y_base[j*w:]
+		target_y := target_y_base[j*w:]
+		target_uv := target_uv_base[(j>>1)*3*uv_w:]
+		best_uv := best_uv_base[(j>>1)*3*uv_w:]
+
+		// prepare two rows of input
+		off := j * rgb_stride
+		ImportOneRow(r_ptr[off:], g_ptr[off:], b_ptr[off:], rgb_step, rgb_bit_depth, width, src1)
+		if !is_last_row {
+			off += rgb_stride
+			ImportOneRow(r_ptr[off:], g_ptr[off:], b_ptr[off:], rgb_step, rgb_bit_depth, width, src2)
+		} else {
+			copy(src2, src1)
+		}
+		StoreGray(src1, best_y[0:], w)
+		StoreGray(src2, best_y[w:], w)
+
+		UpdateW(src1, target_y, w, rgb_bit_depth, transfer_type)
+		UpdateW(src2, target_y[w:], w, rgb_bit_depth, transfer_type)
+		UpdateChroma(src1, src2, target_uv, uv_w, rgb_bit_depth, transfer_type)
+		copy(best_uv[:3*uv_w], target_uv[:3*uv_w])
+	}
+
+	// Iterate and resolve clipping conflicts.
+	for iter := 0; iter < kNumIterations; iter++ {
+		cur_uv := best_uv_base
+		prev_uv := best_uv_base
+		diff_y_sum := uint64(0)
+
+		for j := 0; j < h; j += 2 {
+			best_y := best_y_base[j*w:]
+			target_y := target_y_base[j*w:]
+			best_uv := best_uv_base[(j>>1)*3*uv_w:]
+			target_uv := target_uv_base[(j>>1)*3*uv_w:]
+
+			next_uv := cur_uv
+			if j < h-2 {
+				next_uv = cur_uv[3*uv_w:]
+			}
+			InterpolateTwoRows(best_y, prev_uv, cur_uv, next_uv, w, src1, src2, rgb_bit_depth)
+			prev_uv = cur_uv
+			cur_uv = next_uv
+
+			UpdateW(src1, best_rgb_y[0*w:], w, rgb_bit_depth, transfer_type)
+			UpdateW(src2, best_rgb_y[1*w:], w, rgb_bit_depth, transfer_type)
+			UpdateChroma(src1, src2, best_rgb_uv, uv_w, rgb_bit_depth, transfer_type)
+
+			// update two rows of Y and one row of RGB
+			diff_y_sum += SharpYuvUpdateY(target_y, best_rgb_y, best_y, 2*w, y_bit_depth)
+			SharpYuvUpdateRGB(target_uv, best_rgb_uv, best_uv, 3*uv_w)
+		}
+		// test exit condition
+		if iter > 0 {
+			if diff_y_sum < diff_y_threshold {
+				break
+			}
+			if diff_y_sum > prev_diff_y_sum {
+				break
+			}
+		}
+		prev_diff_y_sum = diff_y_sum
+	}
+
+	// final reconstruction
+	ConvertWRGBToYUV(best_y_base, best_uv_base, y_ptr, y_stride, u_ptr, u_stride, v_ptr, v_stride,
+		rgb_bit_depth, yuv_bit_depth, width, height, &scaled_matrix)
+	return nil
+}
+
+// Same as SharpYuvConvertWithOptions, using the default (sRGB) transfer
+// function.
+func SharpYuvConvert[S Sample, D Sample](r_ptr, g_ptr, b_ptr []S, rgb_step int, rgb_stride int, rgb_bit_depth int, y_ptr []D, y_stride int, u_ptr []D, u_stride int, v_ptr []D, v_stride int, yuv_bit_depth int, width, height int, yuv_matrix *SharpYuvConversionMatrix) error {
+	var options SharpYuvOptions
+	if err := SharpYuvOptionsInit(yuv_matrix, &options); err != nil {
+		return err
+	}
+	return SharpYuvConvertWithOptions(r_ptr, g_ptr, b_ptr, rgb_step, rgb_stride, rgb_bit_depth,
+		y_ptr, y_stride, u_ptr, u_stride, v_ptr, v_stride, yuv_bit_depth, width, height, &options)
+}
+
+// Returns true if the sample type is wider than 8 bits.
+func isWide[T Sample]() bool {
+	return ^T(0) > 0xff
+}
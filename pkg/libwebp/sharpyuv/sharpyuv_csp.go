@@ -0,0 +1,146 @@
+// Copyright 2022 Google Inc. All Rights Reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the COPYING file in the root of the source
+// tree. An additional intellectual property rights grant can be found
+// in the file PATENTS. All contributing project authors may
+// be found in the AUTHORS file in the root of the source tree.
+
+package sharpyuv
+
+import "math"
+
+// Range of YUV values.
+type SharpYuvRange int
+
+const (
+	SharpYuvRangeFull    SharpYuvRange = iota // YUV values between [0;255] (for 8 bit)
+	SharpYuvRangeLimited                      // Y in [16;235], YUV in [16;240] (for 8 bit)
+)
+
+// Describes a RGB . YUV conversion, used to compute a conversion matrix
+// with SharpYuvComputeConversionMatrix().
+type SharpYuvColorSpace struct {
+	Kr, Kb   float32 // luma coefficients of red and blue
+	BitDepth int     // 8, 10 or 12
+	Range    SharpYuvRange
+}
+
+// Fixed-point (YUV_FIX) RGB . YUV conversion matrix.
+// The last column of each row is the (already scaled) offset.
+type SharpYuvConversionMatrix struct {
+	RGBToY [4]int
+	RGBToU [4]int
+	RGBToV [4]int
+}
+
+// Predefined conversion matrices, see SharpYuvGetConversionMatrix().
+type SharpYuvMatrixType int
+
+const (
+	SharpYuvMatrixWebp SharpYuvMatrixType = iota
+	SharpYuvMatrixRec601Limited
+	SharpYuvMatrixRec601Full
+	SharpYuvMatrixRec709Limited
+	SharpYuvMatrixRec709Full
+	SharpYuvMatrixNum
+)
+
+func ToFixed16(f float32) int {
+	return int(math.Floor(float64(f)*(1<<16) + 0.5))
+}
+
+// Computes the conversion matrix for the given color space.
+func SharpYuvComputeConversionMatrix(yuv_color_space *SharpYuvColorSpace, matrix *SharpYuvConversionMatrix) {
+	kr := yuv_color_space.Kr
+	kb := yuv_color_space.Kb
+	kg := 1.0 - kr - kb
+	cr := 0.5 / (1.0 - kb)
+	cb := 0.5 / (1.0 - kr)
+
+	shift := yuv_color_space.BitDepth - 8
+
+	denom := float32(int(1)<<yuv_color_space.BitDepth - 1)
+	scale_y := float32(1.0)
+	add_y := float32(0.0)
+	scale_u := cr
+	scale_v := cb
+	add_uv := float32(int(128) << shift)
+
+	if yuv_color_space.Range == SharpYuvRangeLimited {
+		scale_y *= float32(int(219)<<shift) / denom
+		scale_u *= float32(int(224)<<shift) / denom
+		scale_v *= float32(int(224)<<shift) / denom
+		add_y = float32(int(16) << shift)
+	}
+
+	matrix.RGBToY[0] = ToFixed16(kr * scale_y)
+	matrix.RGBToY[1] = ToFixed16(kg * scale_y)
+	matrix.RGBToY[2] = ToFixed16(kb * scale_y)
+	matrix.RGBToY[3] = ToFixed16(add_y)
+
+	matrix.RGBToU[0] = ToFixed16(-kr * scale_u)
+	matrix.RGBToU[1] = ToFixed16(-kg * scale_u)
+	matrix.RGBToU[2] = ToFixed16((1 - kb) * scale_u)
+	matrix.RGBToU[3] = ToFixed16(add_uv)
+
+	matrix.RGBToV[0] = ToFixed16((1 - kr) * scale_v)
+	matrix.RGBToV[1] = ToFixed16(-kg * scale_v)
+	matrix.RGBToV[2] = ToFixed16(-kb * scale_v)
+	matrix.RGBToV[3] = ToFixed16(add_uv)
+}
+
+// Matrices are in YUV_FIX fixed point precision.
+// WebP's matrix, similar but not identical to kRec601LimitedMatrix.
+var kWebpMatrix = SharpYuvConversionMatrix{
+	RGBToY: [4]int{16839, 33059, 6420, 16 << 16},
+	RGBToU: [4]int{-9719, -19081, 28800, 128 << 16},
+	RGBToV: [4]int{28800, -24116, -4684, 128 << 16},
+}
+
+// Kr=0.2990f Kb=0.1140f bits=8 range=SharpYuvRangeLimited
+var kRec601LimitedMatrix = SharpYuvConversionMatrix{
+	RGBToY: [4]int{16829, 33039, 6416, 16 << 16},
+	RGBToU: [4]int{-9714, -19071, 28784, 128 << 16},
+	RGBToV: [4]int{28784, -24103, -4681, 128 << 16},
+}
+
+// Kr=0.2990f Kb=0.1140f bits=8 range=SharpYuvRangeFull
+var kRec601FullMatrix = SharpYuvConversionMatrix{
+	RGBToY: [4]int{19595, 38470, 7471, 0},
+	RGBToU: [4]int{-11058, -21710, 32768, 128 << 16},
+	RGBToV: [4]int{32768, -27439, -5329, 128 << 16},
+}
+
+// Kr=0.2126f Kb=0.0722f bits=8 range=SharpYuvRangeLimited
+var kRec709LimitedMatrix = SharpYuvConversionMatrix{
+	RGBToY: [4]int{11966, 40254, 4064, 16 << 16},
+	RGBToU: [4]int{-6596, -22189, 28784, 128 << 16},
+	RGBToV: [4]int{28784, -26145, -2639, 128 << 16},
+}
+
+// Kr=0.2126f Kb=0.0722f bits=8 range=SharpYuvRangeFull
+var kRec709FullMatrix = SharpYuvConversionMatrix{
+	RGBToY: [4]int{13933, 46871, 4732, 0},
+	RGBToU: [4]int{-7509, -25259, 32768, 128 << 16},
+	RGBToV: [4]int{32768, -29763, -3005, 128 << 16},
+}
+
+// Returns a pointer to a matrix for one of the predefined colorspaces,
+// or nil for an unknown type.
+func SharpYuvGetConversionMatrix(matrix_type SharpYuvMatrixType) *SharpYuvConversionMatrix {
+	switch matrix_type {
+	case SharpYuvMatrixWebp:
+		return &kWebpMatrix
+	case SharpYuvMatrixRec601Limited:
+		return &kRec601LimitedMatrix
+	case SharpYuvMatrixRec601Full:
+		return &kRec601FullMatrix
+	case SharpYuvMatrixRec709Limited:
+		return &kRec709LimitedMatrix
+	case SharpYuvMatrixRec709Full:
+		return &kRec709FullMatrix
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2022 Google Inc. All Rights Reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the COPYING file in the root of the source
+// tree. An additional intellectual property rights grant can be found
+// in the file PATENTS. All contributing project authors may
+// be found in the AUTHORS file in the root of the source tree.
+
+package sharpyuv
+
+func clipInt(v, min_v, max_v int) int {
+	if v < min_v {
+		return min_v
+	}
+	if v > max_v {
+		return max_v
+	}
+	return v
+}
+
+func SharpYuvUpdateY(ref []fixed_y_t, src []fixed_y_t, dst []fixed_y_t, len int, bit_depth int) uint64 {
+	diff := uint64(0)
+	max_y := (1 << bit_depth) - 1
+	for i := 0; i < len; i++ {
+		diff_y := int(ref[i]) - int(src[i])
+		new_y := int(dst[i]) + diff_y
+		dst[i] = fixed_y_t(clipInt(new_y, 0, max_y))
+		if diff_y < 0 {
+			diff_y = -diff_y
+		}
+		diff += uint64(diff_y)
+	}
+	return diff
+}
+
+func SharpYuvUpdateRGB(ref []fixed_t, src []fixed_t, dst []fixed_t, len int) {
+	for i := 0; i < len; i++ {
+		diff_uv := ref[i] - src[i]
+		dst[i] += diff_uv
+	}
+}
+
+func SharpYuvFilterRow(A []fixed_t, B []fixed_t, len int, best_y []fixed_y_t, out []fixed_y_t, bit_depth int) {
+	max_y := (1 << bit_depth) - 1
+	for i := 0; i < len; i++ {
+		a0, a1 := int(A[i]), int(A[i+1])
+		b0, b1 := int(B[i]), int(B[i+1])
+		v0 := (a0*9 + a1*3 + b0*3 + b1 + 8) >> 4
+		v1 := (a1*9 + a0*3 + b1*3 + b0 + 8) >> 4
+		out[2*i+0] = fixed_y_t(clipInt(int(best_y[2*i+0])+v0, 0, max_y))
+		out[2*i+1] = fixed_y_t(clipInt(int(best_y[2*i+1])+v1, 0, max_y))
+	}
+}
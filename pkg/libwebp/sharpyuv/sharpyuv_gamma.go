@@ -0,0 +1,170 @@
+// Copyright 2022 Google Inc. All Rights Reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the COPYING file in the root of the source
+// tree. An additional intellectual property rights grant can be found
+// in the file PATENTS. All contributing project authors may
+// be found in the AUTHORS file in the root of the source tree.
+
+package sharpyuv
+
+import (
+	"math"
+	"sync"
+)
+
+// Transfer function used to go from the gamma-encoded input samples to
+// linear light, in which the chroma downsampling is done.
+type SharpYuvTransferFunctionType int
+
+const (
+	// Rec. 709 / libwebp's historical "sRGB" approximation. Default.
+	SharpYuvTransferFunctionSrgb SharpYuvTransferFunctionType = iota
+	// Input samples are already linear.
+	SharpYuvTransferFunctionLinear
+	// Exact IEC 61966-2-1 sRGB curve.
+	SharpYuvTransferFunctionIec61966
+	SharpYuvTransferFunctionNum
+)
+
+// Gamma correction compensates loss of resolution during chroma subsampling.
+// Size of pre-computed table for converting from gamma to linear.
+const GAMMA_TO_LINEAR_TAB_BITS = 10
+const GAMMA_TO_LINEAR_TAB_SIZE = 1 << GAMMA_TO_LINEAR_TAB_BITS
+
+// Size of pre-computed table for converting from linear to gamma.
+const LINEAR_TO_GAMMA_TAB_BITS = 9
+const LINEAR_TO_GAMMA_TAB_SIZE = 1 << LINEAR_TO_GAMMA_TAB_BITS
+
+// Linear values are in GAMMA_TO_LINEAR_BITS fixed-point precision.
+const GAMMA_TO_LINEAR_BITS = 16
+
+const kGammaF = 1. / 0.45
+
+var (
+	kGammaToLinearTabS [GAMMA_TO_LINEAR_TAB_SIZE + 2]uint32
+	kLinearToGammaTabS [LINEAR_TO_GAMMA_TAB_SIZE + 2]uint32
+	gammaTablesOnce    sync.Once
+)
+
+// Initializes the gamma tables, safe to call multiple times and from
+// multiple goroutines.
+func SharpYuvInitGammaTables() {
+	gammaTablesOnce.Do(initGammaTables)
+}
+
+func initGammaTables() {
+	const a = 0.09929682680944
+	const thresh = 0.018053968510807
+	const final_scale = 1 << GAMMA_TO_LINEAR_BITS
+
+	// Precompute gamma to linear table.
+	norm := 1. / GAMMA_TO_LINEAR_TAB_SIZE
+	a_rec := 1. / (1. + a)
+	for v := 0; v <= GAMMA_TO_LINEAR_TAB_SIZE; v++ {
+		g := norm * float64(v)
+		var value float64
+		if g <= thresh*4.5 {
+			value = g / 4.5
+		} else {
+			value = math.Pow(a_rec*(g+a), kGammaF)
+		}
+		kGammaToLinearTabS[v] = uint32(value*final_scale + .5)
+	}
+	// to prevent small rounding errors to cause read-overflow:
+	kGammaToLinearTabS[GAMMA_TO_LINEAR_TAB_SIZE+1] = kGammaToLinearTabS[GAMMA_TO_LINEAR_TAB_SIZE]
+
+	// Precompute linear to gamma table.
+	scale := 1. / LINEAR_TO_GAMMA_TAB_SIZE
+	for v := 0; v <= LINEAR_TO_GAMMA_TAB_SIZE; v++ {
+		g := scale * float64(v)
+		var value float64
+		if g <= thresh {
+			value = 4.5 * g
+		} else {
+			value = (1.+a)*math.Pow(g, 1./kGammaF) - a
+		}
+		kLinearToGammaTabS[v] = uint32(final_scale*value + 0.5)
+	}
+	// to prevent small rounding errors to cause read-overflow:
+	kLinearToGammaTabS[LINEAR_TO_GAMMA_TAB_SIZE+1] = kLinearToGammaTabS[LINEAR_TO_GAMMA_TAB_SIZE]
+}
+
+func Shift(v int, shift int) int {
+	if shift >= 0 {
+		return v << shift
+	}
+	return v >> -shift
+}
+
+func FixedPointInterpolation(v int, tab []uint32, tab_pos_shift_right int, tab_value_shift int) uint32 {
+	tab_pos := Shift(v, -tab_pos_shift_right)
+	// fractional part, in 'tab_pos_shift' fixed-point precision
+	x := uint32(v - (tab_pos << tab_pos_shift_right))
+	// v0 / v1 are in GAMMA_TO_LINEAR_BITS fixed-point precision (range [0..1])
+	v0 := uint32(Shift(int(tab[tab_pos+0]), tab_value_shift))
+	v1 := uint32(Shift(int(tab[tab_pos+1]), tab_value_shift))
+	// Final interpolation.
+	v2 := (v1 - v0) * x // note: v1 >= v0.
+	half := uint32(0)
+	if tab_pos_shift_right > 0 {
+		half = 1 << (tab_pos_shift_right - 1)
+	}
+	return v0 + ((v2 + half) >> tab_pos_shift_right)
+}
+
+func ToLinearSrgb(v uint16, bit_depth int) uint32 {
+	shift := GAMMA_TO_LINEAR_TAB_BITS - bit_depth
+	if shift > 0 {
+		return kGammaToLinearTabS[int(v)<<shift]
+	}
+	return FixedPointInterpolation(int(v), kGammaToLinearTabS[:], -shift, 0)
+}
+
+func FromLinearSrgb(value uint32, bit_depth int) uint16 {
+	return uint16(FixedPointInterpolation(int(value), kLinearToGammaTabS[:],
+		GAMMA_TO_LINEAR_BITS-LINEAR_TO_GAMMA_TAB_BITS, bit_depth-GAMMA_TO_LINEAR_BITS))
+}
+
+func ToLinearIec61966(g float64) float64 {
+	if g <= 0.04045 {
+		return g / 12.92
+	}
+	return math.Pow((g+0.055)/1.055, 2.4)
+}
+
+func FromLinearIec61966(l float64) float64 {
+	if l <= 0.0031308 {
+		return l * 12.92
+	}
+	return 1.055*math.Pow(l, 1./2.4) - 0.055
+}
+
+// Converts a 'bit_depth' gamma-encoded sample to linear light, in
+// GAMMA_TO_LINEAR_BITS fixed-point precision.
+func SharpYuvGammaToLinear(v uint16, bit_depth int, transfer_type SharpYuvTransferFunctionType) uint32 {
+	switch transfer_type {
+	case SharpYuvTransferFunctionLinear:
+		return uint32(Shift(int(v), GAMMA_TO_LINEAR_BITS-bit_depth))
+	case SharpYuvTransferFunctionIec61966:
+		max_v := float64(int(1)<<bit_depth - 1)
+		return uint32(ToLinearIec61966(float64(v)/max_v)*(1<<GAMMA_TO_LINEAR_BITS) + .5)
+	default:
+		return ToLinearSrgb(v, bit_depth)
+	}
+}
+
+// Converts a linear value in GAMMA_TO_LINEAR_BITS fixed-point precision back
+// to a 'bit_depth' gamma-encoded sample.
+func SharpYuvLinearToGamma(value uint32, bit_depth int, transfer_type SharpYuvTransferFunctionType) uint16 {
+	switch transfer_type {
+	case SharpYuvTransferFunctionLinear:
+		return uint16(Shift(int(value), bit_depth-GAMMA_TO_LINEAR_BITS))
+	case SharpYuvTransferFunctionIec61966:
+		max_v := float64(int(1)<<bit_depth - 1)
+		l := math.Min(float64(value)/(1<<GAMMA_TO_LINEAR_BITS), 1.)
+		return uint16(FromLinearIec61966(l)*max_v + .5)
+	default:
+		return FromLinearSrgb(value, bit_depth)
+	}
+}
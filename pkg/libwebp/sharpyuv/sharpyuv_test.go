@@ -0,0 +1,188 @@
+package sharpyuv
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// The predefined matrices are the ones libwebp computes from their Kr/Kb.
+func TestSharpYuvComputeConversionMatrix(t *testing.T) {
+	for _, tc := range []struct {
+		matrix_type SharpYuvMatrixType
+		color_space SharpYuvColorSpace
+	}{
+		{SharpYuvMatrixRec601Limited, SharpYuvColorSpace{Kr: 0.2990, Kb: 0.1140, BitDepth: 8, Range: SharpYuvRangeLimited}},
+		{SharpYuvMatrixRec601Full, SharpYuvColorSpace{Kr: 0.2990, Kb: 0.1140, BitDepth: 8, Range: SharpYuvRangeFull}},
+		{SharpYuvMatrixRec709Limited, SharpYuvColorSpace{Kr: 0.2126, Kb: 0.0722, BitDepth: 8, Range: SharpYuvRangeLimited}},
+		{SharpYuvMatrixRec709Full, SharpYuvColorSpace{Kr: 0.2126, Kb: 0.0722, BitDepth: 8, Range: SharpYuvRangeFull}},
+	} {
+		var matrix SharpYuvConversionMatrix
+		SharpYuvComputeConversionMatrix(&tc.color_space, &matrix)
+		require.Equal(t, *SharpYuvGetConversionMatrix(tc.matrix_type), matrix, "matrix %d", tc.matrix_type)
+	}
+	require.Nil(t, SharpYuvGetConversionMatrix(SharpYuvMatrixNum))
+}
+
+func convertFlat(t *testing.T, r, g, b uint8, width, height int, matrix_type SharpYuvMatrixType) (y, u, v []uint8) {
+	rgb := make([]uint8, 3*width*height)
+	for i := 0; i < len(rgb); i += 3 {
+		rgb[i+0], rgb[i+1], rgb[i+2] = r, g, b
+	}
+	uv_width, uv_height := (width+1)>>1, (height+1)>>1
+	y = make([]uint8, width*height)
+	u = make([]uint8, uv_width*uv_height)
+	v = make([]uint8, uv_width*uv_height)
+	require.NoError(t, SharpYuvConvert(rgb[0:], rgb[1:], rgb[2:], 3, 3*width, 8,
+		y, width, u, uv_width, v, uv_width, 8, width, height, SharpYuvGetConversionMatrix(matrix_type)))
+	return y, u, v
+}
+
+func requireFlat(t *testing.T, plane []uint8, want uint8, name string, width, height int) {
+	for i, got := range plane {
+		require.Equal(t, want, got, "%s sample %d of %dx%d", name, i, width, height)
+	}
+}
+
+// Flat images convert to the values of libwebp's VP8RGBToY/U/V, odd sizes
+// included.
+func TestSharpYuvConvertFlat(t *testing.T) {
+	for _, tc := range []struct {
+		r, g, b uint8
+		y, u, v uint8
+	}{
+		{0, 0, 0, 16, 128, 128},
+		{255, 255, 255, 235, 128, 128},
+		{128, 128, 128, 126, 128, 128},
+		{255, 0, 0, 82, 90, 240},
+		{0, 255, 0, 145, 54, 34},
+		{0, 0, 255, 41, 240, 110},
+	} {
+		for _, size := range [][2]int{{1, 1}, {4, 4}, {7, 5}, {16, 9}} {
+			y, u, v := convertFlat(t, tc.r, tc.g, tc.b, size[0], size[1], SharpYuvMatrixWebp)
+			requireFlat(t, y, tc.y, "Y", size[0], size[1])
+			requireFlat(t, u, tc.u, "U", size[0], size[1])
+			requireFlat(t, v, tc.v, "V", size[0], size[1])
+		}
+	}
+}
+
+// 8-bit input converts to 10-bit output with a matrix computed for 10 bits.
+func TestSharpYuvConvertBitDepth(t *testing.T) {
+	const width, height = 4, 2
+	var matrix SharpYuvConversionMatrix
+	SharpYuvComputeConversionMatrix(&SharpYuvColorSpace{Kr: 0.2990, Kb: 0.1140, BitDepth: 10, Range: SharpYuvRangeFull}, &matrix)
+	rgb := make([]uint8, 3*width*height)
+	for i := range rgb {
+		rgb[i] = 255
+	}
+	y := make([]uint16, width*height)
+	u := make([]uint16, width/2*height/2)
+	v := make([]uint16, width/2*height/2)
+	require.NoError(t, SharpYuvConvert(rgb[0:], rgb[1:], rgb[2:], 3, 3*width, 8,
+		y, width, u, width/2, v, width/2, 10, width, height, &matrix))
+	for i := range y {
+		require.Equal(t, uint16(1023), y[i])
+	}
+	for i := range u {
+		require.Equal(t, uint16(512), u[i])
+		require.Equal(t, uint16(512), v[i])
+	}
+}
+
+// Across an edge between saturated colors, the luma away from the edge is
+// that of the flat colors.
+func TestSharpYuvConvertEdge(t *testing.T) {
+	const width, height = 16, 8
+	rgb := make([]uint8, 3*width*height)
+	for j := 0; j < height; j++ {
+		for i := 0; i < width; i++ {
+			p := rgb[3*(j*width+i):]
+			if i < 7 { // the edge falls inside a chroma sample
+				p[0], p[1], p[2] = 255, 0, 0
+			} else {
+				p[0], p[1], p[2] = 0, 0, 255
+			}
+		}
+	}
+	y := make([]uint8, width*height)
+	u := make([]uint8, width*height/4)
+	v := make([]uint8, width*height/4)
+	require.NoError(t, SharpYuvConvert(rgb[0:], rgb[1:], rgb[2:], 3, 3*width, 8,
+		y, width, u, width/2, v, width/2, 8, width, height, SharpYuvGetConversionMatrix(SharpYuvMatrixWebp)))
+	for j := 0; j < height; j++ {
+		row := y[j*width : (j+1)*width]
+		// away from the edge, the values are those of the flat colors
+		require.Equal(t, uint8(82), row[0], "row %d", j)
+		require.Equal(t, uint8(41), row[width-1], "row %d", j)
+		// at the edge, luma still steps down from red to blue
+		require.Greater(t, row[6], row[7], "row %d", j)
+	}
+}
+
+func TestSharpYuvConvertErrors(t *testing.T) {
+	rgb := make([]uint8, 3*4*4)
+	y := make([]uint8, 4*4)
+	uv := make([]uint8, 2*2)
+	matrix := SharpYuvGetConversionMatrix(SharpYuvMatrixWebp)
+
+	require.ErrorIs(t, SharpYuvConvert(rgb, rgb, rgb, 3, 12, 8, y, 4, uv, 2, uv, 2, 8, 4, 4, nil), ErrInvalidParameter)
+	require.ErrorIs(t, SharpYuvConvert(rgb, rgb, rgb, 3, 12, 8, y, 4, uv, 2, uv, 2, 8, 0, 4, matrix), ErrInvalidParameter)
+	require.ErrorIs(t, SharpYuvConvert(rgb, rgb, rgb, 3, 12, 9, y, 4, uv, 2, uv, 2, 8, 4, 4, matrix), ErrInvalidBitDepth)
+	require.ErrorIs(t, SharpYuvConvert(rgb, rgb, rgb, 3, 12, 8, y, 4, uv, 2, uv, 2, 10, 4, 4, matrix), ErrInvalidBitDepth)
+	require.ErrorIs(t, SharpYuvConvert(rgb, rgb, rgb, 3, 12, 8, y[:15], 4, uv, 2, uv, 2, 8, 4, 4, matrix), ErrBufferTooSmall)
+	require.ErrorIs(t, SharpYuvConvert(rgb[:45], rgb, rgb, 3, 12, 8, y, 4, uv, 2, uv, 2, 8, 4, 4, matrix), ErrBufferTooSmall)
+}
+
+// 16-bit input of flat colors converts to the 8-bit values of their 8-bit
+// counterparts, within the rounding of the matrix scaled to 16-bit input.
+func TestSharpYuvConvert16Bit(t *testing.T) {
+	const width, height = 7, 5
+	const uv_width, uv_height = (width + 1) >> 1, (height + 1) >> 1
+	requireClose := func(want, got []uint8, name string, c [3]uint8) {
+		for i := range want {
+			require.InDelta(t, want[i], got[i], 1, "%s sample %d of %v", name, i, c)
+		}
+	}
+	for _, c := range [][3]uint8{{0, 0, 0}, {255, 255, 255}, {128, 128, 128}, {255, 0, 0}, {0, 255, 0}, {0, 0, 255}} {
+		rgb := make([]uint16, 3*width*height)
+		for i := 0; i < len(rgb); i += 3 {
+			rgb[i+0], rgb[i+1], rgb[i+2] = 257*uint16(c[0]), 257*uint16(c[1]), 257*uint16(c[2])
+		}
+		y := make([]uint8, width*height)
+		u := make([]uint8, uv_width*uv_height)
+		v := make([]uint8, uv_width*uv_height)
+		require.NoError(t, SharpYuvConvert(rgb[0:], rgb[1:], rgb[2:], 3, 3*width, 16,
+			y, width, u, uv_width, v, uv_width, 8, width, height, SharpYuvGetConversionMatrix(SharpYuvMatrixWebp)))
+
+		want_y, want_u, want_v := convertFlat(t, c[0], c[1], c[2], width, height, SharpYuvMatrixWebp)
+		requireClose(want_y, y, "Y", c)
+		requireClose(want_u, u, "U", c)
+		requireClose(want_v, v, "V", c)
+	}
+}
+
+// 16-bit input keeps its extra precision: a ramp finer than 8-bit steps
+// still gives a non-decreasing 10-bit luma spanning several values.
+func TestSharpYuvConvert16BitRamp(t *testing.T) {
+	const width, height = 64, 2
+	var matrix SharpYuvConversionMatrix
+	SharpYuvComputeConversionMatrix(&SharpYuvColorSpace{Kr: 0.2990, Kb: 0.1140, BitDepth: 10, Range: SharpYuvRangeFull}, &matrix)
+	rgb := make([]uint16, 3*width*height)
+	for j := 0; j < height; j++ {
+		for i := 0; i < width; i++ {
+			p := rgb[3*(j*width+i):]
+			p[0], p[1], p[2] = uint16(32768+64*i), uint16(32768+64*i), uint16(32768+64*i)
+		}
+	}
+	y := make([]uint16, width*height)
+	u := make([]uint16, width/2*height/2)
+	v := make([]uint16, width/2*height/2)
+	require.NoError(t, SharpYuvConvert(rgb[0:], rgb[1:], rgb[2:], 3, 3*width, 16,
+		y, width, u, width/2, v, width/2, 10, width, height, &matrix))
+	for i := 1; i < width; i++ {
+		require.GreaterOrEqual(t, y[i], y[i-1], "sample %d", i)
+	}
+	// 64 steps of 1/1024 of the range
+	require.InDelta(t, 63, int(y[width-1])-int(y[0]), 2)
+}
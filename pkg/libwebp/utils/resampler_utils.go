@@ -0,0 +1,275 @@
+// Separable kernel resampler (Lanczos3, Catmull-Rom, Mitchell), streaming
+// rows like WebPRescaler. Rows are filtered horizontally when imported and
+// vertically when exported.
+package utils
+
+import "math"
+
+// Resampling kernel used to rescale.
+type WebPResampleFilter int
+
+const (
+	WEBP_FILTER_BOX         WebPResampleFilter = iota // area averaging (WebPRescaler), the default
+	WEBP_FILTER_LANCZOS3                              // windowed sinc, 3 lobes: sharpest
+	WEBP_FILTER_CATMULL_ROM                           // cubic, B = 0, C = 1/2
+	WEBP_FILTER_MITCHELL                              // cubic, B = C = 1/3: least ringing
+	WEBP_FILTER_LAST
+)
+
+// Fixed-point precision of the filter weights.
+const WEBP_RESAMPLER_WFIX = 14
+
+// Horizontal or vertical contribution of the source to one destination
+// sample: 'weights' apply to the samples starting at 'start'.
+type resampleContrib struct {
+	start   int
+	weights []int32
+}
+
+type WebPResampler struct {
+	num_channels          int
+	src_width, src_height int
+	dst_width, dst_height int
+	src_y, dst_y          int
+	dst                   []uint8
+	dst_stride            int
+	x_contrib, y_contrib  []resampleContrib
+	// Ring buffer of horizontally filtered rows, 'y % num_rows' being the
+	// slot of row 'y'. Values are scaled by 1 << WEBP_RESAMPLER_WFIX.
+	rows     [][]int32
+	num_rows int
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	x *= math.Pi
+	return math.Sin(x) / x
+}
+
+// Mitchell-Netravali cubic with parameters 'b' and 'c'.
+func cubic(x, b, c float64) float64 {
+	x = math.Abs(x)
+	switch {
+	case x < 1:
+		return ((12-9*b-6*c)*x*x*x + (-18+12*b+6*c)*x*x + (6 - 2*b)) / 6
+	case x < 2:
+		return ((-b-6*c)*x*x*x + (6*b+30*c)*x*x + (-12*b-48*c)*x + (8*b + 24*c)) / 6
+	}
+	return 0
+}
+
+// Returns the kernel of 'filter' and its radius.
+func resampleKernel(filter WebPResampleFilter) (func(float64) float64, float64) {
+	switch filter {
+	case WEBP_FILTER_LANCZOS3:
+		return func(x float64) float64 {
+			if x <= -3 || x >= 3 {
+				return 0
+			}
+			return sinc(x) * sinc(x/3)
+		}, 3
+	case WEBP_FILTER_CATMULL_ROM:
+		return func(x float64) float64 { return cubic(x, 0, 0.5) }, 2
+	default:
+		return func(x float64) float64 { return cubic(x, 1./3, 1./3) }, 2
+	}
+}
+
+// Computes the contributions of 'src_size' samples to each of the 'dst_size'
+// ones. Samples past the edges are clamped, their weights being merged into
+// the edge samples.
+func resampleContribs(filter WebPResampleFilter, src_size, dst_size int) []resampleContrib {
+	kernel, radius := resampleKernel(filter)
+	scale := float64(dst_size) / float64(src_size)
+	support, kscale := radius, 1.
+	if scale < 1 { // widen the kernel when shrinking, to average
+		support, kscale = radius/scale, scale
+	}
+	contribs := make([]resampleContrib, dst_size)
+	weights := make([]float64, 0, 2*int(math.Ceil(support))+2)
+	for j := range contribs {
+		center := (float64(j)+0.5)/scale - 0.5
+		first := max(int(math.Ceil(center-support)), 0)
+		last := min(int(math.Floor(center+support)), src_size-1)
+		weights = weights[:0]
+		sum := 0.
+		for i := int(math.Ceil(center - support)); i <= int(math.Floor(center+support)); i++ {
+			w := kernel((float64(i) - center) * kscale)
+			k := min(max(i, first), last) - first
+			for len(weights) <= k {
+				weights = append(weights, 0)
+			}
+			weights[k] += w
+			sum += w
+		}
+		if sum == 0 { // degenerate: nearest sample
+			weights = append(weights[:0], 1)
+			first = min(max(int(math.Round(center)), 0), src_size-1)
+			sum = 1
+		}
+		// Quantize, giving the rounding error to the largest weight so
+		// that the weights add up to exactly 1 << WEBP_RESAMPLER_WFIX.
+		fixed := make([]int32, len(weights))
+		total, largest := int32(0), 0
+		for k, w := range weights {
+			fixed[k] = int32(math.Round(w / sum * (1 << WEBP_RESAMPLER_WFIX)))
+			total += fixed[k]
+			if fixed[k] > fixed[largest] {
+				largest = k
+			}
+		}
+		fixed[largest] += (1 << WEBP_RESAMPLER_WFIX) - total
+		contribs[j] = resampleContrib{start: first, weights: fixed}
+	}
+	return contribs
+}
+
+// Initializes a resampler scaling 'src_width' x 'src_height' samples of
+// 'num_channels' interleaved channels into 'dst'. 'filter' must not be
+// WEBP_FILTER_BOX. Returns false in case of error.
+func WebPResamplerInit(resampler *WebPResampler, filter WebPResampleFilter, src_width, src_height int, dst []uint8, dst_width, dst_height, dst_stride, num_channels int) bool {
+	if resampler == nil || filter <= WEBP_FILTER_BOX || filter >= WEBP_FILTER_LAST ||
+		src_width <= 0 || src_height <= 0 || dst_width <= 0 || dst_height <= 0 ||
+		num_channels <= 0 {
+		return false
+	}
+	*resampler = WebPResampler{
+		num_channels: num_channels,
+		src_width:    src_width,
+		src_height:   src_height,
+		dst_width:    dst_width,
+		dst_height:   dst_height,
+		dst:          dst,
+		dst_stride:   dst_stride,
+		x_contrib:    resampleContribs(filter, src_width, dst_width),
+		y_contrib:    resampleContribs(filter, src_height, dst_height),
+	}
+	// Rows are buffered ahead of the output so that planes of different
+	// heights, like the YUV420 chroma, can be imported in lock-step with the
+	// luma (see EmitRescaledRGB()).
+	for _, c := range resampler.y_contrib {
+		resampler.num_rows = max(resampler.num_rows, 3*len(c.weights)+2)
+	}
+	resampler.rows = make([][]int32, resampler.num_rows)
+	for i := range resampler.rows {
+		resampler.rows[i] = make([]int32, dst_width*num_channels)
+	}
+	return true
+}
+
+// Size of a resampleContrib, slice header included.
+const kResampleContribSize = 8 + 24
+
+// Returns an upper bound of the number of weights of a contribution when
+// scaling 'src_size' samples to 'dst_size' with a kernel of radius 'radius'.
+func resampleTaps(radius float64, src_size, dst_size int) uint64 {
+	support := radius
+	if dst_size < src_size {
+		support = radius * float64(src_size) / float64(dst_size)
+	}
+	return uint64(min(2*math.Ceil(support)+1, float64(src_size)))
+}
+
+// Returns an upper bound of the memory WebPResamplerInit() allocates: the
+// contributions and the ring buffer of rows.
+func WebPResamplerMemorySize(filter WebPResampleFilter, src_width, src_height, dst_width, dst_height, num_channels int) uint64 {
+	if src_width <= 0 || src_height <= 0 || dst_width <= 0 || dst_height <= 0 {
+		return 0
+	}
+	_, radius := resampleKernel(filter)
+	x_taps := resampleTaps(radius, src_width, dst_width)
+	y_taps := resampleTaps(radius, src_height, dst_height)
+	size := uint64(dst_width)*(kResampleContribSize+4*x_taps) +
+		uint64(dst_height)*(kResampleContribSize+4*y_taps)
+	num_rows := 3*y_taps + 2
+	return size + num_rows*(24+4*uint64(dst_width*num_channels))
+}
+
+// Returns true if input is finished.
+func WebPResamplerInputDone( /* const */ resampler *WebPResampler) bool {
+	return resampler.src_y >= resampler.src_height
+}
+
+// Returns true if output is finished.
+func WebPResamplerOutputDone( /* const */ resampler *WebPResampler) bool {
+	return resampler.dst_y >= resampler.dst_height
+}
+
+// Returns true if all the rows needed by the next output row are imported.
+func WebPResamplerHasPendingOutput( /* const */ resampler *WebPResampler) bool {
+	if WebPResamplerOutputDone(resampler) {
+		return false
+	}
+	c := &resampler.y_contrib[resampler.dst_y]
+	return c.start+len(c.weights) <= resampler.src_y
+}
+
+// Returns the number of input rows that can be imported next without
+// overwriting a row still needed, at most 'max_num_lines'.
+func WebPResamplerNeededLines( /* const */ resampler *WebPResampler, max_num_lines int) int {
+	num_lines := resampler.src_height - resampler.src_y
+	if !WebPResamplerOutputDone(resampler) {
+		c := &resampler.y_contrib[resampler.dst_y]
+		num_lines = min(num_lines, c.start+resampler.num_rows-resampler.src_y)
+	}
+	return max(min(num_lines, max_num_lines), 0)
+}
+
+// Filters the source row 'src' horizontally into its ring buffer slot.
+func WebPResamplerImportRow(resampler *WebPResampler /*const*/, src []uint8) {
+	num_channels := resampler.num_channels
+	row := resampler.rows[resampler.src_y%resampler.num_rows]
+	for x, c := range resampler.x_contrib {
+		for ch := 0; ch < num_channels; ch++ {
+			sum := int32(0)
+			in := src[c.start*num_channels+ch:]
+			for k, w := range c.weights {
+				sum += w * int32(in[k*num_channels])
+			}
+			row[x*num_channels+ch] = sum
+		}
+	}
+	resampler.src_y++
+}
+
+// Import up to 'num_lines' rows, as many as the buffer holds. Returns the
+// actual number of rows imported.
+func WebPResamplerImport(resampler *WebPResampler, num_lines int /*const*/, src []uint8, src_stride int) int {
+	num_lines = WebPResamplerNeededLines(resampler, num_lines)
+	for y := 0; y < num_lines; y++ {
+		WebPResamplerImportRow(resampler, src[y*src_stride:])
+	}
+	return num_lines
+}
+
+// Filters the buffered rows vertically into the next destination row.
+func WebPResamplerExportRow(resampler *WebPResampler) {
+	const kRound = int64(1) << (2*WEBP_RESAMPLER_WFIX - 1)
+	c := &resampler.y_contrib[resampler.dst_y]
+	width := resampler.dst_width * resampler.num_channels
+	dst := resampler.dst[:width]
+	for x := range dst {
+		sum := int64(0)
+		for k, w := range c.weights {
+			sum += int64(w) * int64(resampler.rows[(c.start+k)%resampler.num_rows][x])
+		}
+		v := (sum + kRound) >> (2 * WEBP_RESAMPLER_WFIX)
+		dst[x] = uint8(min(max(v, 0), 255))
+	}
+	if resampler.dst_stride > 0 && resampler.dst_y+1 < resampler.dst_height {
+		resampler.dst = resampler.dst[resampler.dst_stride:]
+	}
+	resampler.dst_y++
+}
+
+// Export as many rows as possible. Returns the number of rows written.
+func WebPResamplerExport(resampler *WebPResampler) int {
+	total_exported := 0
+	for WebPResamplerHasPendingOutput(resampler) {
+		WebPResamplerExportRow(resampler)
+		total_exported++
+	}
+	return total_exported
+}
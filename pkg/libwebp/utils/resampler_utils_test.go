@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var kernelFilters = map[string]WebPResampleFilter{
+	"Lanczos3":    WEBP_FILTER_LANCZOS3,
+	"Catmull-Rom": WEBP_FILTER_CATMULL_ROM,
+	"Mitchell":    WEBP_FILTER_MITCHELL,
+}
+
+// The cubic kernels are partitions of unity; the windowed sinc nearly is.
+func TestResampleKernelSum(t *testing.T) {
+	for name, filter := range kernelFilters {
+		kernel, radius := resampleKernel(filter)
+		delta := 1e-12
+		if filter == WEBP_FILTER_LANCZOS3 {
+			delta = 0.01
+		}
+		for phase := 0.; phase < 1; phase += 0.125 {
+			sum := 0.
+			for i := -int(radius); i <= int(radius)+1; i++ {
+				sum += kernel(float64(i) - phase)
+			}
+			require.InDelta(t, 1, sum, delta, "%s phase %v", name, phase)
+		}
+	}
+}
+
+// Once quantized, the weights of every destination sample add up to exactly
+// one, edges included, and stay within the source.
+func TestResampleContribsSum(t *testing.T) {
+	for name, filter := range kernelFilters {
+		for _, size := range [][2]int{{1, 1}, {1, 7}, {7, 1}, {10, 3}, {3, 10}, {100, 33}, {33, 100}, {64, 64}} {
+			src_size, dst_size := size[0], size[1]
+			contribs := resampleContribs(filter, src_size, dst_size)
+			require.Len(t, contribs, dst_size)
+			for j, c := range contribs {
+				sum := int32(0)
+				for _, w := range c.weights {
+					sum += w
+				}
+				require.Equal(t, int32(1<<WEBP_RESAMPLER_WFIX), sum, "%s %d -> %d, sample %d", name, src_size, dst_size, j)
+				require.GreaterOrEqual(t, c.start, 0)
+				require.LessOrEqual(t, c.start+len(c.weights), src_size)
+			}
+		}
+	}
+}
+
+// resample scales the 'src_width' x 'src_height' image 'src' with rows
+// imported as the resampler asks for them.
+func resample(t *testing.T, filter WebPResampleFilter, src []uint8, src_width, src_height, dst_width, dst_height, num_channels int) []uint8 {
+	dst := make([]uint8, dst_width*dst_height*num_channels)
+	var resampler WebPResampler
+	require.True(t, WebPResamplerInit(&resampler, filter, src_width, src_height, dst, dst_width, dst_height, dst_width*num_channels, num_channels))
+	src_stride := src_width * num_channels
+	for !WebPResamplerInputDone(&resampler) || !WebPResamplerOutputDone(&resampler) {
+		imported := WebPResamplerImport(&resampler, 2, src[resampler.src_y*src_stride:], src_stride)
+		exported := WebPResamplerExport(&resampler)
+		require.False(t, imported == 0 && exported == 0, "stalled at row %d", resampler.src_y)
+	}
+	return dst
+}
+
+func TestResamplerConstantImage(t *testing.T) {
+	const num_channels = 4
+	for name, filter := range kernelFilters {
+		for _, size := range [][4]int{{37, 23, 10, 7}, {10, 7, 37, 23}, {16, 16, 16, 16}, {5, 40, 20, 3}} {
+			src := make([]uint8, size[0]*size[1]*num_channels)
+			for i := range src {
+				src[i] = []uint8{0, 17, 128, 255}[i%num_channels]
+			}
+			dst := resample(t, filter, src, size[0], size[1], size[2], size[3], num_channels)
+			for i, v := range dst {
+				require.Equal(t, src[i%num_channels], v, "%s %v sample %d", name, size, i)
+			}
+		}
+	}
+}
+
+func TestResamplerInitErrors(t *testing.T) {
+	var resampler WebPResampler
+	dst := make([]uint8, 16)
+	require.False(t, WebPResamplerInit(&resampler, WEBP_FILTER_BOX, 4, 4, dst, 2, 2, 2, 1))
+	require.False(t, WebPResamplerInit(&resampler, WEBP_FILTER_LAST, 4, 4, dst, 2, 2, 2, 1))
+	require.False(t, WebPResamplerInit(&resampler, WEBP_FILTER_MITCHELL, 0, 4, dst, 2, 2, 2, 1))
+	require.False(t, WebPResamplerInit(&resampler, WEBP_FILTER_MITCHELL, 4, 4, dst, 2, 0, 2, 1))
+	require.False(t, WebPResamplerInit(nil, WEBP_FILTER_MITCHELL, 4, 4, dst, 2, 2, 2, 1))
+}
+
+// WebPResamplerMemorySize() bounds what WebPResamplerInit() allocates.
+func TestResamplerMemorySize(t *testing.T) {
+	const num_channels = 4
+	for name, filter := range kernelFilters {
+		for _, size := range [][4]int{{1, 1, 1, 1}, {37, 23, 10, 7}, {10, 7, 37, 23}, {1000, 10, 3, 1}, {5, 40, 20, 3}} {
+			var resampler WebPResampler
+			dst := make([]uint8, size[2]*size[3]*num_channels)
+			require.True(t, WebPResamplerInit(&resampler, filter, size[0], size[1], dst, size[2], size[3], size[2]*num_channels, num_channels))
+			allocated := uint64(0)
+			for _, contribs := range [][]resampleContrib{resampler.x_contrib, resampler.y_contrib} {
+				for _, c := range contribs {
+					allocated += kResampleContribSize + 4*uint64(len(c.weights))
+				}
+			}
+			for _, row := range resampler.rows {
+				allocated += 24 + 4*uint64(len(row))
+			}
+			bound := WebPResamplerMemorySize(filter, size[0], size[1], size[2], size[3], num_channels)
+			require.LessOrEqual(t, allocated, bound, "%s %v", name, size)
+			require.LessOrEqual(t, bound, 2*allocated, "%s %v", name, size)
+		}
+	}
+}
@@ -85,6 +85,44 @@ int WebPRescalerInit(/* const */ rescaler *WebPRescaler, src_width int, src_heig
   return 1
 }
 
+// Same as WebPRescalerInit(), with the resampling kernel 'filter'.
+// WEBP_FILTER_BOX uses the area-averaging rescaler and 'work'; the other
+// filters allocate their own buffers. Returns false in case of error.
+func WebPRescalerInitFilter(rescaler *WebPRescaler, filter WebPResampleFilter, src_width, src_height int, dst []uint8, dst_width, dst_height, dst_stride, num_channels int, work []rescaler_t) bool {
+	if filter == WEBP_FILTER_BOX {
+		rescaler.resampler = nil
+		return WebPRescalerInit(rescaler, src_width, src_height, dst, dst_width, dst_height, dst_stride, num_channels, work) != 0
+	}
+	resampler := &WebPResampler{}
+	if !WebPResamplerInit(resampler, filter, src_width, src_height, dst, dst_width, dst_height, dst_stride, num_channels) {
+		return false
+	}
+	*rescaler = WebPRescaler{
+		x_expand:     tenary.If(src_width < dst_width, 1, 0),
+		y_expand:     tenary.If(src_height < dst_height, 1, 0),
+		src_width:    src_width,
+		src_height:   src_height,
+		dst_width:    dst_width,
+		dst_height:   dst_height,
+		dst_stride:   dst_stride,
+		num_channels: num_channels,
+		resampler:    resampler,
+	}
+	WebPRescalerSyncResampler(rescaler)
+	return true
+}
+
+// Copies the progress of the kernel resampler of 'rescaler' into the
+// WebPRescaler fields read by the callers (src_y, dst_y, dst and y_accum).
+func WebPRescalerSyncResampler(rescaler *WebPRescaler) {
+	resampler := rescaler.resampler
+	rescaler.src_y = resampler.src_y
+	rescaler.dst_y = resampler.dst_y
+	rescaler.dst = resampler.dst
+	// WebPRescalerHasPendingOutput() tests 'y_accum <= 0'.
+	rescaler.y_accum = tenary.If(WebPResamplerHasPendingOutput(resampler), 0, 1)
+}
+
 func WebPRescalerGetScaledDimensions(src_width int, src_height int, /*const*/ scaled_width *int, /*const*/ scaled_height *int) int {
   assert.Assert(scaled_width != nil)
   assert.Assert(scaled_height != nil)
@@ -116,12 +154,20 @@ func WebPRescalerGetScaledDimensions(src_width int, src_height int, /*const*/ sc
 // all-in-one calls
 
 func WebPRescaleNeededLines(/* const */ rescaler *WebPRescaler, max_num_lines int) int {
+	if rescaler.resampler != nil {
+		return WebPResamplerNeededLines(rescaler.resampler, max_num_lines)
+	}
 	num_lines := (rescaler.y_accum + rescaler.y_sub - 1) / rescaler.y_sub
 
 	return tenary.If(num_lines > max_num_lines, max_num_lines, num_lines)
 }
 
 func WebPRescalerImport(/* const */ rescaler *WebPRescaler, num_lines int, /*const*/ src *uint8, src_stride int) int {
+  if (rescaler.resampler != nil) {
+    total_imported := WebPResamplerImport(rescaler.resampler, num_lines, src, src_stride)
+    WebPRescalerSyncResampler(rescaler)
+    return total_imported
+  }
   total_imported := 0
   while (total_imported < num_lines &&
          !WebPRescalerHasPendingOutput(rescaler)) {
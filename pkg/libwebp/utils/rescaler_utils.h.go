@@ -42,6 +42,9 @@ type WebPRescaler struct {
   // work buffer
   irow (dst_num_channels *width) *rescaler_t
   irow (dst_num_channels *width) *rescaler_t
+  // if not nil, rows are resampled with a kernel filter instead of being
+  // area-averaged. The fields above only mirror its progress then.
+  resampler *WebPResampler
 }
 
 // Initialize a rescaler given scratch area 'work' and dimensions of src & dst.
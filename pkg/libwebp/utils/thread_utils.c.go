@@ -13,280 +13,182 @@ package utils
 //
 // Author: Skal (pascal.massimino@gmail.com)
 
-import "github.com/daanv2/go-webp/pkg/libwebp/utils"
+import (
+	"runtime"
+	"sync"
 
-import "github.com/daanv2/go-webp/pkg/assert"
-import "github.com/daanv2/go-webp/pkg/string"  // for stdlib.Memset()
-
-import "github.com/daanv2/go-webp/pkg/libwebp/utils"
-import "github.com/daanv2/go-webp/pkg/libwebp/utils"
-
-
-#ifdef WEBP_USE_THREAD
-
-#if defined(_WIN32)
-
-import "github.com/daanv2/go-webp/pkg/windows"
-typedef HANDLE pthread_t
-
-#if _WIN32_WINNT < 0x0600
-#error _WIN32_WINNT must target Windows Vista / Server 2008 or newer.
-#endif
-typedef SRWLOCK pthread_mutex_t
-typedef CONDITION_VARIABLE pthread_cond_t
-
-#define WINAPI_FAMILY_PARTITION(x) x
-#endif
-
-#if !WINAPI_FAMILY_PARTITION(WINAPI_PARTITION_DESKTOP)
-#define USE_CREATE_THREAD
-#endif
-
-#else  // !_WIN32
-
-import "github.com/daanv2/go-webp/pkg/pthread"
-
-#endif  // _WIN32
+	"github.com/daanv2/go-webp/pkg/assert"
+)
 
+// Per-worker state while a job is in flight.
 type WebPWorkerImpl struct {
-  pthread_mutex_t mutex
-  pthread_cond_t condition
-  pthread_t thread
-}
-
-#if defined(_WIN32)
-
-//------------------------------------------------------------------------------
-// simplistic pthread emulation layer
-
-import "github.com/daanv2/go-webp/pkg/process"
-
-// _beginthreadex requires __stdcall
-const THREADFN = unsigned int __stdcall
-#define THREAD_RETURN(val) (unsigned int)((DWORD_PTR)val)
-
-// Deprecated: use go routines instead.
-func pthread_create(thread *pthread_t, /* const */ attr *void, unsigned int(__start *stdcall)(*void), arg *void) int {
-  return 0
-}
-
-func pthread_join(thread pthread_t, value_ptr *void) int {
-  (void)value_ptr
-  return (WaitForSingleObject(thread, INFINITE) != WAIT_OBJECT_0 ||
-          CloseHandle(thread) == 0)
-}
-
-// Mutex
-func pthread_mutex_init(pthread_mutex_t* const mutex, mutexattr *void) int {
-  _ = mutexattr
-  InitializeSRWLock(mutex)
-  return 0
-}
-
-func pthread_mutex_lock(pthread_mutex_t* const mutex) int {
-  AcquireSRWLockExclusive(mutex)
-  return 0
-}
-
-func pthread_mutex_unlock(pthread_mutex_t* const mutex) int {
-  ReleaseSRWLockExclusive(mutex)
-  return 0
-}
-
-func pthread_mutex_destroy(pthread_mutex_t* const mutex) int {
-  _ = mutex
-  return 0
+	done chan struct{} // closed when the launched hook returns
 }
 
-// Condition
-func pthread_cond_destroy(pthread_cond_t* const condition) int {
-  _ = condition
-  return 0
-}
-
-func pthread_cond_init(pthread_cond_t* const condition, cond_attr *void) int {
-  (void)cond_attr
-  InitializeConditionVariable(condition)
-  return 0
-}
-
-func pthread_cond_signal(pthread_cond_t* const condition) int {
-  WakeConditionVariable(condition)
-  return 0
-}
-
-func pthread_cond_wait(pthread_cond_t* const condition, pthread_mutex_t* const mutex) int {
-  ok := SleepConditionVariableSRW(condition, mutex, INFINITE, 0)
-  return !ok
-}
-
-#else  // !_WIN32
-const THREADFN = *void
-#define THREAD_RETURN(val) val
-#endif  // _WIN32
-
 //------------------------------------------------------------------------------
-
-func ThreadLoop(ptr *void) THREADFN {
-  var worker *WebPWorker = (*WebPWorker)ptr
-  var impl *WebPWorkerImpl = (*WebPWorkerImpl)worker.impl
-  done := 0
-  for !done {
-    pthread_mutex_lock(&impl.mutex)
-    for worker.status == OK {  // wait in idling mode
-      pthread_cond_wait(&impl.condition, &impl.mutex)
-    }
-    if (worker.status == WORK) {
-      WebPGetWorkerInterface().Execute(worker)
-      worker.status = OK
-    } else if (worker.status == NOT_OK) {  // finish the worker
-      done = 1
-    }
-    // signal to the main thread that we're done (for Sync())
-    // Note the associated mutex does not need to be held when signaling the
-    // condition. Unlocking the mutex first may improve performance in some
-    // implementations, avoiding the case where the waiting thread can't
-    // reacquire the mutex when woken.
-    pthread_mutex_unlock(&impl.mutex)
-    pthread_cond_signal(&impl.condition)
-  }
-  return THREAD_RETURN(nil);  // Thread is finished
-}
-
-// main thread state control
-func ChangeState(/* const */ worker *WebPWorker, WebPWorkerStatus new_status) {
-  // No-op when attempting to change state on a thread that didn't come up.
-  // Checking 'status' without acquiring the lock first would result in a data
-  // race.
-  var impl *WebPWorkerImpl = (*WebPWorkerImpl)worker.impl
-  if impl == nil { return }
-
-  pthread_mutex_lock(&impl.mutex)
-  if (worker.status >= OK) {
-    // wait for the worker to finish
-    for worker.status != OK {
-      pthread_cond_wait(&impl.condition, &impl.mutex)
-    }
-    // assign new status and release the working thread if needed
-    if (new_status != OK) {
-      worker.status = new_status
-      // Note the associated mutex does not need to be held when signaling the
-      // condition. Unlocking the mutex first may improve performance in some
-      // implementations, avoiding the case where the waiting thread can't
-      // reacquire the mutex when woken.
-      pthread_mutex_unlock(&impl.mutex)
-      pthread_cond_signal(&impl.condition)
-      return
-    }
-  }
-  pthread_mutex_unlock(&impl.mutex)
+// Shared worker pool
+//
+// All workers of the process (decoder filtering, alpha compression, ...) run
+// their jobs on goroutines drawn from a single bounded pool. When the pool is
+// saturated, Launch() runs the job synchronously in the caller: the work still
+// gets done, and the number of concurrent goroutines never exceeds the limit.
+
+type workerPool struct {
+	mu      sync.Mutex
+	max     int // maximum number of concurrently running jobs
+	running int // number of jobs currently running
+}
+
+var g_worker_pool = workerPool{max: runtime.GOMAXPROCS(0)}
+
+func (pool *workerPool) tryAcquire() bool {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	if pool.running >= pool.max {
+		return false
+	}
+	pool.running++
+	return true
+}
+
+func (pool *workerPool) release() {
+	pool.mu.Lock()
+	pool.running--
+	pool.mu.Unlock()
+}
+
+// Sets the maximum number of jobs running concurrently on the shared worker
+// pool, for the whole process. 'max_workers' <= 0 selects GOMAXPROCS.
+// Returns the previous value. Jobs already running are not affected.
+func WebPSetMaxWorkers(max_workers int) int {
+	if max_workers <= 0 {
+		max_workers = runtime.GOMAXPROCS(0)
+	}
+	g_worker_pool.mu.Lock()
+	defer g_worker_pool.mu.Unlock()
+	previous := g_worker_pool.max
+	g_worker_pool.max = max_workers
+	return previous
+}
+
+// Returns the current limit set by WebPSetMaxWorkers().
+func WebPGetMaxWorkers() int {
+	g_worker_pool.mu.Lock()
+	defer g_worker_pool.mu.Unlock()
+	return g_worker_pool.max
+}
+
+// Reserves up to 'n' slots of the shared pool for goroutines started outside
+// of a WebPWorker. Returns the number of slots obtained, possibly 0. They must
+// be given back with WebPReleaseWorkers().
+func WebPAcquireWorkers(n int) int {
+	g_worker_pool.mu.Lock()
+	defer g_worker_pool.mu.Unlock()
+	if free := g_worker_pool.max - g_worker_pool.running; n > free {
+		n = free
+	}
+	if n < 0 {
+		n = 0
+	}
+	g_worker_pool.running += n
+	return n
+}
+
+// Gives back 'n' slots obtained with WebPAcquireWorkers().
+func WebPReleaseWorkers(n int) {
+	g_worker_pool.mu.Lock()
+	g_worker_pool.running -= n
+	g_worker_pool.mu.Unlock()
 }
 
-#endif  // WEBP_USE_THREAD
-
 //------------------------------------------------------------------------------
 
-func Init(/* const */ worker *WebPWorker) {
-  stdlib.Memset(worker, 0, sizeof(*worker))
-  worker.status = NOT_OK
-}
-
-func Sync(/* const */ worker *WebPWorker) int {
-#ifdef WEBP_USE_THREAD
-  ChangeState(worker, OK)
-#endif
-  assert.Assert(worker.status <= OK)
-  return !worker.had_error
-}
-
-func Reset(/* const */ worker *WebPWorker) int {
-  ok := 1
-  worker.had_error = 0
-  if (worker.status < OK) {
-#ifdef WEBP_USE_THREAD
-    // var impl *WebPWorkerImpl = (*WebPWorkerImpl)WebPSafeCalloc(1, sizeof(WebPWorkerImpl))
-    impl := &WebPWorkerImpl{}
-
-    worker.impl = (*void)impl
-    if (worker.impl == nil) {
-      return 0
-    }
-    if (pthread_mutex_init(&impl.mutex, nil)) {
-      goto Error
-    }
-    if (pthread_cond_init(&impl.condition, nil)) {
-      pthread_mutex_destroy(&impl.mutex)
-      goto Error
-    }
-    pthread_mutex_lock(&impl.mutex)
-    ok = !pthread_create(&impl.thread, nil, ThreadLoop, worker)
-    if ok { worker.status = OK }
-    pthread_mutex_unlock(&impl.mutex)
-    if (!ok) {
-      pthread_mutex_destroy(&impl.mutex)
-      pthread_cond_destroy(&impl.condition)
-    Error:
-      worker.impl = nil
-      return 0
-    }
-#else
-    worker.status = OK
-#endif
-  } else if (worker.status > OK) {
-    ok = Sync(worker)
-  }
-  assert.Assert(!ok || (worker.status == OK))
-  return ok
-}
-
-func Execute(/* const */ worker *WebPWorker) {
-  if (worker.hook != nil) {
-    worker.had_error |= !worker.hook(worker.data1, worker.data2)
-  }
-}
-
-func Launch(/* const */ worker *WebPWorker) {
-#ifdef WEBP_USE_THREAD
-  ChangeState(worker, WORK)
-#else
-  Execute(worker)
-#endif
-}
-
-func End(/* const */ worker *WebPWorker) {
-#ifdef WEBP_USE_THREAD
-  if (worker.impl != nil) {
-    var impl *WebPWorkerImpl = (*WebPWorkerImpl)worker.impl
-    ChangeState(worker, NOT_OK)
-    pthread_join(impl.thread, nil)
-    pthread_mutex_destroy(&impl.mutex)
-    pthread_cond_destroy(&impl.condition)
-    worker.impl = nil
-  }
-#else
-  worker.status = NOT_OK
-  assert.Assert(worker.impl == nil)
-#endif
-  assert.Assert(worker.status == NOT_OK)
+func Init( /* const */ worker *WebPWorker) {
+	*worker = WebPWorker{status: NOT_OK}
+}
+
+func Sync( /* const */ worker *WebPWorker) int {
+	if worker.status == WORK {
+		<-worker.impl.done
+		worker.status = OK
+	}
+	assert.Assert(worker.status <= OK)
+	if worker.had_error != 0 {
+		return 0
+	}
+	return 1
+}
+
+func Reset( /* const */ worker *WebPWorker) int {
+	ok := 1
+	worker.had_error = 0
+	if worker.status < OK {
+		worker.impl = &WebPWorkerImpl{}
+		worker.status = OK
+	} else if worker.status > OK {
+		ok = Sync(worker)
+	}
+	assert.Assert(ok == 0 || (worker.status == OK))
+	return ok
+}
+
+func Execute( /* const */ worker *WebPWorker) {
+	if worker.hook != nil && worker.hook(worker.data1, worker.data2) == 0 {
+		worker.had_error = 1
+	}
+}
+
+func Launch( /* const */ worker *WebPWorker) {
+	if worker.impl == nil { // not Reset(): behave like the non-threaded build.
+		Execute(worker)
+		return
+	}
+	// Wait for the previous job, like ChangeState() does.
+	Sync(worker)
+	done := make(chan struct{})
+	worker.impl.done = done
+	worker.status = WORK
+	if !g_worker_pool.tryAcquire() {
+		Execute(worker)
+		close(done)
+		return
+	}
+	go func() {
+		defer g_worker_pool.release()
+		defer close(done)
+		Execute(worker)
+	}()
+}
+
+func End( /* const */ worker *WebPWorker) {
+	if worker.impl != nil {
+		Sync(worker)
+		worker.impl = nil
+	}
+	worker.status = NOT_OK
 }
 
 //------------------------------------------------------------------------------
 
-static WebPWorkerInterface g_worker_interface = {Init,   Reset,   Sync, Launch, Execute, End}
+var g_worker_interface = WebPWorkerInterface{Init, Reset, Sync, Launch, Execute, End}
 
-func WebPSetWorkerInterface(/* const */ winterface *WebPWorkerInterface) int {
-  if (winterface == nil || winterface.Init == nil ||
-      winterface.Reset == nil || winterface.Sync == nil ||
-      winterface.Launch == nil || winterface.Execute == nil ||
-      winterface.End == nil) {
-    return 0
-  }
-  g_worker_interface = *winterface
-  return 1
+// Install a new set of threading functions, overriding the defaults. This
+// should be done before any workers are started, i.e., before any encoding or
+// decoding takes place. The contents of the interface struct are copied.
+// This function is not thread-safe. Return false in case of invalid pointer
+// or methods.
+func WebPSetWorkerInterface( /* const */ winterface *WebPWorkerInterface) int {
+	if winterface == nil || winterface.Init == nil ||
+		winterface.Reset == nil || winterface.Sync == nil ||
+		winterface.Launch == nil || winterface.Execute == nil ||
+		winterface.End == nil {
+		return 0
+	}
+	g_worker_interface = *winterface
+	return 1
 }
 
-const WebPGetWorkerInterface *WebPWorkerInterface(){
-  return &g_worker_interface
+// Retrieve the currently set thread worker interface.
+func WebPGetWorkerInterface() *WebPWorkerInterface {
+	return &g_worker_interface
 }
-
-//------------------------------------------------------------------------------
@@ -22,17 +22,17 @@ const (
 	WORK                           // busy finishing the current task
 )
 
-// Function to be called by the worker thread. Takes two opaque pointers as
-// arguments (data1 and data2), and should return false in case of error.
-type WebPWorkerHook = func(*void, *void) int
+// Function to be called by the worker thread. Takes two opaque arguments
+// (data1 and data2), and should return false in case of error.
+type WebPWorkerHook = func(data1, data2 any) int
 
 // Synchronization object used to launch job in the worker thread
 type WebPWorker struct {
-	impl      *void // platform-dependent implementation worker details
+	impl      *WebPWorkerImpl // goroutine bookkeeping, nil until Reset()
 	status    WebPWorkerStatus
 	hook      WebPWorkerHook // hook to call
-	data1     *void          // first argument passed to 'hook'
-	data2     *void          // second argument passed to 'hook'
+	data1     any            // first argument passed to 'hook'
+	data2     any            // second argument passed to 'hook'
 	had_error int            // return value of the last call to 'hook'
 }
 
@@ -41,38 +41,23 @@ type WebPWorker struct {
 type WebPWorkerInterface struct {
 	// Must be called first, before any other method.
 	Init func( /* const */ worker *WebPWorker)
-	// Must be called to initialize the object and spawn the thread. Re-entrant.
-	// Will potentially launch the thread. Returns false in case of error.
+	// Must be called to initialize the object. Re-entrant.
+	// Returns false in case of error.
 	Reset func( /* const */ worker *WebPWorker) int
 	// Makes sure the previous work is finished. Returns true if worker.had_error
 	// was not set and no error condition was triggered by the working thread.
 	Sync func( /* const */ worker *WebPWorker) int
-	// Triggers the thread to call hook() with data1 and data2 arguments. These
-	// hook/data1/data2 values can be changed at any time before calling this
-	// function, but not be changed afterward until the next call to Sync().
+	// Schedules hook() with data1 and data2 arguments on the shared worker
+	// pool. These hook/data1/data2 values can be changed at any time before
+	// calling this function, but not be changed afterward until the next call
+	// to Sync().
 	Launch func( /* const */ worker *WebPWorker)
 	// This function is similar to Launch() except that it calls the
-	// hook directly instead of using a thread. Convenient to bypass the thread
-	// mechanism while still using the WebPWorker structs. Sync() must
+	// hook directly instead of using a goroutine. Convenient to bypass the
+	// pool while still using the WebPWorker structs. Sync() must
 	// still be called afterward (for error reporting).
 	Execute func( /* const */ worker *WebPWorker)
-	// Kill the thread and terminate the object. To use the object again, one
-	// must call Reset() again.
+	// Waits for the pending job and terminates the object. To use the object
+	// again, one must call Reset() again.
 	End func( /* const */ worker *WebPWorker)
 }
-
-// Install a new set of threading functions, overriding the defaults. This
-// should be done before any workers are started, i.e., before any encoding or
-// decoding takes place. The contents of the interface struct are copied, it
-// is safe to free the corresponding memory after this call. This function is
-// not thread-safe. Return false in case of invalid pointer or methods.
-func WebPSetWorkerInterface( /* const */ winterface *WebPWorkerInterface) int {
-	// TODO: implement this function
-	return 0
-}
-
-// Retrieve the currently set thread worker interface.
-func WebPWorkerInterface() *WebPGetWorkerInterface {
-	// TODO: implement this function
-	return nil
-}
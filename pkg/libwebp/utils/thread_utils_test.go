@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Jobs launched on more workers than the pool allows all run, with at most
+// the pool limit on goroutines plus the callers running them synchronously.
+func TestWorkerPoolBound(t *testing.T) {
+	const max_workers, num_workers = 2, 8
+	previous := WebPSetMaxWorkers(max_workers)
+	defer WebPSetMaxWorkers(previous)
+
+	var running, max_running, done atomic.Int32
+	hook := func(data1, data2 any) int {
+		n := running.Add(1)
+		for {
+			m := max_running.Load()
+			if n <= m || max_running.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		running.Add(-1)
+		done.Add(1)
+		return 1
+	}
+
+	winterface := WebPGetWorkerInterface()
+	workers := make([]WebPWorker, num_workers)
+	for i := range workers {
+		winterface.Init(&workers[i])
+		require.NotZero(t, winterface.Reset(&workers[i]))
+		workers[i].hook = hook
+	}
+	for i := range workers {
+		winterface.Launch(&workers[i])
+	}
+	for i := range workers {
+		require.NotZero(t, winterface.Sync(&workers[i]))
+		winterface.End(&workers[i])
+	}
+	require.Equal(t, int32(num_workers), done.Load())
+	// The launching goroutine runs the jobs the pool has no room for.
+	require.LessOrEqual(t, max_running.Load(), int32(max_workers+1))
+}
+
+// Launch() and Execute() compute the same results, errors included.
+func TestWorkerLaunchMatchesExecute(t *testing.T) {
+	hook := func(data1, data2 any) int {
+		in, out := data1.([]int), data2.([]int)
+		for i, v := range in {
+			out[i] = v*v + 1
+		}
+		return int(in[0] & 1)
+	}
+	in := make([]int, 1024)
+	for i := range in {
+		in[i] = 3*i + 7
+	}
+
+	winterface := WebPGetWorkerInterface()
+	run := func(launch bool, first int) ([]int, int) {
+		in := append([]int{first}, in[1:]...)
+		out := make([]int, len(in))
+		var worker WebPWorker
+		winterface.Init(&worker)
+		require.NotZero(t, winterface.Reset(&worker))
+		defer winterface.End(&worker)
+		worker.hook, worker.data1, worker.data2 = hook, in, out
+		if launch {
+			winterface.Launch(&worker)
+		} else {
+			winterface.Execute(&worker)
+		}
+		return out, winterface.Sync(&worker)
+	}
+	for _, first := range []int{1, 2} { // odd succeeds, even fails
+		launched, launched_ok := run(true, first)
+		executed, executed_ok := run(false, first)
+		require.Equal(t, executed, launched)
+		require.Equal(t, executed_ok, launched_ok)
+		require.Equal(t, first&1, launched_ok)
+	}
+}
+
+func TestAcquireWorkers(t *testing.T) {
+	previous := WebPSetMaxWorkers(3)
+	defer WebPSetMaxWorkers(previous)
+	require.Equal(t, 3, WebPGetMaxWorkers())
+
+	require.Equal(t, 2, WebPAcquireWorkers(2))
+	require.Equal(t, 1, WebPAcquireWorkers(5))
+	require.Zero(t, WebPAcquireWorkers(1))
+	WebPReleaseWorkers(3)
+	require.Equal(t, 3, WebPAcquireWorkers(3))
+	WebPReleaseWorkers(3)
+}
+
+// Many goroutines sharing the pool; meant to be run with -race.
+func TestWorkerPoolConcurrentUse(t *testing.T) {
+	winterface := WebPGetWorkerInterface()
+	var wg sync.WaitGroup
+	var total atomic.Int64
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var worker WebPWorker
+			winterface.Init(&worker)
+			if winterface.Reset(&worker) == 0 {
+				t.Error("Reset failed")
+				return
+			}
+			defer winterface.End(&worker)
+			worker.hook = func(data1, data2 any) int {
+				total.Add(int64(data1.(int)))
+				return 1
+			}
+			for i := 1; i <= 100; i++ {
+				worker.data1 = i
+				winterface.Launch(&worker)
+				winterface.Sync(&worker)
+			}
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, int64(16*5050), total.Load())
+}
@@ -9,7 +9,9 @@
 package webp
 
 import (
+	"github.com/daanv2/go-webp/pkg/color/yuv"
 	"github.com/daanv2/go-webp/pkg/libwebp/decoder"
+	"github.com/daanv2/go-webp/pkg/libwebp/utils"
 	"github.com/daanv2/go-webp/pkg/vp8"
 )
 
@@ -71,6 +73,22 @@ type WebPDecoderOptions struct {
 	dithering_strength       int // dithering strength (0=Off, 100=full)
 	flip                     int // if true, flip output vertically
 	alpha_dithering_strength int // alpha dithering strength in [0..100]
+	// Y'CbCr matrix used for the YUV.RGB conversion. nil means BT.601
+	// limited range, which is what the encoder produces by default.
+	yuv_matrix *yuv.Matrix
+	// If true (and use_threads is set), macroblock rows belonging to
+	// different token partitions are decoded concurrently. Only effective for
+	// lossy bitstreams with several partitions.
+	use_wavefront int
+	// Kernel used when use_scaling is set. WEBP_FILTER_BOX (the default)
+	// averages the source area, the others are sharper.
+	resample_filter utils.WebPResampleFilter
+	// Limits checked once the headers are parsed, before any pixel memory is
+	// allocated; VP8_STATUS_LIMIT_EXCEEDED is returned when one is exceeded.
+	// 0 means no limit.
+	max_pixels       int   // width x height of the bitstream (canvas for animations)
+	max_memory_bytes int64 // output buffer plus decoder memory, canvases for animations
+	max_frames       int   // number of frames of animations
 
 	pad [5]uint32 // padding for later use
 }
@@ -94,8 +94,13 @@ type WebPAnimDecoderOptions struct {
 	// Output colorspace. Only the following modes are supported:
 	// MODE_RGBA, MODE_BGRA, MODE_rgbA and MODE_bgrA.
 	color_mode  WEBP_CSP_MODE
-	use_threads int       // If true, use multi-threaded decoding.
-	padding     [7]uint32 // Padding for later use.
+	use_threads int // If true, use multi-threaded decoding.
+	// Decoding limits, 0 meaning no limit: canvas pixels, memory for the
+	// canvases and the frame decoding, and number of frames.
+	max_pixels       int
+	max_memory_bytes int64
+	max_frames       int
+	padding          [7]uint32 // Padding for later use.
 }
 
 // Should always be called, to initialize a fresh WebPAnimDecoderOptions
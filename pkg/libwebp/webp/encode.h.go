@@ -150,6 +150,10 @@ int WebPPictureIsView(/* const */ picture *picture.Picture)
 // Returns false in case of error (invalid parameter or insufficient memory).
 int WebPPictureRescale(picture *picture.Picture, width, height int)
 
+// Same as WebPPictureRescale(), resampling with the kernel 'filter'
+// (WEBP_FILTER_BOX being the area averaging of WebPPictureRescale()).
+int WebPPictureRescaleFilter(picture *picture.Picture, width, height int, filter WebPResampleFilter)
+
 // Colorspace conversion function to import RGB samples.
 // Previous buffer will be free'd, if any.
 // buffer should have *rgb a size of at least height * rgb_stride.
@@ -37,3 +37,50 @@ type WebPAuxStats struct {
 
 	pad [1]uint32 // padding for later use
 }
+
+// Go view of WebPAuxStats.
+type AuxStats struct {
+	CodedSize     int
+	PSNR          [5]float64 // Y/U/V/All/Alpha
+	BlockCount    [3]int     // intra4/intra16/skipped macroblocks
+	HeaderBytes   [2]int     // header and mode-partition #0
+	ResidualBytes [3][4]int  // DC/AC/uv coefficients for each segment
+	SegmentSize   [4]int
+	SegmentQuant  [4]int
+	SegmentLevel  [4]int
+	AlphaDataSize int
+
+	LosslessFeatures        uint32 // bit0:predictor bit1:cross-color bit2:subtract-green bit3:color indexing
+	HistogramBits           int
+	TransformBits           int
+	CrossColorTransformBits int
+	CacheBits               int
+	PaletteSize             int
+	LosslessSize            int
+	LosslessHdrSize         int
+	LosslessDataSize        int
+}
+
+// Returns the Go view of 'stats'.
+func (stats *WebPAuxStats) Export() AuxStats {
+	return AuxStats{
+		CodedSize:               stats.coded_size,
+		PSNR:                    stats.PSNR,
+		BlockCount:              stats.block_count,
+		HeaderBytes:             stats.header_bytes,
+		ResidualBytes:           stats.residual_bytes,
+		SegmentSize:             stats.segment_size,
+		SegmentQuant:            stats.segment_quant,
+		SegmentLevel:            stats.segment_level,
+		AlphaDataSize:           stats.alpha_data_size,
+		LosslessFeatures:        stats.lossless_features,
+		HistogramBits:           stats.histogram_bits,
+		TransformBits:           stats.transform_bits,
+		CrossColorTransformBits: stats.cross_color_transform_bits,
+		CacheBits:               stats.cache_bits,
+		PaletteSize:             stats.palette_size,
+		LosslessSize:            stats.lossless_size,
+		LosslessHdrSize:         stats.lossless_hdr_size,
+		LosslessDataSize:        stats.lossless_data_size,
+	}
+}
@@ -72,8 +72,7 @@ func ImportYUVAFromRGBA( /* const */ r_ptr []uint8 /*const*/, g_ptr []uint8 /*co
 	}
 
 	if use_iterative_conversion {
-		SharpYuvInit(VP8GetCPUInfo)
-		if !PreprocessARGB(r_ptr, g_ptr, b_ptr, step, rgb_stride, pict) {
+		if err := enc.PreprocessARGB(r_ptr, g_ptr, b_ptr, step, rgb_stride, pict); err != nil {
 			return 0
 		}
 		if has_alpha {
@@ -105,7 +104,7 @@ func ImportYUVAFromRGBA( /* const */ r_ptr []uint8 /*const*/, g_ptr []uint8 /*co
 
 		if rg == nil {
 			// Downsample Y/U/V planes, two rows at a time
-			WebPImportYUVAFromRGBA(r_ptr, g_ptr, b_ptr, a_ptr, step, rgb_stride, has_alpha, width, height, tmp_rgb, pict.YStride, pict.UVStride, pict.AStride, dst_y, dst_u, dst_v, dst_a)
+			WebPImportYUVAFromRGBA(r_ptr, g_ptr, b_ptr, a_ptr, step, rgb_stride, has_alpha, width, height, tmp_rgb, pict.YStride, pict.UVStride, pict.AStride, dst_y, dst_u, dst_v, dst_a, pict.YUVMatrix)
 			if height & 1 {
 				dst_y += (height - 1) * ptrdiff_t(pict.YStride)
 				dst_u += (height >> 1) * ptrdiff_t(pict.UVStride)
@@ -117,14 +116,14 @@ func ImportYUVAFromRGBA( /* const */ r_ptr []uint8 /*const*/, g_ptr []uint8 /*co
 					dst_a += (height - 1) * ptrdiff_t(pict.AStride)
 					a_ptr += (height - 1) * ptrdiff_t(rgb_stride)
 				}
-				WebPImportYUVAFromRGBALastLine(r_ptr, g_ptr, b_ptr, a_ptr, step, has_alpha, width, tmp_rgb, dst_y, dst_u, dst_v, dst_a)
+				WebPImportYUVAFromRGBALastLine(r_ptr, g_ptr, b_ptr, a_ptr, step, has_alpha, width, tmp_rgb, dst_y, dst_u, dst_v, dst_a, pict.YUVMatrix)
 			}
 		} else {
 			// Copy of WebPImportYUVAFromRGBA/WebPImportYUVAFromRGBALastLine, // but with dithering.
 			for y = 0; y < (height >> 1); y++ {
 				rows_have_alpha := has_alpha
-				ConvertRowToY(r_ptr, g_ptr, b_ptr, step, dst_y, width, rg)
-				ConvertRowToY(r_ptr+rgb_stride, g_ptr+rgb_stride, b_ptr+rgb_stride, step, dst_y+picture.YStride, width, rg)
+				ConvertRowToY(r_ptr, g_ptr, b_ptr, step, dst_y, width, rg, pict.YUVMatrix)
+				ConvertRowToY(r_ptr+rgb_stride, g_ptr+rgb_stride, b_ptr+rgb_stride, step, dst_y+picture.YStride, width, rg, pict.YUVMatrix)
 				dst_y += 2 * picture.YStride
 				if has_alpha {
 					rows_have_alpha &= !WebPExtractAlpha(a_ptr, rgb_stride, width, 2, dst_a, picture.AStride)
@@ -137,7 +136,7 @@ func ImportYUVAFromRGBA( /* const */ r_ptr []uint8 /*const*/, g_ptr []uint8 /*co
 					WebPAccumulateRGBA(r_ptr, g_ptr, b_ptr, a_ptr, rgb_stride, tmp_rgb, width)
 				}
 				// Convert to U/V
-				ConvertRowsToUV(tmp_rgb, dst_u, dst_v, uv_width, rg)
+				ConvertRowsToUV(tmp_rgb, dst_u, dst_v, uv_width, rg, pict.YUVMatrix)
 				dst_u += picture.UVStride
 				dst_v += picture.UVStride
 				r_ptr += 2 * rgb_stride
@@ -149,7 +148,7 @@ func ImportYUVAFromRGBA( /* const */ r_ptr []uint8 /*const*/, g_ptr []uint8 /*co
 			}
 			if height & 1 { // extra last row
 				row_has_alpha := has_alpha
-				ConvertRowToY(r_ptr, g_ptr, b_ptr, step, dst_y, width, rg)
+				ConvertRowToY(r_ptr, g_ptr, b_ptr, step, dst_y, width, rg, pict.YUVMatrix)
 				if row_has_alpha {
 					row_has_alpha &= !WebPExtractAlpha(a_ptr, 0, width, 1, dst_a, 0)
 				}
@@ -160,7 +159,7 @@ func ImportYUVAFromRGBA( /* const */ r_ptr []uint8 /*const*/, g_ptr []uint8 /*co
 				} else {
 					WebPAccumulateRGBA(r_ptr, g_ptr, b_ptr, a_ptr /*rgb_stride=*/, 0, tmp_rgb, width)
 				}
-				ConvertRowsToUV(tmp_rgb, dst_u, dst_v, uv_width, rg)
+				ConvertRowsToUV(tmp_rgb, dst_u, dst_v, uv_width, rg, pict.YUVMatrix)
 			}
 		}
 
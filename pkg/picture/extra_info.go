@@ -0,0 +1,148 @@
+package picture
+
+import (
+	"image"
+	"image/color"
+)
+
+// Kind of per-macroblock map to store in Picture.ExtraInfo (lossy only).
+type ExtraInfoType int
+
+const (
+	EXTRA_INFO_NONE         ExtraInfoType = iota // no map is stored
+	EXTRA_INFO_INTRA_TYPE                        // 0=i4x4, 1=i16x16
+	EXTRA_INFO_SEGMENT                           // segment index [0..3]
+	EXTRA_INFO_QUANT                             // segment quantizer [0..127]
+	EXTRA_INFO_INTRA16_MODE                      // intra-16 prediction mode, 0xff for i4x4
+	EXTRA_INFO_UV_MODE                           // chroma prediction mode
+	EXTRA_INFO_BIT_COST                          // bytes spent on the residuals, capped at 255
+	EXTRA_INFO_DISTORTION                        // mean squared error per pixel, capped at 255
+	EXTRA_INFO_LAST
+)
+
+// Per-macroblock encoding decisions, as collected by the lossy encoder loop.
+type MacroblockInfo struct {
+	IntraType   uint8 // 0=i4x4, 1=i16x16
+	Segment     uint8 // segment index [0..3]
+	Quant       uint8 // segment quantizer [0..127]
+	Intra16Mode uint8 // intra-16 prediction mode, 0xff for i4x4
+	UVMode      uint8 // chroma prediction mode
+	Skip        bool  // true if no residual was coded
+	BitCost     uint8 // bytes spent on the residuals, capped at 255
+	Distortion  uint8 // mean squared error per pixel, capped at 255
+}
+
+// Value returns the field of 'info' selected by 'kind'.
+func (info *MacroblockInfo) Value(kind ExtraInfoType) uint8 {
+	switch kind {
+	case EXTRA_INFO_INTRA_TYPE:
+		return info.IntraType
+	case EXTRA_INFO_SEGMENT:
+		return info.Segment
+	case EXTRA_INFO_QUANT:
+		return info.Quant
+	case EXTRA_INFO_INTRA16_MODE:
+		return info.Intra16Mode
+	case EXTRA_INFO_UV_MODE:
+		return info.UVMode
+	case EXTRA_INFO_BIT_COST:
+		return info.BitCost
+	case EXTRA_INFO_DISTORTION:
+		return info.Distortion
+	default:
+		return 0
+	}
+}
+
+// MacroblockDimensions returns the size of the picture in macroblock units.
+func (pic *Picture) MacroblockDimensions() (mb_w, mb_h int) {
+	return (pic.Width + 15) >> 4, (pic.Height + 15) >> 4
+}
+
+// EnableExtraInfo allocates the macroblock grid and the ExtraInfo map of
+// the given kind, so they get filled in by the next lossy encode.
+func (pic *Picture) EnableExtraInfo(kind ExtraInfoType) {
+	mb_w, mb_h := pic.MacroblockDimensions()
+	pic.ExtraInfoType = kind
+	pic.MacroblockInfo = make([]MacroblockInfo, mb_w*mb_h)
+	if kind != EXTRA_INFO_NONE {
+		pic.ExtraInfo = make([]uint8, mb_w*mb_h)
+	} else {
+		pic.ExtraInfo = nil
+	}
+}
+
+// ExtraInfoMap extracts a single map of the given kind from the macroblock
+// grid. Returns nil if no grid was collected.
+func (pic *Picture) ExtraInfoMap(kind ExtraInfoType) []uint8 {
+	if pic.MacroblockInfo == nil {
+		return nil
+	}
+	values := make([]uint8, len(pic.MacroblockInfo))
+	for i := range pic.MacroblockInfo {
+		values[i] = pic.MacroblockInfo[i].Value(kind)
+	}
+	return values
+}
+
+// ExtraInfoHeatmap renders the map of the given kind as a heatmap, see Heatmap.
+func (pic *Picture) ExtraInfoHeatmap(kind ExtraInfoType, block_size int) *image.RGBA {
+	mb_w, mb_h := pic.MacroblockDimensions()
+	values := pic.ExtraInfoMap(kind)
+	if values == nil && kind == pic.ExtraInfoType {
+		values = pic.ExtraInfo
+	}
+	return Heatmap(values, mb_w, mb_h, block_size)
+}
+
+// Heatmap renders a macroblock map of mb_w x mb_h values as an image where
+// every value covers a block_size x block_size square (use 16 to overlay the
+// map on the source picture). Values are normalized against the largest one
+// and mapped from blue (low) through green to red (high). The 0xff value used
+// for "not applicable" entries is drawn black.
+func Heatmap(values []uint8, mb_w, mb_h, block_size int) *image.RGBA {
+	if block_size <= 0 {
+		block_size = 1
+	}
+	img := image.NewRGBA(image.Rect(0, 0, mb_w*block_size, mb_h*block_size))
+	if len(values) < mb_w*mb_h {
+		return img
+	}
+
+	max_value := 1
+	for _, v := range values[:mb_w*mb_h] {
+		if v != 0xff && int(v) > max_value {
+			max_value = int(v)
+		}
+	}
+
+	for mb_y := 0; mb_y < mb_h; mb_y++ {
+		for mb_x := 0; mb_x < mb_w; mb_x++ {
+			v := values[mb_x+mb_y*mb_w]
+			c := color.RGBA{A: 0xff}
+			if v != 0xff {
+				c = heatColor(int(v) * 1020 / max_value)
+			}
+			for y := mb_y * block_size; y < (mb_y+1)*block_size; y++ {
+				for x := mb_x * block_size; x < (mb_x+1)*block_size; x++ {
+					img.SetRGBA(x, y, c)
+				}
+			}
+		}
+	}
+	return img
+}
+
+// Maps 't' in [0..1020] onto a blue-cyan-green-yellow-red ramp.
+func heatColor(t int) color.RGBA {
+	switch {
+	case t < 255:
+		return color.RGBA{0, uint8(t), 255, 255}
+	case t < 510:
+		return color.RGBA{0, 255, uint8(510 - t), 255}
+	case t < 765:
+		return color.RGBA{uint8(t - 510), 255, 0, 255}
+	default:
+		return color.RGBA{255, uint8(max(1020-t, 0)), 0, 255}
+	}
+}
@@ -0,0 +1,231 @@
+package picture
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/daanv2/go-webp/pkg/color/colorspace"
+	"github.com/daanv2/go-webp/pkg/libwebp/enc"
+	"github.com/daanv2/go-webp/pkg/libwebp/sharpyuv"
+	"github.com/daanv2/go-webp/pkg/util/tenary"
+)
+
+// Dithering used when reducing samples wider than 8 bits.
+type WideDither int
+
+const (
+	WIDE_DITHER_NONE      WideDither = iota // round to the nearest 8-bit value
+	WIDE_DITHER_ORDERED                     // 8x8 ordered (Bayer) dithering
+	WIDE_DITHER_DIFFUSION                   // Floyd-Steinberg error diffusion
+	WIDE_DITHER_LAST
+)
+
+// 8x8 Bayer matrix, thresholds in [0..63].
+var kBayer8x8 = [8][8]uint8{
+	{0, 32, 8, 40, 2, 34, 10, 42},
+	{48, 16, 56, 24, 50, 18, 58, 26},
+	{12, 44, 4, 36, 14, 46, 6, 38},
+	{60, 28, 52, 20, 62, 30, 54, 22},
+	{3, 35, 11, 43, 1, 33, 9, 41},
+	{51, 19, 59, 27, 49, 17, 57, 25},
+	{15, 47, 7, 39, 13, 45, 5, 37},
+	{63, 31, 55, 23, 61, 29, 53, 21},
+}
+
+// Reduces 'num_rows' rows of 16-bit samples (4 per pixel) to 8 bits.
+// 'src_stride' and 'dst_stride' are in samples. 'err' holds the diffusion
+// error of two rows, 2 * 4 * (width + 2) entries, and is only used by
+// WIDE_DITHER_DIFFUSION.
+func ReduceRGBA16Rows(src []uint16, src_stride int, dst []uint8, dst_stride int, width, num_rows int, dither WideDither, err []int32) {
+	const kMax = 65535
+	for y := 0; y < num_rows; y++ {
+		s := src[y*src_stride:]
+		d := dst[y*dst_stride:]
+		switch dither {
+		case WIDE_DITHER_ORDERED:
+			row := &kBayer8x8[y&7]
+			for x := 0; x < 4*width; x++ {
+				// threshold in ]0..1[, scaled to a 16-bit step
+				t := (2*uint32(row[(x>>2)&7]) + 1) * kMax / 128
+				d[x] = uint8((uint32(s[x])*255 + t) / kMax)
+			}
+		case WIDE_DITHER_DIFFUSION:
+			// Errors are kept in 1/65535th of an 8-bit step. Rows are
+			// padded by one pixel on each side.
+			cur := err[(y&1)*4*(width+2):][:4*(width+2)]
+			next := err[((y+1)&1)*4*(width+2):][:4*(width+2)]
+			for i := range next {
+				next[i] = 0
+			}
+			for x := 0; x < 4*width; x++ {
+				i := x + 4
+				v := int32(s[x])*255 + cur[i]
+				q := tenary.If(v <= 0, int32(0), tenary.If(v >= 255*kMax, int32(255), (v+kMax/2)/kMax))
+				d[x] = uint8(q)
+				e := v - q*kMax
+				cur[i+4] += e * 7 / 16
+				next[i-4] += e * 3 / 16
+				next[i] += e * 5 / 16
+				next[i+4] += e / 16
+			}
+		default:
+			for x := 0; x < 4*width; x++ {
+				d[x] = uint8((uint32(s[x])*255 + kMax/2) / kMax)
+			}
+		}
+	}
+}
+
+// Imports non-premultiplied 16-bit R, G, B, A samples into 'picture', whose
+// width and height must be set. 'rgba_stride' is in samples.
+// The samples are reduced to 8 bits with 'dither'. If the picture is not
+// using ARGB and 'use_sharp_yuv' is set, the luma and chroma planes are
+// computed from the 16-bit samples with the sharp conversion instead, and only
+// alpha is reduced.
+func WebPPictureImportRGBA16(picture *Picture, rgba []uint16, rgba_stride int, dither WideDither, use_sharp_yuv int) error {
+	if picture == nil {
+		return ENC_ERROR_nil_PARAMETER
+	}
+	if rgba == nil {
+		return picture.SetEncodingError(ENC_ERROR_nil_PARAMETER)
+	}
+	width := picture.Width
+	height := picture.Height
+	if width <= 0 || height <= 0 || rgba_stride < 4*width ||
+		len(rgba) < (height-1)*rgba_stride+4*width ||
+		dither < 0 || dither >= WIDE_DITHER_LAST {
+		return picture.SetEncodingError(ENC_ERROR_INVALID_CONFIGURATION)
+	}
+
+	rgba8 := make([]uint8, 4*width*height)
+	var diffusion []int32
+	if dither == WIDE_DITHER_DIFFUSION {
+		diffusion = make([]int32, 2*4*(width+2))
+	}
+	ReduceRGBA16Rows(rgba, rgba_stride, rgba8, 4*width, width, height, dither, diffusion)
+
+	if picture.UseARGB {
+		if err := WebPPictureAlloc(picture); err != nil {
+			return err
+		}
+		for y := 0; y < height; y++ {
+			src := rgba8[4*width*y:]
+			dst := picture.ARGB[y*picture.ARGBStride:]
+			for x := 0; x < width; x++ {
+				dst[x] = color.RGBA{R: src[4*x+0], G: src[4*x+1], B: src[4*x+2], A: src[4*x+3]}
+			}
+		}
+		return nil
+	}
+
+	if use_sharp_yuv == 0 || width < enc.MinDimensionIterativeConversion ||
+		height < enc.MinDimensionIterativeConversion {
+		if ImportYUVAFromRGBA(rgba8[0:], rgba8[1:], rgba8[2:], rgba8[3:], 4, 4*width, 0.0, 0, picture) == 0 {
+			return picture.SetEncodingError(ENC_ERROR_OUT_OF_MEMORY)
+		}
+		return nil
+	}
+
+	has_alpha := false
+	for i := 3; i < len(rgba8); i += 4 {
+		if rgba8[i] != 0xff {
+			has_alpha = true
+			break
+		}
+	}
+	picture.ColorSpace = tenary.If(has_alpha, colorspace.WEBP_YUV420A, colorspace.WEBP_YUV420)
+	if err := WebPPictureAllocYUVA(picture); err != nil {
+		return err
+	}
+	if err := sharpyuv.SharpYuvConvert(rgba[0:], rgba[1:], rgba[2:], 4, rgba_stride, 16, // rgb_bit_depth
+		picture.Y, picture.YStride, picture.U, picture.UVStride, picture.V, picture.UVStride, 8, // yuv_bit_depth
+		width, height, enc.SharpYuvMatrix(picture.YUVMatrix)); err != nil {
+		return picture.SetEncodingError(err)
+	}
+	if has_alpha {
+		for y := 0; y < height; y++ {
+			src := rgba8[4*width*y:]
+			dst := picture.A[y*picture.AStride:]
+			for x := 0; x < width; x++ {
+				dst[x] = src[4*x+3]
+			}
+		}
+	}
+	return nil
+}
+
+// Same as WebPPictureImportRGBA16(), with floating point samples in [0, 1]
+// (values outside are clipped). The samples are expected to be
+// gamma-encoded: tone-mapping HDR content is left to the caller.
+func WebPPictureImportRGBAFloat(picture *Picture, rgba []float32, rgba_stride int, dither WideDither, use_sharp_yuv int) error {
+	if picture == nil {
+		return ENC_ERROR_nil_PARAMETER
+	}
+	if rgba == nil {
+		return picture.SetEncodingError(ENC_ERROR_nil_PARAMETER)
+	}
+	width := picture.Width
+	height := picture.Height
+	if width <= 0 || height <= 0 || rgba_stride < 4*width ||
+		len(rgba) < (height-1)*rgba_stride+4*width {
+		return picture.SetEncodingError(ENC_ERROR_INVALID_CONFIGURATION)
+	}
+	rgba16 := make([]uint16, 4*width*height)
+	for y := 0; y < height; y++ {
+		src := rgba[y*rgba_stride:]
+		dst := rgba16[4*width*y:]
+		for x := 0; x < 4*width; x++ {
+			v := src[x]
+			dst[x] = uint16(tenary.If(v > 0, tenary.If(v < 1, v*65535+0.5, float32(65535)), 0))
+		}
+	}
+	return WebPPictureImportRGBA16(picture, rgba16, 4*width, dither, use_sharp_yuv)
+}
+
+// Imports 'img' into 'picture', setting its dimensions. Images with 16 bits
+// per channel (*image.RGBA64, *image.NRGBA64 and, through At(), any other
+// image) keep their precision until the reduction to 8 bits described in
+// WebPPictureImportRGBA16().
+func WebPPictureImportImage(picture *Picture, img image.Image, dither WideDither, use_sharp_yuv int) error {
+	if picture == nil {
+		return ENC_ERROR_nil_PARAMETER
+	}
+	if img == nil {
+		return picture.SetEncodingError(ENC_ERROR_nil_PARAMETER)
+	}
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	picture.Width = width
+	picture.Height = height
+	if width <= 0 || height <= 0 {
+		return picture.SetEncodingError(ENC_ERROR_BAD_DIMENSION)
+	}
+
+	if src, ok := img.(*image.NRGBA64); ok {
+		// Big-endian samples, copied as is.
+		rgba16 := make([]uint16, 4*width*height)
+		for y := 0; y < height; y++ {
+			row := src.Pix[src.PixOffset(b.Min.X, b.Min.Y+y):]
+			dst := rgba16[4*width*y:]
+			for x := 0; x < 4*width; x++ {
+				dst[x] = uint16(row[2*x])<<8 | uint16(row[2*x+1])
+			}
+		}
+		return WebPPictureImportRGBA16(picture, rgba16, 4*width, dither, use_sharp_yuv)
+	}
+
+	// Premultiplied sources (including *image.RGBA64) are converted back to
+	// straight alpha at 16 bits.
+	rgba16 := make([]uint16, 4*width*height)
+	for y := 0; y < height; y++ {
+		dst := rgba16[4*width*y:]
+		for x := 0; x < width; x++ {
+			c := color.NRGBA64Model.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA64)
+			dst[4*x+0] = c.R
+			dst[4*x+1] = c.G
+			dst[4*x+2] = c.B
+			dst[4*x+3] = c.A
+		}
+	}
+	return WebPPictureImportRGBA16(picture, rgba16, 4*width, dither, use_sharp_yuv)
+}
@@ -0,0 +1,75 @@
+package picture
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// reduceFlat reduces a width x height image whose samples are all 'v'.
+func reduceFlat(v uint16, width, height int, dither WideDither) []uint8 {
+	src := make([]uint16, 4*width*height)
+	for i := range src {
+		src[i] = v
+	}
+	dst := make([]uint8, 4*width*height)
+	err := make([]int32, 2*4*(width+2))
+	ReduceRGBA16Rows(src, 4*width, dst, 4*width, width, height, dither, err)
+	return dst
+}
+
+func mean(samples []uint8) float64 {
+	sum := 0
+	for _, s := range samples {
+		sum += int(s)
+	}
+	return float64(sum) / float64(len(samples))
+}
+
+// Without dithering, samples are rounded to the nearest 8-bit value.
+func TestReduceRGBA16Rounding(t *testing.T) {
+	for _, tc := range []struct {
+		v    uint16
+		want uint8
+	}{
+		{0, 0},
+		{0x0080, 0},
+		{0x0081, 1},
+		{0x8080, 128},
+		{0xff7e, 254},
+		{0xff7f, 255},
+		{0xffff, 255},
+	} {
+		for i, got := range reduceFlat(tc.v, 3, 2, WIDE_DITHER_NONE) {
+			require.Equal(t, tc.want, got, "0x%04x sample %d", tc.v, i)
+		}
+	}
+	// Exact 8-bit values scaled to 16 bits are kept.
+	for v := 0; v < 256; v++ {
+		require.Equal(t, uint8(v), reduceFlat(uint16(257*v), 1, 1, WIDE_DITHER_NONE)[0])
+	}
+}
+
+// Dithering keeps exact 8-bit values, and spreads the others between the two
+// nearest 8-bit values so that their mean is preserved.
+func TestReduceRGBA16Dithering(t *testing.T) {
+	const width, height = 32, 32
+	for _, dither := range []WideDither{WIDE_DITHER_ORDERED, WIDE_DITHER_DIFFUSION} {
+		for _, v := range []int{0, 1, 100, 255} {
+			for i, got := range reduceFlat(uint16(257*v), width, height, dither) {
+				require.Equal(t, uint8(v), got, "dither %d, %d sample %d", dither, v, i)
+			}
+		}
+		for _, v := range []uint16{0x1000, 0x4040 + 0x40, 0x8080 + 0x60, 0xc000} {
+			dst := reduceFlat(v, width, height, dither)
+			low := uint8(uint32(v) * 255 / 65535)
+			levels := map[uint8]bool{}
+			for i, got := range dst {
+				require.True(t, got == low || got == low+1, "dither %d, 0x%04x sample %d: %d", dither, v, i, got)
+				levels[got] = true
+			}
+			require.Len(t, levels, 2, "dither %d, 0x%04x", dither, v)
+			require.InDelta(t, float64(v)*255/65535, mean(dst), 0.05, "dither %d, 0x%04x", dither, v)
+		}
+	}
+}
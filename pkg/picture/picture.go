@@ -43,6 +43,11 @@ type Picture struct {
 	// colorspace: should be YUV420 for now (=Y'CbCr).
 	ColorSpace    colorspace.CSP
 	Width, Height int // dimensions (less or equal to WEBP_MAX_DIMENSION)
+	// Y'CbCr matrix and range used when converting from/to RGB.
+	// nil means BT.601 limited range, which is what WebP decoders expect.
+	// Any other matrix is not signalled in the bitstream: decoders not
+	// given the same matrix render the file with wrong colors.
+	YUVMatrix *yuv.Matrix
 
 	// pointers to luma/chroma planes.
 	// YStride, UVStride int
@@ -71,12 +76,19 @@ type Picture struct {
 	// 5: chroma prediction mode
 	// 6: bit cost
 	// 7: distortion
-	ExtraInfoType int
+	ExtraInfoType ExtraInfoType
 	// if not nil, points to an array of size
 	// ((width + 15) / 16) * ((height + 15) / 16) that
 	// will be filled with a macroblock map, depending
 	// on extra_info_type.
-	ExtraInfo *uint8
+	ExtraInfo []uint8
+	// if not nil, same size as ExtraInfo, filled with all
+	// the per-macroblock maps at once. See EnableExtraInfo().
+	MacroblockInfo []MacroblockInfo
+	// Prediction filter the alpha plane was encoded with, one of
+	// WEBP_FILTER_NONE..WEBP_FILTER_GRADIENT. Set by lossy encoding of
+	// pictures with transparency.
+	AlphaFilter int
 
 	// Pointer to side statistics (updated only if not nil)
 	stats *WebPAuxStats
@@ -245,6 +257,12 @@ func WebPPictureHasTransparency( /* const */ picture *Picture) int {
 	return CheckNonOpaque(picture.A, picture.Width, picture.Height, 1, picture.AStride)
 }
 
+// Makes the encoder fill 'stats' (cleared first) with the statistics of the
+// next encodings of 'pic'. nil disables them.
+func (pic *Picture) SetStats(stats *WebPAuxStats) {
+	pic.stats = stats
+}
+
 //go:fix inline
 func WebPReportProgress( /* const */ pic *Picture, percent int /*const*/, percent_store *int) error {
 	return pic.ReportProgress(percent, percent_store)
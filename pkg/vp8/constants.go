@@ -26,6 +26,12 @@ const (
 	// minimal width under which lossy multi-threading is always disabled
 	MIN_WIDTH_FOR_THREADS = 512
 
+	// Multi-threading methods of the lossy decoder, see VP8GetThreadMethod().
+	MT_NONE      = 0
+	MT_FILTER    = 1 // [parse+recon][filter]
+	MT_PIPELINE  = 2 // [parse][recon+filter]
+	MT_WAVEFRONT = 3 // rows of different partitions decoded concurrently
+
 	MB_FEATURE_TREE_PROBS = 3
 	NUM_MB_SEGMENTS       = 4
 	NUM_REF_LF_DELTAS     = 4
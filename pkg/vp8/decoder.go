@@ -27,7 +27,7 @@ type VP8Decoder struct {
 
 	// Worker
 	worker WebPWorker
-	// multi-thread method: 0=off, 1=[parse+recon][filter] 2=[parse][recon+filter]
+	// multi-thread method: MT_NONE, MT_FILTER, MT_PIPELINE or MT_WAVEFRONT
 	mt_method  int
 	cache_id   int              // current cache row
 	num_caches int              // number of cached rows of 16 pixels (1, 2 or 3)
@@ -132,4 +132,14 @@ type VP8LDecoder struct {
 
 	rescaler_memory *uint8        // Working memory for rescaling work.
 	rescaler        *WebPRescaler // Common rescaler for all channels.
-}
\ No newline at end of file
+
+	// Pixel buffer kept from a previous decoding (see ReleaseVP8LDecoder()),
+	// reused by the allocation functions if large enough.
+	spare_pixels []uint32
+}
+// Reports whether a frame of 1 + 'num_parts_minus_one' token partitions can
+// be decoded with MT_WAVEFRONT: the partitions are what is decoded
+// concurrently, and the dithering random generator is sequential.
+func VP8CanDecodeWavefront(num_parts_minus_one uint32, dither int) bool {
+	return num_parts_minus_one > 0 && dither == 0
+}
@@ -195,6 +195,24 @@ type VP8EncIterator struct {
 	yuv_left_mem [17 + 16 + 16 + 8 + constants.WEBP_ALIGN_CST]uint8
 	// memory for *yuv
 	yuv_mem [3*YUV_SIZE_ENC + PRED_SIZE_ENC + constants.WEBP_ALIGN_CST]uint8
+
+	band *VP8EncBand // if not nil, the iterator only covers this band
+}
+
+// Private context of an iterator scanning a band of macroblock rows
+// concurrently with other bands. The top samples, contexts and intra modes
+// are kept here instead of in the encoder, and the token statistics are
+// collected separately, to be merged afterward.
+type VP8EncBand struct {
+	start_row, end_row int      // rows [start_row, end_row) of the band
+	y_top, uv_top      []uint8  // top samples (mb_w * 16 bytes each)
+	nz                 []uint32 // non-zero contexts, nz[0] is the left border
+	top_derr           []DError // nil if error diffusion is disabled
+	// intra modes: 1 + 4 * (end_row - start_row) rows of preds_w modes, the
+	// first row and column being the top and left borders.
+	preds   []uint8
+	stats   [NUM_TYPES][NUM_BANDS]StatsArray
+	nb_skip int
 }
 
 type VP8TBuffer struct {
@@ -28,6 +28,7 @@ const (
 	VP8_STATUS_SUSPENDED
 	VP8_STATUS_USER_ABORT
 	VP8_STATUS_NOT_ENOUGH_DATA
+	VP8_STATUS_LIMIT_EXCEEDED // the image exceeds the limits of the decoder options
 )
 
 type VP8LImageTransformType int
@@ -0,0 +1,85 @@
+package vp8
+
+import (
+	"testing"
+
+	"github.com/daanv2/go-webp/pkg/constants"
+	"github.com/daanv2/go-webp/pkg/huffman"
+	"github.com/daanv2/go-webp/pkg/webptest"
+	"github.com/stretchr/testify/require"
+)
+
+func dataPtr(data []byte) *uint8 {
+	if len(data) == 0 {
+		return nil
+	}
+	return &data[0]
+}
+
+func FuzzVP8GetInfo(f *testing.F) {
+	webptest.AddChunks(f, "VP8 ")
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var width, height int
+		if VP8GetInfo(dataPtr(data), uint64(len(data)), uint64(len(data)), &width, &height) == 0 {
+			return
+		}
+		require.True(t, width >= 1 && width <= 0x3fff, "width %d", width)
+		require.True(t, height >= 1 && height <= 0x3fff, "height %d", height)
+	})
+}
+
+func FuzzVP8LGetInfo(f *testing.F) {
+	webptest.AddChunks(f, "VP8L")
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var width, height, has_alpha int
+		if VP8LGetInfo(dataPtr(data), uint64(len(data)), &width, &height, &has_alpha) == 0 {
+			return
+		}
+		require.True(t, width >= 1 && width <= 1<<14, "width %d", width)
+		require.True(t, height >= 1 && height <= 1<<14, "height %d", height)
+	})
+}
+
+// Code lengths are fuzzed one per byte. The table must be built for the
+// complete codes, and for the codes of a single symbol, only.
+func FuzzVP8LBuildHuffmanTable(f *testing.F) {
+	for _, seed := range [][]byte{
+		{1, 1},
+		{0, 0, 5},
+		{2, 2, 2, 2},
+		{1, 2, 3, 3},
+		{1, 2, 3, 4}, // incomplete
+		{1, 1, 1},    // over-subscribed
+		{15, 15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1},
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if len(data) == 0 || len(data) > huffman.MAX_CODE_LENGTHS_SIZE {
+			return
+		}
+		code_lengths := make([]int, len(data))
+		kraft, num_symbols := 0, 0
+		for i, b := range data {
+			code_lengths[i] = int(b) % (constants.MAX_ALLOWED_CODE_LENGTH + 1)
+			if code_lengths[i] > 0 {
+				kraft += 1 << (constants.MAX_ALLOWED_CODE_LENGTH - code_lengths[i])
+				num_symbols++
+			}
+		}
+		valid := num_symbols == 1 || kraft == 1<<constants.MAX_ALLOWED_CODE_LENGTH
+
+		size := VP8LBuildHuffmanTable(nil, huffman.HUFFMAN_TABLE_BITS, code_lengths, len(code_lengths))
+		require.Equal(t, valid, size > 0, "code lengths %v", code_lengths)
+		if size == 0 {
+			return
+		}
+		require.GreaterOrEqual(t, size, 1<<huffman.HUFFMAN_TABLE_BITS)
+
+		// Small segments force the allocation of a new one.
+		var tables huffman.HuffmanTables
+		require.NotZero(t, VP8LHuffmanTablesAllocate(1<<huffman.HUFFMAN_TABLE_BITS, &tables))
+		defer VP8LHuffmanTablesDeallocate(&tables)
+		require.Equal(t, size, VP8LBuildHuffmanTable(&tables, huffman.HUFFMAN_TABLE_BITS, code_lengths, len(code_lengths)))
+	})
+}
@@ -597,13 +597,13 @@ func NzCodeBits(nz_coeffs uint32, nz, dc_nz int) uint32 {
   return nz_coeffs
 }
 
-func ParseResiduals(/* const */ dec *VP8Decoder, /*const*/ mb *VP8MB, /*const*/ token_br *VP8BitReader) int {
+// 'mb' and 'left_mb' hold the top and left non-zero contexts, 'block' receives
+// the coefficients.
+func ParseResiduals(/* const */ dec *VP8Decoder, /*const*/ mb *VP8MB, /*const*/ left_mb *VP8MB, /*const*/ block *VP8MBData, /*const*/ token_br *VP8BitReader) int {
   // C: const *VP8BandProbas(bands *const)[16 + 1] = dec.proba.bands_ptr
   // C: const ac_proba *VP8BandProbas *const
-  // C: var block *VP8MBData = dec.mb_data + dec.mb_x
   var q *VP8QuantMatrix = &dec.dqm[block.segment]
   // C: dst *int16 = block.coeffs
-  // C: var left_mb *VP8MB = dec.mb_info - 1
   var tnz, lnz uint8
   non_zero_y := 0
   non_zero_uv := 0
@@ -707,13 +707,25 @@ func ParseResiduals(/* const */ dec *VP8Decoder, /*const*/ mb *VP8MB, /*const*/
 // Main loop
 // Decode one macroblock. Returns false if there is not enough data.
 func VP8DecodeMB(/* const */ dec *VP8Decoder, /* const */ token_br *VP8BitReader) int {
-  // C: var left *VP8MB = dec.mb_info - 1
-  // C: var mb *VP8MB = dec.mb_info + dec.mb_x
-  // C: var block *VP8MBData = dec.mb_data + dec.mb_x
+  var left *VP8MB = dec.mb_info - 1
+  var mb *VP8MB = dec.mb_info + dec.mb_x
+  var block *VP8MBData = dec.mb_data + dec.mb_x
+  var finfo *VP8FInfo = nil
+  if dec.filter_type > 0 {
+    finfo = dec.f_info + dec.mb_x
+  }
+  return DecodeMB(dec, mb, left, block, finfo, token_br)
+}
+
+// Same as VP8DecodeMB(), with explicit contexts: 'mb'/'left' are the top/left
+// non-zero contexts, 'block' the macroblock data and 'finfo' its filter info
+// (only used if dec.filter_type > 0). Used by the wavefront decoder, where
+// each row has its own contexts.
+func DecodeMB(/* const */ dec *VP8Decoder, /* const */ mb *VP8MB, /* const */ left *VP8MB, /* const */ block *VP8MBData, /* const */ finfo *VP8FInfo, /* const */ token_br *VP8BitReader) int {
   skip := tenary.If(dec.use_skip_proba != 0, block.skip, 0)
 
   if skip == 0 {
-    skip = ParseResiduals(dec, mb, token_br)
+    skip = ParseResiduals(dec, mb, left, block, token_br)
   } else {
     // C: left.nz = mb.nz = 0
     if block.is_i4x4 == 0 {
@@ -725,8 +737,7 @@ func VP8DecodeMB(/* const */ dec *VP8Decoder, /* const */ token_br *VP8BitReader
   }
 
   if dec.filter_type > 0 {  // store filter info
-    // C: var finfo *VP8FInfo = dec.f_info + dec.mb_x
-    // C: *finfo = dec.fstrengths[block.segment][block.is_i4x4]
+    *finfo = dec.fstrengths[block.segment][block.is_i4x4]
     if skip == 0 {
       finfo.f_inner |= 1
     }
@@ -748,6 +759,9 @@ func VP8InitScanline(/* const */ dec *VP8Decoder) {
 }
 
 func ParseFrame(/* const */ dec *VP8Decoder, io *VP8Io) int {
+  if dec.mt_method == MT_WAVEFRONT && VP8CanDecodeWavefront(dec.num_parts_minus_one, dec.dither) {
+    return VP8ParseFrameWavefront(dec, io)
+  }
   for dec.mb_y = 0; dec.mb_y < dec.br_mb_y; dec.mb_y++ {
     // Parse bitstream for this row.
     var token_br *VP8BitReader = &dec.parts[dec.mb_y & dec.num_parts_minus_one]
@@ -1412,6 +1412,18 @@ End:
 
 //------------------------------------------------------------------------------
 // Allocate internal buffers dec.pixels and dec.argb_cache.
+// Returns a zeroed buffer of 'size' pixels, recycling dec.spare_pixels when
+// possible.
+func GetPixelBuffer(/* const */ dec *VP8LDecoder, size uint64) []uint32 {
+  if uint64(cap(dec.spare_pixels)) >= size {
+    pixels := dec.spare_pixels[:size]
+    dec.spare_pixels = nil
+    clear(pixels)
+    return pixels
+  }
+  return make([]uint32, size)
+}
+
 func AllocateInternalBuffers32b(/* const */ dec *VP8LDecoder, final_width int) int {
   num_pixels := uint64(dec.width) * dec.height
   // Scratch buffer corresponding to top-prediction row for transforming the
@@ -1433,7 +1445,7 @@ func AllocateInternalBuffers32b(/* const */ dec *VP8LDecoder, final_width int) i
 //     dec.argb_cache = nil;  // for soundness
 //     return VP8LSetError(dec, VP8_STATUS_OUT_OF_MEMORY)
 //   }
-  dec.pixels = make([]uint32, total_num_pixels) // NOTE: have the feeling that this should be divided by 4
+  dec.pixels = GetPixelBuffer(dec, total_num_pixels) // NOTE: have the feeling that this should be divided by 4
 
   dec.argb_cache = dec.pixels + num_pixels + cache_top_pixels
   dec.accumulated_rgb_pixels = accumulated_rgb_pixels == 0
@@ -1451,7 +1463,7 @@ func AllocateInternalBuffers8b(/* const */ dec *VP8LDecoder) int {
 //   if (dec.pixels == nil) {
 //     return VP8LSetError(dec, VP8_STATUS_OUT_OF_MEMORY)
 //   }
-  dec.pixels = make([]uint32, total_num_pixels) // NOTE: have the feeling that this should be divided by 4
+  dec.pixels = GetPixelBuffer(dec, total_num_pixels) // NOTE: have the feeling that this should be divided by 4
 
   return 1
 }
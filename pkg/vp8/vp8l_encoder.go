@@ -1308,8 +1308,8 @@ type StreamEncodeContext struct {
 	stats                    *WebPAuxStats
 }
 
-func EncodeStreamHook(input *StreamEncodeContext, data *void2) int {
-	var params *StreamEncodeContext = input
+func EncodeStreamHook(input, _ any) int {
+	var params *StreamEncodeContext = input.(*StreamEncodeContext)
 	var config *config.Config = params.config
 	var picture *picture.Picture = params.picture
 	var bw *VP8LBitWriter = params.bw
@@ -1454,139 +1454,147 @@ Error:
 	return (params.picture.ErrorCode == ENC_OK)
 }
 
+// One of the concurrent encodings of VP8LEncodeStream(), trying a contiguous
+// range of the crunch configurations.
+type StreamEncodeJob struct {
+	worker       WebPWorker
+	params       StreamEncodeContext
+	bw           VP8LBitWriter   // side bit writer
+	picture_side picture.Picture // view of the main picture
+	stats_side   WebPAuxStats
+}
+
 // Encodes the main image stream using the supplied bit writer.
 // Returns false in case of error (stored in picture.ErrorCode).
+// With config.ThreadLevel > 0, the crunch configurations are split between
+// up to 1 + config.ThreadLevel concurrent jobs. The smallest bitstream wins,
+// ties going to the first configuration, so that the output does not depend
+// on the scheduling nor on the number of jobs.
 func VP8LEncodeStream( /* const */ config *config.Config /*const*/, picture *picture.Picture /*const*/, bw_main *VP8LBitWriter) int {
 	var enc_main *VP8LEncoder = VP8LEncoderNew(config, picture)
-	enc_side * VP8LEncoder = nil
-	var crunch_configs CrunchConfig[CRUNCH_CONFIGS_MAX]
-	var num_crunch_configs_main, num_crunch_configs_side int = 0, 0
+	var crunch_configs [CRUNCH_CONFIGS_MAX]CrunchConfig
+	var num_crunch_configs int
 	var idx int
 	red_and_blue_always_zero := 0
-	var worker_main, worker_side WebPWorker
-	var params_main, params_side StreamEncodeContext
-	// The main thread uses picture.stats, the side thread uses stats_side.
-	var stats_side WebPAuxStats
-	var bw_side VP8LBitWriter
-	var picture_side picture.Picture
+	var jobs []StreamEncodeJob
+	num_jobs := 1
 	var worker_interface *WebPWorkerInterface = WebPGetWorkerInterface()
-	var ok_main int
+	ok := 1
 
-	if enc_main == nil || !VP8LBitWriterInit(&bw_side, 0) {
-		VP8LEncoderDelete(enc_main)
+	if enc_main == nil {
 		return picture.SetEncodingError(picture.ENC_ERROR_OUT_OF_MEMORY)
 	}
 
-	// Afunc "garbage value" error from Clang's static analysis tool.
-	if !picture.WebPPictureInit(&picture_side) {
-		goto Error
-	}
-
 	// Analyze image (entropy, num_palettes etc)
-	if !EncoderAnalyze(enc_main, crunch_configs, &num_crunch_configs_main, &red_and_blue_always_zero) ||
+	if !EncoderAnalyze(enc_main, crunch_configs, &num_crunch_configs, &red_and_blue_always_zero) ||
 		!EncoderInit(enc_main) {
 		picture.SetEncodingError(picture.ENC_ERROR_OUT_OF_MEMORY)
 		goto Error
 	}
-
-	// Split the configs between the main and side threads (if any).
-	if config.ThreadLevel > 0 {
-		num_crunch_configs_side = num_crunch_configs_main / 2
-		for idx = 0; idx < num_crunch_configs_side; idx++ {
-			params_side.crunch_configs[idx] = crunch_configs[num_crunch_configs_main-num_crunch_configs_side+
-				idx]
-		}
-		params_side.num_crunch_configs = num_crunch_configs_side
-	}
-	num_crunch_configs_main -= num_crunch_configs_side
-	for idx = 0; idx < num_crunch_configs_main; idx++ {
-		params_main.crunch_configs[idx] = crunch_configs[idx]
+	if num_crunch_configs == 0 { // nothing to try, and jobs[0] needs one
+		picture.SetEncodingError(picture.ENC_ERROR_INVALID_CONFIGURATION)
+		goto Error
 	}
-	params_main.num_crunch_configs = num_crunch_configs_main
 
-	// Fill in the parameters for the thread workers.
-	{
-		params_size := tenary.If(num_crunch_configs_side > 0, 2, 1)
-		for idx = 0; idx < params_size; idx++ {
-			// Create the parameters for each worker.
-			var worker *WebPWorker = tenary.If(idx == 0, &worker_main, &worker_side)
-			const param *StreamEncodeContext = tenary.If(idx == 0, &params_main, &params_side)
-			param.config = config
-			param.red_and_blue_always_zero = red_and_blue_always_zero
-			if idx == 0 {
-				param.picture = picture
-				param.stats = picture.stats
-				param.bw = bw_main
-				param.enc = enc_main
-			} else {
-				// Create a side picture (error_code is not thread-safe).
-				if !picture.WebPPictureView(picture /*left=*/, 0 /*top=*/, 0, picture.Width, picture.Height, &picture_side) {
-					assert.Assert(0)
-				}
-				picture_side.ProgressHook = nil // Progress hook is not thread-safe.
-				param.picture = &picture_side   // No need to free a view afterwards.
-				param.stats = tenary.If(picture.stats == nil, nil, &stats_side)
-				// Create a side bit writer.
-				if !VP8LBitWriterClone(bw_main, &bw_side) {
-					picture.SetEncodingError(picture.ENC_ERROR_OUT_OF_MEMORY)
-					goto Error
-				}
-				param.bw = &bw_side
-				// Create a side encoder.
-				enc_side = VP8LEncoderNew(config, &picture_side)
-				if enc_side == nil || !EncoderInit(enc_side) {
-					picture.SetEncodingError(picture.ENC_ERROR_OUT_OF_MEMORY)
-					goto Error
-				}
-				// Copy the values that were computed for the main encoder.
-				enc_side.histo_bits = enc_main.histo_bits
-				enc_side.predictor_transform_bits = enc_main.predictor_transform_bits
-				enc_side.cross_color_transform_bits = enc_main.cross_color_transform_bits
-				enc_side.palette_size = enc_main.palette_size
-				// C: memcpy(enc_side.palette, enc_main.palette, sizeof(enc_main.palette))
-				// C: memcpy(enc_side.palette_sorted, enc_main.palette_sorted, sizeof(enc_main.palette_sorted))
-				param.enc = enc_side
+	// Split the configs between the jobs, the first one running in this
+	// goroutine.
+	if config.ThreadLevel > 0 {
+		num_jobs = min(1+config.ThreadLevel, num_crunch_configs)
+	}
+	jobs = make([]StreamEncodeJob, num_jobs)
+	for idx = 0; idx < num_jobs; idx++ {
+		var job *StreamEncodeJob = &jobs[idx]
+		var param *StreamEncodeContext = &job.params
+		first := idx * num_crunch_configs / num_jobs
+		last := (idx + 1) * num_crunch_configs / num_jobs
+		copy(param.crunch_configs[:], crunch_configs[first:last])
+		param.num_crunch_configs = last - first
+		param.config = config
+		param.red_and_blue_always_zero = red_and_blue_always_zero
+		if idx == 0 {
+			param.picture = picture
+			param.stats = picture.stats
+			param.bw = bw_main
+			param.enc = enc_main
+		} else {
+			// Create a side picture (error_code is not thread-safe).
+			if !picture.WebPPictureView(picture /*left=*/, 0 /*top=*/, 0, picture.Width, picture.Height, &job.picture_side) {
+				assert.Assert(0)
 			}
-			// Create the workers.
-			worker_interface.Init(worker)
-			worker.data1 = param
-			worker.data2 = nil
-			worker.hook = EncodeStreamHook
+			job.picture_side.ProgressHook = nil // Progress hook is not thread-safe.
+			param.picture = &job.picture_side   // No need to free a view afterwards.
+			param.stats = tenary.If(picture.stats == nil, nil, &job.stats_side)
+			// Create a side bit writer.
+			if !VP8LBitWriterClone(bw_main, &job.bw) {
+				picture.SetEncodingError(picture.ENC_ERROR_OUT_OF_MEMORY)
+				goto Error
+			}
+			param.bw = &job.bw
+			// Create a side encoder.
+			param.enc = VP8LEncoderNew(config, &job.picture_side)
+			if param.enc == nil || !EncoderInit(param.enc) {
+				picture.SetEncodingError(picture.ENC_ERROR_OUT_OF_MEMORY)
+				goto Error
+			}
+			// Copy the values that were computed for the main encoder.
+			param.enc.histo_bits = enc_main.histo_bits
+			param.enc.predictor_transform_bits = enc_main.predictor_transform_bits
+			param.enc.cross_color_transform_bits = enc_main.cross_color_transform_bits
+			param.enc.palette_size = enc_main.palette_size
+			param.enc.palette = enc_main.palette
+			param.enc.palette_sorted = enc_main.palette_sorted
 		}
+		// Create the workers.
+		worker_interface.Init(&job.worker)
+		job.worker.data1 = param
+		job.worker.data2 = nil
+		job.worker.hook = EncodeStreamHook
 	}
 
-	// Start the second thread if needed.
-	if num_crunch_configs_side != 0 {
-		if !worker_interface.Reset(&worker_side) {
+	// Start the side jobs, on the shared worker pool.
+	for idx = 1; idx < num_jobs; idx++ {
+		if !worker_interface.Reset(&jobs[idx].worker) {
 			picture.SetEncodingError(picture.ENC_ERROR_OUT_OF_MEMORY)
-			goto Error
+			num_jobs = idx // only wait for the launched ones
+			ok = 0
+			break
 		}
-
-		worker_interface.Launch(&worker_side)
-	}
-	// Execute the main thread.
-	worker_interface.Execute(&worker_main)
-	ok_main = worker_interface.Sync(&worker_main)
-	worker_interface.End(&worker_main)
-	if num_crunch_configs_side != 0 {
-		// Wait for the second thread.
-		ok_side := worker_interface.Sync(&worker_side)
-		worker_interface.End(&worker_side)
-		if !ok_main || !ok_side {
-			if picture.ErrorCode == ENC_OK {
-				assert.Assert(picture_side.ErrorCode != ENC_OK)
-				picture.SetEncodingError(picture.picture_side.ErrorCode)
+		worker_interface.Launch(&jobs[idx].worker)
+	}
+	// Execute the main job.
+	if ok {
+		worker_interface.Execute(&jobs[0].worker)
+	}
+	for idx = 0; idx < num_jobs; idx++ {
+		var job *StreamEncodeJob = &jobs[idx]
+		if !worker_interface.Sync(&job.worker) {
+			if ok && idx > 0 && picture.ErrorCode == ENC_OK {
+				assert.Assert(job.picture_side.ErrorCode != ENC_OK)
+				picture.SetEncodingError(job.picture_side.ErrorCode)
 			}
-			goto Error
+			ok = 0
 		}
-		if VP8LBitWriterNumBytes(&bw_side) < VP8LBitWriterNumBytes(bw_main) {
-			VP8LBitWriterSwap(bw_main, &bw_side)
+		worker_interface.End(&job.worker)
+	}
+	if !ok || picture.ErrorCode != ENC_OK {
+		goto Error
+	}
+	// Keep the smallest bitstream, in configuration order.
+	for idx = 1; idx < num_jobs; idx++ {
+		var job *StreamEncodeJob = &jobs[idx]
+		if VP8LBitWriterNumBytes(&job.bw) < VP8LBitWriterNumBytes(bw_main) {
+			VP8LBitWriterSwap(bw_main, &job.bw)
+			if picture.stats != nil {
+				*picture.stats = job.stats_side
+			}
 		}
 	}
 
 Error:
 	VP8LEncoderDelete(enc_main)
-	VP8LEncoderDelete(enc_side)
+	for idx = 1; idx < len(jobs); idx++ {
+		VP8LEncoderDelete(jobs[idx].params.enc)
+	}
 	return (picture.ErrorCode == ENC_OK)
 }
 
@@ -1673,11 +1681,9 @@ func VP8LEncodeImage( /* const */ config *config.Config /*const*/, pict *picture
 		goto UserAbort
 	}
 
-	if pict.ExtraInfo != nil {
-		mb_w := (width + 15) >> 4
-		mb_h := (height + 15) >> 4
-		stdlib.Memset(pict.ExtraInfo, 0, mb_w*mb_h*sizeof(*picture.ExtraInfo))
-	}
+	// Lossless has no macroblocks: report empty maps.
+	clear(pict.ExtraInfo)
+	clear(pict.MacroblockInfo)
 
 Error:
 	if bw.error {
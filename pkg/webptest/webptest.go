@@ -0,0 +1,98 @@
+// Seed corpus and RIFF helpers shared by the fuzz tests.
+package webptest
+
+import (
+	"embed"
+	"encoding/binary"
+	"io/fs"
+	"testing"
+)
+
+// Small valid WebP files: lossy, lossless, lossy with alpha, animated (one
+// lossless frame, and two frames mixing lossless and lossy with alpha), and
+// a VP8X file carrying ICCP, EXIF and XMP chunks.
+//
+//go:embed testdata/*.webp
+var corpus embed.FS
+
+// Corpus returns the seed files, sorted by name.
+func Corpus() [][]byte {
+	names, err := fs.Glob(corpus, "testdata/*.webp")
+	if err != nil {
+		panic(err)
+	}
+	files := make([][]byte, 0, len(names))
+	for _, name := range names {
+		data, err := corpus.ReadFile(name)
+		if err != nil {
+			panic(err)
+		}
+		files = append(files, data)
+	}
+	return files
+}
+
+// AddCorpus adds the seed files to the corpus of 'f'.
+func AddCorpus(f *testing.F) {
+	for _, data := range Corpus() {
+		f.Add(data)
+	}
+}
+
+// AddChunks adds to the corpus of 'f' the payloads of the 'fourcc' chunks of
+// the seed files, those nested in ANMF chunks included.
+func AddChunks(f *testing.F, fourcc string) {
+	for _, data := range Corpus() {
+		if len(data) < 12 {
+			continue
+		}
+		for _, payload := range Chunks(data[12:], fourcc) {
+			f.Add(payload)
+		}
+	}
+}
+
+// Chunks returns the payloads of the 'fourcc' chunks in the chunk sequence
+// 'data', looking into the frames of ANMF chunks. Parsing stops at the first
+// truncated chunk.
+func Chunks(data []byte, fourcc string) [][]byte {
+	const anmfHeaderSize = 16
+	var payloads [][]byte
+	for len(data) >= 8 {
+		tag := string(data[:4])
+		size := uint64(binary.LittleEndian.Uint32(data[4:8]))
+		if size > uint64(len(data)-8) {
+			break
+		}
+		payload := data[8 : 8+size]
+		if tag == fourcc {
+			payloads = append(payloads, payload)
+		} else if tag == "ANMF" && len(payload) >= anmfHeaderSize {
+			payloads = append(payloads, Chunks(payload[anmfHeaderSize:], fourcc)...)
+		}
+		data = data[min(8+size+size&1, uint64(len(data))):]
+	}
+	return payloads
+}
+
+// Chunk returns the chunk 'fourcc' holding 'payload', padded to an even size.
+func Chunk(fourcc string, payload []byte) []byte {
+	chunk := make([]byte, 8, 8+len(payload)+1)
+	copy(chunk, fourcc)
+	binary.LittleEndian.PutUint32(chunk[4:], uint32(len(payload)))
+	chunk = append(chunk, payload...)
+	if len(payload)&1 != 0 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+// RIFF returns the WebP file made of 'chunks'.
+func RIFF(chunks ...[]byte) []byte {
+	data := []byte("RIFF\x00\x00\x00\x00WEBP")
+	for _, chunk := range chunks {
+		data = append(data, chunk...)
+	}
+	binary.LittleEndian.PutUint32(data[4:], uint32(len(data)-8))
+	return data
+}
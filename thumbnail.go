@@ -0,0 +1,292 @@
+package webp
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"io"
+
+	"github.com/daanv2/go-webp/pkg/config"
+	"github.com/daanv2/go-webp/pkg/libwebp/decoder"
+	"github.com/daanv2/go-webp/pkg/libwebp/enc"
+	"github.com/daanv2/go-webp/pkg/libwebp/utils"
+	libwebp "github.com/daanv2/go-webp/pkg/libwebp/webp"
+	"github.com/daanv2/go-webp/pkg/picture"
+	"github.com/daanv2/go-webp/pkg/vp8"
+)
+
+// ThumbnailMode selects how the source is fitted into the thumbnail box.
+type ThumbnailMode int
+
+const (
+	// ThumbnailFit scales the whole image to fit inside the box, keeping its
+	// aspect ratio. One side of the box may be 0 to only bound the other.
+	ThumbnailFit ThumbnailMode = iota
+	// ThumbnailFill scales the image to cover the box, keeping its aspect
+	// ratio, and crops what overflows at Gravity.
+	ThumbnailFill
+	// ThumbnailCrop cuts the box out of the image at Gravity, unscaled.
+	ThumbnailCrop
+)
+
+// Gravity is the part of the image kept by ThumbnailFill and ThumbnailCrop.
+type Gravity int
+
+const (
+	GravityCenter Gravity = iota
+	GravityNorth
+	GravitySouth
+	GravityEast
+	GravityWest
+	GravityNorthEast
+	GravityNorthWest
+	GravitySouthEast
+	GravitySouthWest
+)
+
+// ThumbnailOptions describe the thumbnail produced by Thumbnail.
+type ThumbnailOptions struct {
+	Width, Height int
+	Mode          ThumbnailMode
+	Gravity       Gravity
+
+	// Upscale allows images smaller than the box to be enlarged. Otherwise
+	// they keep their size, only cropped as the mode requires.
+	Upscale bool
+
+	// Filter is the resampling kernel. The zero value, utils.WEBP_FILTER_BOX,
+	// averages the source area; Lanczos3, Catmull-Rom and Mitchell give
+	// sharper thumbnails.
+	Filter utils.WebPResampleFilter
+
+	// Config is used to encode the thumbnail; nil selects the default
+	// lossy settings at quality 75.
+	Config *config.Config
+
+	// MaxPixels and MaxMemoryBytes limit the source image as those of
+	// DecoderOptions do: larger sources are rejected with ErrLimitExceeded
+	// before they are decoded. For formats other than WebP, the decoded
+	// image and its ARGB copy are counted as 8 bytes per pixel. 0 disables a
+	// limit.
+	MaxPixels      int
+	MaxMemoryBytes int64
+}
+
+// ErrThumbnailSize is returned for a box that does not describe a thumbnail.
+var ErrThumbnailSize = errors.New("webp: invalid thumbnail size")
+
+// Thumbnail decodes the image read from 'r', resizes it as 'opts' describes
+// and returns it encoded as WebP.
+//
+// WebP sources are cropped and, when opaque, scaled by the decoder itself so
+// that the full-size image is never materialized. Sources with alpha are
+// scaled after decoding, premultiplied, so that transparent pixels do not
+// bleed dark halos into the edges. Other formats registered with the image
+// package are decoded first, then cropped and scaled the same way.
+func Thumbnail(r io.Reader, opts *ThumbnailOptions) ([]byte, error) {
+	if r == nil || opts == nil || opts.MaxPixels < 0 || opts.MaxMemoryBytes < 0 {
+		return nil, ErrInvalidParam
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	conf := opts.Config
+	if conf == nil {
+		conf = &config.Config{}
+		if err := conf.Init(); err != nil {
+			return nil, err
+		}
+	}
+
+	var pic picture.Picture
+	picture.WebPPictureInit(&pic)
+	defer picture.WebPPictureFree(&pic)
+	if info, status := decoder.GetImageInfo(data); status == vp8.VP8_STATUS_OK {
+		if info.HasAnimation {
+			return nil, ErrUnsupportedFeature
+		}
+		err = thumbnailWebP(data, info, opts, &pic)
+	} else {
+		err = thumbnailImage(data, opts, conf, &pic)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	if err := EncodePicture(&out, &pic, conf); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// Decodes the WebP 'data' into 'pic', cropped and scaled.
+func thumbnailWebP(data []byte, info decoder.ImageInfo, opts *ThumbnailOptions, pic *picture.Picture) error {
+	crop, width, height, err := thumbnailGeometry(info.Width, info.Height, opts)
+	if err != nil {
+		return err
+	}
+	// The decoder moves the crop origin to even coordinates: odd offsets are
+	// decoded from the even ones and cropped afterwards.
+	decCrop := image.Rect(crop.Min.X&^1, crop.Min.Y&^1, crop.Max.X, crop.Max.Y)
+	decOpts := &decoder.DecodeOptions{
+		UseCropping: decCrop != image.Rect(0, 0, info.Width, info.Height),
+		CropLeft:    decCrop.Min.X,
+		CropTop:     decCrop.Min.Y,
+		CropWidth:   decCrop.Dx(),
+		CropHeight:  decCrop.Dy(),
+
+		ResampleFilter: opts.Filter,
+
+		MaxPixels:      opts.MaxPixels,
+		MaxMemoryBytes: opts.MaxMemoryBytes,
+	}
+	// The decoder scales colour and alpha independently, which darkens the
+	// edges of transparent areas: images with alpha are scaled afterwards,
+	// as are the images cropped after decoding.
+	rescale := (info.HasAlpha || decCrop != crop) && (width != crop.Dx() || height != crop.Dy())
+	if !rescale {
+		decOpts.UseScaling = width != crop.Dx() || height != crop.Dy()
+		decOpts.ScaledWidth, decOpts.ScaledHeight = width, height
+	}
+	img, status := decoder.WebPDecodeAdvanced(data, libwebp.MODE_RGBA, decOpts)
+	if err := statusError(status); err != nil {
+		return err
+	}
+
+	pic.UseARGB = true
+	pic.Width, pic.Height = img.Width, img.Height
+	if enc.WebPPictureImportRGBA(pic, img.RGBA, img.Stride) == 0 {
+		return pic.ErrorCode
+	}
+	if decCrop != crop && enc.WebPPictureCrop(pic, crop.Min.X-decCrop.Min.X, crop.Min.Y-decCrop.Min.Y, crop.Dx(), crop.Dy()) == 0 {
+		return pic.ErrorCode
+	}
+	if rescale {
+		return rescalePicture(pic, width, height, opts.Filter)
+	}
+	return nil
+}
+
+// Decodes 'data' with the image package into 'pic', cropped and scaled.
+func thumbnailImage(data []byte, opts *ThumbnailOptions, conf *config.Config, pic *picture.Picture) error {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	pixels := int64(cfg.Width) * int64(cfg.Height)
+	if (opts.MaxPixels > 0 && pixels > int64(opts.MaxPixels)) ||
+		(opts.MaxMemoryBytes > 0 && 8*pixels > opts.MaxMemoryBytes) {
+		return ErrLimitExceeded
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	bounds := img.Bounds()
+	crop, width, height, err := thumbnailGeometry(bounds.Dx(), bounds.Dy(), opts)
+	if err != nil {
+		return err
+	}
+	if crop == image.Rect(0, 0, bounds.Dx(), bounds.Dy()) && width == crop.Dx() && height == crop.Dy() {
+		return importImage(pic, img, conf)
+	}
+	// Cropped and scaled as ARGB, converted by the encoder if needed.
+	pic.UseARGB = true
+	if err := picture.WebPPictureImportImage(pic, img, picture.WideDither(conf.WideDithering), 0); err != nil {
+		return err
+	}
+	if crop != image.Rect(0, 0, pic.Width, pic.Height) &&
+		enc.WebPPictureCrop(pic, crop.Min.X, crop.Min.Y, crop.Dx(), crop.Dy()) == 0 {
+		return pic.ErrorCode
+	}
+	return rescalePicture(pic, width, height, opts.Filter)
+}
+
+// Scales the ARGB 'pic' to 'width' x 'height' with 'filter'.
+// WebPPictureRescaleFilter() premultiplies the samples by alpha
+// (AlphaMultiplyARGB()) around the rescaling.
+func rescalePicture(pic *picture.Picture, width, height int, filter utils.WebPResampleFilter) error {
+	if pic.Width == width && pic.Height == height {
+		return nil
+	}
+	if enc.WebPPictureRescaleFilter(pic, width, height, filter) == 0 {
+		return pic.ErrorCode
+	}
+	return nil
+}
+
+// thumbnailGeometry returns the rectangle of the source kept and the size it
+// is scaled to.
+func thumbnailGeometry(srcWidth, srcHeight int, opts *ThumbnailOptions) (crop image.Rectangle, width, height int, err error) {
+	boxWidth, boxHeight := opts.Width, opts.Height
+	if srcWidth <= 0 || srcHeight <= 0 || boxWidth < 0 || boxHeight < 0 ||
+		(boxWidth == 0 && boxHeight == 0) ||
+		(opts.Mode != ThumbnailFit && (boxWidth == 0 || boxHeight == 0)) {
+		return image.Rectangle{}, 0, 0, ErrThumbnailSize
+	}
+	full := image.Rect(0, 0, srcWidth, srcHeight)
+	sw, sh := float64(srcWidth), float64(srcHeight)
+
+	switch opts.Mode {
+	case ThumbnailFit:
+		scale := 0.
+		if boxWidth > 0 {
+			scale = float64(boxWidth) / sw
+		}
+		if boxHeight > 0 && (scale == 0 || float64(boxHeight)/sh < scale) {
+			scale = float64(boxHeight) / sh
+		}
+		if !opts.Upscale {
+			scale = min(scale, 1)
+		}
+		return full, max(roundInt(sw*scale), 1), max(roundInt(sh*scale), 1), nil
+
+	case ThumbnailFill:
+		scale := max(float64(boxWidth)/sw, float64(boxHeight)/sh)
+		cropWidth := min(max(roundInt(float64(boxWidth)/scale), 1), srcWidth)
+		cropHeight := min(max(roundInt(float64(boxHeight)/scale), 1), srcHeight)
+		crop = gravityRect(full, cropWidth, cropHeight, opts.Gravity)
+		if scale > 1 && !opts.Upscale {
+			return crop, cropWidth, cropHeight, nil
+		}
+		return crop, boxWidth, boxHeight, nil
+
+	case ThumbnailCrop:
+		crop = gravityRect(full, min(boxWidth, srcWidth), min(boxHeight, srcHeight), opts.Gravity)
+		return crop, crop.Dx(), crop.Dy(), nil
+	}
+	return image.Rectangle{}, 0, 0, ErrInvalidParam
+}
+
+// Places a 'width' x 'height' rectangle inside 'r' according to 'gravity'.
+func gravityRect(r image.Rectangle, width, height int, gravity Gravity) image.Rectangle {
+	// 0: left or top, 1: center, 2: right or bottom.
+	gx, gy := 1, 1
+	switch gravity {
+	case GravityNorth:
+		gy = 0
+	case GravitySouth:
+		gy = 2
+	case GravityEast:
+		gx = 2
+	case GravityWest:
+		gx = 0
+	case GravityNorthEast:
+		gx, gy = 2, 0
+	case GravityNorthWest:
+		gx, gy = 0, 0
+	case GravitySouthEast:
+		gx, gy = 2, 2
+	case GravitySouthWest:
+		gx, gy = 0, 2
+	}
+	x := r.Min.X + (r.Dx()-width)*gx/2
+	y := r.Min.Y + (r.Dy()-height)*gy/2
+	return image.Rect(x, y, x+width, y+height)
+}
+
+func roundInt(v float64) int {
+	return int(v + 0.5)
+}
@@ -0,0 +1,78 @@
+package webp
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestThumbnailGeometry(t *testing.T) {
+	const srcWidth, srcHeight = 400, 300
+	full := image.Rect(0, 0, srcWidth, srcHeight)
+	for _, tc := range []struct {
+		name          string
+		opts          ThumbnailOptions
+		crop          image.Rectangle
+		width, height int
+	}{
+		{"fit", ThumbnailOptions{Width: 200, Height: 200}, full, 200, 150},
+		{"fit width", ThumbnailOptions{Width: 200}, full, 200, 150},
+		{"fit height", ThumbnailOptions{Height: 60}, full, 80, 60},
+		{"fit smaller", ThumbnailOptions{Width: 800, Height: 800}, full, 400, 300},
+		{"fit upscale", ThumbnailOptions{Width: 800, Height: 800, Upscale: true}, full, 800, 600},
+		{"fit tiny", ThumbnailOptions{Width: 1, Height: 1}, full, 1, 1},
+		{"fill", ThumbnailOptions{Width: 100, Height: 100, Mode: ThumbnailFill}, image.Rect(50, 0, 350, 300), 100, 100},
+		{"fill west", ThumbnailOptions{Width: 100, Height: 100, Mode: ThumbnailFill, Gravity: GravityWest}, image.Rect(0, 0, 300, 300), 100, 100},
+		{"fill east", ThumbnailOptions{Width: 100, Height: 100, Mode: ThumbnailFill, Gravity: GravityEast}, image.Rect(100, 0, 400, 300), 100, 100},
+		{"fill wide", ThumbnailOptions{Width: 1000, Height: 100, Mode: ThumbnailFill}, image.Rect(0, 130, 400, 170), 400, 40},
+		{"fill wide upscale", ThumbnailOptions{Width: 1000, Height: 100, Mode: ThumbnailFill, Upscale: true}, image.Rect(0, 130, 400, 170), 1000, 100},
+		{"crop larger", ThumbnailOptions{Width: 500, Height: 500, Mode: ThumbnailCrop}, full, 400, 300},
+	} {
+		crop, width, height, err := thumbnailGeometry(srcWidth, srcHeight, &tc.opts)
+		require.NoError(t, err, tc.name)
+		require.Equal(t, tc.crop, crop, tc.name)
+		require.Equal(t, [2]int{tc.width, tc.height}, [2]int{width, height}, tc.name)
+	}
+
+	// Odd offsets are kept: the WebP decoder path crops them after decoding.
+	for gravity, want := range map[Gravity]image.Point{
+		GravityCenter:    {149, 99},
+		GravityNorth:     {149, 0},
+		GravitySouth:     {149, 199},
+		GravityEast:      {299, 99},
+		GravityWest:      {0, 99},
+		GravityNorthEast: {299, 0},
+		GravityNorthWest: {0, 0},
+		GravitySouthEast: {299, 199},
+		GravitySouthWest: {0, 199},
+	} {
+		opts := ThumbnailOptions{Width: 101, Height: 101, Mode: ThumbnailCrop, Gravity: gravity}
+		crop, width, height, err := thumbnailGeometry(srcWidth, srcHeight, &opts)
+		require.NoError(t, err, "gravity %d", gravity)
+		require.Equal(t, image.Rectangle{Min: want, Max: want.Add(image.Pt(101, 101))}, crop, "gravity %d", gravity)
+		require.Equal(t, [2]int{101, 101}, [2]int{width, height}, "gravity %d", gravity)
+		require.Equal(t, crop, gravityRect(full, 101, 101, gravity), "gravity %d", gravity)
+	}
+
+	for _, opts := range []ThumbnailOptions{
+		{},
+		{Width: -1, Height: 10},
+		{Width: 10, Mode: ThumbnailFill},
+		{Height: 10, Mode: ThumbnailCrop},
+		{Width: 10, Height: 10, Mode: ThumbnailCrop + 1},
+	} {
+		_, _, _, err := thumbnailGeometry(srcWidth, srcHeight, &opts)
+		require.Error(t, err, "%+v", opts)
+	}
+	_, _, _, err := thumbnailGeometry(0, srcHeight, &ThumbnailOptions{Width: 10, Height: 10})
+	require.ErrorIs(t, err, ErrThumbnailSize)
+}
+
+// gravityRect places the rectangle relative to the origin of its bounds.
+func TestGravityRectOffset(t *testing.T) {
+	r := image.Rect(10, 20, 30, 50)
+	require.Equal(t, image.Rect(15, 30, 25, 40), gravityRect(r, 10, 10, GravityCenter))
+	require.Equal(t, image.Rect(20, 40, 30, 50), gravityRect(r, 10, 10, GravitySouthEast))
+	require.Equal(t, image.Rect(10, 20, 20, 30), gravityRect(r, 10, 10, GravityNorthWest))
+}
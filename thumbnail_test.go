@@ -0,0 +1,81 @@
+package webp_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/daanv2/go-webp"
+	"github.com/daanv2/go-webp/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+// Scaling an opaque white area next to a transparent black one must not
+// darken the edge: the samples are premultiplied by alpha while resampled.
+func TestThumbnailPremultiplied(t *testing.T) {
+	const width, height = 64, 8
+	src := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := width / 2; x < width; x++ {
+			src.SetNRGBA(x, y, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+		}
+	}
+	var pngData bytes.Buffer
+	require.NoError(t, png.Encode(&pngData, src))
+
+	var conf config.Config
+	require.NoError(t, conf.Init())
+	conf.Lossless = 1
+	for name, data := range map[string][]byte{
+		"png":  pngData.Bytes(),
+		"webp": encode(t, src, lossless),
+	} {
+		// 64 / 21 is not an integer: the edge at x = 32 falls inside a pixel.
+		thumb, err := webp.Thumbnail(bytes.NewReader(data), &webp.ThumbnailOptions{Width: 21, Config: &conf})
+		require.NoError(t, err, name)
+		out, err := webp.DecodeToFormat(bytes.NewReader(thumb), webp.PixelFormatRGBA)
+		require.NoError(t, err, name)
+		require.Equal(t, 21, out.Width, name)
+
+		translucent := 0
+		for y := 0; y < out.Height; y++ {
+			for x := 0; x < out.Width; x++ {
+				p := out.Pix[y*out.Stride+4*x:][:4]
+				if p[3] == 0 {
+					continue
+				}
+				if p[3] < 255 {
+					translucent++
+				}
+				require.GreaterOrEqual(t, p[0], uint8(250), "%s: pixel %d,%d is %v", name, x, y, p)
+				require.GreaterOrEqual(t, p[1], uint8(250), "%s: pixel %d,%d is %v", name, x, y, p)
+				require.GreaterOrEqual(t, p[2], uint8(250), "%s: pixel %d,%d is %v", name, x, y, p)
+			}
+		}
+		require.NotZero(t, translucent, "%s: the edge is not blended", name)
+	}
+}
+
+// Sources over the limits are rejected before they are decoded.
+func TestThumbnailLimits(t *testing.T) {
+	src := gradient(40, 30)
+	var pngData bytes.Buffer
+	require.NoError(t, png.Encode(&pngData, src))
+
+	for name, data := range map[string][]byte{
+		"png":  pngData.Bytes(),
+		"webp": encode(t, src, nil),
+	} {
+		thumbnail := func(opts webp.ThumbnailOptions) error {
+			opts.Width = 20
+			_, err := webp.Thumbnail(bytes.NewReader(data), &opts)
+			return err
+		}
+		require.NoError(t, thumbnail(webp.ThumbnailOptions{MaxPixels: 40 * 30, MaxMemoryBytes: 1 << 20}), name)
+		require.ErrorIs(t, thumbnail(webp.ThumbnailOptions{MaxPixels: 40*30 - 1}), webp.ErrLimitExceeded, name)
+		require.ErrorIs(t, thumbnail(webp.ThumbnailOptions{MaxMemoryBytes: 40 * 30}), webp.ErrLimitExceeded, name)
+		require.ErrorIs(t, thumbnail(webp.ThumbnailOptions{MaxPixels: -1}), webp.ErrInvalidParam, name)
+	}
+}
@@ -0,0 +1,362 @@
+// Package webphttp serves WebP versions of PNG and JPEG responses to the
+// clients that advertise WebP support in their Accept header.
+//
+// The middleware wraps any http.Handler. Responses of other handlers are left
+// untouched, except for the Vary header added to every PNG and JPEG answer so
+// that caches keep both variants apart:
+//
+//	http.Handle("/static/", webphttp.Handler(http.FileServer(dir), nil))
+//
+// Only complete 200 responses to GET requests are transcoded; HEAD, partial
+// and already content-encoded responses pass through.
+package webphttp
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"mime"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+
+	webp "github.com/daanv2/go-webp"
+	"github.com/daanv2/go-webp/pkg/config"
+)
+
+const contentTypeWebP = "image/webp"
+
+// ETag suffix of the transcoded variant of an upstream entity.
+const etagSuffix = "-webp"
+
+// Options control the transcoding done by Handler.
+type Options struct {
+	// Preset and Quality select the lossy encoder settings, as
+	// config.Config.InitPreset does.
+	Preset  config.Preset
+	Quality float64
+
+	// AutoPreset picks the preset, image hint and lossless mode from the
+	// content of each image, as webp.InitAutoPreset does, in place of
+	// Preset. It suits uploads and other images of unknown type.
+	AutoPreset bool
+
+	// LosslessPNG encodes PNG responses losslessly; JPEG ones are always
+	// encoded lossy, even when AutoPreset selects lossless.
+	LosslessPNG bool
+
+	// MaxConcurrent bounds the number of images transcoded at the same time.
+	// Requests wait for a free slot. <= 0 selects GOMAXPROCS.
+	MaxConcurrent int
+
+	// MaxBodyBytes is the largest upstream body buffered for transcoding,
+	// MaxPixels the largest image decoded. Bigger responses are served
+	// unchanged. <= 0 disables the limit.
+	MaxBodyBytes int64
+	MaxPixels    int
+
+	// ErrorLog, if set, is called when an image could not be transcoded; the
+	// original response is served instead.
+	ErrorLog func(r *http.Request, err error)
+}
+
+// DefaultOptions returns the options used by a nil *Options: default preset
+// at quality 75, lossy PNG, 16 MiB bodies and 40 megapixels at most.
+func DefaultOptions() *Options {
+	return &Options{
+		Preset:       config.WEBP_PRESET_DEFAULT,
+		Quality:      75,
+		MaxBodyBytes: 16 << 20,
+		MaxPixels:    40_000_000,
+	}
+}
+
+// errTooManyPixels is reported to ErrorLog for images over MaxPixels.
+var errTooManyPixels = fmt.Errorf("webphttp: %w", webp.ErrLimitExceeded)
+
+type handler struct {
+	next http.Handler
+	opts Options
+	sem  chan struct{}
+}
+
+// Handler returns a middleware transcoding the PNG and JPEG responses of
+// 'next' to WebP for the clients accepting image/webp. 'opts' may be nil for
+// DefaultOptions. The upstream ETag, if any, is suffixed so that both
+// variants validate separately; conditional requests are translated back
+// before reaching 'next'.
+func Handler(next http.Handler, opts *Options) http.Handler {
+	if opts == nil {
+		opts = DefaultOptions()
+	}
+	maxConcurrent := opts.MaxConcurrent
+	if maxConcurrent <= 0 {
+		maxConcurrent = runtime.GOMAXPROCS(0)
+	}
+	return &handler{next: next, opts: *opts, sem: make(chan struct{}, maxConcurrent)}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rw := &responseWriter{ResponseWriter: w, h: h, req: r}
+	if r.Method == http.MethodGet && AcceptsWebP(r) {
+		rw.transcode = true
+		if inm := r.Header.Get("If-None-Match"); inm != "" {
+			rw.ifNoneMatch = inm
+			r = r.Clone(r.Context())
+			r.Header.Set("If-None-Match", upstreamETags(inm))
+		}
+	}
+	h.next.ServeHTTP(rw, r)
+	rw.finish()
+}
+
+// AcceptsWebP reports whether the Accept header of 'r' lists image/webp
+// with a non-zero quality. Wildcards do not count: many clients sending
+// image/* cannot display WebP.
+func AcceptsWebP(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+			if err != nil || mediaType != contentTypeWebP {
+				continue
+			}
+			if q, ok := params["q"]; ok {
+				if v, err := strconv.ParseFloat(q, 64); err != nil || v <= 0 {
+					continue
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// Reports whether the response with headers 'header' has a WebP variant.
+func negotiable(header http.Header) bool {
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	return err == nil && (mediaType == "image/png" || mediaType == "image/jpeg")
+}
+
+func addVary(header http.Header) {
+	for _, v := range header.Values("Vary") {
+		for _, field := range strings.Split(v, ",") {
+			if field = strings.TrimSpace(field); field == "*" || strings.EqualFold(field, "Accept") {
+				return
+			}
+		}
+	}
+	header.Add("Vary", "Accept")
+}
+
+// ETag of the WebP variant of the entity tagged 'etag', "" for none.
+func webpETag(etag string) string {
+	if len(etag) < 2 || !strings.HasSuffix(etag, `"`) {
+		return ""
+	}
+	return etag[:len(etag)-1] + etagSuffix + `"`
+}
+
+// Rewrites the WebP variant tags of an If-None-Match list into the upstream
+// ones.
+func upstreamETags(list string) string {
+	tags := strings.Split(list, ",")
+	for i, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if strings.HasSuffix(tag, etagSuffix+`"`) {
+			tag = tag[:len(tag)-len(etagSuffix)-1] + `"`
+		}
+		tags[i] = tag
+	}
+	return strings.Join(tags, ", ")
+}
+
+// Reports whether 'etag' matches the If-None-Match 'list', using the weak
+// comparison.
+func etagMatch(list, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(list, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	stateUndecided = iota
+	statePassThrough
+	stateBuffering
+)
+
+// responseWriter buffers the transcodable responses of the wrapped handler
+// and streams the others.
+type responseWriter struct {
+	http.ResponseWriter
+	h           *handler
+	req         *http.Request
+	transcode   bool   // the client accepts WebP
+	ifNoneMatch string // as sent by the client
+	state       int
+	buf         bytes.Buffer
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.state != stateUndecided {
+		return
+	}
+	if code >= 100 && code < 200 {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	header := w.Header()
+	if negotiable(header) {
+		addVary(header)
+		if w.transcode && code == http.StatusOK && header.Get("Content-Encoding") == "" {
+			w.state = stateBuffering
+			return
+		}
+	}
+	if code == http.StatusNotModified && w.transcode {
+		// The client validated the WebP variant.
+		if etag := webpETag(header.Get("ETag")); etag != "" && etagMatch(w.ifNoneMatch, etag) {
+			header.Set("ETag", etag)
+			addVary(header)
+		}
+	}
+	w.state = statePassThrough
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	if w.state == stateUndecided {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.state == stateBuffering {
+		maxBody := w.h.opts.MaxBodyBytes
+		if maxBody <= 0 || int64(w.buf.Len()+len(p)) <= maxBody {
+			return w.buf.Write(p)
+		}
+		// Too big to be transcoded: stream it.
+		if err := w.passThrough(); err != nil {
+			return 0, err
+		}
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush forwards to the wrapped writer, unless the response is buffered.
+func (w *responseWriter) Flush() {
+	if w.state == stateBuffering {
+		return
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap gives http.ResponseController access to the wrapped writer.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Sends the buffered upstream response unchanged.
+func (w *responseWriter) passThrough() error {
+	w.state = statePassThrough
+	w.ResponseWriter.WriteHeader(http.StatusOK)
+	_, err := w.ResponseWriter.Write(w.buf.Bytes())
+	w.buf = bytes.Buffer{}
+	return err
+}
+
+func (w *responseWriter) finish() {
+	if w.state != stateBuffering {
+		return
+	}
+	data, err := w.h.transcode(w.req, w.buf.Bytes(), w.Header().Get("Content-Type"))
+	if err != nil {
+		if w.h.opts.ErrorLog != nil {
+			w.h.opts.ErrorLog(w.req, err)
+		}
+		w.passThrough()
+		return
+	}
+	if data == nil {
+		w.passThrough()
+		return
+	}
+
+	header := w.Header()
+	header.Del("Content-MD5")
+	header.Del("Accept-Ranges")
+	if etag := webpETag(header.Get("ETag")); etag != "" {
+		header.Set("ETag", etag)
+		if w.ifNoneMatch != "" && etagMatch(w.ifNoneMatch, etag) {
+			header.Del("Content-Type")
+			header.Del("Content-Length")
+			w.state = statePassThrough
+			w.ResponseWriter.WriteHeader(http.StatusNotModified)
+			return
+		}
+	} else {
+		header.Del("ETag")
+	}
+	header.Set("Content-Type", contentTypeWebP)
+	header.Set("Content-Length", strconv.Itoa(len(data)))
+	w.state = statePassThrough
+	w.ResponseWriter.WriteHeader(http.StatusOK)
+	w.ResponseWriter.Write(data)
+}
+
+// Encodes the PNG or JPEG 'data'. Returns nil without error when the WebP
+// version is not smaller or when the request was canceled.
+func (h *handler) transcode(r *http.Request, data []byte, contentType string) ([]byte, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if h.opts.MaxPixels > 0 && int64(cfg.Width)*int64(cfg.Height) > int64(h.opts.MaxPixels) {
+		return nil, fmt.Errorf("%w: %d x %d", errTooManyPixels, cfg.Width, cfg.Height)
+	}
+
+	select {
+	case h.sem <- struct{}{}:
+		defer func() { <-h.sem }()
+	case <-r.Context().Done():
+		return nil, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	var conf config.Config
+	if h.opts.AutoPreset {
+		if _, err := webp.InitAutoPreset(&conf, img, h.opts.Quality); err != nil {
+			return nil, err
+		}
+	} else if err := conf.InitPreset(h.opts.Preset, h.opts.Quality); err != nil {
+		return nil, err
+	}
+	switch mediaType, _, _ := mime.ParseMediaType(contentType); {
+	case mediaType == "image/png" && h.opts.LosslessPNG:
+		conf.Lossless = 1
+	case mediaType == "image/jpeg":
+		conf.Lossless = 0
+	}
+	var out bytes.Buffer
+	out.Grow(len(data) / 2)
+	if err := webp.Encode(&out, img, &conf); err != nil {
+		return nil, err
+	}
+	if out.Len() >= len(data) {
+		return nil, nil
+	}
+	return out.Bytes(), nil
+}
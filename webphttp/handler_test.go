@@ -0,0 +1,293 @@
+package webphttp
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	webp "github.com/daanv2/go-webp"
+	"github.com/daanv2/go-webp/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcceptsWebP(t *testing.T) {
+	for _, tc := range []struct {
+		accept []string
+		want   bool
+	}{
+		{nil, false},
+		{[]string{"image/webp"}, true},
+		{[]string{"image/avif,image/webp,*/*"}, true},
+		{[]string{"text/html", "image/webp;q=0.8"}, true},
+		{[]string{"IMAGE/WEBP"}, true},
+		{[]string{"image/webp;q=0"}, false},
+		{[]string{"image/webp;q=0.0"}, false},
+		{[]string{"image/webp;q=abc"}, false},
+		{[]string{"image/*"}, false},
+		{[]string{"*/*"}, false},
+		{[]string{"image/png,image/jpeg"}, false},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		for _, v := range tc.accept {
+			r.Header.Add("Accept", v)
+		}
+		require.Equal(t, tc.want, AcceptsWebP(r), "Accept %q", tc.accept)
+	}
+}
+
+func TestNegotiable(t *testing.T) {
+	for _, tc := range []struct {
+		contentType string
+		want        bool
+	}{
+		{"image/png", true},
+		{"image/jpeg", true},
+		{"image/jpeg; charset=binary", true},
+		{"image/gif", false},
+		{"image/webp", false},
+		{"text/html; charset=utf-8", false},
+		{"", false},
+		{"image/png;;", false},
+	} {
+		header := http.Header{}
+		header.Set("Content-Type", tc.contentType)
+		require.Equal(t, tc.want, negotiable(header), "Content-Type %q", tc.contentType)
+	}
+}
+
+func TestWebPETag(t *testing.T) {
+	for _, tc := range []struct{ etag, want string }{
+		{`"abc"`, `"abc-webp"`},
+		{`W/"abc"`, `W/"abc-webp"`},
+		{`""`, `"-webp"`},
+		{``, ``},
+		{`"`, ``},
+		{`abc`, ``},
+	} {
+		require.Equal(t, tc.want, webpETag(tc.etag), "ETag %q", tc.etag)
+	}
+}
+
+func TestUpstreamETags(t *testing.T) {
+	for _, tc := range []struct{ list, want string }{
+		{`"abc-webp"`, `"abc"`},
+		{`W/"abc-webp"`, `W/"abc"`},
+		{`"abc"`, `"abc"`},
+		{`"a-webp", "b" ,W/"c-webp"`, `"a", "b", W/"c"`},
+		{`*`, `*`},
+	} {
+		require.Equal(t, tc.want, upstreamETags(tc.list), "If-None-Match %q", tc.list)
+	}
+}
+
+func TestETagMatch(t *testing.T) {
+	for _, tc := range []struct {
+		list, etag string
+		want       bool
+	}{
+		{`"a"`, `"a"`, true},
+		{`"b", "a"`, `"a"`, true},
+		{`W/"a"`, `"a"`, true},
+		{`"a"`, `W/"a"`, true},
+		{`*`, `"a"`, true},
+		{`"b"`, `"a"`, false},
+		{`"a-webp"`, `"a"`, false},
+		{``, `"a"`, false},
+	} {
+		require.Equal(t, tc.want, etagMatch(tc.list, tc.etag), "%q in %q", tc.etag, tc.list)
+	}
+}
+
+// PNG and JPEG responses vary on Accept, whether they are transcoded or not;
+// other responses are left alone.
+func TestHandlerVaryAccept(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4))))
+	pngData := buf.Bytes()
+
+	for _, tc := range []struct {
+		name        string
+		contentType string
+		body        []byte
+		vary        []string
+		want        []string
+	}{
+		{"png", "image/png", pngData, nil, []string{"Accept"}},
+		{"sniffed png", "", pngData, nil, []string{"Accept"}},
+		{"other vary", "image/png", pngData, []string{"Accept-Encoding"}, []string{"Accept-Encoding", "Accept"}},
+		{"already varying", "image/png", pngData, []string{"Origin, accept"}, []string{"Origin, accept"}},
+		{"vary all", "image/png", pngData, []string{"*"}, []string{"*"}},
+		{"html", "text/html", []byte("<p>hi</p>"), nil, nil},
+	} {
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if tc.contentType != "" {
+				w.Header().Set("Content-Type", tc.contentType)
+			}
+			for _, v := range tc.vary {
+				w.Header().Add("Vary", v)
+			}
+			w.Write(tc.body)
+		})
+		rec := httptest.NewRecorder()
+		Handler(next, nil).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		require.Equal(t, http.StatusOK, rec.Code, tc.name)
+		require.Equal(t, tc.want, rec.Header().Values("Vary"), tc.name)
+		require.Equal(t, tc.body, rec.Body.Bytes(), tc.name)
+	}
+}
+
+// pngFile returns the PNG encoding of 'img'.
+func pngFile(t *testing.T, img image.Image) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+// photo returns an opaque gradient with grain, which lossy WebP encodes much
+// smaller than PNG.
+func photo(width, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	seed := uint32(1)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			seed = seed*1664525 + 1013904223
+			n := uint8(seed>>24) & 31
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(x) + n, G: uint8(y) + n, B: uint8((x+y)/2) + n, A: 255})
+		}
+	}
+	return img
+}
+
+// serveFile returns a handler serving 'data' as the file 'name', with the
+// ETag 'etag' if not empty. Conditional requests are answered by
+// http.ServeContent.
+func serveFile(name string, data []byte, etag string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if etag != "" {
+			w.Header().Set("ETag", etag)
+		}
+		http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(data))
+	})
+}
+
+// get runs a GET request accepting WebP through 'h'. 'header' holds
+// name/value pairs of additional request headers.
+func get(h http.Handler, header ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept", "image/avif,image/webp,*/*")
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec
+}
+
+// requireWebP checks that 'rec' holds a complete transcoded response.
+func requireWebP(t *testing.T, rec *httptest.ResponseRecorder) {
+	t.Helper()
+
+	data := rec.Body.Bytes()
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, contentTypeWebP, rec.Header().Get("Content-Type"))
+	require.Equal(t, strconv.Itoa(len(data)), rec.Header().Get("Content-Length"))
+	require.Empty(t, rec.Header().Get("Accept-Ranges"))
+	require.Equal(t, []string{"Accept"}, rec.Header().Values("Vary"))
+	require.Greater(t, len(data), 12)
+	require.Equal(t, "RIFF", string(data[:4]))
+	require.Equal(t, "WEBP", string(data[8:12]))
+}
+
+func TestHandlerTranscode(t *testing.T) {
+	data := pngFile(t, photo(128, 96))
+	rec := get(Handler(serveFile("photo.png", data, ""), nil))
+	requireWebP(t, rec)
+	require.Less(t, rec.Body.Len(), len(data))
+	cfg, err := webp.DecodeConfig(bytes.NewReader(rec.Body.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, 128, cfg.Width)
+	require.Equal(t, 96, cfg.Height)
+
+	// Clients without WebP support get the PNG.
+	rec = httptest.NewRecorder()
+	Handler(serveFile("photo.png", data, ""), nil).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, "image/png", rec.Header().Get("Content-Type"))
+	require.Equal(t, data, rec.Body.Bytes())
+}
+
+// The WebP variant has its own ETag; validating it revalidates the upstream
+// entity and answers 304 with the WebP ETag.
+func TestHandlerNotModified(t *testing.T) {
+	h := Handler(serveFile("photo.png", pngFile(t, photo(64, 64)), `"x"`), nil)
+
+	rec := get(h)
+	requireWebP(t, rec)
+	require.Equal(t, `"x-webp"`, rec.Header().Get("ETag"))
+
+	rec = get(h, "If-None-Match", `"x-webp"`)
+	require.Equal(t, http.StatusNotModified, rec.Code)
+	require.Equal(t, `"x-webp"`, rec.Header().Get("ETag"))
+	require.Equal(t, []string{"Accept"}, rec.Header().Values("Vary"))
+	require.Zero(t, rec.Body.Len())
+
+	// The upstream ETag validates the PNG variant, not the WebP one.
+	rec = get(h, "If-None-Match", `"x"`)
+	requireWebP(t, rec)
+	require.Equal(t, `"x-webp"`, rec.Header().Get("ETag"))
+
+	rec = get(h, "If-None-Match", `"y-webp"`)
+	requireWebP(t, rec)
+}
+
+// Responses over MaxBodyBytes and images over MaxPixels are served
+// unchanged; the latter are reported to ErrorLog.
+func TestHandlerLimits(t *testing.T) {
+	data := pngFile(t, photo(64, 64))
+	var logged []error
+	opts := DefaultOptions()
+	opts.ErrorLog = func(r *http.Request, err error) { logged = append(logged, err) }
+
+	opts.MaxBodyBytes = int64(len(data) - 1)
+	rec := get(Handler(serveFile("photo.png", data, ""), opts))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "image/png", rec.Header().Get("Content-Type"))
+	require.Equal(t, data, rec.Body.Bytes())
+	require.Empty(t, logged)
+
+	opts.MaxBodyBytes = int64(len(data))
+	requireWebP(t, get(Handler(serveFile("photo.png", data, ""), opts)))
+
+	opts.MaxPixels = 64*64 - 1
+	rec = get(Handler(serveFile("photo.png", data, ""), opts))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "image/png", rec.Header().Get("Content-Type"))
+	require.Equal(t, data, rec.Body.Bytes())
+	require.Len(t, logged, 1)
+	require.ErrorIs(t, logged[0], errTooManyPixels)
+	require.ErrorIs(t, logged[0], webp.ErrLimitExceeded)
+}
+
+// With AutoPreset, images with few colours are encoded losslessly, whatever
+// Preset says.
+func TestHandlerAutoPreset(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(255 * (x / 16)), B: 128, A: 255})
+		}
+	}
+	opts := DefaultOptions()
+	opts.Preset = config.WEBP_PRESET_PHOTO
+	opts.AutoPreset = true
+	rec := get(Handler(serveFile("icon.png", pngFile(t, img), ""), opts))
+	requireWebP(t, rec)
+	require.Equal(t, "VP8L", string(rec.Body.Bytes()[12:16]))
+}
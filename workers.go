@@ -0,0 +1,12 @@
+package webp
+
+import "github.com/daanv2/go-webp/pkg/libwebp/utils"
+
+// SetMaxWorkers bounds the number of goroutines used, process-wide, by the
+// multi-threaded parts of the encoder and decoder (filtering, alpha
+// compression, ...). n <= 0 selects GOMAXPROCS, which is the default.
+// It returns the previous limit. When the limit is reached, work runs on the
+// calling goroutine instead of waiting.
+func SetMaxWorkers(n int) int {
+	return utils.WebPSetMaxWorkers(n)
+}
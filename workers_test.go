@@ -0,0 +1,147 @@
+package webp_test
+
+import (
+	"image"
+	"testing"
+
+	"github.com/daanv2/go-webp"
+	"github.com/daanv2/go-webp/pkg/config"
+	"github.com/daanv2/go-webp/pkg/libwebp/decoder"
+	"github.com/daanv2/go-webp/pkg/libwebp/utils"
+	libwebp "github.com/daanv2/go-webp/pkg/libwebp/webp"
+	"github.com/daanv2/go-webp/pkg/vp8"
+	"github.com/stretchr/testify/require"
+)
+
+// The worker pool only changes where the work runs: the output of threaded
+// encodes and decodes is the one of the single-threaded runs, whatever the
+// pool limit. Run with -race to check the jobs share no state.
+
+// threads returns a configure function for encode setting the thread level.
+func threads(threadLevel int) func(conf *config.Config) {
+	return func(conf *config.Config) { conf.ThreadLevel = threadLevel }
+}
+
+func TestThreadedAlphaEncode(t *testing.T) {
+	serial := encode(t, shadow(160), threads(0)) // lossy, with alpha
+	for _, maxWorkers := range []int{1, 4} {
+		previous := webp.SetMaxWorkers(maxWorkers)
+		require.Equal(t, serial, encode(t, shadow(160), threads(1)), "max workers %d", maxWorkers)
+		webp.SetMaxWorkers(previous)
+	}
+}
+
+func TestThreadedDecode(t *testing.T) {
+	// Narrower images are decoded by a single goroutine.
+	data := encode(t, gradient(vp8.MIN_WIDTH_FOR_THREADS, 64), nil)
+
+	serial, status := decoder.WebPDecodeAdvanced(data, libwebp.MODE_RGBA, nil)
+	require.Equal(t, vp8.VP8_STATUS_OK, status)
+	for _, maxWorkers := range []int{1, 4} {
+		previous := webp.SetMaxWorkers(maxWorkers)
+		threaded, status := decoder.WebPDecodeAdvanced(data, libwebp.MODE_RGBA, &decoder.DecodeOptions{UseThreads: true})
+		webp.SetMaxWorkers(previous)
+		require.Equal(t, vp8.VP8_STATUS_OK, status)
+		require.Equal(t, serial, threaded, "max workers %d", maxWorkers)
+	}
+}
+
+// Wavefront decoding is bit-exact with the sequential decoder, for all
+// partition counts and whatever the number of goroutines available.
+func TestWavefrontDecode(t *testing.T) {
+	for partitions := 0; partitions <= 3; partitions++ {
+		data := encode(t, gradient(vp8.MIN_WIDTH_FOR_THREADS, 160), func(conf *config.Config) {
+			conf.Partitions = partitions // 1 << partitions token partitions
+		})
+
+		for _, mode := range []libwebp.WEBP_CSP_MODE{libwebp.MODE_RGBA, libwebp.MODE_YUV} {
+			serial, status := decoder.WebPDecodeAdvanced(data, mode, nil)
+			require.Equal(t, vp8.VP8_STATUS_OK, status)
+			for _, maxWorkers := range []int{1, 2, 8} {
+				previous := webp.SetMaxWorkers(maxWorkers)
+				wavefront, status := decoder.WebPDecodeAdvanced(data, mode, &decoder.DecodeOptions{UseThreads: true, UseWavefront: true})
+				webp.SetMaxWorkers(previous)
+				require.Equal(t, vp8.VP8_STATUS_OK, status)
+				require.Equal(t, serial, wavefront, "%d partitions, mode %d, max workers %d", 1<<partitions, mode, maxWorkers)
+			}
+		}
+	}
+}
+
+// encodeLossy encodes 'img' in several statistics passes with 'threadLevel'.
+func encodeLossy(t *testing.T, img image.Image, threadLevel int) []byte {
+	t.Helper()
+
+	return encode(t, img, func(conf *config.Config) {
+		conf.ThreadLevel = threadLevel
+		conf.Pass = 3
+		conf.TargetPSNR = 42
+	})
+}
+
+// runSerially makes the workers run their jobs in the launching goroutine
+// until the end of the test.
+func runSerially(t *testing.T) {
+	saved := *utils.WebPGetWorkerInterface()
+	serial := saved
+	serial.Launch = serial.Execute
+	require.NotZero(t, utils.WebPSetWorkerInterface(&serial))
+	t.Cleanup(func() { utils.WebPSetWorkerInterface(&saved) })
+}
+
+// The analysis bands are merged exactly: below two statistics bands, the
+// thread level does not change the output.
+func TestThreadedAnalysis(t *testing.T) {
+	img := textured(256, 96)
+	serial := encodeLossy(t, img, 0)
+	for _, threadLevel := range []int{1, 2, 5, config.MAX_THREAD_LEVEL} {
+		require.Equal(t, serial, encodeLossy(t, img, threadLevel), "thread level %d", threadLevel)
+	}
+}
+
+// With statistics bands, the output only depends on the thread level being
+// non-zero: not on its value, the pool limit, or the jobs running
+// concurrently at all.
+func TestThreadedStatisticsBands(t *testing.T) {
+	img := textured(128, 640) // 40 macroblock rows, 2 bands
+	threaded := encodeLossy(t, img, 1)
+	for _, threadLevel := range []int{2, 3, 8} {
+		for _, maxWorkers := range []int{1, 4} {
+			previous := webp.SetMaxWorkers(maxWorkers)
+			out := encodeLossy(t, img, threadLevel)
+			webp.SetMaxWorkers(previous)
+			require.Equal(t, threaded, out, "thread level %d, max workers %d", threadLevel, maxWorkers)
+		}
+	}
+
+	runSerially(t)
+	require.Equal(t, threaded, encodeLossy(t, img, 4))
+}
+
+// The lossless crunch configurations are split between the jobs: the
+// smallest bitstream is kept in configuration order, so the output is the
+// one of the single-threaded encode whatever the thread level, the pool
+// limit or the worker interface.
+func TestThreadedLosslessEncode(t *testing.T) {
+	img := textured(96, 64)
+	crunch := func(threadLevel int) func(conf *config.Config) {
+		return func(conf *config.Config) {
+			conf.Lossless = 1
+			conf.Method = 6 // several crunch configurations
+			conf.Quality = 100
+			conf.ThreadLevel = threadLevel
+		}
+	}
+	serial := encode(t, img, crunch(0))
+	for _, threadLevel := range []int{1, 2, 5, config.MAX_THREAD_LEVEL} {
+		for _, maxWorkers := range []int{1, 4} {
+			previous := webp.SetMaxWorkers(maxWorkers)
+			out := encode(t, img, crunch(threadLevel))
+			webp.SetMaxWorkers(previous)
+			require.Equal(t, serial, out, "thread level %d, max workers %d", threadLevel, maxWorkers)
+		}
+	}
+
+	runSerially(t)
+	require.Equal(t, serial, encode(t, img, crunch(3)))
+}